@@ -33,8 +33,17 @@ import (
 	"golang.org/x/sync/semaphore"
 )
 
+// cipdBackend is the URL of the CIPD backend. It is a variable so that tests
+// can use a fake backend.
+var cipdBackend = "https://chrome-infra-packages.appspot.com"
+
+// PackageURL returns the URL of version ver of package pkg in the cipd
+// backend.
+func PackageURL(pkg, ver string) string {
+	return cipdBackend + "/p/" + pkg + "/+/" + ver
+}
+
 const (
-	cipdBackend = "https://chrome-infra-packages.appspot.com"
 	// This git hash corresponds to a commit in https://chromium.googlesource.com/infra/infra
 	// to update the pinned version of the CIPD client in the DEPS file.
 	cipdVersion       = "git_revision:4511c670f851c56473a7370c10c199cdad4dfc3c"
@@ -360,6 +369,9 @@ func CheckPackageACL(jirix *jiri.X, pkgs map[string]bool) error {
 	// Not declared as CheckPackageACL(jirix *jiri.X, pkgs map[*package.Package]bool)
 	// due to import cycles. Package jiri/package imports jiri/cipd so here we cannot
 	// import jiri/package.
+	if jirix.CipdNative {
+		return checkPackageACLNative(jirix, pkgs)
+	}
 	if _, err := Bootstrap(jirix, jirix.CIPDPath()); err != nil {
 		return err
 	}
@@ -386,6 +398,10 @@ func CheckPackageACL(jirix *jiri.X, pkgs map[string]bool) error {
 // if login information is found or return false if login information is not
 // found.
 func CheckLoggedIn(jirix *jiri.X) (bool, error) {
+	if jirix.CipdNative {
+		token, err := newClient(jirix).accessToken()
+		return token != "", err
+	}
 	cipdPath, err := Bootstrap(jirix, jirix.CIPDPath())
 	if err != nil {
 		return false, err
@@ -406,15 +422,25 @@ func CheckLoggedIn(jirix *jiri.X) (bool, error) {
 	return true, nil
 }
 
-// Ensure runs cipd binary's ensure functionality over file. Fetched packages will be
-// saved to projectRoot directory. Parameter timeout is in minutes.
+// Ensure runs cipd binary's ensure functionality over file, or the native
+// client if jirix.CipdNative is set. Fetched packages will be saved to
+// projectRoot directory. Parameter timeout is in minutes.
 func Ensure(jirix *jiri.X, file, projectRoot string, timeout uint) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Minute)
+	defer cancel()
+	if jirix.CipdNative {
+		task := jirix.Logger.AddTaskMsg("Fetching CIPD packages")
+		defer task.Done()
+		err := ensureNative(ctx, jirix, file, projectRoot)
+		if ctx.Err() == context.DeadlineExceeded {
+			err = ctx.Err()
+		}
+		return err
+	}
 	cipdPath, err := Bootstrap(jirix, jirix.CIPDPath())
 	if err != nil {
 		return err
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Minute)
-	defer cancel()
 	args := []string{
 		"ensure",
 		"-ensure-file", file,
@@ -448,6 +474,15 @@ func Ensure(jirix *jiri.X, file, projectRoot string, timeout uint) error {
 }
 
 func EnsureFileVerify(jirix *jiri.X, file string) error {
+	if jirix.CipdNative {
+		task := jirix.Logger.AddTaskMsg("Verifying CIPD ensure file")
+		defer task.Done()
+		if _, err := resolveNative(jirix, file); err != nil {
+			jirix.Logger.Errorf("Verifying ensure file %q failed:\n%v", file, err)
+			return cipdManifestInvalidErr
+		}
+		return nil
+	}
 	cipdPath, err := Bootstrap(jirix, jirix.CIPDPath())
 	if err != nil {
 		return err
@@ -496,9 +531,13 @@ type PackageInstance struct {
 	InstanceID  string
 }
 
-// Resolve runs cipd binary's ensure-file-resolve functionality over file.
-// It returns a slice containing resolved packages and cipd instance ids.
+// Resolve runs cipd binary's ensure-file-resolve functionality over file, or
+// the native client if jirix.CipdNative is set. It returns a slice containing
+// resolved packages and cipd instance ids.
 func Resolve(jirix *jiri.X, file string) ([]PackageInstance, error) {
+	if jirix.CipdNative {
+		return resolveNative(jirix, file)
+	}
 	cipdPath, err := Bootstrap(jirix, jirix.CIPDPath())
 	if err != nil {
 		return nil, err
@@ -616,15 +655,12 @@ type packageFloatingRef struct {
 // CheckFloatingRefs determines if pkgs contains a floating ref which shouldn't
 // be used normally.
 func CheckFloatingRefs(jirix *jiri.X, pkgs map[PackageInstance]bool, plats map[PackageInstance][]Platform) error {
-	if _, err := Bootstrap(jirix, jirix.CIPDPath()); err != nil {
-		return err
+	if jirix.CipdNative {
+		return checkFloatingRefsNative(jirix, pkgs, plats)
 	}
-
-	jsonDir, err := ioutil.TempDir("", "jiri_cipd")
-	if err != nil {
+	if _, err := Bootstrap(jirix, jirix.CIPDPath()); err != nil {
 		return err
 	}
-	defer os.RemoveAll(jsonDir)
 
 	c := make(chan packageFloatingRef)
 	sem := semaphore.NewWeighted(10)
@@ -634,7 +670,7 @@ func CheckFloatingRefs(jirix *jiri.X, pkgs map[PackageInstance]bool, plats map[P
 		if !ok {
 			return fmt.Errorf("Platforms for package \"%s\" is not found", k.PackageName)
 		}
-		go checkFloatingRefs(jirix, k, plat, jsonDir, sem, c)
+		go checkFloatingRefs(jirix, k, plat, sem, c)
 	}
 
 	for i := 0; i < len(pkgs); i++ {
@@ -654,65 +690,61 @@ func CheckFloatingRefs(jirix *jiri.X, pkgs map[PackageInstance]bool, plats map[P
 }
 
 type describeJSON struct {
+	Pin  pinJSON    `json:"pin"`
 	Refs []refsJSON `json:"refs,omitempty"`
+	Tags []tagsJSON `json:"tags,omitempty"`
+}
+
+type pinJSON struct {
+	Package    string `json:"package"`
+	InstanceID string `json:"instance_id"`
 }
 
 type refsJSON struct {
 	Ref string `json:"ref,omitempty"`
 }
 
-func checkFloatingRefs(jirix *jiri.X, pkg PackageInstance, plats []Platform, jsonDir string, sem *semaphore.Weighted, c chan<- packageFloatingRef) {
-	// cipd should already bootstrapped before calling
-	// this function.
-	sem.Acquire(context.Background(), 1)
-	defer sem.Release(1)
+type tagsJSON struct {
+	Tag string `json:"tag,omitempty"`
+}
+
+// Description describes an instance of a cipd package.
+type Description struct {
+	Package    string
+	InstanceID string
+	// Refs are the refs, like "latest", pointing to the instance.
+	Refs []string
+	// Tags are the tags, like "git_revision:<sha>", attached to the
+	// instance.
+	Tags []string
+}
+
+// Describe returns the description of the instance which version resolves to
+// in package pkg. The name of the package must not use templates.
+func Describe(jirix *jiri.X, pkg, version string) (*Description, error) {
+	if jirix.CipdNative {
+		return describeNative(jirix, pkg, version)
+	}
+	if _, err := Bootstrap(jirix, jirix.CIPDPath()); err != nil {
+		return nil, err
+	}
+	return describe(jirix, pkg, version)
+}
+
+// describe runs "cipd describe". cipd should already be bootstrapped.
+func describe(jirix *jiri.X, pkg, version string) (*Description, error) {
 	if cipdBinary == "" {
-		c <- packageFloatingRef{
-			pkg:      pkg,
-			err:      errors.New("cipd is not bootstrapped when calling checkFloatingRefs"),
-			floating: false,
-		}
-		return
+		return nil, errors.New("cipd is not bootstrapped when calling describe")
 	}
-	// jsonFile will be cleaned up by caller.
-	jsonFile, err := ioutil.TempFile(jsonDir, "cipd*.json")
+	jsonFile, err := ioutil.TempFile("", "cipd*.json")
 	if err != nil {
-		c <- packageFloatingRef{
-			pkg:      pkg,
-			err:      err,
-			floating: false,
-		}
-		return
+		return nil, err
 	}
 	jsonFileName := jsonFile.Name()
 	jsonFile.Close()
+	defer os.Remove(jsonFileName)
 
-	// Remove ${platform}, ${os} ... from package name before calling cipd describe
-	// as it will fail when these tags are not compatible with current host.
-	pkgName := pkg.PackageName
-	if MustExpand(pkgName) {
-		expandedPkgName, err := Expand(pkgName, plats)
-		if err != nil {
-			c <- packageFloatingRef{
-				pkg:      pkg,
-				err:      err,
-				floating: false,
-			}
-			return
-		}
-		if len(expandedPkgName) == 0 {
-			c <- packageFloatingRef{
-				pkg: pkg,
-				// avoid using %q as we don't want escape characters in the output.
-				err:      fmt.Errorf("cannot expand package \"%s\"", pkgName),
-				floating: false,
-			}
-			return
-		}
-		pkgName = expandedPkgName[0]
-	}
-
-	args := []string{"describe", pkgName, "-version", pkg.VersionTag, "-json-output", jsonFileName}
+	args := []string{"describe", pkg, "-version", version, "-json-output", jsonFileName}
 	jirix.Logger.Debugf("Invoke cipd with %v", args)
 
 	var stdoutBuf bytes.Buffer
@@ -724,22 +756,12 @@ func checkFloatingRefs(jirix *jiri.X, pkg PackageInstance, plats []Platform, jso
 	command.Stderr = &stderrBuf
 
 	if err := command.Run(); err != nil {
-		c <- packageFloatingRef{
-			pkg:      pkg,
-			err:      fmt.Errorf("cipd describe failed due to error: %v, stdout: %s\n, stderr: %s", err, stdoutBuf.String(), stderrBuf.String()),
-			floating: false,
-		}
-		return
+		return nil, fmt.Errorf("cipd describe failed due to error: %v, stdout: %s\n, stderr: %s", err, stdoutBuf.String(), stderrBuf.String())
 	}
 
 	jsonData, err := ioutil.ReadFile(jsonFileName)
 	if err != nil {
-		c <- packageFloatingRef{
-			pkg:      pkg,
-			err:      err,
-			floating: false,
-		}
-		return
+		return nil, err
 	}
 	// Example of generated JSON:
 	// {
@@ -772,13 +794,59 @@ func checkFloatingRefs(jirix *jiri.X, pkg PackageInstance, plats []Platform, jso
 	// 	  ]
 	// 	}
 	// }
-	// Only "refs" is needed.
 
 	var result struct {
 		Result describeJSON `json:"result"`
 	}
 
 	if err := json.Unmarshal(jsonData, &result); err != nil {
+		return nil, err
+	}
+	desc := &Description{
+		Package:    result.Result.Pin.Package,
+		InstanceID: result.Result.Pin.InstanceID,
+	}
+	for _, v := range result.Result.Refs {
+		desc.Refs = append(desc.Refs, v.Ref)
+	}
+	for _, v := range result.Result.Tags {
+		desc.Tags = append(desc.Tags, v.Tag)
+	}
+	return desc, nil
+}
+
+func checkFloatingRefs(jirix *jiri.X, pkg PackageInstance, plats []Platform, sem *semaphore.Weighted, c chan<- packageFloatingRef) {
+	// cipd should already bootstrapped before calling
+	// this function.
+	sem.Acquire(context.Background(), 1)
+	defer sem.Release(1)
+	// Remove ${platform}, ${os} ... from package name before calling cipd describe
+	// as it will fail when these tags are not compatible with current host.
+	pkgName := pkg.PackageName
+	if MustExpand(pkgName) {
+		expandedPkgName, err := Expand(pkgName, plats)
+		if err != nil {
+			c <- packageFloatingRef{
+				pkg:      pkg,
+				err:      err,
+				floating: false,
+			}
+			return
+		}
+		if len(expandedPkgName) == 0 {
+			c <- packageFloatingRef{
+				pkg: pkg,
+				// avoid using %q as we don't want escape characters in the output.
+				err:      fmt.Errorf("cannot expand package \"%s\"", pkgName),
+				floating: false,
+			}
+			return
+		}
+		pkgName = expandedPkgName[0]
+	}
+
+	desc, err := describe(jirix, pkgName, pkg.VersionTag)
+	if err != nil {
 		c <- packageFloatingRef{
 			pkg:      pkg,
 			err:      err,
@@ -786,15 +854,13 @@ func checkFloatingRefs(jirix *jiri.X, pkg PackageInstance, plats []Platform, jso
 		}
 		return
 	}
-
-	for _, v := range result.Result.Refs {
-		if v.Ref == pkg.VersionTag {
+	for _, ref := range desc.Refs {
+		if ref == pkg.VersionTag {
 			c <- packageFloatingRef{pkg: pkg, err: nil, floating: true}
 			return
 		}
 	}
 	c <- packageFloatingRef{pkg: pkg, err: nil, floating: false}
-	return
 }
 
 // Platform contains the parameters for a "${platform}" template.
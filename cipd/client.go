@@ -0,0 +1,925 @@
+// Copyright 2026 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cipd
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.fuchsia.dev/jiri"
+	"go.fuchsia.dev/jiri/osutil"
+	"go.fuchsia.dev/jiri/pkgstore"
+	"go.fuchsia.dev/jiri/retry"
+	"golang.org/x/sync/semaphore"
+)
+
+// The native client talks to the pRPC API of the CIPD backend instead of
+// running the cipd binary. It is used when "cipd_native" is set in the jiri
+// config.
+
+// Error is an error of the native CIPD client.
+type Error struct {
+	// Op is the operation which failed, such as "resolve" or "download".
+	Op      string
+	Package string
+	Version string
+	// Code is the HTTP status code returned by the backend, or 0 if the
+	// backend was not reached.
+	Code int
+	Err  error
+}
+
+func (e *Error) Error() string {
+	var buf bytes.Buffer
+	buf.WriteString(e.Op)
+	if e.Package != "" {
+		fmt.Fprintf(&buf, " %s", e.Package)
+		if e.Version != "" {
+			fmt.Fprintf(&buf, "@%s", e.Version)
+		}
+	}
+	if e.Code != 0 {
+		fmt.Fprintf(&buf, " (HTTP %d)", e.Code)
+	}
+	fmt.Fprintf(&buf, ": %v", e.Err)
+	return buf.String()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Errors are the errors of several packages.
+type Errors []error
+
+func (e Errors) Error() string {
+	var msgs []string
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// httpStatusError is the error returned for an unsuccessful response.
+type httpStatusError struct {
+	code int
+	msg  string
+}
+
+func (e *httpStatusError) Error() string {
+	return e.msg
+}
+
+// wrapError wraps err into an *Error describing op on pkg at version.
+func wrapError(op, pkg, version string, err error) error {
+	if e, ok := err.(*Error); ok {
+		return e
+	}
+	e := &Error{Op: op, Package: pkg, Version: version, Err: err}
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) {
+		e.Code = statusErr.code
+	}
+	return e
+}
+
+type client struct {
+	jirix   *jiri.X
+	backend string
+	http    *http.Client
+
+	tokenOnce sync.Once
+	token     string
+	tokenErr  error
+}
+
+func newClient(jirix *jiri.X) *client {
+	return &client{
+		jirix:   jirix,
+		backend: strings.TrimRight(cipdBackend, "/"),
+		http:    &http.Client{},
+	}
+}
+
+// accessToken returns the OAuth token printed by the token command of the
+// jiri config for the backend host. It returns an empty token if no token
+// command is configured.
+func (c *client) accessToken() (string, error) {
+	c.tokenOnce.Do(func() {
+		if c.jirix.TokenCommand == "" {
+			return
+		}
+		u, err := url.Parse(c.backend)
+		if err != nil {
+			c.tokenErr = err
+			return
+		}
+		c.token, c.tokenErr = c.jirix.RunTokenCommand(u.Host)
+	})
+	return c.token, c.tokenErr
+}
+
+// call calls method of the cipd.Repository pRPC service.
+func (c *client) call(ctx context.Context, method string, req, resp interface{}) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	token, err := c.accessToken()
+	if err != nil {
+		return err
+	}
+	// Client errors are not retried.
+	var clientErr error
+	err = retry.Function(c.jirix, func() error {
+		httpReq, err := http.NewRequest("POST", c.backend+"/prpc/cipd.Repository/"+method, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		httpReq = httpReq.WithContext(ctx)
+		httpReq.Header.Set("Content-Type", "application/json")
+		httpReq.Header.Set("Accept", "application/json")
+		httpReq.Header.Set("User-Agent", getUserAgent())
+		if token != "" {
+			httpReq.Header.Set("Authorization", "Bearer "+token)
+		}
+		httpResp, err := c.http.Do(httpReq)
+		if err != nil {
+			return err
+		}
+		defer httpResp.Body.Close()
+		data, err := ioutil.ReadAll(httpResp.Body)
+		if err != nil {
+			return err
+		}
+		if httpResp.StatusCode != http.StatusOK {
+			statusErr := &httpStatusError{
+				code: httpResp.StatusCode,
+				msg:  fmt.Sprintf("%s failed: %s: %s", method, httpResp.Status, strings.TrimSpace(string(data))),
+			}
+			if httpResp.StatusCode >= 400 && httpResp.StatusCode < 500 {
+				clientErr = statusErr
+				return nil
+			}
+			return statusErr
+		}
+		// pRPC prefixes JSON responses to prevent XSSI.
+		data = bytes.TrimPrefix(data, []byte(")]}'"))
+		return json.Unmarshal(data, resp)
+	}, fmt.Sprintf("calling %s", method), retry.AttemptsOpt(c.jirix.Attempts))
+	if err != nil {
+		return err
+	}
+	return clientErr
+}
+
+// objectRef is a reference to the content of an instance.
+type objectRef struct {
+	HashAlgo  string `json:"hashAlgo"`
+	HexDigest string `json:"hexDigest"`
+}
+
+// instanceIDToObjectRef converts an instance id to a reference to its
+// content. Instance ids are either the hex SHA1 digest of the instance, or
+// the base64 encoded digest followed by a byte identifying the hash.
+func instanceIDToObjectRef(iid string) (objectRef, error) {
+	if len(iid) == 40 {
+		if _, err := hex.DecodeString(iid); err == nil {
+			return objectRef{HashAlgo: "SHA1", HexDigest: iid}, nil
+		}
+	}
+	data, err := base64.RawURLEncoding.DecodeString(iid)
+	if err != nil || len(data) < 2 {
+		return objectRef{}, fmt.Errorf("invalid instance id %q", iid)
+	}
+	digest, algo := data[:len(data)-1], data[len(data)-1]
+	switch {
+	case algo == 1 && len(digest) == sha1.Size:
+		return objectRef{HashAlgo: "SHA1", HexDigest: hex.EncodeToString(digest)}, nil
+	case algo == 2 && len(digest) == sha256.Size:
+		return objectRef{HashAlgo: "SHA256", HexDigest: hex.EncodeToString(digest)}, nil
+	}
+	return objectRef{}, fmt.Errorf("invalid instance id %q", iid)
+}
+
+// instanceID converts a reference to the content of an instance to its
+// instance id.
+func (ref objectRef) instanceID() (string, error) {
+	digest, err := hex.DecodeString(ref.HexDigest)
+	if err != nil {
+		return "", fmt.Errorf("invalid digest %q", ref.HexDigest)
+	}
+	switch ref.HashAlgo {
+	case "SHA1":
+		return ref.HexDigest, nil
+	case "SHA256":
+		return base64.RawURLEncoding.EncodeToString(append(digest, 2)), nil
+	}
+	return "", fmt.Errorf("unsupported hash algorithm %q", ref.HashAlgo)
+}
+
+func (ref objectRef) newHash() hash.Hash {
+	if ref.HashAlgo == "SHA1" {
+		return sha1.New()
+	}
+	return sha256.New()
+}
+
+// resolveVersion returns the instance id of version of pkg.
+func (c *client) resolveVersion(ctx context.Context, pkg, version string) (string, error) {
+	req := struct {
+		Package string `json:"package"`
+		Version string `json:"version"`
+	}{pkg, version}
+	var resp struct {
+		Instance objectRef `json:"instance"`
+	}
+	if err := c.call(ctx, "ResolveVersion", req, &resp); err != nil {
+		return "", wrapError("resolve", pkg, version, err)
+	}
+	iid, err := resp.Instance.instanceID()
+	if err != nil {
+		return "", wrapError("resolve", pkg, version, err)
+	}
+	return iid, nil
+}
+
+// describe returns the refs and tags of the instance which version of pkg
+// resolves to.
+func (c *client) describe(ctx context.Context, pkg, version string) (*Description, error) {
+	iid, err := c.resolveVersion(ctx, pkg, version)
+	if err != nil {
+		return nil, err
+	}
+	ref, err := instanceIDToObjectRef(iid)
+	if err != nil {
+		return nil, wrapError("describe", pkg, version, err)
+	}
+	req := struct {
+		Package      string    `json:"package"`
+		Instance     objectRef `json:"instance"`
+		DescribeRefs bool      `json:"describeRefs"`
+		DescribeTags bool      `json:"describeTags"`
+	}{pkg, ref, true, true}
+	var resp struct {
+		Refs []struct {
+			Name string `json:"name"`
+		} `json:"refs"`
+		Tags []struct {
+			Key   string `json:"key"`
+			Value string `json:"value"`
+		} `json:"tags"`
+	}
+	if err := c.call(ctx, "DescribeInstance", req, &resp); err != nil {
+		return nil, wrapError("describe", pkg, version, err)
+	}
+	desc := &Description{Package: pkg, InstanceID: iid}
+	for _, r := range resp.Refs {
+		desc.Refs = append(desc.Refs, r.Name)
+	}
+	for _, t := range resp.Tags {
+		desc.Tags = append(desc.Tags, t.Key+":"+t.Value)
+	}
+	return desc, nil
+}
+
+// canRead returns true if the user can read pkg.
+func (c *client) canRead(ctx context.Context, pkg string) (bool, error) {
+	req := struct {
+		Prefix string `json:"prefix"`
+	}{pkg}
+	var resp struct {
+		Roles []struct {
+			Role string `json:"role"`
+		} `json:"roles"`
+	}
+	if err := c.call(ctx, "FetchRoles", req, &resp); err != nil {
+		return false, wrapError("acl-check", pkg, "", err)
+	}
+	for _, r := range resp.Roles {
+		switch r.Role {
+		case "READER", "WRITER", "OWNER":
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// download downloads instance iid of pkg into f, verifying its digest.
+func (c *client) download(ctx context.Context, pkg, iid string, f *os.File) error {
+	ref, err := instanceIDToObjectRef(iid)
+	if err != nil {
+		return wrapError("download", pkg, iid, err)
+	}
+	req := struct {
+		Package  string    `json:"package"`
+		Instance objectRef `json:"instance"`
+	}{pkg, ref}
+	var resp struct {
+		SignedURL string `json:"signedUrl"`
+	}
+	if err := c.call(ctx, "GetInstanceURL", req, &resp); err != nil {
+		return wrapError("download", pkg, iid, err)
+	}
+	h := ref.newHash()
+	if err := retry.Function(c.jirix, func() error {
+		httpReq, err := http.NewRequest("GET", resp.SignedURL, nil)
+		if err != nil {
+			return err
+		}
+		httpReq = httpReq.WithContext(ctx)
+		httpReq.Header.Set("User-Agent", getUserAgent())
+		httpResp, err := c.http.Do(httpReq)
+		if err != nil {
+			return err
+		}
+		defer httpResp.Body.Close()
+		if httpResp.StatusCode != http.StatusOK {
+			return &httpStatusError{code: httpResp.StatusCode, msg: fmt.Sprintf("got non-success response: %s", httpResp.Status)}
+		}
+		if err := f.Truncate(0); err != nil {
+			return err
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		h.Reset()
+		_, err = io.Copy(io.MultiWriter(f, h), httpResp.Body)
+		return err
+	}, fmt.Sprintf("downloading %s", pkg), retry.AttemptsOpt(c.jirix.Attempts)); err != nil {
+		return wrapError("download", pkg, iid, err)
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); got != ref.HexDigest {
+		return wrapError("download", pkg, iid, fmt.Errorf("digest mismatch, got %s, expected %s", got, ref.HexDigest))
+	}
+	return nil
+}
+
+// forEach runs fn for each of n items in parallel, using at most
+// jirix.CipdMaxThreads goroutines.
+func forEach(jirix *jiri.X, n int, fn func(i int) error) error {
+	threads := jirix.CipdMaxThreads
+	if threads <= 0 {
+		threads = runtime.NumCPU()
+	}
+	sem := semaphore.NewWeighted(int64(threads))
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		sem.Acquire(context.Background(), 1)
+		go func(i int) {
+			defer wg.Done()
+			defer sem.Release(1)
+			errs[i] = fn(i)
+		}(i)
+	}
+	wg.Wait()
+	var result Errors
+	for _, err := range errs {
+		if err != nil {
+			result = append(result, err)
+		}
+	}
+	if len(result) != 0 {
+		return result
+	}
+	return nil
+}
+
+// checkPackageACLNative is the native implementation of CheckPackageACL.
+func checkPackageACLNative(jirix *jiri.X, pkgs map[string]bool) error {
+	var names []string
+	for name := range pkgs {
+		names = append(names, name)
+	}
+	access := make([]bool, len(names))
+	c := newClient(jirix)
+	forEach(jirix, len(names), func(i int) error {
+		ok, err := c.canRead(context.Background(), names[i])
+		if err != nil {
+			// Like the cipd binary, treat errors as a lack of access.
+			jirix.Logger.Debugf("%v", err)
+		}
+		access[i] = ok
+		return nil
+	})
+	for i, name := range names {
+		pkgs[name] = access[i]
+	}
+	return nil
+}
+
+// checkFloatingRefsNative is the native implementation of CheckFloatingRefs.
+func checkFloatingRefsNative(jirix *jiri.X, pkgs map[PackageInstance]bool, plats map[PackageInstance][]Platform) error {
+	var keys []PackageInstance
+	for k := range pkgs {
+		if _, ok := plats[k]; !ok {
+			return fmt.Errorf("Platforms for package \"%s\" is not found", k.PackageName)
+		}
+		keys = append(keys, k)
+	}
+	floating := make([]bool, len(keys))
+	c := newClient(jirix)
+	err := forEach(jirix, len(keys), func(i int) error {
+		pkg := keys[i]
+		pkgName := pkg.PackageName
+		if MustExpand(pkgName) {
+			expanded, err := Expand(pkgName, plats[pkg])
+			if err != nil {
+				return wrapError("describe", pkgName, pkg.VersionTag, err)
+			}
+			if len(expanded) == 0 {
+				return wrapError("describe", pkgName, pkg.VersionTag, errors.New("cannot expand package"))
+			}
+			pkgName = expanded[0]
+		}
+		desc, err := c.describe(context.Background(), pkgName, pkg.VersionTag)
+		if err != nil {
+			return err
+		}
+		for _, ref := range desc.Refs {
+			if ref == pkg.VersionTag {
+				floating[i] = true
+			}
+		}
+		return nil
+	})
+	for i, k := range keys {
+		pkgs[k] = floating[i]
+	}
+	return err
+}
+
+func describeNative(jirix *jiri.X, pkg, version string) (*Description, error) {
+	return newClient(jirix).describe(context.Background(), pkg, version)
+}
+
+// ensureEntry is a package line of an ensure file.
+type ensureEntry struct {
+	subdir  string
+	pkg     string
+	version string
+}
+
+// ensureFile is a parsed ensure file.
+type ensureFile struct {
+	verifiedPlatforms []Platform
+	resolvedVersions  string
+	paranoid          bool
+	entries           []ensureEntry
+}
+
+// parseEnsureFile parses the subset of the ensure file format which jiri
+// generates.
+func parseEnsureFile(file string) (*ensureFile, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	result := &ensureFile{}
+	subdir := ""
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("failed to parse ensure file %q (line %d): %q", file, lineNo, line)
+		}
+		switch fields[0] {
+		case "$VerifiedPlatform":
+			plat, err := NewPlatform(fields[1])
+			if err != nil {
+				return nil, err
+			}
+			result.verifiedPlatforms = append(result.verifiedPlatforms, plat)
+		case "$ResolvedVersions":
+			result.resolvedVersions = fields[1]
+		case "$ParanoidMode":
+			result.paranoid = fields[1] != "NotParanoid"
+		case "@Subdir":
+			subdir = fields[1]
+		default:
+			if strings.HasPrefix(fields[0], "$") || strings.HasPrefix(fields[0], "@") {
+				return nil, fmt.Errorf("unsupported directive %q in ensure file %q", fields[0], file)
+			}
+			result.entries = append(result.entries, ensureEntry{subdir: subdir, pkg: fields[0], version: fields[1]})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Scan() failed: %v", err)
+	}
+	return result, nil
+}
+
+// resolveNative resolves the packages of the ensure file for all of its
+// verified platforms.
+func resolveNative(jirix *jiri.X, file string) ([]PackageInstance, error) {
+	ef, err := parseEnsureFile(file)
+	if err != nil {
+		return nil, err
+	}
+	plats := ef.verifiedPlatforms
+	if len(plats) == 0 {
+		plats = []Platform{CipdPlatform}
+	}
+	seen := make(map[PackageInstance]bool)
+	var instances []PackageInstance
+	for _, entry := range ef.entries {
+		for _, plat := range plats {
+			pkg, err := plat.Expander().Expand(entry.pkg)
+			if err == ErrSkipTemplate {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			key := PackageInstance{PackageName: pkg, VersionTag: entry.version}
+			if !seen[key] {
+				seen[key] = true
+				instances = append(instances, key)
+			}
+		}
+	}
+	c := newClient(jirix)
+	err = forEach(jirix, len(instances), func(i int) error {
+		iid, err := c.resolveVersion(context.Background(), instances[i].PackageName, instances[i].VersionTag)
+		instances[i].InstanceID = iid
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(instances, func(i, j int) bool {
+		if instances[i].PackageName != instances[j].PackageName {
+			return instances[i].PackageName < instances[j].PackageName
+		}
+		return instances[i].VersionTag < instances[j].VersionTag
+	})
+	return instances, nil
+}
+
+// nativeInstall is a package installed by the native client.
+type nativeInstall struct {
+	Subdir     string   `json:"subdir"`
+	Package    string   `json:"package"`
+	InstanceID string   `json:"instance_id"`
+	Files      []string `json:"files"`
+}
+
+// nativeStateFile returns the file recording the packages installed into
+// root by the native client.
+func nativeStateFile(root string) string {
+	return filepath.Join(root, ".cipd", "jiri_native.json")
+}
+
+func readNativeState(root string) (map[string]nativeInstall, error) {
+	state := make(map[string]nativeInstall)
+	data, err := ioutil.ReadFile(nativeStateFile(root))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return state, nil
+		}
+		return nil, err
+	}
+	var installs []nativeInstall
+	if err := json.Unmarshal(data, &installs); err != nil {
+		return nil, fmt.Errorf("invalid native cipd state %q: %v", nativeStateFile(root), err)
+	}
+	for _, inst := range installs {
+		state[inst.key()] = inst
+	}
+	return state, nil
+}
+
+func writeNativeState(root string, state map[string]nativeInstall) error {
+	var installs []nativeInstall
+	for _, inst := range state {
+		installs = append(installs, inst)
+	}
+	sort.Slice(installs, func(i, j int) bool { return installs[i].key() < installs[j].key() })
+	data, err := json.MarshalIndent(installs, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(nativeStateFile(root)), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(nativeStateFile(root), data, 0644)
+}
+
+// InstalledInstances returns the instance ids of the packages which the
+// native client installed into root, keyed by the cleaned subdirectory of the
+// package, in slash format, and its name, separated by ":". It returns nil
+// if the native client is not used.
+func InstalledInstances(jirix *jiri.X, root string) (map[string]string, error) {
+	if !jirix.CipdNative {
+		return nil, nil
+	}
+	state, err := readNativeState(root)
+	if err != nil {
+		return nil, err
+	}
+	instances := make(map[string]string)
+	for _, inst := range state {
+		instances[filepath.ToSlash(filepath.Clean(inst.Subdir))+":"+inst.Package] = inst.InstanceID
+	}
+	return instances, nil
+}
+
+func (inst nativeInstall) key() string {
+	return inst.Subdir + ":" + inst.Package
+}
+
+// present returns true if all the files of inst exist in root.
+func (inst nativeInstall) present(root string) bool {
+	for _, f := range inst.Files {
+		if _, err := os.Lstat(filepath.Join(root, inst.Subdir, f)); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// remove removes the files of inst from root, and the directories which
+// become empty.
+func (inst nativeInstall) remove(root string) error {
+	dirs := make(map[string]bool)
+	for _, f := range inst.Files {
+		path := filepath.Join(root, inst.Subdir, f)
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		for dir := filepath.Dir(path); dir != root && strings.HasPrefix(dir, root); dir = filepath.Dir(dir) {
+			dirs[dir] = true
+		}
+	}
+	var sorted []string
+	for dir := range dirs {
+		sorted = append(sorted, dir)
+	}
+	// Remove the deepest directories first.
+	sort.Sort(sort.Reverse(sort.StringSlice(sorted)))
+	for _, dir := range sorted {
+		// Only empty directories are removed.
+		os.Remove(dir)
+	}
+	return nil
+}
+
+// ensureNative installs the packages of the ensure file into root for the
+// current platform, and removes the packages it installed before which are
+// not in the ensure file anymore.
+func ensureNative(ctx context.Context, jirix *jiri.X, file, root string) error {
+	ef, err := parseEnsureFile(file)
+	if err != nil {
+		return err
+	}
+	pinned := make(map[PackageInstance]string)
+	if ef.resolvedVersions != "" {
+		if _, err := os.Stat(ef.resolvedVersions); err == nil {
+			versions, err := parseVersions(ef.resolvedVersions)
+			if err != nil {
+				return err
+			}
+			for _, v := range versions {
+				pinned[PackageInstance{PackageName: v.PackageName, VersionTag: v.VersionTag}] = v.InstanceID
+			}
+		}
+	}
+	state, err := readNativeState(root)
+	if err != nil {
+		return err
+	}
+
+	var wanted []nativeInstall
+	var versions []string
+	for _, entry := range ef.entries {
+		pkg, err := CipdPlatform.Expander().Expand(entry.pkg)
+		if err == ErrSkipTemplate {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		wanted = append(wanted, nativeInstall{Subdir: entry.subdir, Package: pkg})
+		versions = append(versions, entry.version)
+	}
+
+	c := newClient(jirix)
+	store := pkgstore.FromX(jirix)
+	var mu sync.Mutex
+	err = forEach(jirix, len(wanted), func(i int) error {
+		inst := &wanted[i]
+		version := versions[i]
+		if iid, ok := pinned[PackageInstance{PackageName: inst.Package, VersionTag: version}]; ok {
+			inst.InstanceID = iid
+		} else if _, err := instanceIDToObjectRef(version); err == nil {
+			inst.InstanceID = version
+		} else {
+			iid, err := c.resolveVersion(ctx, inst.Package, version)
+			if err != nil {
+				return err
+			}
+			inst.InstanceID = iid
+		}
+		mu.Lock()
+		prev, ok := state[inst.key()]
+		mu.Unlock()
+		if ok && prev.InstanceID == inst.InstanceID && (!ef.paranoid || prev.present(root)) {
+			inst.Files = prev.Files
+			return nil
+		}
+		jirix.Logger.Debugf("Installing cipd package %s:%s into %q", inst.Package, inst.InstanceID, filepath.Join(root, inst.Subdir))
+		files, err := c.install(ctx, store, inst.Package, inst.InstanceID, filepath.Join(root, inst.Subdir))
+		if err != nil {
+			return err
+		}
+		inst.Files = files
+		if ok {
+			// Remove the files of the previous instance which are not
+			// part of the new one.
+			current := make(map[string]bool)
+			for _, f := range files {
+				current[f] = true
+			}
+			var stale []string
+			for _, f := range prev.Files {
+				if !current[f] {
+					stale = append(stale, f)
+				}
+			}
+			prev.Files = stale
+			if err := prev.remove(root); err != nil {
+				return wrapError("install", inst.Package, inst.InstanceID, err)
+			}
+		}
+		mu.Lock()
+		state[inst.key()] = *inst
+		mu.Unlock()
+		return nil
+	})
+
+	// Remove the packages which are not in the ensure file anymore, unless
+	// installing the packages failed.
+	if err == nil {
+		keep := make(map[string]bool)
+		for _, inst := range wanted {
+			keep[inst.key()] = true
+		}
+		for key, inst := range state {
+			if keep[key] {
+				continue
+			}
+			jirix.Logger.Debugf("Removing cipd package %s from %q", inst.Package, filepath.Join(root, inst.Subdir))
+			if err := inst.remove(root); err != nil {
+				return wrapError("remove", inst.Package, inst.InstanceID, err)
+			}
+			delete(state, key)
+		}
+	}
+	if err := writeNativeState(root, state); err != nil {
+		return err
+	}
+	return err
+}
+
+// install downloads instance iid of pkg and extracts it into dir. If store
+// is not nil, the instance is extracted into the store once and installed
+// from there. It returns the files which were installed, relative to dir.
+func (c *client) install(ctx context.Context, store *pkgstore.Store, pkg, iid, dir string) ([]string, error) {
+	if store == nil {
+		return c.extract(ctx, pkg, iid, dir)
+	}
+	id := "cipd/" + iid
+	if err := store.Add(id, func(tmpDir string) error {
+		_, err := c.extract(ctx, pkg, iid, tmpDir)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	files, err := store.Install(id, dir)
+	if err != nil {
+		return nil, wrapError("install", pkg, iid, err)
+	}
+	return files, nil
+}
+
+// extract downloads instance iid of pkg and extracts it into dir. It returns
+// the files which were extracted, relative to dir.
+func (c *client) extract(ctx context.Context, pkg, iid, dir string) ([]string, error) {
+	tmp, err := ioutil.TempFile("", "jiri-cipd*.zip")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	if err := c.download(ctx, pkg, iid, tmp); err != nil {
+		return nil, err
+	}
+	if err := tmp.Sync(); err != nil {
+		return nil, err
+	}
+	files, err := extractZip(tmp.Name(), dir)
+	if err != nil {
+		return nil, wrapError("extract", pkg, iid, err)
+	}
+	return files, nil
+}
+
+// extractZip extracts the zip file into dir, leaving out the package
+// metadata in ".cipdpkg". It returns the files which were extracted, relative
+// to dir.
+func extractZip(file, dir string) ([]string, error) {
+	dir = filepath.Clean(dir)
+	zr, err := zip.OpenReader(file)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	var files []string
+	for _, f := range zr.File {
+		name := filepath.Clean(filepath.FromSlash(f.Name))
+		if filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+			return nil, fmt.Errorf("invalid path %q in package", f.Name)
+		}
+		if name == ".cipdpkg" || strings.HasPrefix(name, ".cipdpkg"+string(filepath.Separator)) || f.FileInfo().IsDir() {
+			continue
+		}
+		path := filepath.Join(dir, name)
+		r, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		var target []byte
+		if f.Mode()&os.ModeSymlink != 0 {
+			target, err = ioutil.ReadAll(r)
+		}
+		if err == nil {
+			err = extractZipEntry(f, r, dir, path, string(target))
+		}
+		r.Close()
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, name)
+	}
+	return files, nil
+}
+
+// extractZipEntry extracts the entry f, read from r, to path. Entries are
+// not extracted through symlinks pointing outside of dir, and symlinks, whose
+// target is the content of the entry, cannot point outside of dir.
+func extractZipEntry(f *zip.File, r io.Reader, dir, path, target string) error {
+	if err := osutil.CheckExtractPath(dir, path, target); err != nil {
+		return fmt.Errorf("invalid entry %q in package: %v", f.Name, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(path); err != nil {
+		return err
+	}
+	if f.Mode()&os.ModeSymlink != 0 {
+		return os.Symlink(target, path)
+	}
+	return writeZipEntry(path, r, f.Mode().Perm())
+}
+
+func writeZipEntry(path string, r io.Reader, perm os.FileMode) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, perm|0200)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
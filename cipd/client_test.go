@@ -0,0 +1,408 @@
+// Copyright 2026 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cipd
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.fuchsia.dev/jiri/jiritest/xtest"
+)
+
+// fakeBackend is a fake CIPD backend serving packages from memory.
+type fakeBackend struct {
+	t *testing.T
+	// versions maps package names to versions, refs and tags to the
+	// digests of instances.
+	versions map[string]map[string]string
+	// refs maps package names to the refs of the package.
+	refs map[string]map[string]bool
+	// instances maps digests to the content of instances.
+	instances map[string][]byte
+	// readable are the package prefixes which can be read.
+	readable map[string]bool
+	// corrupt makes downloads return wrong content.
+	corrupt bool
+}
+
+func newFakeBackend(t *testing.T) *fakeBackend {
+	return &fakeBackend{
+		t:         t,
+		versions:  make(map[string]map[string]string),
+		refs:      make(map[string]map[string]bool),
+		instances: make(map[string][]byte),
+		readable:  make(map[string]bool),
+	}
+}
+
+// addInstance adds an instance of pkg containing files, and returns its
+// instance id.
+func (f *fakeBackend) addInstance(pkg string, files map[string]string, versions ...string) string {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	files[".cipdpkg/manifest.json"] = "{}"
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			f.t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		f.t.Fatal(err)
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	digest := hex.EncodeToString(sum[:])
+	f.instances[digest] = buf.Bytes()
+	if f.versions[pkg] == nil {
+		f.versions[pkg] = make(map[string]string)
+	}
+	for _, v := range versions {
+		f.versions[pkg][v] = digest
+	}
+	iid, err := objectRef{HashAlgo: "SHA256", HexDigest: digest}.instanceID()
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	f.versions[pkg][iid] = digest
+	return iid
+}
+
+func (f *fakeBackend) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.URL.Path, "/dl/") {
+		data, ok := f.instances[strings.TrimPrefix(r.URL.Path, "/dl/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if f.corrupt {
+			data = append([]byte("corrupt"), data...)
+		}
+		w.Write(data)
+		return
+	}
+	var req struct {
+		Package  string    `json:"package"`
+		Version  string    `json:"version"`
+		Instance objectRef `json:"instance"`
+		Prefix   string    `json:"prefix"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var resp interface{}
+	switch strings.TrimPrefix(r.URL.Path, "/prpc/cipd.Repository/") {
+	case "ResolveVersion":
+		digest, ok := f.versions[req.Package][req.Version]
+		if !ok {
+			http.Error(w, "no such version", http.StatusNotFound)
+			return
+		}
+		resp = map[string]interface{}{"instance": objectRef{HashAlgo: "SHA256", HexDigest: digest}}
+	case "DescribeInstance":
+		var refs []map[string]string
+		for ref := range f.refs[req.Package] {
+			if f.versions[req.Package][ref] == req.Instance.HexDigest {
+				refs = append(refs, map[string]string{"name": ref})
+			}
+		}
+		var tags []map[string]string
+		for version, digest := range f.versions[req.Package] {
+			if i := strings.Index(version, ":"); i > 0 && digest == req.Instance.HexDigest {
+				tags = append(tags, map[string]string{"key": version[:i], "value": version[i+1:]})
+			}
+		}
+		resp = map[string]interface{}{"refs": refs, "tags": tags}
+	case "GetInstanceURL":
+		resp = map[string]string{"signedUrl": "http://" + r.Host + "/dl/" + req.Instance.HexDigest}
+	case "FetchRoles":
+		var roles []map[string]string
+		if f.readable[req.Prefix] {
+			roles = append(roles, map[string]string{"role": "READER"})
+		}
+		resp = map[string]interface{}{"roles": roles}
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	w.Write([]byte(")]}'\n"))
+	json.NewEncoder(w).Encode(resp)
+}
+
+// firstError returns the first of the errors of several packages.
+func firstError(err error) error {
+	if errs, ok := err.(Errors); ok && len(errs) != 0 {
+		return errs[0]
+	}
+	return err
+}
+
+func setupNative(t *testing.T) (*fakeBackend, func()) {
+	backend := newFakeBackend(t)
+	server := httptest.NewServer(backend)
+	oldBackend := cipdBackend
+	cipdBackend = server.URL
+	return backend, func() {
+		cipdBackend = oldBackend
+		server.Close()
+	}
+}
+
+func writeEnsureFile(t *testing.T, dir, content string) string {
+	file := filepath.Join(dir, "test.ensure")
+	if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestNativeResolve(t *testing.T) {
+	fakex, cleanup := xtest.NewX(t)
+	defer cleanup()
+	fakex.CipdNative = true
+	fakex.Attempts = 1
+	backend, cleanupBackend := setupNative(t)
+	defer cleanupBackend()
+	linuxIID := backend.addInstance("tool/linux-amd64", map[string]string{"tool": "linux"}, "version:1")
+	macIID := backend.addInstance("tool/mac-amd64", map[string]string{"tool": "mac"}, "version:1")
+
+	file := writeEnsureFile(t, fakex.Root, `
+$VerifiedPlatform linux-amd64
+$VerifiedPlatform mac-amd64
+
+@Subdir tool
+tool/${platform=linux-amd64,mac-amd64} version:1
+`)
+	instances, err := Resolve(fakex, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []PackageInstance{
+		{"tool/linux-amd64", "version:1", linuxIID},
+		{"tool/mac-amd64", "version:1", macIID},
+	}
+	if len(instances) != len(want) || instances[0] != want[0] || instances[1] != want[1] {
+		t.Errorf("wrong instances, got %+v, want %+v", instances, want)
+	}
+
+	if err := EnsureFileVerify(fakex, file); err != nil {
+		t.Errorf("ensure file should be valid: %v", err)
+	}
+	file = writeEnsureFile(t, fakex.Root, "tool/linux-amd64 version:2\n")
+	if err := EnsureFileVerify(fakex, file); err != cipdManifestInvalidErr {
+		t.Errorf("expected invalid ensure file, got %v", err)
+	}
+	_, err = Resolve(fakex, file)
+	var cipdErr *Error
+	if !errors.As(firstError(err), &cipdErr) || cipdErr.Code != http.StatusNotFound || cipdErr.Package != "tool/linux-amd64" {
+		t.Errorf("expected a not found error for tool/linux-amd64, got %v", err)
+	}
+}
+
+func TestNativeEnsure(t *testing.T) {
+	fakex, cleanup := xtest.NewX(t)
+	defer cleanup()
+	fakex.CipdNative = true
+	fakex.Attempts = 1
+	backend, cleanupBackend := setupNative(t)
+	defer cleanupBackend()
+	backend.addInstance("tool", map[string]string{"bin/tool": "tool 1", "bin/old": "old"}, "version:1")
+	backend.addInstance("tool", map[string]string{"bin/tool": "tool 2"}, "version:2")
+	backend.addInstance("other", map[string]string{"other": "other"}, "version:1")
+	root := filepath.Join(fakex.Root, "root")
+
+	read := func(path string) string {
+		data, err := ioutil.ReadFile(filepath.Join(root, path))
+		if err != nil {
+			if os.IsNotExist(err) {
+				return ""
+			}
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	file := writeEnsureFile(t, fakex.Root, `
+$ParanoidMode CheckPresence
+
+@Subdir prebuilt/tool
+tool version:1
+@Subdir prebuilt/other
+other version:1
+`)
+	if err := Ensure(fakex, file, root, 1); err != nil {
+		t.Fatal(err)
+	}
+	if got := read("prebuilt/tool/bin/tool"); got != "tool 1" {
+		t.Errorf("wrong content, got %q, want %q", got, "tool 1")
+	}
+	if _, err := os.Stat(filepath.Join(root, "prebuilt/tool/.cipdpkg")); !os.IsNotExist(err) {
+		t.Errorf("package metadata should not be extracted")
+	}
+
+	// A new version replaces the files of the old one, and packages which
+	// are not in the ensure file anymore are removed.
+	file = writeEnsureFile(t, fakex.Root, `
+@Subdir prebuilt/tool
+tool version:2
+`)
+	if err := Ensure(fakex, file, root, 1); err != nil {
+		t.Fatal(err)
+	}
+	if got := read("prebuilt/tool/bin/tool"); got != "tool 2" {
+		t.Errorf("wrong content, got %q, want %q", got, "tool 2")
+	}
+	if got := read("prebuilt/tool/bin/old"); got != "" {
+		t.Errorf("file of the old version should have been removed")
+	}
+	if _, err := os.Stat(filepath.Join(root, "prebuilt/other")); !os.IsNotExist(err) {
+		t.Errorf("removed package should have been deleted")
+	}
+
+	// Downloads are verified.
+	backend.corrupt = true
+	file = writeEnsureFile(t, fakex.Root, `
+@Subdir prebuilt/tool
+tool version:1
+`)
+	err := Ensure(fakex, file, root, 1)
+	var cipdErr *Error
+	if !errors.As(firstError(err), &cipdErr) || cipdErr.Op != "download" || !strings.Contains(err.Error(), "digest mismatch") {
+		t.Errorf("expected a digest mismatch, got %v", err)
+	}
+	if got := read("prebuilt/tool/bin/tool"); got != "tool 2" {
+		t.Errorf("failed download should leave the package alone, got %q", got)
+	}
+}
+
+func TestNativeACLAndFloatingRefs(t *testing.T) {
+	fakex, cleanup := xtest.NewX(t)
+	defer cleanup()
+	fakex.CipdNative = true
+	fakex.Attempts = 1
+	backend, cleanupBackend := setupNative(t)
+	defer cleanupBackend()
+	backend.addInstance("public/tool", map[string]string{"tool": "tool"}, "version:1", "latest")
+	backend.refs["public/tool"] = map[string]bool{"latest": true}
+	backend.readable["public/tool"] = true
+
+	acls := map[string]bool{"public/tool": false, "internal/tool": false}
+	if err := CheckPackageACL(fakex, acls); err != nil {
+		t.Fatal(err)
+	}
+	if !acls["public/tool"] || acls["internal/tool"] {
+		t.Errorf("wrong access: %v", acls)
+	}
+
+	pinned := PackageInstance{PackageName: "public/tool", VersionTag: "version:1"}
+	floating := PackageInstance{PackageName: "public/tool", VersionTag: "latest"}
+	pkgs := map[PackageInstance]bool{pinned: false, floating: false}
+	plats := map[PackageInstance][]Platform{pinned: DefaultPlatforms(), floating: DefaultPlatforms()}
+	if err := CheckFloatingRefs(fakex, pkgs, plats); err != nil {
+		t.Fatal(err)
+	}
+	if pkgs[pinned] || !pkgs[floating] {
+		t.Errorf("wrong floating refs: %v", pkgs)
+	}
+
+	desc, err := Describe(fakex, "public/tool", "latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(desc.Refs) != 1 || desc.Refs[0] != "latest" {
+		t.Errorf("wrong refs: %v", desc.Refs)
+	}
+	if len(desc.Tags) != 1 || desc.Tags[0] != "version:1" {
+		t.Errorf("wrong tags: %v", desc.Tags)
+	}
+}
+
+func TestExtractZipSymlinks(t *testing.T) {
+	for _, test := range []struct {
+		target string
+		ok     bool
+	}{
+		{"file", true},
+		{"../bin/file", true},
+		{"../../file", false},
+		{"/etc/passwd", false},
+	} {
+		var buf bytes.Buffer
+		zw := zip.NewWriter(&buf)
+		h := &zip.FileHeader{Name: "bin/link"}
+		h.SetMode(os.ModeSymlink | 0777)
+		w, err := zw.CreateHeader(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(test.target))
+		if err := zw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		tmp := t.TempDir()
+		file := filepath.Join(tmp, "pkg.zip")
+		if err := ioutil.WriteFile(file, buf.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+		dir := filepath.Join(tmp, "out")
+		_, err = extractZip(file, dir)
+		if test.ok && err != nil {
+			t.Errorf("symlink to %q: %v", test.target, err)
+		} else if !test.ok && err == nil {
+			t.Errorf("symlink to %q was extracted", test.target)
+		}
+	}
+}
+
+func TestExtractZipSymlinkChain(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, entry := range []struct {
+		name, content string
+		symlink       bool
+	}{
+		{"a/b", "..", true},
+		{"a/b/c", "..", true},
+		{"a/b/c/evil", "evil", false},
+	} {
+		h := &zip.FileHeader{Name: entry.name}
+		if entry.symlink {
+			h.SetMode(os.ModeSymlink | 0777)
+		} else {
+			h.SetMode(0644)
+		}
+		w, err := zw.CreateHeader(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(entry.content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	tmp := t.TempDir()
+	file := filepath.Join(tmp, "pkg.zip")
+	if err := ioutil.WriteFile(file, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := extractZip(file, filepath.Join(tmp, "out")); err == nil {
+		t.Errorf("symlink chain was extracted")
+	}
+	if _, err := os.Lstat(filepath.Join(tmp, "evil")); !os.IsNotExist(err) {
+		t.Errorf("file was written outside of the package: %v", err)
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2026 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"go.fuchsia.dev/jiri"
+	"go.fuchsia.dev/jiri/cmdline"
+	"go.fuchsia.dev/jiri/project"
+)
+
+var attributesFlags struct {
+	localManifest bool
+	fetchOptional string
+	jsonOutput    string
+}
+
+var cmdAttributes = &cmdline.Command{
+	Runner: jiri.RunnerFunc(runAttributes),
+	Name:   "attributes",
+	Short:  "Explain which optional projects, packages and hooks are fetched",
+	Long: `
+Lists the projects, packages and hooks of the manifest which have attributes,
+and whether they are fetched with the attributes set up by
+"jiri init -fetch-optional", or with the attributes of the -fetch-optional flag.
+
+The attributes of a project, package or hook are either a comma separated list
+of attributes, which matches when any of them is fetched, or an expression
+using "&&", "||", "!" and parentheses, like "(sdk && !arch=arm64) || tests".
+The predicates "os=<os>", "arch=<arch>" and "platform=<os>-<arch>" test the
+host platform.
+
+The attributes to fetch can be an expression too, like "sdk && !internal". It
+enables the attributes it uses without negation, and an item using any of the
+attributes of the expression is only fetched if the expression is also true
+with the attributes of the item.
+
+For each of them, the expression is printed with the value of each attribute
+and predicate.
+`,
+}
+
+func init() {
+	cmdAttributes.Flags.BoolVar(&attributesFlags.localManifest, "local-manifest", false, "Use local checked out manifest.")
+	cmdAttributes.Flags.StringVar(&attributesFlags.fetchOptional, "fetch-optional", optionalAttrsNotSet, "Comma separated list of attributes, or attribute expression, to use instead of the ones of the jiri root.")
+	cmdAttributes.Flags.StringVar(&attributesFlags.jsonOutput, "json-output", "", "Path to write the explanations to, in json format.")
+}
+
+func runAttributes(jirix *jiri.X, args []string) error {
+	if len(args) != 0 {
+		return jirix.UsageErrorf("unexpected number of arguments")
+	}
+	attrs := jirix.FetchingAttrs
+	if attributesFlags.fetchOptional != optionalAttrsNotSet {
+		if err := project.CheckFetchingAttributes(attributesFlags.fetchOptional); err != nil {
+			return err
+		}
+		attrs = attributesFlags.fetchOptional
+	}
+	localProjects, err := project.LocalProjects(jirix, project.FastScan)
+	if err != nil {
+		return err
+	}
+	var projects project.Projects
+	var hooks project.Hooks
+	var pkgs project.Packages
+	if !attributesFlags.localManifest {
+		projects, hooks, pkgs, err = project.LoadUpdatedManifest(jirix, localProjects, attributesFlags.localManifest)
+	} else {
+		projects, hooks, pkgs, err = project.LoadManifestFile(jirix, jirix.JiriManifestFile(), localProjects, attributesFlags.localManifest)
+	}
+	if err != nil {
+		return err
+	}
+	items, err := project.ExplainOptional(attrs, projects, hooks, pkgs)
+	if err != nil {
+		return err
+	}
+	if attributesFlags.jsonOutput != "" {
+		out, err := json.MarshalIndent(items, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to serialize JSON output: %s", err)
+		}
+		return ioutil.WriteFile(attributesFlags.jsonOutput, out, 0600)
+	}
+	for _, item := range items {
+		status := "excluded"
+		if item.Included {
+			status = "included"
+		}
+		fmt.Printf("%s %s %q: %s\n", status, item.Kind, item.Name, item.Reason)
+	}
+	return nil
+}
@@ -55,10 +55,14 @@ Command jiri is a multi-purpose tool for multi-repo development.
 `,
 		LookPath: true,
 		Children: []*cmdline.Command{
+			cmdAbandon,
+			cmdAttributes,
 			cmdBranch,
 			cmdBootstrap,
 			cmdDiff,
 			cmdEdit,
+			cmdEnv,
+			cmdExportPatches,
 			cmdFetchPkgs,
 			cmdGenGitModule,
 			cmdGrep,
@@ -68,15 +72,22 @@ Command jiri is a multi-purpose tool for multi-repo development.
 			cmdPatch,
 			cmdProject,
 			cmdProjectConfig,
+			cmdProvenance,
 			cmdManifest,
 			cmdOverride,
+			cmdOwners,
+			cmdRebase,
 			cmdResolve,
 			cmdRunHooks,
 			cmdRunP,
+			cmdSbom,
 			cmdSelfUpdate,
 			cmdSnapshot,
 			cmdSourceManifest,
+			cmdStart,
 			cmdStatus,
+			cmdSuperproject,
+			cmdSwitch,
 			cmdUpdate,
 			cmdUpload,
 			cmdVersion,
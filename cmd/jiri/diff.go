@@ -11,6 +11,7 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 	"sync"
 
 	"go.fuchsia.dev/jiri"
@@ -78,8 +79,36 @@ returned json:
 			has_more_cls: true,
 			error: error in retrieving CL
 		},{...}...
+	],
+	new_packages: [
+		{
+			name: name,
+			path: path,
+			version: version,
+			instances: {
+				expanded-name: instance-id, ...
+			}
+		},{...}...
+	],
+	deleted_packages: [
+		{...}...
+	],
+	updated_packages: [
+		{
+			name: name,
+			path: path,
+			version: version,
+			old_version: old-version, // if updated
+			instances: {...},
+			old_instances: {...}, // if updated
+			revision_range: old-rev..rev, // if versions are git_revision:<sha>
+			changelog_url: url // if a project is pinned at rev
+		},{...}...
 	]
 }
+
+Packages are matched by name and path. Their instances are the per-platform
+instance ids pinned by the snapshots.
 `,
 }
 
@@ -123,19 +152,137 @@ func (p DiffProjectsByName) Less(i, j int) bool {
 	return p[i].Name < p[j].Name
 }
 
+type DiffPackage struct {
+	Name          string            `json:"name"`
+	Path          string            `json:"path"`
+	Version       string            `json:"version"`
+	OldVersion    string            `json:"old_version,omitempty"`
+	Instances     map[string]string `json:"instances,omitempty"`
+	OldInstances  map[string]string `json:"old_instances,omitempty"`
+	RevisionRange string            `json:"revision_range,omitempty"`
+	ChangelogURL  string            `json:"changelog_url,omitempty"`
+}
+
+type DiffPackagesByName []DiffPackage
+
+func (p DiffPackagesByName) Len() int {
+	return len(p)
+}
+func (p DiffPackagesByName) Swap(i, j int) {
+	p[i], p[j] = p[j], p[i]
+}
+func (p DiffPackagesByName) Less(i, j int) bool {
+	if p[i].Name != p[j].Name {
+		return p[i].Name < p[j].Name
+	}
+	return p[i].Path < p[j].Path
+}
+
 type Diff struct {
 	NewProjects     []DiffProject `json:"new_projects"`
 	DeletedProjects []DiffProject `json:"deleted_projects"`
 	UpdatedProjects []DiffProject `json:"updated_projects"`
+	NewPackages     []DiffPackage `json:"new_packages"`
+	DeletedPackages []DiffPackage `json:"deleted_packages"`
+	UpdatedPackages []DiffPackage `json:"updated_packages"`
 }
 
 func (d *Diff) Sort() *Diff {
 	sort.Sort(DiffProjectsByName(d.NewProjects))
 	sort.Sort(DiffProjectsByName(d.DeletedProjects))
 	sort.Sort(DiffProjectsByName(d.UpdatedProjects))
+	sort.Sort(DiffPackagesByName(d.NewPackages))
+	sort.Sort(DiffPackagesByName(d.DeletedPackages))
+	sort.Sort(DiffPackagesByName(d.UpdatedPackages))
 	return d
 }
 
+const gitRevisionVersionPrefix = "git_revision:"
+
+// packageChangelog returns the range between two package versions which
+// encode git revisions, like "git_revision:<sha>", and a link to the log of
+// that range. The link is only known if remotes maps the new revision to the
+// remote of a project pinned at it.
+func packageChangelog(oldVersion, version string, remotes map[string]string) (string, string) {
+	if !strings.HasPrefix(oldVersion, gitRevisionVersionPrefix) || !strings.HasPrefix(version, gitRevisionVersionPrefix) {
+		return "", ""
+	}
+	oldRev := strings.TrimPrefix(oldVersion, gitRevisionVersionPrefix)
+	rev := strings.TrimPrefix(version, gitRevisionVersionPrefix)
+	revRange := oldRev + ".." + rev
+	remote, ok := remotes[rev]
+	if !ok || remote == "" {
+		return revRange, ""
+	}
+	return revRange, fmt.Sprintf("%s/+log/%s", strings.TrimSuffix(remote, ".git"), revRange)
+}
+
+// packageInstances returns the instance ids of pkg keyed by the name of the
+// package expanded for each platform.
+func packageInstances(pkg project.Package) map[string]string {
+	if len(pkg.Instances) == 0 {
+		return nil
+	}
+	instances := make(map[string]string)
+	for _, ins := range pkg.Instances {
+		instances[ins.Name] = ins.ID
+	}
+	return instances
+}
+
+func sameInstances(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if b[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// diffPackages adds the packages which were added, deleted or updated between
+// pkgs1 and pkgs2 to diff. projects are used to link the changelog of
+// packages built from them.
+func diffPackages(diff *Diff, pkgs1, pkgs2 project.Packages, projects project.Projects) {
+	remotes := make(map[string]string)
+	for _, p := range projects {
+		remotes[p.Revision] = p.Remote
+	}
+	newDiffPackage := func(pkg project.Package) DiffPackage {
+		return DiffPackage{
+			Name:      pkg.Name,
+			Path:      pkg.Path,
+			Version:   pkg.Version,
+			Instances: packageInstances(pkg),
+		}
+	}
+	for key, pkg1 := range pkgs1 {
+		if _, ok := pkgs2[key]; !ok {
+			diff.DeletedPackages = append(diff.DeletedPackages, newDiffPackage(pkg1))
+		}
+	}
+	for key, pkg2 := range pkgs2 {
+		pkg1, ok := pkgs1[key]
+		if !ok {
+			diff.NewPackages = append(diff.NewPackages, newDiffPackage(pkg2))
+			continue
+		}
+		diffP := newDiffPackage(pkg2)
+		oldInstances := packageInstances(pkg1)
+		if pkg1.Version == pkg2.Version && sameInstances(oldInstances, diffP.Instances) {
+			continue
+		}
+		if pkg1.Version != pkg2.Version {
+			diffP.OldVersion = pkg1.Version
+			diffP.RevisionRange, diffP.ChangelogURL = packageChangelog(pkg1.Version, pkg2.Version, remotes)
+		}
+		diffP.OldInstances = oldInstances
+		diff.UpdatedPackages = append(diff.UpdatedPackages, diffP)
+	}
+}
+
 func runDiff(jirix *jiri.X, args []string) error {
 	if len(args) != 2 {
 		return jirix.UsageErrorf("Please provide two snapshots to diff")
@@ -156,23 +303,28 @@ func getDiff(jirix *jiri.X, snapshot1, snapshot2 string) (*Diff, error) {
 		NewProjects:     make([]DiffProject, 0),
 		DeletedProjects: make([]DiffProject, 0),
 		UpdatedProjects: make([]DiffProject, 0),
+		NewPackages:     make([]DiffPackage, 0),
+		DeletedPackages: make([]DiffPackage, 0),
+		UpdatedPackages: make([]DiffPackage, 0),
 	}
 	oldLogger := jirix.Logger
 	defer func() {
 		jirix.Logger = oldLogger
 	}()
 	jirix.Logger = log.NewLogger(log.NoLogLevel, jirix.Color, false, 0, oldLogger.TimeLogThreshold(), nil, nil)
-	projects1, _, _, err := project.LoadSnapshotFile(jirix, snapshot1)
+	projects1, _, pkgs1, err := project.LoadSnapshotFile(jirix, snapshot1)
 	if err != nil {
 		return nil, err
 	}
-	projects2, _, _, err := project.LoadSnapshotFile(jirix, snapshot2)
+	projects2, _, pkgs2, err := project.LoadSnapshotFile(jirix, snapshot2)
 	if err != nil {
 		return nil, err
 	}
 	project.MatchLocalWithRemote(projects1, projects2)
 	jirix.Logger = oldLogger
 
+	diffPackages(diff, pkgs1, pkgs2, projects2)
+
 	// Get deleted projects
 	for key, p1 := range projects1 {
 		if _, ok := projects2[key]; !ok {
@@ -91,6 +91,90 @@ func setUpSnapshots(t *testing.T, rootDir string) ([]byte, []byte, *Diff) {
 	// rename project
 	i = 3
 	m2.Projects[i].Name = fmt.Sprintf("new-project-%d", i)
+
+	// packages
+	m1.Packages = []project.Package{
+		{
+			Name:    "pkg-deleted",
+			Path:    "prebuilt/deleted",
+			Version: "version:1",
+		},
+		{
+			Name:    "pkg-same",
+			Path:    "prebuilt/same",
+			Version: "version:1",
+			Instances: []project.PackageInstance{
+				{Name: "pkg-same", ID: "id-same"},
+			},
+		},
+		{
+			Name:    "pkg-rolled/${platform}",
+			Path:    "prebuilt/rolled",
+			Version: "git_revision:" + m1.Projects[0].Revision,
+			Instances: []project.PackageInstance{
+				{Name: "pkg-rolled/linux-amd64", ID: "id-linux-1"},
+				{Name: "pkg-rolled/mac-amd64", ID: "id-mac-1"},
+			},
+		},
+		{
+			Name:    "pkg-rebuilt",
+			Path:    "prebuilt/rebuilt",
+			Version: "version:1",
+			Instances: []project.PackageInstance{
+				{Name: "pkg-rebuilt", ID: "id-1"},
+			},
+		},
+	}
+	m2.Packages = []project.Package{
+		m1.Packages[1],
+		{
+			Name:    "pkg-new",
+			Path:    "prebuilt/new",
+			Version: "version:2",
+		},
+		{
+			Name:    "pkg-rolled/${platform}",
+			Path:    "prebuilt/rolled",
+			Version: "git_revision:" + m2.Projects[0].Revision,
+			Instances: []project.PackageInstance{
+				{Name: "pkg-rolled/linux-amd64", ID: "id-linux-2"},
+				{Name: "pkg-rolled/mac-amd64", ID: "id-mac-2"},
+			},
+		},
+		{
+			Name:    "pkg-rebuilt",
+			Path:    "prebuilt/rebuilt",
+			Version: "version:1",
+			Instances: []project.PackageInstance{
+				{Name: "pkg-rebuilt", ID: "id-2"},
+			},
+		},
+	}
+	d.NewPackages = []DiffPackage{
+		{Name: "pkg-new", Path: "prebuilt/new", Version: "version:2"},
+	}
+	d.DeletedPackages = []DiffPackage{
+		{Name: "pkg-deleted", Path: "prebuilt/deleted", Version: "version:1"},
+	}
+	d.UpdatedPackages = []DiffPackage{
+		{
+			Name:          "pkg-rolled/${platform}",
+			Path:          "prebuilt/rolled",
+			Version:       m2.Packages[2].Version,
+			OldVersion:    m1.Packages[2].Version,
+			Instances:     map[string]string{"pkg-rolled/linux-amd64": "id-linux-2", "pkg-rolled/mac-amd64": "id-mac-2"},
+			OldInstances:  map[string]string{"pkg-rolled/linux-amd64": "id-linux-1", "pkg-rolled/mac-amd64": "id-mac-1"},
+			RevisionRange: m1.Projects[0].Revision + ".." + m2.Projects[0].Revision,
+			ChangelogURL:  "remote-url/+log/" + m1.Projects[0].Revision + ".." + m2.Projects[0].Revision,
+		},
+		{
+			Name:         "pkg-rebuilt",
+			Path:         "prebuilt/rebuilt",
+			Version:      "version:1",
+			Instances:    map[string]string{"pkg-rebuilt": "id-2"},
+			OldInstances: map[string]string{"pkg-rebuilt": "id-1"},
+		},
+	}
 	b1, err := m1.ToBytes()
 	if err != nil {
 		t.Fatal(err)
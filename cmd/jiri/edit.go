@@ -37,6 +37,7 @@ var editFlags struct {
 	packages   arrayFlag
 	jsonOutput string
 	editMode   string
+	signingKey string
 }
 
 const (
@@ -61,9 +62,11 @@ type importChanges struct {
 }
 
 type packageChanges struct {
-	Name   string `json:"name"`
-	OldVer string `json:"old_version"`
-	NewVer string `json:"new_version"`
+	Name          string `json:"name"`
+	OldVer        string `json:"old_version"`
+	NewVer        string `json:"new_version"`
+	RevisionRange string `json:"revision_range,omitempty"`
+	ChangelogURL  string `json:"changelog_url,omitempty"`
 }
 
 type editChanges struct {
@@ -106,6 +109,7 @@ func init() {
 	flags.Var(&editFlags.packages, "package", "List of packages to update. It is of form <package-name>=<version>. It can be specified multiple times.")
 	flags.StringVar(&editFlags.jsonOutput, "json-output", "", "File to print changes to, in json format.")
 	flags.StringVar(&editFlags.editMode, "edit-mode", "both", "Edit mode. It can be 'manifest' for updating project revisions in manifest only, 'lockfile' for updating project revisions in lockfile only or 'both' for updating project revisions in both files.")
+	flags.StringVar(&editFlags.signingKey, "sign", "", "Sign the updated lockfiles with this private key, like \"jiri resolve -sign\". Without it, the signatures of the updated lockfiles are removed.")
 }
 
 func runEdit(jirix *jiri.X, args []string) error {
@@ -176,24 +180,9 @@ func writeManifest(jirix *jiri.X, manifestPath, manifestContent string, projects
 		}
 	}
 
-	isLockfileDir := func(jirix *jiri.X, s string) bool {
-		switch s {
-		case "", ".", jirix.Root, string(filepath.Separator):
-			return false
-		}
-		return true
-	}
-
 	if len(projects) != 0 && (editFlags.editMode == lockfile || editFlags.editMode == both) {
 		// Search lockfiles and update
-		dir := manifestPath
-		for ; isLockfileDir(jirix, dir); dir = path.Dir(dir) {
-			lockfile := path.Join(path.Dir(dir), jirix.LockfileName)
-
-			if _, err := os.Stat(lockfile); err != nil {
-				jirix.Logger.Debugf("lockfile could not be accessed at %q due to error %v", lockfile, err)
-				continue
-			}
+		for _, lockfile := range manifestLockfiles(jirix, manifestPath) {
 			if err := updateLocks(jirix, tempDir, lockfile, backup, projects); err != nil {
 				rewind()
 				return err
@@ -205,9 +194,37 @@ func writeManifest(jirix *jiri.X, manifestPath, manifestContent string, projects
 		rewind()
 		return err
 	}
+	for lockfile := range backup {
+		if err := signLockfile(jirix, lockfile, editFlags.signingKey); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// manifestLockfiles returns the lockfiles which exist in the directory of
+// manifestPath and its parents, up to the jiri root.
+func manifestLockfiles(jirix *jiri.X, manifestPath string) []string {
+	isLockfileDir := func(jirix *jiri.X, s string) bool {
+		switch s {
+		case "", ".", jirix.Root, string(filepath.Separator):
+			return false
+		}
+		return true
+	}
+
+	var lockfiles []string
+	for dir := manifestPath; isLockfileDir(jirix, dir); dir = path.Dir(dir) {
+		lockfile := path.Join(path.Dir(dir), jirix.LockfileName)
+		if _, err := os.Stat(lockfile); err != nil {
+			jirix.Logger.Debugf("lockfile could not be accessed at %q due to error %v", lockfile, err)
+			continue
+		}
+		lockfiles = append(lockfiles, lockfile)
+	}
+	return lockfiles
+}
+
 func updateLocks(jirix *jiri.X, tempDir, lockfile string, backup, projects map[string]string) error {
 	jirix.Logger.Debugf("try updating lockfile %q", lockfile)
 	bin, err := ioutil.ReadFile(lockfile)
@@ -387,6 +404,13 @@ func updateManifest(jirix *jiri.X, manifestPath string, projects, imports, packa
 		})
 	}
 
+	remotes := make(map[string]string)
+	for _, p := range m.Projects {
+		remotes[p.Revision] = p.Remote
+	}
+	for _, p := range ec.Projects {
+		remotes[p.NewRev] = p.Remote
+	}
 	for _, p := range m.Packages {
 		newVersion := ""
 		if ver, ok := packages[p.Name]; !ok {
@@ -406,6 +430,7 @@ func updateManifest(jirix *jiri.X, manifestPath string, projects, imports, packa
 		if err != nil {
 			return err
 		}
+		pc.RevisionRange, pc.ChangelogURL = packageChangelog(pc.OldVer, pc.NewVer, remotes)
 		ec.Packages = append(ec.Packages, pc)
 	}
 	if editFlags.jsonOutput != "" {
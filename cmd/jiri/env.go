@@ -0,0 +1,150 @@
+// Copyright 2026 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"go.fuchsia.dev/jiri"
+	"go.fuchsia.dev/jiri/cmdline"
+	"go.fuchsia.dev/jiri/envvar"
+	"go.fuchsia.dev/jiri/project"
+)
+
+var envFlags struct {
+	format        string
+	hermetic      bool
+	localManifest bool
+}
+
+var cmdEnv = &cmdline.Command{
+	Runner: jiri.RunnerFunc(runEnv),
+	Name:   "env",
+	Short:  "Print the environment exported by projects and packages",
+	Long: `
+Prints the environment exported by the <env> elements of the projects and
+packages of the manifest, like
+
+  <package name="fuchsia/third_party/clang/${platform}" path="prebuilt/clang" ...>
+    <env name="PATH" prepend="prebuilt/clang/bin"/>
+  </package>
+
+The paths of "prepend" and "append" are relative to the jiri root. Optional
+projects and packages which are not fetched are left out.
+
+The environment is printed as commands for sh-compatible shells, for fish, or
+as json, depending on the -format flag. For example:
+
+  eval "$(jiri env)"
+
+By default, only the variables changed from the current environment are
+printed. With -hermetic, the environment is computed from an empty one, so
+that PATH only has the paths exported by the manifest.
+`,
+}
+
+func init() {
+	cmdEnv.Flags.StringVar(&envFlags.format, "format", "sh", "Output format, one of sh, fish or json.")
+	cmdEnv.Flags.BoolVar(&envFlags.hermetic, "hermetic", false, "Compute the environment from an empty environment instead of the current one.")
+	cmdEnv.Flags.BoolVar(&envFlags.localManifest, "local-manifest", false, "Use local checked out manifest.")
+}
+
+// manifestEnv returns the environment exported by the projects and packages
+// of the manifest, on top of base.
+func manifestEnv(jirix *jiri.X, base map[string]string, localManifest bool) (*envvar.Vars, error) {
+	localProjects, err := project.LocalProjects(jirix, project.FastScan)
+	if err != nil {
+		return nil, err
+	}
+	var projects project.Projects
+	var pkgs project.Packages
+	if !localManifest {
+		projects, _, pkgs, err = project.LoadUpdatedManifest(jirix, localProjects, localManifest)
+	} else {
+		projects, _, pkgs, err = project.LoadManifestFile(jirix, jirix.JiriManifestFile(), localProjects, localManifest)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := project.FilterOptionalProjectsPackages(jirix, jirix.FetchingAttrs, projects, pkgs); err != nil {
+		return nil, err
+	}
+	vars := envvar.VarsFromMap(base)
+	if err := project.ApplyEnv(jirix, vars, projects, pkgs); err != nil {
+		return nil, err
+	}
+	return vars, nil
+}
+
+func runEnv(jirix *jiri.X, args []string) error {
+	if len(args) != 0 {
+		return jirix.UsageErrorf("unexpected number of arguments")
+	}
+	switch envFlags.format {
+	case "sh", "fish", "json":
+	default:
+		return jirix.UsageErrorf("unknown format %q, it should be one of sh, fish or json", envFlags.format)
+	}
+	base := jirix.Env()
+	if envFlags.hermetic {
+		base = nil
+	}
+	vars, err := manifestEnv(jirix, base, envFlags.localManifest)
+	if err != nil {
+		return err
+	}
+	return writeEnv(jirix.Stdout(), vars.Deltas(), envFlags.format)
+}
+
+// writeEnv writes the variables of deltas to w in format.
+func writeEnv(w io.Writer, deltas map[string]*string, format string) error {
+	if format == "json" {
+		out, err := json.MarshalIndent(deltas, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to serialize JSON output: %s", err)
+		}
+		_, err = fmt.Fprintf(w, "%s\n", out)
+		return err
+	}
+	names := make([]string, 0, len(deltas))
+	for k := range deltas {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := deltas[name]
+		var line string
+		switch {
+		case format == "fish" && value == nil:
+			line = fmt.Sprintf("set -e %s", name)
+		case format == "fish":
+			// fish splits variables whose name ends in PATH on ":".
+			line = fmt.Sprintf("set -gx %s %s", name, quoteFish(*value))
+		case value == nil:
+			line = fmt.Sprintf("unset %s", name)
+		default:
+			line = fmt.Sprintf("export %s=%s", name, quoteShell(*value))
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// quoteShell quotes s for sh-compatible shells.
+func quoteShell(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// quoteFish quotes s for fish.
+func quoteFish(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	return "'" + strings.Replace(s, "'", `\'`, -1) + "'"
+}
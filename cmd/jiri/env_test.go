@@ -0,0 +1,36 @@
+// Copyright 2026 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteEnv(t *testing.T) {
+	path := "/root/prebuilt/clang/bin:/usr/bin"
+	quoted := "it's"
+	deltas := map[string]*string{
+		"PATH":   &path,
+		"QUOTED": &quoted,
+		"GONE":   nil,
+	}
+	tests := []struct {
+		format, want string
+	}{
+		{"sh", "unset GONE\nexport PATH='/root/prebuilt/clang/bin:/usr/bin'\nexport QUOTED='it'\\''s'\n"},
+		{"fish", "set -e GONE\nset -gx PATH '/root/prebuilt/clang/bin:/usr/bin'\nset -gx QUOTED 'it\\'s'\n"},
+		{"json", "{\n  \"GONE\": null,\n  \"PATH\": \"/root/prebuilt/clang/bin:/usr/bin\",\n  \"QUOTED\": \"it's\"\n}\n"},
+	}
+	for _, test := range tests {
+		var buf bytes.Buffer
+		if err := writeEnv(&buf, deltas, test.format); err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.format, got, test.want)
+		}
+	}
+}
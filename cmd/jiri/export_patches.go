@@ -0,0 +1,149 @@
+// Copyright 2026 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"go.fuchsia.dev/jiri"
+	"go.fuchsia.dev/jiri/cmdline"
+	"go.fuchsia.dev/jiri/gitutil"
+	"go.fuchsia.dev/jiri/project"
+)
+
+var exportPatchesFlags struct {
+	projects string
+	branch   string
+}
+
+var cmdExportPatches = &cmdline.Command{
+	Runner: jiri.RunnerFunc(runExportPatches),
+	Name:   "export-patches",
+	Short:  "Export local commits of all projects into a single patch file",
+	Long: `
+Command "export-patches" writes the commits on the current branch of every
+project that are not merged with the remote into a single mailbox file, as
+produced by "git format-patch". The file paths in the patches are relative to
+the jiri root, so the file can be applied to another checkout with
+"jiri patch -file", which routes every file to the right project.
+
+Commits are exported from projects that are on a local branch. The commits are
+the ones between the upstream of the branch, or the remote branch of the
+project if the branch has no upstream, and the branch.
+`,
+	ArgsName: "<file>",
+	ArgsLong: "<file> is the file to write the patches to, or - for stdout.",
+}
+
+func init() {
+	flags := &cmdExportPatches.Flags
+	flags.StringVar(&exportPatchesFlags.projects, "projects", "", "A regular expression specifying project keys to export commits from. By default commits from all projects are exported.")
+	flags.StringVar(&exportPatchesFlags.branch, "branch", "", "Only export commits from projects on this branch.")
+}
+
+func runExportPatches(jirix *jiri.X, args []string) error {
+	if len(args) != 1 {
+		return jirix.UsageErrorf("unexpected number of arguments: expected 1, got %v", len(args))
+	}
+	var projectsRE *regexp.Regexp
+	if exportPatchesFlags.projects != "" {
+		var err error
+		if projectsRE, err = regexp.Compile(exportPatchesFlags.projects); err != nil {
+			return fmt.Errorf("failed to compile regexp %q: %v", exportPatchesFlags.projects, err)
+		}
+	}
+	localProjects, err := project.LocalProjects(jirix, project.FastScan)
+	if err != nil {
+		return err
+	}
+	states, err := project.GetProjectStates(jirix, localProjects, false)
+	if err != nil {
+		return err
+	}
+	var keys project.ProjectKeys
+	for key := range localProjects {
+		if projectsRE == nil || projectsRE.MatchString(key.String()) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return localProjects[keys[i]].Path < localProjects[keys[j]].Path
+	})
+
+	var patches strings.Builder
+	exported := 0
+	for _, key := range keys {
+		local := localProjects[key]
+		state, ok := states[key]
+		if !ok || state.CurrentBranch.Name == "" {
+			continue
+		}
+		if exportPatchesFlags.branch != "" && state.CurrentBranch.Name != exportPatchesFlags.branch {
+			continue
+		}
+		patch, count, err := exportProjectPatches(jirix, local, state.CurrentBranch)
+		if err != nil {
+			return fmt.Errorf("exporting patches for project %s(%s): %v", local.Name, local.Path, err)
+		}
+		if count == 0 {
+			continue
+		}
+		jirix.Logger.Debugf("exported %d commit(s) from project %s(%s)", count, local.Name, local.Path)
+		patches.WriteString(patch)
+		exported += count
+	}
+	if exported == 0 {
+		return fmt.Errorf("no commits to export")
+	}
+	if args[0] == "-" {
+		_, err := fmt.Fprint(jirix.Stdout(), patches.String())
+		return err
+	}
+	if err := ioutil.WriteFile(args[0], []byte(patches.String()), 0644); err != nil {
+		return err
+	}
+	jirix.Logger.Infof("Exported %d commit(s) to %s\n", exported, args[0])
+	return nil
+}
+
+// exportProjectPatches returns the commits on branch that are not merged
+// with the remote in mailbox format, with file paths relative to the jiri
+// root, and the number of exported commits.
+func exportProjectPatches(jirix *jiri.X, local project.Project, branch project.BranchState) (string, int, error) {
+	scm := gitutil.New(jirix, gitutil.RootDirOpt(local.Path))
+	remoteBranch := local.RemoteBranch
+	if remoteBranch == "" {
+		remoteBranch = "master"
+	}
+	base := "remotes/origin/" + remoteBranch
+	if branch.Tracking != nil {
+		base = branch.Tracking.Name
+	}
+	commits, err := scm.ExtraCommits(branch.Name, base)
+	if err != nil {
+		return "", 0, err
+	}
+	if len(commits) == 0 {
+		return "", 0, nil
+	}
+	rel, err := filepath.Rel(jirix.Root, local.Path)
+	if err != nil {
+		return "", 0, err
+	}
+	prefix := ""
+	if rel != "." {
+		prefix = filepath.ToSlash(rel) + "/"
+	}
+	patch, err := scm.FormatPatch(base, branch.Name, "a/"+prefix, "b/"+prefix)
+	if err != nil {
+		return "", 0, err
+	}
+	return patch, len(commits), nil
+}
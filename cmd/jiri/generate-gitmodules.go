@@ -6,8 +6,6 @@ package main
 
 import (
 	"bytes"
-	"crypto/sha256"
-	"encoding/hex"
 	"fmt"
 	"io/ioutil"
 	"path/filepath"
@@ -170,8 +168,7 @@ func makePathRel(basepath, targpath string) (string, error) {
 
 func moduleDecl(p project.Project) string {
 	tmpl := "[submodule \"%s\"]\n\tpath = %s\n\turl = %s"
-	hashBytes := sha256.Sum256([]byte(p.Key().String()))
-	return fmt.Sprintf(tmpl, p.Name+"-"+hex.EncodeToString(hashBytes[:5]), p.Path, p.Remote)
+	return fmt.Sprintf(tmpl, project.SubmoduleName(p), p.Path, p.Remote)
 }
 
 func commandDecl(p project.Project) string {
@@ -26,6 +26,7 @@ var (
 	flagImportRevision   string
 	flagImportList       bool
 	flagImportJsonOutput string
+	flagImportAttributes string
 )
 
 func init() {
@@ -33,6 +34,7 @@ func init() {
 	cmdImport.Flags.StringVar(&flagImportRemoteBranch, "remote-branch", "master", `The branch of the remote manifest project to track, without the leading "origin/".`)
 	cmdImport.Flags.StringVar(&flagImportRevision, "revision", "", `Revision to check out for the remote.`)
 	cmdImport.Flags.StringVar(&flagImportRoot, "root", "", `Root to store the manifest project locally.`)
+	cmdImport.Flags.StringVar(&flagImportAttributes, "attributes", "", `Attributes, or an attribute expression, the import is conditional on. The import is only loaded when they match the attributes set up with "jiri init -fetch-optional".`)
 
 	cmdImport.Flags.BoolVar(&flagImportOverwrite, "overwrite", false, `Write a new .jiri_manifest file with the given specification.  If it already exists, the existing content will be ignored and the file will be overwritten.`)
 	cmdImport.Flags.StringVar(&flagImportOut, "out", "", `The output file.  Uses <root>/.jiri_manifest if unspecified.  Uses stdout if set to "-".`)
@@ -212,6 +214,7 @@ func runImport(jirix *jiri.X, args []string) error {
 			RemoteBranch: flagImportRemoteBranch,
 			Revision:     flagImportRevision,
 			Root:         flagImportRoot,
+			Attributes:   flagImportAttributes,
 		})
 	}
 
@@ -8,13 +8,18 @@ import (
 	"crypto/rand"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 
 	"go.fuchsia.dev/jiri"
 	"go.fuchsia.dev/jiri/analytics_util"
+	"go.fuchsia.dev/jiri/cipd"
 	"go.fuchsia.dev/jiri/cmdline"
+	"go.fuchsia.dev/jiri/project"
+	"go.fuchsia.dev/jiri/sshsig"
 )
 
 var cmdInit = &cmdline.Command{
@@ -41,6 +46,7 @@ var (
 	analyticsOptFlag      string
 	rewriteSsoToHttpsFlag string
 	ssoCookieFlag         string
+	tokenCommandFlag      string
 	keepGitHooks          string
 	enableLockfileFlag    string
 	lockfileNameFlag      string
@@ -51,10 +57,15 @@ var (
 	offloadPackfilesFlag  bool
 	cipdParanoidFlag      string
 	cipdMaxThreads        int
+	cipdNativeFlag        string
+	targetPlatformsFlag   string
+	sharedPackagesFlag    string
+	trustedKeysFlag       string
 )
 
 const (
-	optionalAttrsNotSet = "[ATTRIBUTES_NOT_SET]"
+	optionalAttrsNotSet   = "[ATTRIBUTES_NOT_SET]"
+	targetPlatformsNotSet = "[PLATFORMS_NOT_SET]"
 )
 
 func init() {
@@ -64,19 +75,24 @@ func init() {
 	cmdInit.Flags.StringVar(&analyticsOptFlag, "analytics-opt", "", "Opt in/out of analytics collection. Takes true/false")
 	cmdInit.Flags.StringVar(&rewriteSsoToHttpsFlag, "rewrite-sso-to-https", "", "Rewrites sso fetches, clones, etc to https. Takes true/false.")
 	cmdInit.Flags.StringVar(&ssoCookieFlag, "sso-cookie-path", "", "Path to master SSO cookie file.")
+	cmdInit.Flags.StringVar(&tokenCommandFlag, "token-command", "", "Command that prints an OAuth token for a Gerrit host. The host name is passed as the last argument. Arguments can be quoted like in a shell.")
 	cmdInit.Flags.StringVar(&keepGitHooks, "keep-git-hooks", "", "Whether to keep current git hooks in '.git/hooks' when doing 'jiri update'. Takes true/false.")
 	cmdInit.Flags.StringVar(&enableLockfileFlag, "enable-lockfile", "", "Enable lockfile enforcement")
 	cmdInit.Flags.StringVar(&lockfileNameFlag, "lockfile-name", "", "Set up filename of lockfile")
 	cmdInit.Flags.StringVar(&prebuiltJSON, "prebuilt-json", "", "Set up filename for prebuilt json file")
 	// Empty string is not used as default value for optionalAttrs as we
 	// use empty string to clear existing saved attributes.
-	cmdInit.Flags.StringVar(&optionalAttrs, "fetch-optional", optionalAttrsNotSet, "Set up attributes of optional projects and packages that should be fetched by jiri.")
+	cmdInit.Flags.StringVar(&optionalAttrs, "fetch-optional", optionalAttrsNotSet, "Set up attributes of optional projects and packages that should be fetched by jiri, as a comma separated list or an attribute expression like 'sdk && !internal'. Use 'jiri attributes' to see which ones are fetched.")
 	cmdInit.Flags.BoolVar(&partialFlag, "partial", false, "Whether to use a partial checkout.")
 	cmdInit.Flags.Var(&partialSkipFlag, "skip-partial", "Skip using partial checkouts for these remotes.")
 	cmdInit.Flags.BoolVar(&offloadPackfilesFlag, "offload-packfiles", true, "Whether to use a CDN for packfiles if available.")
 	cmdInit.Flags.StringVar(&cipdParanoidFlag, "cipd-paranoid-mode", "", "Whether to use paranoid mode in cipd.")
 	// Default (0) causes CIPD to use as many threads as there are CPUs.
 	cmdInit.Flags.IntVar(&cipdMaxThreads, "cipd-max-threads", 0, "Number of threads to use for unpacking CIPD packages. If zero, uses all CPUs.")
+	cmdInit.Flags.StringVar(&targetPlatformsFlag, "target-platforms", targetPlatformsNotSet, "Comma separated list of platforms, like linux-arm64,mac-amd64, which packages are also fetched for. Packages for a target platform are installed to the paths expanded for that platform.")
+	cmdInit.Flags.StringVar(&sharedPackagesFlag, "shared-packages", "", "Whether to install packages from a content store in the cache directory, shared with other jiri roots, through hardlinks. Files of shared packages are read-only. Requires -cipd-native, the cipd binary does not use the content store. Takes true/false.")
+	cmdInit.Flags.StringVar(&trustedKeysFlag, "trusted-lockfile-keys", "", "File with the public keys, in the authorized_keys format, which lockfiles must be signed with. Only ed25519 keys are supported. An empty file disables lockfile signature checks.")
+	cmdInit.Flags.StringVar(&cipdNativeFlag, "cipd-native", "", "Whether to talk to the CIPD backend directly instead of running the cipd binary. Takes true/false.")
 }
 
 func runInit(env *cmdline.Env, args []string) error {
@@ -165,6 +181,9 @@ func runInit(env *cmdline.Env, args []string) error {
 	}
 
 	if optionalAttrs != optionalAttrsNotSet {
+		if err := project.CheckFetchingAttributes(optionalAttrs); err != nil {
+			return err
+		}
 		config.FetchingAttrs = optionalAttrs
 	}
 
@@ -184,6 +203,10 @@ func runInit(env *cmdline.Env, args []string) error {
 		config.SsoCookiePath = ssoCookieFlag
 	}
 
+	if tokenCommandFlag != "" {
+		config.TokenCommand = tokenCommandFlag
+	}
+
 	if lockfileNameFlag != "" {
 		config.LockfileName = lockfileNameFlag
 	}
@@ -208,6 +231,57 @@ func runInit(env *cmdline.Env, args []string) error {
 
 	config.CipdMaxThreads = cipdMaxThreads
 
+	if cipdNativeFlag != "" {
+		if val, err := strconv.ParseBool(cipdNativeFlag); err != nil {
+			return fmt.Errorf("'cipd-native' flag should be true or false")
+		} else {
+			config.CipdNative = val
+		}
+	}
+
+	if sharedPackagesFlag != "" {
+		if val, err := strconv.ParseBool(sharedPackagesFlag); err != nil {
+			return fmt.Errorf("'shared-packages' flag should be true or false")
+		} else {
+			config.SharedPackages = val
+		}
+	}
+	// Only the native CIPD client installs packages from the content store,
+	// the cipd binary has its own cache.
+	if config.SharedPackages && !config.CipdNative {
+		return fmt.Errorf("'shared-packages' flag requires the native CIPD client, set up with 'cipd-native'")
+	}
+
+	if targetPlatformsFlag != targetPlatformsNotSet {
+		for _, plat := range strings.Split(targetPlatformsFlag, ",") {
+			if plat == "" {
+				continue
+			}
+			if _, err := cipd.NewPlatform(plat); err != nil {
+				return fmt.Errorf("invalid target platform %q: %v", plat, err)
+			}
+		}
+		config.TargetPlatforms = targetPlatformsFlag
+	}
+
+	if trustedKeysFlag != "" {
+		data, err := ioutil.ReadFile(trustedKeysFlag)
+		if err != nil {
+			return err
+		}
+		config.TrustedLockfileKeys = nil
+		for _, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			if _, err := sshsig.ParsePublicKey(line); err != nil {
+				return fmt.Errorf("invalid key in %q: %v", trustedKeysFlag, err)
+			}
+			config.TrustedLockfileKeys = append(config.TrustedLockfileKeys, line)
+		}
+	}
+
 	if analyticsOptFlag != "" {
 		if val, err := strconv.ParseBool(analyticsOptFlag); err != nil {
 			return fmt.Errorf("'analytics-opt' flag should be true or false")
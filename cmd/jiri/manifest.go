@@ -45,8 +45,25 @@ var cmdManifest = &cmdline.Command{
 
 	    Read packages's 'version' attribute:
 	        manifest -element=$PACKAGE_NAME -template="{{.Version}}"
+
+	The "jiri manifest import-repo [-manifest-url <url>] [-output <file>]
+	<default.xml>" command converts a manifest of the repo tool to a jiri
+	manifest. Included manifests are read from the directory of
+	<default.xml>, and relative fetch URLs of remotes are resolved against
+	-manifest-url, the URL of the manifest repository. Groups of projects
+	which are not in the default group are mapped to attributes.
+
+	The "jiri manifest import-deps [-output <file>] <DEPS>" command converts
+	a DEPS file of gclient to a jiri manifest. Only DEPS files which assign
+	literals to variables are supported. Git dependencies are mapped to
+	projects, cipd dependencies to packages, and conditions to attributes.
+
+	Both commands write the manifest to stdout unless -output is set, and
+	report the constructs which could not be mapped on stderr. A manifest
+	file named import-repo or import-deps must be given as a path, like
+	./import-repo.
 	`,
-	ArgsName: "<manifest>",
+	ArgsName: "<manifest> | import-repo <default.xml> | import-deps <DEPS>",
 	ArgsLong: "<manifest> is the manifest file.",
 }
 
@@ -62,6 +79,9 @@ func setManifestFlags(f *flag.FlagSet) {
 
 // Run executes the ManifestCommand.
 func runManifest(jirix *jiri.X, args []string) error {
+	if len(args) > 0 && (args[0] == "import-repo" || args[0] == "import-deps") {
+		return importManifest(jirix, args[0], args[1:])
+	}
 	if len(args) != 1 {
 		return jirix.UsageErrorf("Wrong number of args")
 	}
@@ -115,3 +135,46 @@ func readManifest(jirix *jiri.X, manifestPath string, tmpl *template.Template) e
 	// Found nothing.
 	return fmt.Errorf("found no project/import/package named %s", manifestFlags.ElementName)
 }
+
+// importManifest converts a manifest of another tool to a jiri manifest.
+func importManifest(jirix *jiri.X, verb string, args []string) error {
+	flags := flag.NewFlagSet(verb, flag.ContinueOnError)
+	flags.SetOutput(jirix.Stderr())
+	output := flags.String("output", "", "Write the manifest to this file instead of stdout.")
+	var manifestURL *string
+	if verb == "import-repo" {
+		manifestURL = flags.String("manifest-url", "", "The URL of the manifest repository, which relative fetch URLs are resolved against.")
+	}
+	if err := flags.Parse(args); err != nil {
+		return jirix.UsageErrorf("%v", err)
+	}
+	if flags.NArg() != 1 {
+		return jirix.UsageErrorf("%s expects a single file to convert, got %d arguments", verb, flags.NArg())
+	}
+	var m *project.Manifest
+	var unmapped []string
+	var err error
+	if verb == "import-repo" {
+		m, unmapped, err = project.ImportRepoManifest(flags.Arg(0), *manifestURL)
+	} else {
+		m, unmapped, err = project.ImportDEPS(flags.Arg(0))
+	}
+	if err != nil {
+		return err
+	}
+	if len(unmapped) != 0 {
+		fmt.Fprintf(jirix.Stderr(), "The following constructs of %s could not be mapped:\n", flags.Arg(0))
+		for _, u := range unmapped {
+			fmt.Fprintf(jirix.Stderr(), "  %s\n", u)
+		}
+	}
+	if *output != "" {
+		return m.ToFile(jirix, *output)
+	}
+	data, err := m.ToBytes()
+	if err != nil {
+		return err
+	}
+	_, err = jirix.Stdout().Write(data)
+	return err
+}
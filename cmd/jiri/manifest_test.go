@@ -5,13 +5,16 @@
 package main
 
 import (
+	"bytes"
 	"flag"
 	"io/ioutil"
+	"path/filepath"
 	"strings"
 	"testing"
 
 	"go.fuchsia.dev/jiri/cipd"
 	"go.fuchsia.dev/jiri/jiritest"
+	"go.fuchsia.dev/jiri/tool"
 )
 
 func TestManifest(t *testing.T) {
@@ -260,3 +263,42 @@ func TestManifest(t *testing.T) {
 			"false")
 	})
 }
+
+// TestManifestImport tests converting a DEPS file with "jiri manifest
+// import-deps".
+func TestManifestImport(t *testing.T) {
+	fake, cleanup := jiritest.NewFakeJiriRoot(t)
+	defer cleanup()
+	var stdout, stderr bytes.Buffer
+	fake.X.Context = tool.NewContext(tool.ContextOpts{Stdout: &stdout, Stderr: &stderr, Env: fake.X.Context.Env()})
+
+	// The file to convert is required.
+	for _, verb := range []string{"import-repo", "import-deps"} {
+		if err := runManifest(fake.X, []string{verb}); err == nil || !strings.Contains(err.Error(), "expects a single file") {
+			t.Errorf("%s without a file: got error %v", verb, err)
+		}
+	}
+
+	deps := filepath.Join(t.TempDir(), "DEPS")
+	if err := ioutil.WriteFile(deps, []byte(`deps = {
+  'third_party/foo': 'https://example.com/foo.git@abcdef0123456789abcdef0123456789abcdef01',
+}
+hooks = [
+  {
+    'name': 'sysroot',
+    'action': ['python3', 'build.py'],
+  },
+]
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := runManifest(fake.X, []string{"import-deps", deps}); err != nil {
+		t.Fatal(err)
+	}
+	if got := stdout.String(); !strings.Contains(got, `remote="https://example.com/foo.git"`) {
+		t.Errorf("the manifest should be written to stdout, got %q", got)
+	}
+	if got := stderr.String(); !strings.Contains(got, "could not be mapped") {
+		t.Errorf("the unmapped constructs should be reported on stderr, got %q", got)
+	}
+}
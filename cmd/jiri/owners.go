@@ -0,0 +1,130 @@
+// Copyright 2026 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"go.fuchsia.dev/jiri"
+	"go.fuchsia.dev/jiri/cmdline"
+	"go.fuchsia.dev/jiri/gitutil"
+	"go.fuchsia.dev/jiri/owners"
+	"go.fuchsia.dev/jiri/project"
+)
+
+var ownersFlags struct {
+	suggestFlag bool
+	filesFlag   bool
+}
+
+var cmdOwners = &cmdline.Command{
+	Runner: jiri.RunnerFunc(runOwners),
+	Name:   "owners",
+	Short:  "Show the owners of files from OWNERS files",
+	Long: `
+Command "owners" prints the owners of the given files, closest first, as found
+in the OWNERS files of the file's directory and its parents. The owners are
+resolved the same way as when "jiri upload" suggests reviewers.
+
+OWNERS files support "per-file" and "include" lines. An included file is
+relative to the OWNERS file, relative to the jiri root if it starts with "/",
+or relative to the root of another project if it has the form
+"<project>:<file>".
+`,
+	ArgsName: "<path> ...",
+	ArgsLong: "<path> is a file or directory in the jiri root.",
+}
+
+func init() {
+	cmdOwners.Flags.BoolVar(&ownersFlags.suggestFlag, "suggest", false, "Print a small set of owners which together own all of the given files.")
+	cmdOwners.Flags.BoolVar(&ownersFlags.filesFlag, "files", false, "Also print the OWNERS files which were consulted.")
+}
+
+// newOwnersResolver returns an OWNERS resolver for the jiri root, which
+// resolves includes of files in other projects by project name.
+func newOwnersResolver(jirix *jiri.X, localProjects project.Projects) *owners.Resolver {
+	paths := make(map[string]string)
+	for _, p := range localProjects {
+		if _, ok := paths[p.Name]; ok {
+			// The project name is ambiguous.
+			paths[p.Name] = ""
+			continue
+		}
+		paths[p.Name] = p.Path
+	}
+	return owners.NewResolver(jirix.Root, paths)
+}
+
+func runOwners(jirix *jiri.X, args []string) error {
+	if len(args) == 0 {
+		return jirix.UsageErrorf("must specify at least one path")
+	}
+	localProjects, err := project.LocalProjects(jirix, project.FastScan)
+	if err != nil {
+		return err
+	}
+	r := newOwnersResolver(jirix, localProjects)
+	if ownersFlags.suggestFlag {
+		suggested, err := r.SuggestReviewers(args, nil, nil)
+		if err != nil {
+			return err
+		}
+		for _, s := range suggested {
+			fmt.Fprintln(jirix.Stdout(), s)
+		}
+		return nil
+	}
+	for _, arg := range args {
+		o, err := r.Owners(arg)
+		if err != nil {
+			return err
+		}
+		if len(args) > 1 {
+			fmt.Fprintf(jirix.Stdout(), "%s:\n", arg)
+		}
+		if o.Anyone {
+			fmt.Fprintln(jirix.Stdout(), "*")
+		}
+		for _, owner := range o.Owners {
+			fmt.Fprintln(jirix.Stdout(), owner)
+		}
+		if ownersFlags.filesFlag {
+			var files []string
+			for _, f := range o.Files {
+				if rel, err := filepath.Rel(jirix.Root, f); err == nil {
+					f = rel
+				}
+				files = append(files, f)
+			}
+			fmt.Fprintf(jirix.Stdout(), "from: %s\n", strings.Join(files, ", "))
+		}
+		if len(args) > 1 {
+			fmt.Fprintln(jirix.Stdout())
+		}
+	}
+	return nil
+}
+
+// ownersReviewers returns a small set of owners of the files modified by
+// the commits between remoteBranch and ref in project p, leaving out the
+// given reviewers and the user.
+func ownersReviewers(jirix *jiri.X, r *owners.Resolver, p project.Project, remoteBranch, ref string, reviewers []string) ([]string, error) {
+	scm := gitutil.New(jirix, gitutil.RootDirOpt(p.Path))
+	files, err := scm.ModifiedFiles("remotes/origin/"+remoteBranch, ref)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, f := range files {
+		paths = append(paths, filepath.Join(p.Path, f))
+	}
+	var exclude []string
+	if email, err := scm.ConfigGetKey("user.email"); err == nil && email != "" {
+		exclude = append(exclude, email)
+	}
+	return r.SuggestReviewers(paths, reviewers, exclude)
+}
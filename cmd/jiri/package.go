@@ -18,10 +18,17 @@ import (
 	"go.fuchsia.dev/jiri/project"
 )
 
-// cmd represents the "jiri project" command.
+// cmdPackage represents the "jiri package" command.
 var cmdPackage = &cmdline.Command{
+	Name:     "package",
+	Short:    "Display, verify, roll and prune the jiri packages",
+	Long:     "Display, verify, roll and prune the jiri packages.",
+	Children: []*cmdline.Command{cmdPackageInfo, cmdPackageVerify, cmdPackageRoll, cmdPackagePrune},
+}
+
+var cmdPackageInfo = &cmdline.Command{
 	Runner: jiri.RunnerFunc(runPackageInfo),
-	Name:   "package",
+	Name:   "info",
 	Short:  "Display the jiri packages",
 	Long: `Display structured info on the existing
 	packages and branches. Packages are specified using either names or	regular
@@ -41,24 +48,15 @@ type packageInfoOutput struct {
 }
 
 func init() {
-	cmdPackage.Flags.StringVar(&jsonOutputFlag, "json-output", "", "Path to write operation results to.")
-	cmdPackage.Flags.BoolVar(&regexpFlag, "regexp", false, "Use argument as regular expression.")
+	cmdPackageInfo.Flags.StringVar(&jsonOutputFlag, "json-output", "", "Path to write operation results to.")
+	cmdPackageInfo.Flags.BoolVar(&regexpFlag, "regexp", false, "Use argument as regular expression.")
 }
 
 // runPackageInfo provides structured info on packages.
 func runPackageInfo(jirix *jiri.X, args []string) error {
-	var err error
-
-	regexps := make([]*regexp.Regexp, 0)
-	for _, arg := range args {
-		if !regexpFlag {
-			arg = "^" + regexp.QuoteMeta(arg) + "$"
-		}
-		if re, err := regexp.Compile(arg); err != nil {
-			return fmt.Errorf("failed to compile regexp %v: %v", arg, err)
-		} else {
-			regexps = append(regexps, re)
-		}
+	regexps, err := packageRegexps(args)
+	if err != nil {
+		return err
 	}
 
 	projects, err := project.LocalProjects(jirix, project.FastScan)
@@ -137,3 +135,20 @@ func runPackageInfo(jirix *jiri.X, args []string) error {
 
 	return nil
 }
+
+// packageRegexps returns the regular expressions matching the package names
+// in args.
+func packageRegexps(args []string) ([]*regexp.Regexp, error) {
+	regexps := make([]*regexp.Regexp, 0)
+	for _, arg := range args {
+		if !regexpFlag {
+			arg = "^" + regexp.QuoteMeta(arg) + "$"
+		}
+		if re, err := regexp.Compile(arg); err != nil {
+			return nil, fmt.Errorf("failed to compile regexp %v: %v", arg, err)
+		} else {
+			regexps = append(regexps, re)
+		}
+	}
+	return regexps, nil
+}
@@ -0,0 +1,40 @@
+// Copyright 2026 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+
+	"go.fuchsia.dev/jiri"
+	"go.fuchsia.dev/jiri/cmdline"
+	"go.fuchsia.dev/jiri/pkgstore"
+)
+
+var cmdPackagePrune = &cmdline.Command{
+	Runner: jiri.RunnerFunc(runPackagePrune),
+	Name:   "prune",
+	Short:  "Remove unused package instances from the shared package store",
+	Long: `
+Removes the package instances which no jiri root uses anymore from the
+package store shared between jiri roots, see "jiri init -shared-packages".
+`,
+}
+
+// runPackagePrune removes unused instances from the package store.
+func runPackagePrune(jirix *jiri.X, args []string) error {
+	store := pkgstore.FromX(jirix)
+	if store == nil {
+		return fmt.Errorf("this jiri root does not share packages, run \"jiri init -shared-packages=true\" to share them")
+	}
+	removed, err := store.Prune()
+	if err != nil {
+		return err
+	}
+	for _, id := range removed {
+		fmt.Fprintf(jirix.Stdout(), "Removed %s\n", id)
+	}
+	fmt.Fprintf(jirix.Stdout(), "Removed %d unused package instance(s)\n", len(removed))
+	return nil
+}
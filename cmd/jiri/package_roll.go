@@ -0,0 +1,286 @@
+// Copyright 2026 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"go.fuchsia.dev/jiri"
+	"go.fuchsia.dev/jiri/cipd"
+	"go.fuchsia.dev/jiri/cmdline"
+	"go.fuchsia.dev/jiri/project"
+)
+
+var packageRollFlags struct {
+	ref        string
+	signingKey string
+}
+
+var cmdPackageRoll = &cmdline.Command{
+	Runner: jiri.RunnerFunc(runPackageRoll),
+	Name:   "roll",
+	Short:  "Roll packages to the version a ref resolves to",
+	Long: `
+Resolves -ref to the version of each package and rewrites the version in the
+manifest declaring the package, along with the package locks in the
+lockfiles next to that manifest and its parent directories. A ref which is a
+tag, like "git_revision:<sha>", is used as the version. Otherwise the
+version is the tag of the instance which the ref points to that has the same
+key as the current version, like "git_revision". The changes are reported in
+the JSON format of "jiri edit".
+`,
+	ArgsName: "<package ...>",
+	ArgsLong: "<package ...> is a list of packages to roll.",
+}
+
+func init() {
+	cmdPackageRoll.Flags.StringVar(&packageRollFlags.ref, "ref", "latest", "Ref or tag to roll the packages to.")
+	cmdPackageRoll.Flags.BoolVar(&regexpFlag, "regexp", false, "Use argument as regular expression.")
+	cmdPackageRoll.Flags.StringVar(&jsonOutputFlag, "json-output", "", "Path to write the changes to, in the JSON format of 'jiri edit'.")
+	cmdPackageRoll.Flags.StringVar(&packageRollFlags.signingKey, "sign", "", "Sign the updated lockfiles with this private key, like \"jiri resolve -sign\". Without it, the signatures of the updated lockfiles are removed.")
+}
+
+// rollVersion returns the version which package pkg is rolled to when ref
+// is resolved to desc: ref itself if it is a tag, or else the tag of desc
+// with the same key as the current version of pkg. Packages whose name does
+// not use templates can be rolled to the instance id of desc.
+func rollVersion(pkg project.Package, ref string, desc *cipd.Description) (string, error) {
+	if strings.Contains(ref, ":") {
+		return ref, nil
+	}
+	var candidates []string
+	if i := strings.Index(pkg.Version, ":"); i > 0 {
+		prefix := pkg.Version[:i+1]
+		for _, tag := range desc.Tags {
+			if strings.HasPrefix(tag, prefix) {
+				candidates = append(candidates, tag)
+			}
+		}
+	}
+	switch {
+	case len(candidates) == 1:
+		return candidates[0], nil
+	case len(candidates) > 1:
+		sort.Strings(candidates)
+		return "", fmt.Errorf("ref %q of package %q has several candidate tags: %s. Use -ref <tag>", ref, pkg.Name, strings.Join(candidates, ", "))
+	case !cipd.MustExpand(pkg.Name):
+		return desc.InstanceID, nil
+	}
+	return "", fmt.Errorf("ref %q of package %q has no tag like %q. Use -ref <tag>", ref, pkg.Name, pkg.Version)
+}
+
+// packageLockNames returns the names of the package locks of pkg, which are
+// its name expanded for each of its platforms.
+func packageLockNames(pkg project.Package) (map[string]bool, error) {
+	plats, err := pkg.GetPlatforms()
+	if err != nil {
+		return nil, err
+	}
+	expanded, err := cipd.Expand(pkg.Name, plats)
+	if err != nil {
+		return nil, err
+	}
+	names := map[string]bool{pkg.Name: true}
+	for _, name := range expanded {
+		names[name] = true
+	}
+	return names, nil
+}
+
+// rollPackageLocks replaces the locks of the old version of each rolled
+// package in pkgLocks with the locks in newLocks. rolled maps the keys of
+// the rolled packages, with their new version, to their old version. It
+// returns true if pkgLocks changed.
+func rollPackageLocks(pkgLocks, newLocks project.PackageLocks, rolled map[project.PackageKey]string, pkgs project.Packages) (bool, error) {
+	changed := false
+	for key, oldVersion := range rolled {
+		pkg := pkgs[key]
+		names, err := packageLockNames(pkg)
+		if err != nil {
+			return false, err
+		}
+		found := false
+		for k, v := range pkgLocks {
+			if v.VersionTag == oldVersion && names[v.PackageName] {
+				delete(pkgLocks, k)
+				found = true
+			}
+		}
+		if !found {
+			continue
+		}
+		for k, v := range newLocks {
+			if v.VersionTag == pkg.Version && names[v.PackageName] {
+				pkgLocks[k] = v
+			}
+		}
+		changed = true
+	}
+	return changed, nil
+}
+
+// runPackageRoll rolls packages to the version a ref resolves to.
+func runPackageRoll(jirix *jiri.X, args []string) error {
+	if len(args) == 0 {
+		return jirix.UsageErrorf("must specify at least one package to roll")
+	}
+	regexps, err := packageRegexps(args)
+	if err != nil {
+		return err
+	}
+
+	projects, err := project.LocalProjects(jirix, project.FastScan)
+	if err != nil {
+		return err
+	}
+	_, _, pkgs, err := project.LoadManifestFile(jirix, jirix.JiriManifestFile(), projects, true)
+	if err != nil {
+		return err
+	}
+	remotes := make(map[string]string)
+	for _, p := range projects {
+		remotes[p.Revision] = p.Remote
+	}
+
+	var keys project.PackageKeys
+	for k, v := range pkgs {
+		for _, re := range regexps {
+			if re.MatchString(v.Name) {
+				keys = append(keys, k)
+				break
+			}
+		}
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("no packages match %s", strings.Join(args, ", "))
+	}
+	sort.Sort(keys)
+
+	ec := &editChanges{
+		Projects: []projectChanges{},
+		Imports:  []importChanges{},
+		Packages: []packageChanges{},
+	}
+	rolled := make(map[project.PackageKey]string)
+	rolledPkgs := make(project.Packages)
+	manifests := make(map[string][]packageChanges)
+	for _, key := range keys {
+		pkg := pkgs[key]
+		if pkg.BackendName() != project.CipdPackageBackend {
+			return fmt.Errorf("package %q uses the %q backend, only %q packages can be rolled", pkg.Name, pkg.BackendName(), project.CipdPackageBackend)
+		}
+		plats, err := pkg.GetPlatforms()
+		if err != nil {
+			return err
+		}
+		names, err := cipd.Expand(pkg.Name, append([]cipd.Platform{cipd.CipdPlatform}, plats...))
+		if err != nil {
+			return err
+		}
+		if len(names) == 0 {
+			return fmt.Errorf("package %q is not available for any platform", pkg.Name)
+		}
+		desc, err := cipd.Describe(jirix, names[0], packageRollFlags.ref)
+		if err != nil {
+			return err
+		}
+		version, err := rollVersion(pkg, packageRollFlags.ref, desc)
+		if err != nil {
+			return err
+		}
+		if version == pkg.Version {
+			fmt.Fprintf(jirix.Stdout(), "%s: already at %s\n", pkg.Name, version)
+			continue
+		}
+		pc := packageChanges{
+			Name:   pkg.Name,
+			OldVer: pkg.Version,
+			NewVer: version,
+		}
+		pc.RevisionRange, pc.ChangelogURL = packageChangelog(pc.OldVer, pc.NewVer, remotes)
+		fmt.Fprintf(jirix.Stdout(), "%s: %s -> %s\n", pkg.Name, pc.OldVer, pc.NewVer)
+		ec.Packages = append(ec.Packages, pc)
+		manifests[pkg.ManifestPath] = append(manifests[pkg.ManifestPath], pc)
+		rolled[key] = pkg.Version
+		pkg.Version = version
+		pkg.Instances = nil
+		rolledPkgs[key] = pkg
+	}
+
+	if len(rolled) != 0 {
+		newLocks, err := project.ResolvePackageLocks(jirix, rolledPkgs)
+		if err != nil {
+			return err
+		}
+		// Compute all of the changes before writing any file.
+		files := make(map[string][]byte)
+		for manifestPath, changes := range manifests {
+			content, err := ioutil.ReadFile(manifestPath)
+			if err != nil {
+				return err
+			}
+			manifestContent := string(content)
+			for _, pc := range changes {
+				if manifestContent, err = updateVersion(manifestContent, "package", pc); err != nil {
+					return fmt.Errorf("failed to update %q: %v", manifestPath, err)
+				}
+			}
+			files[manifestPath] = []byte(manifestContent)
+			for _, lockfile := range manifestLockfiles(jirix, manifestPath) {
+				if _, ok := files[lockfile]; ok {
+					continue
+				}
+				data, err := ioutil.ReadFile(lockfile)
+				if err != nil {
+					return err
+				}
+				projectLocks, pkgLocks, err := project.UnmarshalLockEntries(data)
+				if err != nil {
+					return err
+				}
+				changed, err := rollPackageLocks(pkgLocks, newLocks, rolled, rolledPkgs)
+				if err != nil {
+					return err
+				}
+				if !changed {
+					jirix.Logger.Debugf("skipped lockfile %q, no matching packages", lockfile)
+					continue
+				}
+				if files[lockfile], err = project.MarshalLockEntries(projectLocks, pkgLocks); err != nil {
+					return err
+				}
+			}
+		}
+		for file, data := range files {
+			info, err := os.Stat(file)
+			if err != nil {
+				return err
+			}
+			if err := ioutil.WriteFile(file, data, info.Mode()); err != nil {
+				return err
+			}
+			jirix.Logger.Debugf("updated %q", file)
+		}
+		for file := range files {
+			if _, ok := manifests[file]; ok {
+				continue
+			}
+			if err := signLockfile(jirix, file, packageRollFlags.signingKey); err != nil {
+				return err
+			}
+		}
+	}
+
+	if jsonOutputFlag != "" {
+		if err := ec.toFile(jsonOutputFlag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+// Copyright 2026 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+
+	"go.fuchsia.dev/jiri/cipd"
+	"go.fuchsia.dev/jiri/project"
+)
+
+func TestRollVersion(t *testing.T) {
+	desc := &cipd.Description{
+		Package:    "gn/gn/linux-amd64",
+		InstanceID: "instance-id",
+		Refs:       []string{"latest"},
+		Tags:       []string{"git_repository:https://gn.googlesource.com/gn", "git_revision:ffff"},
+	}
+	tests := []struct {
+		name, version, ref string
+		want               string
+		wantErr            bool
+	}{
+		// The tag with the key of the current version is used.
+		{"gn/gn/${platform}", "git_revision:aaaa", "latest", "git_revision:ffff", false},
+		// A tag ref is used as is.
+		{"gn/gn/${platform}", "git_revision:aaaa", "version:2", "version:2", false},
+		// Templated packages can't be rolled to an instance id.
+		{"gn/gn/${platform}", "version:1", "latest", "", true},
+		{"gn/gn/linux-amd64", "version:1", "latest", "instance-id", false},
+	}
+	for _, test := range tests {
+		pkg := project.Package{Name: test.name, Version: test.version}
+		got, err := rollVersion(pkg, test.ref, desc)
+		if (err != nil) != test.wantErr {
+			t.Errorf("rollVersion(%q, %q, %q): unexpected error %v", test.name, test.version, test.ref, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("rollVersion(%q, %q, %q): got %q, want %q", test.name, test.version, test.ref, got, test.want)
+		}
+	}
+
+	desc.Tags = append(desc.Tags, "git_revision:eeee")
+	if _, err := rollVersion(project.Package{Name: "gn/gn/${platform}", Version: "git_revision:aaaa"}, "latest", desc); err == nil {
+		t.Errorf("expected an error for several candidate tags")
+	}
+}
+
+func TestRollPackageLocks(t *testing.T) {
+	lock := func(name, version, id string) project.PackageLock {
+		return project.PackageLock{PackageName: name, VersionTag: version, InstanceID: id}
+	}
+	locks := func(l ...project.PackageLock) project.PackageLocks {
+		m := make(project.PackageLocks)
+		for _, v := range l {
+			m[v.Key()] = v
+		}
+		return m
+	}
+	pkg := project.Package{
+		Name:      "gn/gn/${platform}",
+		Version:   "git_revision:bbbb",
+		Platforms: "linux-amd64,mac-amd64",
+	}
+	pkgs := project.Packages{pkg.Key(): pkg}
+	rolled := map[project.PackageKey]string{pkg.Key(): "git_revision:aaaa"}
+	other := lock("other", "git_revision:aaaa", "other-id")
+	pkgLocks := locks(
+		lock("gn/gn/linux-amd64", "git_revision:aaaa", "linux-1"),
+		lock("gn/gn/mac-amd64", "git_revision:aaaa", "mac-1"),
+		other,
+	)
+	newLocks := locks(
+		lock("gn/gn/linux-amd64", "git_revision:bbbb", "linux-2"),
+		lock("gn/gn/mac-amd64", "git_revision:bbbb", "mac-2"),
+	)
+	changed, err := rollPackageLocks(pkgLocks, newLocks, rolled, pkgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed {
+		t.Fatalf("expected the locks to change")
+	}
+	want := locks(
+		lock("gn/gn/linux-amd64", "git_revision:bbbb", "linux-2"),
+		lock("gn/gn/mac-amd64", "git_revision:bbbb", "mac-2"),
+		other,
+	)
+	if len(pkgLocks) != len(want) {
+		t.Fatalf("got locks %+v, want %+v", pkgLocks, want)
+	}
+	for k, v := range want {
+		if pkgLocks[k] != v {
+			t.Errorf("got lock %+v, want %+v", pkgLocks[k], v)
+		}
+	}
+
+	// Lockfiles without the package are left alone.
+	pkgLocks = locks(other)
+	if changed, err := rollPackageLocks(pkgLocks, newLocks, rolled, pkgs); err != nil {
+		t.Fatal(err)
+	} else if changed || len(pkgLocks) != 1 {
+		t.Errorf("expected the locks to be unchanged, got %+v", pkgLocks)
+	}
+}
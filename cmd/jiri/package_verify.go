@@ -0,0 +1,102 @@
+// Copyright 2026 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+
+	"go.fuchsia.dev/jiri"
+	"go.fuchsia.dev/jiri/cmdline"
+	"go.fuchsia.dev/jiri/project"
+)
+
+var cmdPackageVerify = &cmdline.Command{
+	Runner: jiri.RunnerFunc(runPackageVerify),
+	Name:   "verify",
+	Short:  "Verify the files of the installed packages",
+	Long: `
+Hashes the files of the installed packages again, and reports the files
+which were modified, removed or added since the packages were fetched. It
+fails if any package changed.
+`,
+	ArgsName: "<package ...>",
+	ArgsLong: "<package ...> is a list of packages to verify. All the installed packages are verified if it is empty.",
+}
+
+func init() {
+	cmdPackageVerify.Flags.StringVar(&jsonOutputFlag, "json-output", "", "Path to write operation results to.")
+	cmdPackageVerify.Flags.BoolVar(&regexpFlag, "regexp", false, "Use argument as regular expression.")
+}
+
+// packageVerifyOutput defines JSON format for 'package verify' output.
+type packageVerifyOutput struct {
+	Name     string   `json:"name"`
+	Path     string   `json:"path"`
+	Modified []string `json:"modified,omitempty"`
+	Missing  []string `json:"missing,omitempty"`
+	Added    []string `json:"added,omitempty"`
+}
+
+// runPackageVerify verifies the files of installed packages against the
+// record made when they were fetched.
+func runPackageVerify(jirix *jiri.X, args []string) error {
+	regexps, err := packageRegexps(args)
+	if err != nil {
+		return err
+	}
+	installed, err := project.ReadInstalledPackages(jirix)
+	if err != nil {
+		return err
+	}
+	info := make([]packageVerifyOutput, 0)
+	changed := 0
+	for _, p := range installed {
+		matched := len(regexps) == 0
+		for _, re := range regexps {
+			if re.MatchString(p.Name) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			continue
+		}
+		changes, err := p.Verify(jirix)
+		if err != nil {
+			return err
+		}
+		if changes.Empty() {
+			fmt.Fprintf(jirix.Stdout(), "%s (%s): OK\n", p.Name, p.Path)
+		} else {
+			changed++
+			fmt.Fprintf(jirix.Stdout(), "%s (%s): %s\n", p.Name, p.Path, jirix.Color.Red("CHANGED"))
+			for _, f := range changes.Modified {
+				fmt.Fprintf(jirix.Stdout(), "  modified: %s\n", f)
+			}
+			for _, f := range changes.Missing {
+				fmt.Fprintf(jirix.Stdout(), "  missing:  %s\n", f)
+			}
+			for _, f := range changes.Added {
+				fmt.Fprintf(jirix.Stdout(), "  added:    %s\n", f)
+			}
+		}
+		info = append(info, packageVerifyOutput{
+			Name:     p.Name,
+			Path:     p.Path,
+			Modified: changes.Modified,
+			Missing:  changes.Missing,
+			Added:    changes.Added,
+		})
+	}
+	if jsonOutputFlag != "" {
+		if err := writeJSONOutput(info); err != nil {
+			return err
+		}
+	}
+	if changed != 0 {
+		return fmt.Errorf("%d package(s) changed since they were fetched", changed)
+	}
+	return nil
+}
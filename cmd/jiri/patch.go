@@ -31,6 +31,7 @@ var (
 	cherryPickFlag      bool
 	detachedHeadFlag    bool
 	patchProjectFlag    string
+	patchFileFlag       bool
 	rebaseFailures      uint32
 )
 
@@ -46,6 +47,7 @@ func init() {
 	cmdPatch.Flags.BoolVar(&patchTopicFlag, "topic", false, `Patch whole topic.`)
 	cmdPatch.Flags.BoolVar(&cherryPickFlag, "cherry-pick", false, `Cherry-pick patches instead of checking out.`)
 	cmdPatch.Flags.BoolVar(&detachedHeadFlag, "no-branch", false, `Don't create the branch for the patch.`)
+	cmdPatch.Flags.BoolVar(&patchFileFlag, "file", false, `Apply patches from a local mbox, diff or "jiri export-patches" file instead of Gerrit.`)
 }
 
 // Use special address codes for errors that are addressable by the user. The
@@ -76,9 +78,18 @@ individual projects. Patch will assume topic is of form {USER}-{BRANCH} and
 will try to create branch name out of it. If this fails default branch name
 will be same as topic. Currently patch does not support the scenario when
 change "B" is created on top of "A" and both have same topic.
+
+If -file flag is true jiri will read patches from a local file instead of
+fetching them from Gerrit. The file can be a "git format-patch" mailbox, a
+plain unified diff or a multi-project file written by "jiri export-patches".
+File paths in the patches must be relative to the jiri root, with a single
+leading component such as "a/" and "b/", and are used to route every file to
+the project that contains it. Mailboxes are applied as commits using "git am",
+plain diffs are applied to the working tree and the index. The default branch
+name is "patch/<file name without extension>".
 `,
-	ArgsName: "<change or topic>",
-	ArgsLong: "<change or topic> is a change ID, full reference or topic when -topic is true.",
+	ArgsName: "<change, topic or file>",
+	ArgsLong: "<change, topic or file> is a change ID, full reference, topic when -topic is true or a patch file when -file is true.",
 }
 
 // patchProject checks out the given change.
@@ -284,6 +295,16 @@ func runPatch(jirix *jiri.X, args []string) error {
 		return jirix.UsageErrorf("-topic and -project flags cannot be used together")
 	}
 
+	if patchFileFlag {
+		if patchProjectFlag != "" || patchTopicFlag || patchHostFlag != "" || cherryPickFlag || patchRebaseRevision != "" {
+			return jirix.UsageErrorf("-file flag cannot be used with -project, -topic, -host, -cherry-pick or -rebase-revision flags")
+		}
+		if err := runPatchFile(jirix, arg); err != nil {
+			return err
+		}
+		return patchFailuresErr(jirix)
+	}
+
 	if patchRebaseRevision != "" && (!patchRebaseFlag || patchProjectFlag == "") {
 		return jirix.UsageErrorf("-rebase-revision should only be used with -rebase and -project flag")
 	}
@@ -506,6 +527,12 @@ func runPatch(jirix *jiri.X, args []string) error {
 			}
 		}
 	}
+	return patchFailuresErr(jirix)
+}
+
+// patchFailuresErr returns the error to exit with after patching based on
+// the failures recorded in jirix.
+func patchFailuresErr(jirix *jiri.X) error {
 	// In the case where jiri is called programatically by a recipe,
 	// we want to make it clear to the recipe if all failures were rebase errors.
 	if rebaseFailures != 0 && rebaseFailures == jirix.Failures() {
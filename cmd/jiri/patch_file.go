@@ -0,0 +1,418 @@
+// Copyright 2026 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"go.fuchsia.dev/jiri"
+	"go.fuchsia.dev/jiri/gitutil"
+	"go.fuchsia.dev/jiri/project"
+)
+
+// mboxFromRE matches the separator line that "git format-patch" emits at
+// the start of each message.
+var mboxFromRE = regexp.MustCompile(`^From [0-9a-f]{40} Mon Sep 17 00:00:00 2001$`)
+
+// patchFileSection is the part of a patch that modifies a single file.
+type patchFileSection struct {
+	// path is the path of the file relative to the jiri root.
+	path string
+	text string
+}
+
+// patchUnit is a single message of a mailbox or a whole plain diff.
+type patchUnit struct {
+	// header holds the mail headers and commit message of the unit. It is
+	// empty for plain diffs.
+	header   string
+	sections []patchFileSection
+}
+
+// projectPatch holds the parts of a patch file that apply to one project.
+type projectPatch struct {
+	project project.Project
+	// relPath is the path of the project relative to the jiri root.
+	relPath string
+	units   []patchUnit
+}
+
+// text returns the patch for p in the format of the original patch file.
+func (p *projectPatch) text() string {
+	var b strings.Builder
+	for _, u := range p.units {
+		b.WriteString(u.header)
+		for _, s := range u.sections {
+			b.WriteString(s.text)
+		}
+	}
+	return b.String()
+}
+
+// strip returns the number of leading path components that need to be
+// removed from the paths in p to make them relative to the project.
+func (p *projectPatch) strip() int {
+	if p.relPath == "." {
+		return 1
+	}
+	return 1 + len(strings.Split(p.relPath, "/"))
+}
+
+// parsePatchFile parses a mailbox produced by "git format-patch" or a plain
+// unified diff. It returns the units in the file and whether the file is
+// a mailbox. The paths in the file must be relative to the jiri root and
+// carry a single leading component such as "a/" or "b/".
+func parsePatchFile(r io.Reader) ([]patchUnit, bool, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text()+"\n")
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, false, err
+	}
+
+	var units []patchUnit
+	unit := &patchUnit{}
+	var header, section strings.Builder
+	inSection, gitSection, isMbox := false, false, false
+	flushSection := func() error {
+		if !inSection {
+			return nil
+		}
+		path, err := sectionPath(section.String())
+		if err != nil {
+			return err
+		}
+		unit.sections = append(unit.sections, patchFileSection{path: path, text: section.String()})
+		section.Reset()
+		inSection = false
+		return nil
+	}
+	flushUnit := func() error {
+		if err := flushSection(); err != nil {
+			return err
+		}
+		unit.header = header.String()
+		header.Reset()
+		if len(unit.sections) != 0 {
+			units = append(units, *unit)
+		}
+		unit = &patchUnit{}
+		return nil
+	}
+	for i, line := range lines {
+		trimmed := strings.TrimSuffix(line, "\n")
+		switch {
+		case mboxFromRE.MatchString(trimmed):
+			isMbox = true
+			if err := flushUnit(); err != nil {
+				return nil, false, err
+			}
+		case strings.HasPrefix(trimmed, "diff --git "):
+			if err := flushSection(); err != nil {
+				return nil, false, err
+			}
+			inSection, gitSection = true, true
+		case strings.HasPrefix(trimmed, "--- ") && !(inSection && gitSection) &&
+			i+2 < len(lines) && strings.HasPrefix(lines[i+1], "+++ ") && strings.HasPrefix(lines[i+2], "@@"):
+			// Start of a file in a plain unified diff.
+			if err := flushSection(); err != nil {
+				return nil, false, err
+			}
+			inSection, gitSection = true, false
+		}
+		if inSection {
+			section.WriteString(line)
+		} else {
+			header.WriteString(line)
+		}
+	}
+	if err := flushUnit(); err != nil {
+		return nil, false, err
+	}
+	if !isMbox {
+		// Anything before the first file of a plain diff is commentary
+		// which git apply would ignore anyway.
+		for i := range units {
+			units[i].header = ""
+		}
+	}
+	return units, isMbox, nil
+}
+
+// sectionPath returns the path, relative to the jiri root, of the file
+// modified by the given file section of a patch.
+func sectionPath(text string) (string, error) {
+	var oldPath, newPath string
+	for _, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(line, "--- ") && oldPath == "" {
+			oldPath = diffPath(line[len("--- "):])
+		} else if strings.HasPrefix(line, "+++ ") && newPath == "" {
+			newPath = diffPath(line[len("+++ "):])
+			break
+		} else if strings.HasPrefix(line, "@@") {
+			break
+		}
+	}
+	if newPath == "" && oldPath == "" {
+		// Binary, rename or mode-only change in a git diff.
+		first := strings.SplitN(text, "\n", 2)[0]
+		fields := strings.Fields(strings.TrimPrefix(first, "diff --git "))
+		if len(fields) != 2 {
+			return "", fmt.Errorf("cannot parse file name from %q", first)
+		}
+		oldPath, newPath = diffPath(fields[0]), diffPath(fields[1])
+	}
+	path := newPath
+	if path == "" {
+		path = oldPath
+	}
+	if path == "" {
+		return "", fmt.Errorf("cannot find the file name in patch section:\n%s", text)
+	}
+	return path, nil
+}
+
+// diffPath converts a file name from a diff header into a path relative to
+// the jiri root by dropping the timestamp and the leading path component.
+// It returns an empty string for "/dev/null".
+func diffPath(name string) string {
+	if i := strings.IndexByte(name, '\t'); i >= 0 {
+		name = name[:i]
+	}
+	name = strings.TrimSpace(name)
+	if name == "/dev/null" {
+		return ""
+	}
+	if i := strings.IndexByte(name, '/'); i >= 0 {
+		return name[i+1:]
+	}
+	return name
+}
+
+// routePatchUnits splits units between local projects by matching the paths
+// of the modified files against the project paths relative to the jiri
+// root. The returned patches are sorted by project path.
+func routePatchUnits(jirix *jiri.X, units []patchUnit, projects project.Projects) ([]*projectPatch, error) {
+	type root struct {
+		rel string
+		p   project.Project
+	}
+	var roots []root
+	for _, p := range projects {
+		rel, err := filepath.Rel(jirix.Root, p.Path)
+		if err != nil {
+			return nil, err
+		}
+		roots = append(roots, root{filepath.ToSlash(rel), p})
+	}
+	// Deepest path first so that nested projects win, and the root project
+	// is tried last.
+	depth := func(rel string) int {
+		if rel == "." {
+			return 0
+		}
+		return strings.Count(rel, "/") + 1
+	}
+	sort.Slice(roots, func(i, j int) bool {
+		if di, dj := depth(roots[i].rel), depth(roots[j].rel); di != dj {
+			return di > dj
+		}
+		return roots[i].rel < roots[j].rel
+	})
+
+	patches := make(map[string]*projectPatch)
+	for _, u := range units {
+		byProject := make(map[string]*patchUnit)
+		var order []string
+		for _, s := range u.sections {
+			found := false
+			for _, r := range roots {
+				if r.rel == "." || strings.HasPrefix(s.path, r.rel+"/") {
+					if _, ok := patches[r.rel]; !ok {
+						patches[r.rel] = &projectPatch{project: r.p, relPath: r.rel}
+					}
+					pu, ok := byProject[r.rel]
+					if !ok {
+						pu = &patchUnit{header: u.header}
+						byProject[r.rel] = pu
+						order = append(order, r.rel)
+					}
+					pu.sections = append(pu.sections, s)
+					found = true
+					break
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("cannot find a project for file %q", s.path)
+			}
+		}
+		for _, rel := range order {
+			patches[rel].units = append(patches[rel].units, *byProject[rel])
+		}
+	}
+	var result []*projectPatch
+	for _, p := range patches {
+		result = append(result, p)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].relPath < result[j].relPath })
+	return result, nil
+}
+
+// patchProjectFromFile applies pp to its project. Mailboxes are applied as
+// commits with "git am", plain diffs are applied to the working tree and
+// the index.
+func patchProjectFromFile(jirix *jiri.X, pp *projectPatch, isMbox bool, branch, remote string) (bool, error) {
+	local := pp.project
+	scm := gitutil.New(jirix, gitutil.RootDirOpt(local.Path))
+	lastRef := ""
+	if state, err := project.GetProjectState(jirix, local, false); err != nil {
+		return false, err
+	} else {
+		lastRef = state.CurrentBranch.Name
+		if lastRef == "" {
+			lastRef = state.CurrentBranch.Revision
+		}
+	}
+	if !detachedHeadFlag {
+		jirix.Logger.Infof("Patching project %s(%s) on branch %q\n", local.Name, local.Path, branch)
+		branchExists, err := scm.BranchExists(branch)
+		if err != nil {
+			return false, err
+		}
+		if branchExists {
+			if !patchDeleteFlag {
+				jirix.Logger.Errorf("Branch %q already exists in project %q", branch, local.Name)
+				jirix.IncrementFailures()
+				return false, nil
+			}
+			if lastRef == branch {
+				if err := scm.CheckoutBranch("remotes/origin/"+remote, local.GitSubmodules, gitutil.DetachOpt(true)); err != nil {
+					return false, err
+				}
+				if lastRef, err = scm.CurrentRevision(); err != nil {
+					return false, err
+				}
+			}
+			if err := scm.DeleteBranch(branch, gitutil.ForceOpt(patchForceFlag)); err != nil {
+				jirix.Logger.Errorf("Cannot delete branch %q: %s", branch, err)
+				jirix.IncrementFailures()
+				return false, nil
+			}
+		}
+		if err := scm.CreateBranchFromRef(branch, "HEAD"); err != nil {
+			return false, err
+		}
+		if err := scm.SetUpstream(branch, "origin/"+remote); err != nil {
+			return false, fmt.Errorf("setting upstream to 'origin/%s': %s", remote, err)
+		}
+		if err := scm.CheckoutBranch(branch, local.GitSubmodules); err != nil {
+			return false, err
+		}
+	} else {
+		jirix.Logger.Infof("Patching project %s(%s)\n", local.Name, local.Path)
+	}
+
+	file, err := ioutil.TempFile("", "jiri-patch-*.patch")
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(file.Name())
+	if _, err := file.WriteString(pp.text()); err != nil {
+		file.Close()
+		return false, err
+	}
+	if err := file.Close(); err != nil {
+		return false, err
+	}
+
+	if isMbox {
+		err = scm.Am(file.Name(), pp.strip())
+	} else {
+		err = scm.Apply(file.Name(), pp.strip())
+	}
+	if err != nil {
+		jirix.Logger.Errorf("Error: %s\n", err)
+		jirix.IncrementFailures()
+		jirix.Logger.Infof("Aborting and checking out last ref: %s\n", lastRef)
+		if isMbox {
+			if err := scm.AmAbort(); err != nil {
+				jirix.Logger.Errorf("git am abort failed. Error:%s\nPlease do it manually:'%s'\n\n", err,
+					jirix.Color.Yellow("git -C %q am --abort && git -C %q checkout %s", local.Path, local.Path, lastRef))
+				return false, nil
+			}
+		}
+		if err := scm.CheckoutBranch(lastRef, local.GitSubmodules); err != nil {
+			jirix.Logger.Errorf("Not able to checkout last ref. Error:%s\nPlease do it manually:'%s'\n\n", err,
+				jirix.Color.Yellow("git -C %q checkout %s", local.Path, lastRef))
+			return false, nil
+		}
+		if !detachedHeadFlag {
+			scm.DeleteBranch(branch, gitutil.ForceOpt(true))
+		}
+		return false, nil
+	}
+	jirix.Logger.Infof("Project patched\n")
+	return true, nil
+}
+
+// runPatchFile implements "jiri patch -file".
+func runPatchFile(jirix *jiri.X, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	units, isMbox, err := parsePatchFile(f)
+	if err != nil {
+		return fmt.Errorf("cannot parse %q: %v", path, err)
+	}
+	if len(units) == 0 {
+		return fmt.Errorf("no patches found in %q", path)
+	}
+	projects, err := project.LocalProjects(jirix, project.FastScan)
+	if err != nil {
+		return err
+	}
+	patches, err := routePatchUnits(jirix, units, projects)
+	if err != nil {
+		return err
+	}
+	branch := patchBranchFlag
+	if branch == "" {
+		base := filepath.Base(path)
+		branch = "patch/" + strings.TrimSuffix(base, filepath.Ext(base))
+	}
+	for _, pp := range patches {
+		remoteBranch := patchRebaseBranch
+		if remoteBranch == "" {
+			remoteBranch = pp.project.RemoteBranch
+		}
+		if remoteBranch == "" {
+			remoteBranch = "master"
+		}
+		ok, err := patchProjectFromFile(jirix, pp, isMbox, branch, remoteBranch)
+		if err != nil {
+			return err
+		}
+		if ok && patchRebaseFlag {
+			if err := rebaseProject(jirix, pp.project, remoteBranch); err != nil {
+				return err
+			}
+		}
+		fmt.Println()
+	}
+	return nil
+}
@@ -0,0 +1,223 @@
+// Copyright 2026 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.fuchsia.dev/jiri/gitutil"
+	"go.fuchsia.dev/jiri/jiritest"
+	"go.fuchsia.dev/jiri/project"
+)
+
+func TestParsePatchFile(t *testing.T) {
+	plain := `Some description.
+--- a/path-0/file1	2020-01-01 00:00:00
++++ b/path-0/file1	2020-01-01 00:00:00
+@@ -1 +1 @@
+-foo
++bar
+--- a/path-1/dir/file2
++++ b/path-1/dir/file2
+@@ -1 +1 @@
+-foo
++bar
+`
+	units, isMbox, err := parsePatchFile(strings.NewReader(plain))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if isMbox {
+		t.Errorf("plain diff detected as mailbox")
+	}
+	if len(units) != 1 || len(units[0].sections) != 2 {
+		t.Fatalf("expected 1 unit with 2 sections, got %+v", units)
+	}
+	if got, want := units[0].sections[0].path, "path-0/file1"; got != want {
+		t.Errorf("wrong path, got %q, want %q", got, want)
+	}
+	if got, want := units[0].sections[1].path, "path-1/dir/file2"; got != want {
+		t.Errorf("wrong path, got %q, want %q", got, want)
+	}
+
+	mbox := `From 0123456789012345678901234567890123456789 Mon Sep 17 00:00:00 2001
+From: John Doe <john.doe@example.com>
+Subject: [PATCH] first
+
+---
+ path-0/file1 | 1 +
+ 1 file changed, 1 insertion(+)
+
+diff --git a/path-0/file1 b/path-0/file1
+new file mode 100644
+index 0000000..257cc56
+--- /dev/null
++++ b/path-0/file1
+@@ -0,0 +1 @@
++foo
+--
+2.30.0
+
+From 1123456789012345678901234567890123456789 Mon Sep 17 00:00:00 2001
+From: John Doe <john.doe@example.com>
+Subject: [PATCH] second
+
+---
+diff --git a/path-1/file2 b/path-1/file2
+deleted file mode 100644
+index 257cc56..0000000
+--- a/path-1/file2
++++ /dev/null
+@@ -1 +0,0 @@
+-foo
+--
+2.30.0
+`
+	units, isMbox, err = parsePatchFile(strings.NewReader(mbox))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isMbox {
+		t.Errorf("mailbox not detected")
+	}
+	if len(units) != 2 {
+		t.Fatalf("expected 2 units, got %d", len(units))
+	}
+	if got, want := units[0].sections[0].path, "path-0/file1"; got != want {
+		t.Errorf("wrong path, got %q, want %q", got, want)
+	}
+	if got, want := units[1].sections[0].path, "path-1/file2"; got != want {
+		t.Errorf("wrong path, got %q, want %q", got, want)
+	}
+	if !strings.Contains(units[1].header, "Subject: [PATCH] second") {
+		t.Errorf("header of second unit is missing the subject: %q", units[1].header)
+	}
+}
+
+// TestRoutePatchUnits tests that files are routed to the deepest project
+// containing them, and to the root project last, even if other projects have
+// one-character paths.
+func TestRoutePatchUnits(t *testing.T) {
+	fake, cleanup := jiritest.NewFakeJiriRoot(t)
+	defer cleanup()
+
+	projects := project.Projects{}
+	for _, path := range []string{".", "a", "b", "c", "a/b"} {
+		p := project.Project{Name: "project-" + path, Path: filepath.Join(fake.X.Root, path)}
+		projects[p.Key()] = p
+	}
+	units := []patchUnit{{sections: []patchFileSection{
+		{path: "a/file"},
+		{path: "b/file"},
+		{path: "a/b/file"},
+		{path: "file"},
+		{path: "d/file"},
+	}}}
+	patches, err := routePatchUnits(fake.X, units, projects)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string][]string)
+	for _, p := range patches {
+		for _, u := range p.units {
+			for _, s := range u.sections {
+				got[p.relPath] = append(got[p.relPath], s.path)
+			}
+		}
+	}
+	want := map[string][]string{
+		".":   {"file", "d/file"},
+		"a":   {"a/file"},
+		"a/b": {"a/b/file"},
+		"b":   {"b/file"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong routing, got %v, want %v", got, want)
+	}
+}
+
+// TestExportPatchesAndPatchFile tests that commits exported by
+// "jiri export-patches" are applied to the right projects by
+// "jiri patch -file".
+func TestExportPatchesAndPatchFile(t *testing.T) {
+	fake, cleanup := jiritest.NewFakeJiriRoot(t)
+	defer cleanup()
+	localProjects := createProjects(t, fake, 2)
+	if err := fake.UpdateUniverse(false); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, p := range localProjects {
+		setDummyUser(t, fake.X, p.Path)
+		scm := gitutil.New(fake.X, gitutil.RootDirOpt(p.Path))
+		if err := scm.CreateBranchWithUpstream("feature", "origin/master"); err != nil {
+			t.Fatal(err)
+		}
+		if err := scm.CheckoutBranch("feature", false); err != nil {
+			t.Fatal(err)
+		}
+		writeFile(t, fake.X, p.Path, "feature-file", "feature "+localProjects[i].Name)
+	}
+
+	patchFile := filepath.Join(fake.X.Root, "feature.patch")
+	if err := runExportPatches(fake.X, []string{patchFile}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(patchFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range localProjects {
+		rel, _ := filepath.Rel(fake.X.Root, p.Path)
+		if !strings.Contains(string(data), "b/"+rel+"/feature-file") {
+			t.Errorf("expected root relative path for project %s in:\n%s", p.Name, data)
+		}
+	}
+
+	// Go back to JIRI_HEAD and apply the patches.
+	for _, p := range localProjects {
+		scm := gitutil.New(fake.X, gitutil.RootDirOpt(p.Path))
+		if err := scm.CheckoutBranch("JIRI_HEAD", false, gitutil.DetachOpt(true)); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Stat(filepath.Join(p.Path, "feature-file")); !os.IsNotExist(err) {
+			t.Fatalf("feature-file should not exist in project %s", p.Name)
+		}
+	}
+	patchFileFlag = true
+	defer func() { patchFileFlag = false }()
+	if err := runPatch(fake.X, []string{patchFile}); err != nil {
+		t.Fatal(err)
+	}
+	for i, p := range localProjects {
+		scm := gitutil.New(fake.X, gitutil.RootDirOpt(p.Path))
+		branch, err := scm.CurrentBranchName()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := branch, "patch/feature"; got != want {
+			t.Errorf("wrong branch in project %s, got %q, want %q", p.Name, got, want)
+		}
+		content, err := ioutil.ReadFile(filepath.Join(p.Path, "feature-file"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := string(content), "feature "+localProjects[i].Name; got != want {
+			t.Errorf("wrong content in project %s, got %q, want %q", p.Name, got, want)
+		}
+		msg, err := scm.LatestCommitMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := msg, "feature "+localProjects[i].Name; got != want {
+			t.Errorf("wrong commit message in project %s, got %q, want %q", p.Name, got, want)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2026 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"go.fuchsia.dev/jiri"
+	"go.fuchsia.dev/jiri/cmdline"
+	"go.fuchsia.dev/jiri/project"
+)
+
+type provenanceFlags struct {
+	snapshot          string
+	localManifestFlag bool
+	builderID         string
+	output            string
+	artifacts         []string
+}
+
+func (p *provenanceFlags) Snapshot() string {
+	return p.snapshot
+}
+
+func (p *provenanceFlags) LocalManifest() bool {
+	return p.localManifestFlag
+}
+
+func (p *provenanceFlags) BuilderID() string {
+	return p.builderID
+}
+
+func (p *provenanceFlags) Artifacts() []string {
+	return p.artifacts
+}
+
+var provenanceFlag provenanceFlags
+
+var cmdProvenance = &cmdline.Command{
+	Runner: jiri.RunnerFunc(runProvenance),
+	Name:   "provenance",
+	Short:  "Generate SLSA provenance for the sources of a build",
+	Long: `
+Generates an in-toto statement with a SLSA provenance predicate
+(https://slsa.dev/provenance/v0.2) for the current checkout, or for a
+snapshot with -snapshot.
+
+Every project is listed as a material, with its repository and git commit,
+and every package instance is listed as a material, with its instance id.
+The build config records the jiri version, the attributes, and the chain of
+manifest imports with the revisions of the manifest repositories.
+
+The subjects of the statement are the <artifact> files, with their sha256
+digest.
+`,
+	ArgsName: "<artifact ...>",
+	ArgsLong: "<artifact ...> are the files built from the sources.",
+}
+
+func init() {
+	flags := &cmdProvenance.Flags
+	flags.StringVar(&provenanceFlag.snapshot, "snapshot", "", "Describe the projects and packages of this snapshot instead of the current checkout.")
+	flags.BoolVar(&provenanceFlag.localManifestFlag, "local-manifest", false, "Use local checked out manifest.")
+	flags.StringVar(&provenanceFlag.builderID, "builder-id", "https://fuchsia.googlesource.com/jiri", "The id of the builder in the provenance.")
+	flags.StringVar(&provenanceFlag.output, "output", "", "Write the provenance to this file instead of stdout.")
+}
+
+func runProvenance(jirix *jiri.X, args []string) error {
+	provenanceFlag.artifacts = args
+	prov, err := project.NewProvenance(jirix, &provenanceFlag)
+	if err != nil {
+		return err
+	}
+	if provenanceFlag.output != "" {
+		return prov.ToFile(jirix, provenanceFlag.output)
+	}
+	out, err := json.MarshalIndent(prov, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize JSON output: %s", err)
+	}
+	_, err = fmt.Fprintf(os.Stdout, "%s\n", out)
+	return err
+}
@@ -0,0 +1,195 @@
+// Copyright 2026 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"go.fuchsia.dev/jiri/gitutil"
+	"go.fuchsia.dev/jiri/jiritest"
+	"go.fuchsia.dev/jiri/project"
+)
+
+// TestProvenance tests the provenance of a checkout and of a snapshot.
+func TestProvenance(t *testing.T) {
+	fake, cleanup := jiritest.NewFakeJiriRoot(t)
+	defer cleanup()
+
+	numProjects := 2
+	for i := 0; i < numProjects; i++ {
+		if err := fake.CreateRemoteProject(remoteProjectName(i)); err != nil {
+			t.Fatal(err)
+		}
+		if err := fake.AddProject(project.Project{
+			Name:   remoteProjectName(i),
+			Path:   localProjectName(i),
+			Remote: fake.Projects[remoteProjectName(i)],
+		}); err != nil {
+			t.Fatal(err)
+		}
+		writeReadme(t, fake.X, fake.Projects[remoteProjectName(i)], fmt.Sprintf("proj %d", i))
+	}
+	if err := project.UpdateUniverse(fake.X, true, false, false, false, false, true /*run-hooks*/, true /*run-packages*/, project.DefaultHookTimeout, project.DefaultPackageTimeout); err != nil {
+		t.Fatal(err)
+	}
+	revision := func(path string) string {
+		t.Helper()
+		rev, err := gitutil.New(fake.X, gitutil.RootDirOpt(filepath.Join(fake.X.Root, path))).CurrentRevision()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rev
+	}
+
+	artifact := filepath.Join(fake.X.Root, "artifact")
+	if err := ioutil.WriteFile(artifact, []byte("artifact\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	output := filepath.Join(fake.X.Root, "provenance.json")
+	provenanceFlag = provenanceFlags{builderID: "https://example.com/builder", output: output}
+	if err := runProvenance(fake.X, []string{artifact}); err != nil {
+		t.Fatal(err)
+	}
+	readProvenance := func() *project.Provenance {
+		t.Helper()
+		data, err := ioutil.ReadFile(output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var prov project.Provenance
+		if err := json.Unmarshal(data, &prov); err != nil {
+			t.Fatal(err)
+		}
+		return &prov
+	}
+	prov := readProvenance()
+	if prov.Type != project.InTotoStatementType || prov.PredicateType != project.SLSAProvenancePredicateType {
+		t.Errorf("unexpected statement type %q and predicate type %q", prov.Type, prov.PredicateType)
+	}
+	sum := sha256.Sum256([]byte("artifact\n"))
+	wantSubject := []project.ProvenanceSubject{{
+		Name:   filepath.ToSlash(artifact),
+		Digest: project.DigestSet{"sha256": hex.EncodeToString(sum[:])},
+	}}
+	if !reflect.DeepEqual(prov.Subject, wantSubject) {
+		t.Errorf("got subject %+v, want %+v", prov.Subject, wantSubject)
+	}
+	if got := prov.Predicate.Builder.ID; got != "https://example.com/builder" {
+		t.Errorf("got builder id %q", got)
+	}
+	materials := make(map[string]project.DigestSet)
+	for _, m := range prov.Predicate.Materials {
+		materials[m.URI] = m.Digest
+	}
+	paths := map[string]string{"manifest": "manifest"}
+	for i := 0; i < numProjects; i++ {
+		paths[remoteProjectName(i)] = localProjectName(i)
+	}
+	for name, path := range paths {
+		uri := "git+" + fake.Projects[name]
+		if got, want := materials[uri], (project.DigestSet{"sha1": revision(path)}); !reflect.DeepEqual(got, want) {
+			t.Errorf("material %s: got %v, want %v", uri, got, want)
+		}
+	}
+	imports := prov.Predicate.BuildConfig.Imports
+	if len(imports) != 1 || imports[0].Remote != fake.Projects["manifest"] || imports[0].Revision != revision("manifest") {
+		t.Errorf("unexpected imports %+v", imports)
+	}
+	if got := prov.Predicate.Invocation.ConfigSource.Digest["sha1"]; got != revision("manifest") {
+		t.Errorf("got config source revision %q, want %q", got, revision("manifest"))
+	}
+
+	// Projects which were removed from the manifest, but not deleted, are not
+	// materials.
+	m, err := fake.ReadRemoteManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	removed := m.Projects[len(m.Projects)-1]
+	m.Projects = m.Projects[:len(m.Projects)-1]
+	if err := fake.WriteRemoteManifest(m); err != nil {
+		t.Fatal(err)
+	}
+	if err := fake.UpdateUniverse(false); err != nil {
+		t.Fatal(err)
+	}
+	provenanceFlag = provenanceFlags{output: output}
+	if err := runProvenance(fake.X, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range readProvenance().Predicate.Materials {
+		if m.URI == "git+"+removed.Remote {
+			t.Errorf("removed project %q is a material", removed.Name)
+		}
+	}
+
+	// Packages of snapshots are described by their instances.
+	snapshot := filepath.Join(fake.X.Root, "snapshot.xml")
+	m = &project.Manifest{
+		Version:  project.ManifestVersion,
+		Projects: []project.Project{{Name: "p", Path: "p", Remote: "https://example.com/p", Revision: "0123456789012345678901234567890123456789"}},
+		Packages: []project.Package{{
+			Name:    "tool/${platform}",
+			Version: "version:1",
+			Path:    "tool",
+			Instances: []project.PackageInstance{
+				{Name: "tool/linux-amd64", ID: "fJu-Xsmz-3dOj6D1QkfpPDTd-OXRb-MHNCDeCugaJi0C"},
+				{Name: "tool/mac-amd64", ID: "b9e0c8b3d2f42d7e8b0a0e6a6c3c8d0f1a2b3c4d"},
+			},
+		}},
+	}
+	if err := m.ToFile(fake.X, snapshot); err != nil {
+		t.Fatal(err)
+	}
+	provenanceFlag = provenanceFlags{snapshot: snapshot, output: output}
+	if err := runProvenance(fake.X, nil); err != nil {
+		t.Fatal(err)
+	}
+	prov = readProvenance()
+	want := []project.ProvenanceMaterial{
+		{URI: "git+https://example.com/p", Digest: project.DigestSet{"sha1": "0123456789012345678901234567890123456789"}},
+		{URI: "https://chrome-infra-packages.appspot.com/p/tool/linux-amd64/+/version:1", Digest: project.DigestSet{
+			"cipdInstanceId": "fJu-Xsmz-3dOj6D1QkfpPDTd-OXRb-MHNCDeCugaJi0C",
+			"sha256":         "7c9bbe5ec9b3fb774e8fa0f54247e93c34ddf8e5d16fe3073420de0ae81a262d",
+		}},
+		{URI: "https://chrome-infra-packages.appspot.com/p/tool/mac-amd64/+/version:1", Digest: project.DigestSet{
+			"cipdInstanceId": "b9e0c8b3d2f42d7e8b0a0e6a6c3c8d0f1a2b3c4d",
+			"sha1":           "b9e0c8b3d2f42d7e8b0a0e6a6c3c8d0f1a2b3c4d",
+		}},
+	}
+	if !reflect.DeepEqual(prov.Predicate.Materials, want) {
+		t.Errorf("got materials %+v, want %+v", prov.Predicate.Materials, want)
+	}
+	if !prov.Predicate.Metadata.Completeness.Materials {
+		t.Errorf("materials with digests should be complete")
+	}
+
+	// Materials are not complete if a package is not pinned.
+	m.Packages[0].Instances = nil
+	if err := m.ToFile(fake.X, snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if err := runProvenance(fake.X, nil); err != nil {
+		t.Fatal(err)
+	}
+	prov = readProvenance()
+	want = []project.ProvenanceMaterial{
+		want[0],
+		{URI: "https://chrome-infra-packages.appspot.com/p/tool/${platform}/+/version:1"},
+	}
+	if !reflect.DeepEqual(prov.Predicate.Materials, want) {
+		t.Errorf("got materials %+v, want %+v", prov.Predicate.Materials, want)
+	}
+	if prov.Predicate.Metadata.Completeness.Materials {
+		t.Errorf("materials without digest should not be complete")
+	}
+}
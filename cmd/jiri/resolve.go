@@ -5,6 +5,8 @@
 package main
 
 import (
+	"fmt"
+	"os"
 	"strings"
 
 	"go.fuchsia.dev/jiri"
@@ -17,10 +19,14 @@ type resolveFlags struct {
 	localManifestFlag    bool
 	enablePackageLock    bool
 	enableProjectLock    bool
+	enableSubmoduleLock  bool
 	enablePackageVersion bool
 	allowFloatingRefs    bool
 	fullResolve          bool
 	hostnameAllowList    string
+	signingKey           string
+	check                bool
+	fix                  bool
 }
 
 func (r *resolveFlags) AllowFloatingRefs() bool {
@@ -43,6 +49,10 @@ func (r *resolveFlags) EnableProjectLock() bool {
 	return r.enableProjectLock
 }
 
+func (r *resolveFlags) EnableSubmoduleLock() bool {
+	return r.enableSubmoduleLock
+}
+
 func (r *resolveFlags) HostnameAllowList() []string {
 	ret := make([]string, 0)
 	hosts := strings.Split(r.hostnameAllowList, ",")
@@ -69,6 +79,36 @@ var cmdResolve = &cmdline.Command{
 	Long: `
 Generate jiri lockfile in json format for <manifest ...>. If no manifest
 provided, jiri will use .jiri_manifest by default.
+
+With -enable-submodule-lock, the project locks of projects with
+gitsubmodules="true" also pin the commits of their submodules and the git-lfs
+objects of their pointer files, as found in the local checkouts. "jiri update"
+verifies them after updating the submodules of the projects checked out at
+their locked revision, and warns about the others. Projects which are not
+pinned are locked to their JIRI_HEAD.
+
+With -sign, the lockfile is also signed. Otherwise the signature of the
+previous lockfile, which no longer matches, is removed. Jiri roots set up with
+"jiri init -trusted-lockfile-keys" refuse lockfiles which are not signed by
+one of the trusted keys. Signatures use the format of "ssh-keygen -Y sign",
+in the "jiri-lockfile" namespace.
+
+With -check, no lockfile is generated. Instead, the manifests are loaded with
+their lockfiles, and jiri reports the lock entries which would break
+"jiri update":
+
+  stale        package entries for another version of a manifest package
+  missing      manifest packages, or projects if projects are locked, which
+               are not locked
+  unused       entries for projects or packages not in the manifest
+  conflicting  project entries which do not match the revision pinned in the
+               manifest, and entries which differ between lockfiles
+
+jiri exits with an error if there are any. With -fix, the lockfiles which have
+issues are rewritten in place: unused and stale entries are removed, project
+entries get the revision pinned in the manifest, and stale, missing and
+conflicting package entries are resolved again. Only lockfiles on the
+filesystem can be fixed, so -fix is used with -local-manifest.
 `,
 	ArgsName: "<manifest ...>",
 	ArgsLong: "<manifest ...> is a list of manifest files for lockfile generation",
@@ -80,9 +120,13 @@ func init() {
 	flags.BoolVar(&resolveFlag.localManifestFlag, "local-manifest", false, "Use local manifest")
 	flags.BoolVar(&resolveFlag.enablePackageLock, "enable-package-lock", true, "Enable resolving packages in lockfile")
 	flags.BoolVar(&resolveFlag.enableProjectLock, "enable-project-lock", false, "Enable resolving projects in lockfile")
+	flags.BoolVar(&resolveFlag.enableSubmoduleLock, "enable-submodule-lock", false, "Enable recording the submodule revisions and git-lfs objects of projects with gitsubmodules in project locks. Requires -enable-project-lock and checked out projects.")
 	flags.BoolVar(&resolveFlag.allowFloatingRefs, "allow-floating-refs", false, "Allow packages to be pinned to floating refs such as \"latest\"")
 	flags.StringVar(&resolveFlag.hostnameAllowList, "allow-hosts", "", "List of hostnames that can be used in the url of a repository, seperated by comma. It will not be enforced if it is left empty.")
 	flags.BoolVar(&resolveFlag.fullResolve, "full-resolve", false, "Resolve all project and packages, not just those are changed.")
+	flags.BoolVar(&resolveFlag.check, "check", false, "Report stale, missing, unused and conflicting entries in the existing lockfiles instead of generating a lockfile.")
+	flags.BoolVar(&resolveFlag.fix, "fix", false, "With -check, rewrite the lockfiles which have issues.")
+	flags.StringVar(&resolveFlag.signingKey, "sign", "", "Sign the lockfile with this private key, an OpenSSH ed25519 key or the base64 encoding of an ed25519 seed. The signature is written next to the lockfile, with a .sig extension.")
 }
 
 func runResolve(jirix *jiri.X, args []string) error {
@@ -95,6 +139,15 @@ func runResolve(jirix *jiri.X, args []string) error {
 			manifestFiles = append(manifestFiles, m)
 		}
 	}
+	if resolveFlag.enableSubmoduleLock && !resolveFlag.enableProjectLock {
+		return jirix.UsageErrorf("-enable-submodule-lock requires -enable-project-lock")
+	}
+	if resolveFlag.fix && !resolveFlag.check {
+		return jirix.UsageErrorf("-fix requires -check")
+	}
+	if resolveFlag.check {
+		return checkLockfiles(jirix, manifestFiles)
+	}
 	// While revision pins for projects can be updated by 'jiri edit',
 	// instance IDs of packages can only be updated by 'jiri resolve' due
 	// to the way how cipd works. Since roller is using 'jiri resolve'
@@ -103,5 +156,59 @@ func runResolve(jirix *jiri.X, args []string) error {
 	// Jiri will halt when detecting conflicts in locks. So to make it work,
 	// we need to temporarily disable the conflicts detection.
 	jirix.IgnoreLockConflicts = true
-	return project.GenerateJiriLockFile(jirix, manifestFiles, &resolveFlag)
+	// The lockfiles being regenerated may not match their signatures.
+	jirix.IgnoreLockSignatures = true
+	if err := project.GenerateJiriLockFile(jirix, manifestFiles, &resolveFlag); err != nil {
+		return err
+	}
+	return signLockfile(jirix, resolveFlag.lockFilePath, resolveFlag.signingKey)
+}
+
+// signLockfile signs lockfile, which was just written, with the private key
+// in keyFile. If keyFile is empty, the signature of the previous content of
+// lockfile is removed instead, with a warning if this jiri root requires
+// signed lockfiles.
+func signLockfile(jirix *jiri.X, lockfile, keyFile string) error {
+	if keyFile != "" {
+		return project.SignLockfile(jirix, lockfile, keyFile)
+	}
+	if err := os.Remove(project.LockfileSignaturePath(lockfile)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if len(jirix.TrustedLockfileKeys) != 0 {
+		jirix.Logger.Warningf("%s is not signed, and this jiri root requires signed lockfiles. Use -sign to sign it.\n\n", lockfile)
+	}
+	return nil
+}
+
+func checkLockfiles(jirix *jiri.X, manifestFiles []string) error {
+	if !resolveFlag.fix {
+		issues, err := project.CheckLockfiles(jirix, manifestFiles, resolveFlag.localManifestFlag)
+		if err != nil {
+			return err
+		}
+		for _, issue := range issues {
+			fmt.Println(issue)
+		}
+		if len(issues) != 0 {
+			return fmt.Errorf("found %d lockfile issues, run \"jiri resolve -check -fix -local-manifest\" to fix them", len(issues))
+		}
+		return nil
+	}
+	// The lockfiles being fixed may not match their signatures.
+	jirix.IgnoreLockSignatures = true
+	issues, lockfiles, err := project.FixLockfiles(jirix, manifestFiles, resolveFlag.localManifestFlag)
+	if err != nil {
+		return err
+	}
+	for _, issue := range issues {
+		fmt.Println(issue)
+	}
+	for _, lockfile := range lockfiles {
+		fmt.Printf("Rewrote %s\n", lockfile)
+		if err := signLockfile(jirix, lockfile, resolveFlag.signingKey); err != nil {
+			return err
+		}
+	}
+	return nil
 }
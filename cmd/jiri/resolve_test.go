@@ -5,7 +5,11 @@
 package main
 
 import (
+	"crypto/ed25519"
+	"encoding/base64"
 	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"go.fuchsia.dev/jiri/jiritest"
@@ -229,3 +233,83 @@ func TestResolvePackagesPartial(t *testing.T) {
 		}
 	}
 }
+
+// TestResolveConditionalImports tests that lockfiles cover the imports whose
+// attributes are not selected.
+func TestResolveConditionalImports(t *testing.T) {
+	fakeroot, cleanup := jiritest.NewFakeJiriRoot(t)
+	defer cleanup()
+	manifestData := []byte(`
+<manifest>
+	<imports>
+		<localimport file="sdk" attributes="sdk"/>
+	</imports>
+</manifest>
+`)
+	sdkData := []byte(`
+<manifest>
+	<projects>
+		<project name="sdk" path="sdk" remote="https://example.com/sdk" revision="c22471f4e3f842ae18dd9adec82ed9eb78ed1127"/>
+	</projects>
+</manifest>
+`)
+	if err := ioutil.WriteFile(fakeroot.X.JiriManifestFile(), manifestData, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(fakeroot.X.Root, "sdk"), sdkData, 0644); err != nil {
+		t.Fatal(err)
+	}
+	lockPath := filepath.Join(fakeroot.X.Root, "jiri.lock")
+	resolveFlag.lockFilePath = lockPath
+	resolveFlag.enablePackageLock = false
+	resolveFlag.enableProjectLock = true
+	fakeroot.X.FetchingAttrs = ""
+	if err := runResolve(fakeroot.X, nil); err != nil {
+		t.Fatalf("resolve failed due to error %v", err)
+	}
+	data, err := ioutil.ReadFile(lockPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	projLocks, _, err := project.UnmarshalLockEntries(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(projLocks) != 1 {
+		t.Errorf("expected a lock for the sdk project, got %+v", projLocks)
+	}
+}
+
+// TestSignLockfile tests that rewritten lockfiles are signed, or lose their
+// stale signature.
+func TestSignLockfile(t *testing.T) {
+	fakeroot, cleanup := jiritest.NewFakeJiriRoot(t)
+	defer cleanup()
+
+	lockfile := filepath.Join(fakeroot.X.Root, "jiri.lock")
+	if err := ioutil.WriteFile(lockfile, []byte("[]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sigFile := project.LockfileSignaturePath(lockfile)
+	if err := ioutil.WriteFile(sigFile, []byte("stale"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := signLockfile(fakeroot.X, lockfile, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(sigFile); !os.IsNotExist(err) {
+		t.Errorf("stale signature was not removed: %v", err)
+	}
+
+	keyFile := filepath.Join(fakeroot.X.Root, "key")
+	seed := make([]byte, ed25519.SeedSize)
+	if err := ioutil.WriteFile(keyFile, []byte(base64.StdEncoding.EncodeToString(seed)), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := signLockfile(fakeroot.X, lockfile, keyFile); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(sigFile); err != nil {
+		t.Errorf("lockfile was not signed: %v", err)
+	}
+}
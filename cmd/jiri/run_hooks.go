@@ -45,20 +45,27 @@ func runHooks(jirix *jiri.X, args []string) (err error) {
 	jirix.Attempts = runHooksFlags.attempts
 
 	// Get hooks.
+	var projects project.Projects
 	var hooks project.Hooks
 	var pkgs project.Packages
 	if !runHooksFlags.localManifest {
-		_, hooks, pkgs, err = project.LoadUpdatedManifest(jirix, localProjects, runHooksFlags.localManifest)
+		projects, hooks, pkgs, err = project.LoadUpdatedManifest(jirix, localProjects, runHooksFlags.localManifest)
 	} else {
-		_, hooks, pkgs, err = project.LoadManifestFile(jirix, jirix.JiriManifestFile(), localProjects, runHooksFlags.localManifest)
+		projects, hooks, pkgs, err = project.LoadManifestFile(jirix, jirix.JiriManifestFile(), localProjects, runHooksFlags.localManifest)
 	}
 	if err != nil {
 		return err
 	}
-	if err = project.RunHooks(jirix, hooks, runHooksFlags.hookTimeout); err != nil {
+	if err := project.FilterOptionalHooks(jirix, jirix.FetchingAttrs, hooks); err != nil {
+		return err
+	}
+	if err := project.FilterOptionalProjectsPackages(jirix, jirix.FetchingAttrs, projects, pkgs); err != nil {
 		return err
 	}
-	if err := project.FilterOptionalProjectsPackages(jirix, jirix.FetchingAttrs, nil, pkgs); err != nil {
+	if err := project.SetHooksEnv(jirix, hooks, projects, pkgs); err != nil {
+		return err
+	}
+	if err = project.RunHooks(jirix, hooks, runHooksFlags.hookTimeout); err != nil {
 		return err
 	}
 	// Get packages if the fetchPackages is true
@@ -40,6 +40,7 @@ var runpFlags struct {
 	collateOutput  bool
 	branch         string
 	remote         string
+	env            bool
 }
 
 var cmdRunP = &cmdline.Command{
@@ -73,6 +74,7 @@ func init() {
 	cmdRunP.Flags.BoolVar(&runpFlags.exitOnError, "exit-on-error", false, "If set, all commands will killed as soon as one reports an error, otherwise, each will run to completion.")
 	cmdRunP.Flags.StringVar(&runpFlags.branch, "branch", "", "A regular expression specifying branch names to use in matching projects. A project will match if the specified branch exists, even if it is not checked out.")
 	cmdRunP.Flags.StringVar(&runpFlags.remote, "remote", "", "A Regular expression specifying projects to run commands in by matching against their remote URLs.")
+	cmdRunP.Flags.BoolVar(&runpFlags.env, "env", false, "Run the command in the environment exported by the projects and packages of the local manifest, see 'jiri env'.")
 }
 
 type mapInput struct {
@@ -113,6 +115,7 @@ func projectKeys(mapInputs map[project.ProjectKey]*mapInput) []string {
 
 type runner struct {
 	args                 []string
+	env                  map[string]string
 	serializedWriterLock sync.Mutex
 	collatedOutputLock   sync.Mutex
 }
@@ -165,7 +168,11 @@ func (r *runner) Map(mr *simplemr.MR, key string, val interface{}) error {
 	}
 	var wg sync.WaitGroup
 	cmd := exec.Command(path, "-c", strings.Join(r.args, " "))
-	cmd.Env = envvar.MapToSlice(jirix.Env())
+	if r.env != nil {
+		cmd.Env = envvar.MapToSlice(r.env)
+	} else {
+		cmd.Env = envvar.MapToSlice(jirix.Env())
+	}
 	cmd.Dir = mi.Project.Path
 	cmd.Stdin = mi.jirix.Stdin()
 	var stdoutCloser, stderrCloser io.Closer
@@ -401,6 +408,13 @@ func runRunp(jirix *jiri.X, args []string) error {
 	runner := &runner{
 		args: args,
 	}
+	if runpFlags.env {
+		vars, err := manifestEnv(jirix, jirix.Env(), true)
+		if err != nil {
+			return err
+		}
+		runner.env = vars.ToMap()
+	}
 	mr := simplemr.MR{}
 	if runpFlags.interactive {
 		// Run one mapper at a time.
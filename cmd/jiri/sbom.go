@@ -0,0 +1,73 @@
+// Copyright 2026 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"go.fuchsia.dev/jiri"
+	"go.fuchsia.dev/jiri/cmdline"
+	"go.fuchsia.dev/jiri/project"
+)
+
+var sbomFlags struct {
+	format            string
+	output            string
+	localManifestFlag bool
+}
+
+var cmdSbom = &cmdline.Command{
+	Runner: jiri.RunnerFunc(runSbom),
+	Name:   "sbom",
+	Short:  "Generate a software bill of materials of the checkout",
+	Long: `
+Generates a software bill of materials (SBOM) of the projects and packages of
+the manifest, in the SPDX 2.3 JSON format with -format=spdx-json, or in the
+CycloneDX 1.4 JSON format with -format=cyclonedx-json.
+
+Every manifest, project and package is a component. Projects have their
+pinned revision, or their current revision if they are not pinned, and their
+remote as download location. Packages have their version, and their cipd or
+archive URL as download location.
+
+The licenses of projects are detected from the LICENSE, LICENSE.txt,
+LICENSE.md, COPYING and README.fuchsia files at their revision, and the
+licenses of packages from the same files in their installed directory.
+README.fuchsia files declare their licenses in "License:" lines, other files
+are matched against the texts of common licenses. Components without
+detected licenses have no license assertion.
+
+Manifests contain the manifests they import, and the projects and packages
+they declare.
+`,
+}
+
+func init() {
+	flags := &cmdSbom.Flags
+	flags.StringVar(&sbomFlags.format, "format", project.SPDXJSONFormat, "The format of the SBOM, spdx-json or cyclonedx-json.")
+	flags.StringVar(&sbomFlags.output, "output", "", "Write the SBOM to this file instead of stdout.")
+	flags.BoolVar(&sbomFlags.localManifestFlag, "local-manifest", false, "Use local checked out manifest.")
+}
+
+func runSbom(jirix *jiri.X, args []string) error {
+	if len(args) != 0 {
+		return jirix.UsageErrorf("unexpected number of arguments")
+	}
+	if sbomFlags.format != project.SPDXJSONFormat && sbomFlags.format != project.CycloneDXJSONFormat {
+		return jirix.UsageErrorf("unknown format %q", sbomFlags.format)
+	}
+	sbom, err := project.NewSBOM(jirix, sbomFlags.localManifestFlag)
+	if err != nil {
+		return err
+	}
+	if sbomFlags.output != "" {
+		return sbom.ToFile(jirix, sbomFlags.output, sbomFlags.format)
+	}
+	if err := sbom.Write(os.Stdout, sbomFlags.format); err != nil {
+		return fmt.Errorf("cannot write SBOM: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+// Copyright 2026 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"go.fuchsia.dev/jiri/jiritest"
+	"go.fuchsia.dev/jiri/project"
+)
+
+// TestSbom tests the SBOM of a checkout with license files.
+func TestSbom(t *testing.T) {
+	fake, cleanup := jiritest.NewFakeJiriRoot(t)
+	defer cleanup()
+
+	numProjects := 2
+	for i := 0; i < numProjects; i++ {
+		if err := fake.CreateRemoteProject(remoteProjectName(i)); err != nil {
+			t.Fatal(err)
+		}
+		if err := fake.AddProject(project.Project{
+			Name:   remoteProjectName(i),
+			Path:   localProjectName(i),
+			Remote: fake.Projects[remoteProjectName(i)],
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile(t, fake.X, fake.Projects[remoteProjectName(0)], "LICENSE", "Copyright 2026 Example\n\nPermission is hereby granted, free of charge, to any person\nobtaining a copy of this software.\n")
+	writeFile(t, fake.X, fake.Projects[remoteProjectName(1)], "README.fuchsia", "Name: proj\nLicense: Apache 2.0, Custom License\n")
+	if err := project.UpdateUniverse(fake.X, true, false, false, false, false, true /*run-hooks*/, true /*run-packages*/, project.DefaultHookTimeout, project.DefaultPackageTimeout); err != nil {
+		t.Fatal(err)
+	}
+
+	sbom, err := project.NewSBOM(fake.X, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	components := make(map[string]project.SBOMComponent)
+	for _, c := range sbom.Components {
+		components[c.Name] = c
+	}
+	wantLicenses := map[string][]string{
+		remoteProjectName(0): {"MIT"},
+		remoteProjectName(1): {"Apache-2.0", "LicenseRef-Custom-License"},
+	}
+	for name, want := range wantLicenses {
+		c, ok := components[name]
+		if !ok {
+			t.Fatalf("no component for project %s in %+v", name, sbom.Components)
+		}
+		if !reflect.DeepEqual(c.Licenses, want) {
+			t.Errorf("project %s: got licenses %v, want %v", name, c.Licenses, want)
+		}
+		if c.Version == "" || c.DownloadLocation != fake.Projects[name] {
+			t.Errorf("project %s: unexpected version %q and download location %q", name, c.Version, c.DownloadLocation)
+		}
+	}
+	// The .jiri_manifest file imports the manifest of the manifest
+	// repository, which declares the projects.
+	rels := make(map[project.SBOMRelationship]bool)
+	for _, rel := range sbom.Relationships {
+		rels[rel] = true
+	}
+	if sbom.Root != "manifest:.jiri_manifest" {
+		t.Errorf("unexpected root %q", sbom.Root)
+	}
+	imported := project.SBOMRelationship{From: sbom.Root, To: "manifest:" + filepath.Join(jiritest.ManifestProjectPath, jiritest.ManifestFileName)}
+	if !rels[imported] {
+		t.Errorf("missing relationship %+v in %+v", imported, sbom.Relationships)
+	}
+	declared := project.SBOMRelationship{From: imported.To, To: components[remoteProjectName(0)].ID}
+	if !rels[declared] {
+		t.Errorf("missing relationship %+v in %+v", declared, sbom.Relationships)
+	}
+
+	output := filepath.Join(fake.X.Root, "sbom.json")
+	sbomFlags.output = output
+	for _, format := range []string{project.SPDXJSONFormat, project.CycloneDXJSONFormat} {
+		sbomFlags.format = format
+		if err := runSbom(fake.X, nil); err != nil {
+			t.Fatal(err)
+		}
+		data, err := ioutil.ReadFile(output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var doc map[string]interface{}
+		if err := json.Unmarshal(data, &doc); err != nil {
+			t.Fatal(err)
+		}
+		switch format {
+		case project.SPDXJSONFormat:
+			if doc["spdxVersion"] != "SPDX-2.3" || len(doc["packages"].([]interface{})) != len(sbom.Components) {
+				t.Errorf("unexpected SPDX document %s", data)
+			}
+		case project.CycloneDXJSONFormat:
+			if doc["bomFormat"] != "CycloneDX" || len(doc["components"].([]interface{})) != len(sbom.Components)-1 {
+				t.Errorf("unexpected CycloneDX document %s", data)
+			}
+		}
+	}
+	sbomFlags.format = "xml"
+	if err := runSbom(fake.X, nil); err == nil {
+		t.Errorf("expected an error for an unknown format")
+	}
+}
@@ -5,19 +5,27 @@
 package main
 
 import (
+	"flag"
+	"fmt"
+	"sort"
+
 	"go.fuchsia.dev/jiri"
 	"go.fuchsia.dev/jiri/cmdline"
 	"go.fuchsia.dev/jiri/project"
 )
 
 var (
-	submoduleFlag  bool
-	cipdEnsureFlag bool
+	submoduleFlag          bool
+	cipdEnsureFlag         bool
+	snapshotProjectsFlag   string
+	snapshotAttributesFlag string
 )
 
 func init() {
 	cmdSnapshot.Flags.BoolVar(&submoduleFlag, "submodule", false, "Filter snapshot for use with git submodules.")
 	cmdSnapshot.Flags.BoolVar(&cipdEnsureFlag, "cipd", false, "Generate a cipd.ensure (packages only) snapshot.")
+	cmdSnapshot.Flags.StringVar(&snapshotProjectsFlag, "projects", "", "A regular expression matching the names of the projects of a partial snapshot.")
+	cmdSnapshot.Flags.StringVar(&snapshotAttributesFlag, "attributes", "", "The attributes selecting the projects and optional packages of a partial snapshot, as a comma separated list or an attribute expression, like the attributes of 'jiri init -fetch-optional'.")
 }
 
 var cmdSnapshot = &cmdline.Command{
@@ -27,14 +35,112 @@ var cmdSnapshot = &cmdline.Command{
 	Long: `
 The "jiri snapshot <snapshot>" command captures the current project state
 in a manifest.
+
+Snapshots also record metadata: the creation time, the jiri version, the
+host platform, the imports of manifests with the revisions of the manifest
+repositories, and the manifest which declared each project. The fetched
+optional attributes are the attributes of the snapshot manifest.
+
+With -projects or -attributes, the snapshot is partial: it only has the
+projects whose names match the -projects regular expression, and which are
+fetched with the -attributes attributes, as projects which are not optional
+always are. The projects these projects are nested in, their hooks, and the
+packages installed in them are included too, as well as the optional
+packages fetched with the -attributes attributes.
+
+The "jiri snapshot inspect <snapshot>" command prints the metadata of a
+snapshot file or URL. Snapshots created by older versions of jiri have no
+metadata.
+
+The "jiri snapshot checkout [-partial] <snapshot>" command checks out a
+snapshot file or URL, like "jiri update <snapshot>". With -partial, only the
+projects of the snapshot are updated, and the other projects are left alone
+instead of being deleted. The packages of the snapshot are fetched along
+with the packages of the last update, and only the hooks of the snapshot
+are run.
 `,
-	ArgsName: "<snapshot>",
+	ArgsName: "<snapshot> | inspect <snapshot> | checkout [-partial] <snapshot>",
 	ArgsLong: "<snapshot> is the snapshot manifest file.",
 }
 
 func runSnapshot(jirix *jiri.X, args []string) error {
+	if len(args) == 2 && args[0] == "inspect" {
+		return inspectSnapshot(jirix, args[1])
+	}
+	if len(args) > 1 && args[0] == "checkout" {
+		return checkoutSnapshot(jirix, args[1:])
+	}
 	if len(args) != 1 {
 		return jirix.UsageErrorf("unexpected number of arguments")
 	}
-	return project.CreateSnapshot(jirix, args[0], nil, nil, true, submoduleFlag, cipdEnsureFlag)
+	var filter *project.SnapshotFilter
+	if snapshotProjectsFlag != "" || snapshotAttributesFlag != "" {
+		filter = &project.SnapshotFilter{Projects: snapshotProjectsFlag, Attributes: snapshotAttributesFlag}
+	}
+	return project.CreateSnapshot(jirix, args[0], nil, nil, true, submoduleFlag, cipdEnsureFlag, filter)
+}
+
+func checkoutSnapshot(jirix *jiri.X, args []string) error {
+	flags := flag.NewFlagSet("checkout", flag.ContinueOnError)
+	partial := flags.Bool("partial", false, "Only update the projects of the snapshot, and leave the other projects alone.")
+	if err := flags.Parse(args); err != nil {
+		return jirix.UsageErrorf("%v", err)
+	}
+	if flags.NArg() != 1 {
+		return jirix.UsageErrorf("unexpected number of arguments")
+	}
+	if *partial {
+		return project.CheckoutPartialSnapshot(jirix, flags.Arg(0), true /*run-hooks*/, true /*run-packages*/, project.DefaultHookTimeout, project.DefaultPackageTimeout)
+	}
+	return project.CheckoutSnapshot(jirix, flags.Arg(0), false, true /*run-hooks*/, true /*run-packages*/, project.DefaultHookTimeout, project.DefaultPackageTimeout)
+}
+
+func inspectSnapshot(jirix *jiri.X, snapshot string) error {
+	m, err := project.LoadSnapshotManifest(jirix, snapshot)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Version: %s\n", m.Version)
+	fmt.Printf("Attributes: %s\n", m.Attributes)
+	fmt.Printf("Projects: %d\n", len(m.Projects))
+	fmt.Printf("Hooks: %d\n", len(m.Hooks))
+	fmt.Printf("Packages: %d\n", len(m.Packages))
+	md := m.Metadata
+	if md == nil {
+		fmt.Printf("The snapshot has no metadata.\n")
+		return nil
+	}
+	fmt.Printf("Created: %s\n", md.Created)
+	fmt.Printf("Jiri version: %s\n", md.JiriVersion)
+	fmt.Printf("Platform: %s\n", md.Platform)
+	fmt.Printf("Manifest: %s\n", md.Manifest)
+	if md.Partial {
+		fmt.Printf("Partial: projects %q, attributes %q\n", md.ProjectFilter, md.AttributeFilter)
+	}
+	if len(md.Imports) != 0 {
+		fmt.Printf("Imports:\n")
+		for _, imp := range md.Imports {
+			if imp.Remote == "" {
+				fmt.Printf("  %s imports %s\n", imp.ImportedBy, imp.Manifest)
+				continue
+			}
+			fmt.Printf("  %s imports %s from %s at %s\n", imp.ImportedBy, imp.Manifest, imp.Remote, imp.Revision)
+		}
+	}
+	if len(md.Sources) != 0 {
+		counts := make(map[string]int)
+		var manifests []string
+		for _, source := range md.Sources {
+			if counts[source.Manifest] == 0 {
+				manifests = append(manifests, source.Manifest)
+			}
+			counts[source.Manifest]++
+		}
+		sort.Strings(manifests)
+		fmt.Printf("Projects by manifest:\n")
+		for _, manifest := range manifests {
+			fmt.Printf("  %s: %d\n", manifest, counts[manifest])
+		}
+	}
+	return nil
 }
@@ -10,6 +10,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 
 	"go.fuchsia.dev/jiri"
@@ -150,7 +151,7 @@ func TestCipdSnapshot(t *testing.T) {
 	}
 	defer os.Remove(tmpfile.Name())
 
-	if err := project.CreateSnapshot(fake.X, tmpfile.Name(), nil, nil, true, false /*submoduleFlag*/, true /*cipdEnsureFlag*/); err != nil {
+	if err := project.CreateSnapshot(fake.X, tmpfile.Name(), nil, nil, true, false /*submoduleFlag*/, true /*cipdEnsureFlag*/, nil); err != nil {
 		t.Fatalf("%v", err)
 	}
 	pathExists := func(pkgPath string) bool {
@@ -182,3 +183,157 @@ func TestCipdSnapshot(t *testing.T) {
 	os.Remove(versionFilePath)
 	os.Remove(versionFileIntPath)
 }
+
+// TestSnapshotMetadata tests the metadata of snapshots.
+func TestSnapshotMetadata(t *testing.T) {
+	fake, cleanup := jiritest.NewFakeJiriRoot(t)
+	defer cleanup()
+
+	if err := fake.CreateRemoteProject(remoteProjectName(0)); err != nil {
+		t.Fatal(err)
+	}
+	if err := fake.AddProject(project.Project{
+		Name:   remoteProjectName(0),
+		Path:   localProjectName(0),
+		Remote: fake.Projects[remoteProjectName(0)],
+	}); err != nil {
+		t.Fatal(err)
+	}
+	writeReadme(t, fake.X, fake.Projects[remoteProjectName(0)], "revision 1")
+	if err := project.UpdateUniverse(fake.X, true, false, false, false, false, true /*run-hooks*/, true /*run-packages*/, project.DefaultHookTimeout, project.DefaultPackageTimeout); err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot := filepath.Join(fake.X.Root, "snapshot.xml")
+	if err := runSnapshot(fake.X, []string{snapshot}); err != nil {
+		t.Fatal(err)
+	}
+	m, err := project.LoadSnapshotManifest(fake.X, snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Version != project.SnapshotVersion {
+		t.Errorf("got version %q, want %q", m.Version, project.SnapshotVersion)
+	}
+	md := m.Metadata
+	if md == nil {
+		t.Fatalf("snapshot has no metadata")
+	}
+	if md.Created == "" || md.Platform == "" || md.Manifest != ".jiri_manifest" {
+		t.Errorf("unexpected metadata %+v", md)
+	}
+	manifestRev, err := gitutil.New(fake.X, gitutil.RootDirOpt(filepath.Join(fake.X.Root, jiritest.ManifestProjectPath))).CurrentRevision()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(md.Imports) != 1 || md.Imports[0].Remote != fake.Projects[jiritest.ManifestProjectPath] || md.Imports[0].Revision != manifestRev {
+		t.Errorf("unexpected imports %+v", md.Imports)
+	}
+	sources := make(map[string]string)
+	for _, source := range md.Sources {
+		sources[source.Name] = source.Manifest
+	}
+	if got, want := sources[remoteProjectName(0)], filepath.Join(jiritest.ManifestProjectPath, jiritest.ManifestFileName); got != want {
+		t.Errorf("project %s: got manifest %q, want %q", remoteProjectName(0), got, want)
+	}
+
+	// The update history snapshot is written with the manifest of the
+	// update, whose repository was cloned by the update.
+	latest, err := project.LoadSnapshotManifest(fake.X, fake.X.UpdateHistoryLatestLink())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest.Metadata == nil || !reflect.DeepEqual(latest.Metadata.Imports, md.Imports) || !reflect.DeepEqual(latest.Metadata.Sources, md.Sources) {
+		t.Errorf("unexpected update history metadata %+v", latest.Metadata)
+	}
+
+	// Snapshots with metadata, and older snapshots without, are loaded.
+	projects, _, _, err := project.LoadSnapshotFile(fake.X, snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(projects) != len(m.Projects) {
+		t.Errorf("got %d projects, want %d", len(projects), len(m.Projects))
+	}
+	m.Version, m.Metadata = project.ManifestVersion, nil
+	if err := m.ToFile(fake.X, snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := project.LoadSnapshotFile(fake.X, snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if err := runSnapshot(fake.X, []string{"inspect", snapshot}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestPartialSnapshot tests creating and checking out a snapshot of some
+// projects.
+func TestPartialSnapshot(t *testing.T) {
+	fake, cleanup := jiritest.NewFakeJiriRoot(t)
+	defer cleanup()
+
+	numProjects := 2
+	for i := 0; i < numProjects; i++ {
+		if err := fake.CreateRemoteProject(remoteProjectName(i)); err != nil {
+			t.Fatal(err)
+		}
+		if err := fake.AddProject(project.Project{
+			Name:   remoteProjectName(i),
+			Path:   localProjectName(i),
+			Remote: fake.Projects[remoteProjectName(i)],
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	update := func(message string) {
+		t.Helper()
+		for i := 0; i < numProjects; i++ {
+			writeReadme(t, fake.X, fake.Projects[remoteProjectName(i)], message)
+		}
+		if err := project.UpdateUniverse(fake.X, true, false, false, false, false, true /*run-hooks*/, true /*run-packages*/, project.DefaultHookTimeout, project.DefaultPackageTimeout); err != nil {
+			t.Fatal(err)
+		}
+	}
+	update("revision 1")
+
+	snapshot := filepath.Join(fake.X.Root, "snapshot.xml")
+	snapshotProjectsFlag = "^" + remoteProjectName(0) + "$"
+	defer func() { snapshotProjectsFlag = "" }()
+	if err := runSnapshot(fake.X, []string{snapshot}); err != nil {
+		t.Fatal(err)
+	}
+	m, err := project.LoadSnapshotManifest(fake.X, snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Projects) != 1 || m.Projects[0].Name != remoteProjectName(0) {
+		t.Errorf("unexpected projects %+v", m.Projects)
+	}
+	if m.Metadata == nil || !m.Metadata.Partial || m.Metadata.ProjectFilter != snapshotProjectsFlag {
+		t.Errorf("unexpected metadata %+v", m.Metadata)
+	}
+
+	// Projects which are not optional are fetched with any attributes.
+	snapshotAttributesFlag = "sdk"
+	defer func() { snapshotAttributesFlag = "" }()
+	if err := runSnapshot(fake.X, []string{snapshot}); err != nil {
+		t.Fatal(err)
+	}
+	if m, err = project.LoadSnapshotManifest(fake.X, snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Projects) != 1 || m.Projects[0].Name != remoteProjectName(0) {
+		t.Errorf("unexpected projects with attributes %+v", m.Projects)
+	}
+	snapshotAttributesFlag = ""
+
+	// Only the project of the snapshot is checked out, the other project
+	// is left alone.
+	update("revision 2")
+	if err := runSnapshot(fake.X, []string{"checkout", "-partial", snapshot}); err != nil {
+		t.Fatal(err)
+	}
+	checkReadme(t, fake.X, filepath.Join(fake.X.Root, localProjectName(0)), "revision 1")
+	checkReadme(t, fake.X, filepath.Join(fake.X.Root, localProjectName(1)), "revision 2")
+}
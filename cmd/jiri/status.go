@@ -32,7 +32,9 @@ var cmdStatus = &cmdline.Command{
 	Long: `
 Prints status for the the projects. It runs git status -s across all the projects
 and prints it if there are some changes. It also shows status if the project is on
-a rev other then the one according to manifest(Named as JIRI_HEAD in git)
+a rev other then the one according to manifest(Named as JIRI_HEAD in git), and
+the copyfile and linkfile destinations of the project which diverged from their
+sources.
 `,
 }
 
@@ -120,6 +122,14 @@ func runStatus(jirix *jiri.X, args []string) error {
 			jirix.IncrementFailures()
 			continue
 		}
+		var diverged []string
+		if statusFlags.changes {
+			if diverged, err = project.CheckProjectFiles(jirix, remoteProject); err != nil {
+				jirix.Logger.Errorf("%s :%s\n\n", errorMsg, err)
+				jirix.IncrementFailures()
+				continue
+			}
+		}
 		revisionMessage := ""
 		git := gitutil.New(jirix, gitutil.RootDirOpt(state.Project.Path))
 		currentLog, err := git.OneLineLog(state.CurrentBranch.Revision)
@@ -143,7 +153,7 @@ func runStatus(jirix *jiri.X, args []string) error {
 			}
 		}
 		if statusFlags.branch != "" || changes != "" || revisionMessage != "" ||
-			len(extraCommits) != 0 {
+			len(extraCommits) != 0 || len(diverged) != 0 {
 			fmt.Printf("%s: %s", jirix.Color.Yellow(relativePath), revisionMessage)
 			fmt.Println()
 			branch := state.CurrentBranch.Name
@@ -163,6 +173,12 @@ func runStatus(jirix *jiri.X, args []string) error {
 					fmt.Println(colorFormatGitiStatusLog(jirix, change))
 				}
 			}
+			if len(diverged) != 0 {
+				fmt.Printf("%s: %d file(s) diverged from the project\n", jirix.Color.Yellow("Files"), len(diverged))
+				for _, d := range diverged {
+					fmt.Println(d)
+				}
+			}
 			fmt.Println()
 		}
 
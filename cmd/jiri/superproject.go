@@ -0,0 +1,115 @@
+// Copyright 2026 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+
+	"go.fuchsia.dev/jiri"
+	"go.fuchsia.dev/jiri/cmdline"
+	"go.fuchsia.dev/jiri/project"
+)
+
+var cmdSuperproject = &cmdline.Command{
+	Name:  "superproject",
+	Short: "Synchronize the checkout with a git superproject",
+	Long: `
+A superproject is a git repository with a submodule for each project of the
+checkout, for tools which only understand git submodules. The gitlinks of
+the submodules record the revisions of the projects, and the .gitmodules
+file is in the format of "jiri generate-gitmodules". Nested projects, and
+the project at the root of the checkout, are not submodules.
+`,
+	Children: []*cmdline.Command{cmdSuperprojectSync, cmdSuperprojectImport},
+}
+
+var superprojectSyncFlags struct {
+	message string
+}
+
+var cmdSuperprojectSync = &cmdline.Command{
+	Runner: jiri.RunnerFunc(runSuperprojectSync),
+	Name:   "sync",
+	Short:  "Commit the revisions of the projects into a superproject",
+	Long: `
+Commits the current revisions of the local projects into the superproject
+<dir>, which is created if it does not exist. The gitlinks of the projects
+whose revision changed are updated, the gitlinks of the projects which are
+gone are removed, and the .gitmodules file is regenerated. Nothing is
+committed if nothing changed. The submodules are not checked out in the
+superproject.
+`,
+	ArgsName: "<dir>",
+	ArgsLong: "<dir> is the superproject repository.",
+}
+
+var superprojectImportFlags struct {
+	ref      string
+	output   string
+	snapshot bool
+}
+
+var cmdSuperprojectImport = &cmdline.Command{
+	Runner: jiri.RunnerFunc(runSuperprojectImport),
+	Name:   "import",
+	Short:  "Generate a manifest from a superproject",
+	Long: `
+Reads the gitlinks and the .gitmodules file of the superproject <dir> at
+-ref, and writes a manifest with a project for each submodule, pinned to the
+revision of its gitlink. Relative submodule URLs are resolved against the
+origin remote of the superproject. With -snapshot, the manifest is a
+snapshot which can be checked out with "jiri update <snapshot>".
+`,
+	ArgsName: "<dir>",
+	ArgsLong: "<dir> is the superproject repository.",
+}
+
+func init() {
+	cmdSuperprojectSync.Flags.StringVar(&superprojectSyncFlags.message, "message", "", "The subject of the commit message. The changed gitlinks are listed in the message.")
+	cmdSuperprojectImport.Flags.StringVar(&superprojectImportFlags.ref, "ref", "HEAD", "The revision of the superproject to import.")
+	cmdSuperprojectImport.Flags.StringVar(&superprojectImportFlags.output, "output", "", "Write the manifest to this file instead of stdout.")
+	cmdSuperprojectImport.Flags.BoolVar(&superprojectImportFlags.snapshot, "snapshot", false, "Generate a snapshot.")
+}
+
+func runSuperprojectSync(jirix *jiri.X, args []string) error {
+	if len(args) != 1 {
+		return jirix.UsageErrorf("unexpected number of arguments")
+	}
+	localProjects, err := project.LocalProjects(jirix, project.FullScan)
+	if err != nil {
+		return err
+	}
+	changes, err := project.SyncSuperproject(jirix, args[0], localProjects, superprojectSyncFlags.message)
+	if err != nil {
+		return err
+	}
+	for _, c := range changes {
+		fmt.Println(c)
+	}
+	return nil
+}
+
+func runSuperprojectImport(jirix *jiri.X, args []string) error {
+	if len(args) != 1 {
+		return jirix.UsageErrorf("unexpected number of arguments")
+	}
+	m, err := project.ImportSuperproject(jirix, args[0], superprojectImportFlags.ref)
+	if err != nil {
+		return err
+	}
+	if superprojectImportFlags.snapshot {
+		m.Version = project.SnapshotVersion
+		m.Attributes = jirix.FetchingAttrs
+	}
+	if superprojectImportFlags.output != "" {
+		return m.ToFile(jirix, superprojectImportFlags.output)
+	}
+	data, err := m.ToBytes()
+	if err != nil {
+		return err
+	}
+	fmt.Print(string(data))
+	return nil
+}
@@ -0,0 +1,116 @@
+// Copyright 2026 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"go.fuchsia.dev/jiri/gitutil"
+	"go.fuchsia.dev/jiri/jiritest"
+	"go.fuchsia.dev/jiri/project"
+)
+
+// TestSuperproject tests syncing the projects into a superproject, and
+// importing the superproject back.
+func TestSuperproject(t *testing.T) {
+	fake, cleanup := jiritest.NewFakeJiriRoot(t)
+	defer cleanup()
+
+	numProjects := 2
+	for i := 0; i < numProjects; i++ {
+		if err := fake.CreateRemoteProject(remoteProjectName(i)); err != nil {
+			t.Fatal(err)
+		}
+		if err := fake.AddProject(project.Project{
+			Name:   remoteProjectName(i),
+			Path:   localProjectName(i),
+			Remote: fake.Projects[remoteProjectName(i)],
+		}); err != nil {
+			t.Fatal(err)
+		}
+		writeReadme(t, fake.X, fake.Projects[remoteProjectName(i)], "initial readme")
+	}
+	if err := project.UpdateUniverse(fake.X, true, false, false, false, false, true /*run-hooks*/, true /*run-packages*/, project.DefaultHookTimeout, project.DefaultPackageTimeout); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := filepath.Join(t.TempDir(), "superproject")
+	if err := gitutil.New(fake.X).Init(dir); err != nil {
+		t.Fatal(err)
+	}
+	scm := gitutil.New(fake.X, gitutil.RootDirOpt(dir))
+	if err := scm.Config("user.email", "john.doe@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err := scm.Config("user.name", "John Doe"); err != nil {
+		t.Fatal(err)
+	}
+	localProjects, err := project.LocalProjects(fake.X, project.FullScan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes, err := project.SyncSuperproject(fake.X, dir, localProjects, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The manifest project is a submodule too.
+	if got, want := len(changes), numProjects+1; got != want {
+		t.Fatalf("got %d changes, want %d: %v", got, want, changes)
+	}
+	gitlinks, err := scm.Submodules("HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range localProjects {
+		rel, err := filepath.Rel(fake.X.Root, p.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if gitlinks[rel] != p.Revision {
+			t.Errorf("project %s: got gitlink %q, want %q", p.Name, gitlinks[rel], p.Revision)
+		}
+	}
+
+	// Nothing is committed if nothing changed.
+	if changes, err := project.SyncSuperproject(fake.X, dir, localProjects, ""); err != nil {
+		t.Fatal(err)
+	} else if len(changes) != 0 {
+		t.Errorf("unexpected changes %v", changes)
+	}
+
+	writeReadme(t, fake.X, fake.Projects[remoteProjectName(1)], "new readme")
+	if err := project.UpdateUniverse(fake.X, true, false, false, false, false, true /*run-hooks*/, true /*run-packages*/, project.DefaultHookTimeout, project.DefaultPackageTimeout); err != nil {
+		t.Fatal(err)
+	}
+	if localProjects, err = project.LocalProjects(fake.X, project.FullScan); err != nil {
+		t.Fatal(err)
+	}
+	changes, err = project.SyncSuperproject(fake.X, dir, localProjects, "Roll projects")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes[0].Path != localProjectName(1) || changes[0].Old == "" || changes[0].New == "" {
+		t.Fatalf("unexpected changes %v", changes)
+	}
+
+	m, err := project.ImportSuperproject(fake.X, dir, "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(m.Projects), len(localProjects); got != want {
+		t.Fatalf("got %d projects, want %d: %+v", got, want, m.Projects)
+	}
+	for _, p := range m.Projects {
+		local, ok := localProjects[p.Key()]
+		if !ok {
+			t.Errorf("unexpected project %+v", p)
+			continue
+		}
+		if p.Path != filepath.Base(local.Path) || p.Revision != local.Revision {
+			t.Errorf("got project %+v, want %+v", p, local)
+		}
+	}
+}
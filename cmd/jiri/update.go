@@ -16,20 +16,21 @@ import (
 )
 
 var (
-	gcFlag               bool
-	localManifestFlag    bool
-	attemptsFlag         uint
-	autoupdateFlag       bool
-	forceAutoupdateFlag  bool
-	rebaseUntrackedFlag  bool
-	hookTimeoutFlag      uint
-	fetchPkgsTimeoutFlag uint
-	rebaseAllFlag        bool
-	rebaseCurrentFlag    bool
-	rebaseTrackedFlag    bool
-	runHooksFlag         bool
-	fetchPkgsFlag        bool
-	overrideOptionalFlag bool
+	gcFlag                bool
+	localManifestFlag     bool
+	attemptsFlag          uint
+	autoupdateFlag        bool
+	forceAutoupdateFlag   bool
+	rebaseUntrackedFlag   bool
+	hookTimeoutFlag       uint
+	fetchPkgsTimeoutFlag  uint
+	rebaseAllFlag         bool
+	rebaseCurrentFlag     bool
+	rebaseTrackedFlag     bool
+	rebaseInteractiveFlag bool
+	runHooksFlag          bool
+	fetchPkgsFlag         bool
+	overrideOptionalFlag  bool
 )
 
 const (
@@ -38,7 +39,7 @@ const (
 )
 
 func init() {
-	cmdUpdate.Flags.BoolVar(&gcFlag, "gc", false, "Garbage collect obsolete repositories.")
+	cmdUpdate.Flags.BoolVar(&gcFlag, "gc", false, "Garbage collect obsolete repositories and packages.")
 	cmdUpdate.Flags.BoolVar(&localManifestFlag, "local-manifest", false, "Use local manifest")
 	cmdUpdate.Flags.UintVar(&attemptsFlag, "attempts", 3, "Number of attempts before failing.")
 	cmdUpdate.Flags.BoolVar(&autoupdateFlag, "autoupdate", true, "Automatically update to the new version.")
@@ -49,6 +50,7 @@ func init() {
 	cmdUpdate.Flags.BoolVar(&rebaseAllFlag, "rebase-all", false, "Rebase all tracked branches. Also rebase all untracked branches if -rebase-untracked is passed")
 	cmdUpdate.Flags.BoolVar(&rebaseCurrentFlag, "rebase-current", false, "Deprecated. Implies -rebase-tracked. Would be removed in future.")
 	cmdUpdate.Flags.BoolVar(&rebaseTrackedFlag, "rebase-tracked", false, "Rebase current tracked branches instead of fast-forwarding them.")
+	cmdUpdate.Flags.BoolVar(&rebaseInteractiveFlag, "rebase-interactive", false, `Implies -rebase-tracked. Instead of leaving branches which cannot be rebased alone, stop at each of them after the update to let the conflicts be resolved, see "jiri help rebase".`)
 	cmdUpdate.Flags.BoolVar(&runHooksFlag, "run-hooks", true, "Run hooks after updating sources.")
 	cmdUpdate.Flags.BoolVar(&fetchPkgsFlag, "fetch-packages", true, "Use cipd to fetch packages.")
 	cmdUpdate.Flags.BoolVar(&overrideOptionalFlag, "override-optional", false, "Override existing optional attributes in the snapshot file with current jiri settings")
@@ -93,6 +95,21 @@ func runUpdate(jirix *jiri.X, args []string) error {
 		rebaseTrackedFlag = true
 	}
 
+	if rebaseInteractiveFlag {
+		if len(args) > 0 {
+			return jirix.UsageErrorf("-rebase-interactive cannot be used when checking out a snapshot")
+		}
+		state, err := project.ReadRebaseState(jirix)
+		if err != nil {
+			return err
+		}
+		if state.InProgress() {
+			return fmt.Errorf("An interactive rebase is in progress, finish it with \"jiri rebase -continue\", \"-skip\" or \"-abort\" first")
+		}
+		jirix.RebaseInteractive = true
+		rebaseTrackedFlag = true
+	}
+
 	if len(args) > 0 {
 		jirix.OverrideOptional = overrideOptionalFlag
 		if err := project.CheckoutSnapshot(jirix, args[0], gcFlag, runHooksFlag, fetchPkgsFlag, hookTimeoutFlag, fetchPkgsTimeoutFlag); err != nil {
@@ -108,15 +125,13 @@ func runUpdate(jirix *jiri.X, args []string) error {
 			}
 		}
 
-		err := project.UpdateUniverse(jirix, gcFlag, localManifestFlag,
-			rebaseTrackedFlag, rebaseUntrackedFlag, rebaseAllFlag, runHooksFlag, fetchPkgsFlag, hookTimeoutFlag, fetchPkgsTimeoutFlag)
-		if err2 := project.WriteUpdateHistorySnapshot(jirix, nil, nil, localManifestFlag); err2 != nil {
-			if err != nil {
+		// The history is written by UpdateUniverse once the update
+		// succeeded, and here if it failed.
+		if err := project.UpdateUniverse(jirix, gcFlag, localManifestFlag,
+			rebaseTrackedFlag, rebaseUntrackedFlag, rebaseAllFlag, runHooksFlag, fetchPkgsFlag, hookTimeoutFlag, fetchPkgsTimeoutFlag); err != nil {
+			if err2 := project.WriteUpdateHistorySnapshot(jirix, nil, nil, localManifestFlag); err2 != nil {
 				return fmt.Errorf("while updating: %s, while writing history: %s", err, err2)
 			}
-			return fmt.Errorf("while writing history: %s", err2)
-		}
-		if err != nil {
 			return err
 		}
 
@@ -126,6 +141,12 @@ func runUpdate(jirix *jiri.X, args []string) error {
 		}
 	}
 
+	if jirix.RebaseInteractive {
+		if err := project.RunPendingRebases(jirix); err != nil {
+			return err
+		}
+	}
+
 	if jirix.Failures() != 0 {
 		return fmt.Errorf("Project update completed with non-fatal errors")
 	}
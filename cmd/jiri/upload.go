@@ -14,6 +14,7 @@ import (
 	"go.fuchsia.dev/jiri/cmdline"
 	"go.fuchsia.dev/jiri/gerrit"
 	"go.fuchsia.dev/jiri/gitutil"
+	"go.fuchsia.dev/jiri/owners"
 	"go.fuchsia.dev/jiri/project"
 )
 
@@ -30,6 +31,7 @@ var (
 	uploadRemoteBranchFlag string
 	uploadLabelsFlag       string
 	uploadGitOptions       string
+	uploadAddOwnersFlag    bool
 )
 
 type uploadError string
@@ -41,10 +43,16 @@ func (e uploadError) Error() string {
 }
 
 var cmdUpload = &cmdline.Command{
-	Runner:   jiri.RunnerFunc(runUpload),
-	Name:     "upload",
-	Short:    "Upload a changelist for review",
-	Long:     `Command "upload" uploads commits of a local branch to Gerrit.`,
+	Runner: jiri.RunnerFunc(runUpload),
+	Name:   "upload",
+	Short:  "Upload a changelist for review",
+	Long: `
+Command "upload" uploads commits of a local branch to Gerrit.
+
+Owners of the modified files are looked up in OWNERS files, and a small set of
+them which together own all of the files is suggested as reviewers, or added
+to the reviewers with -add-owners.
+`,
 	ArgsName: "<ref>",
 	ArgsLong: `
 <ref> is the valid git ref to upload. It is optional and HEAD is used by
@@ -63,10 +71,11 @@ func init() {
 	cmdUpload.Flags.BoolVar(&uploadVerifyFlag, "verify", true, `Run pre-push git hooks.`)
 	cmdUpload.Flags.BoolVar(&uploadRebaseFlag, "rebase", false, `Run rebase before pushing.`)
 	cmdUpload.Flags.BoolVar(&uploadMultipartFlag, "multipart", false, `Send multipart CL.  Use -set-topic or -topic flag if you want to set a topic.`)
-	cmdUpload.Flags.StringVar(&uploadBranchFlag, "branch", "", `Used when multipart flag is true and this command is executed from root folder`)
+	cmdUpload.Flags.StringVar(&uploadBranchFlag, "branch", "", `Used when multipart flag is true and this command is executed from root folder. Defaults to the current workspace branch started with "jiri start"`)
 	cmdUpload.Flags.StringVar(&uploadRemoteBranchFlag, "remoteBranch", "", `Remote branch to upload change to. If this is not specified and branch is untracked,
 change would be uploaded to branch in project manifest`)
 	cmdUpload.Flags.StringVar(&uploadGitOptions, "git-options", "", `Passthrough git options`)
+	cmdUpload.Flags.BoolVar(&uploadAddOwnersFlag, "add-owners", false, `Add reviewers from OWNERS files which together own all modified files. Without this flag they are only printed. See "jiri help owners".`)
 }
 
 // runUpload is a wrapper that pushes the changes to gerrit for review.
@@ -109,14 +118,21 @@ func runUpload(jirix *jiri.X, args []string) error {
 		setTopic = true
 	}
 
+	workspaceBranches, err := project.ReadWorkspaceBranches(jirix)
+	if err != nil {
+		return err
+	}
+
 	currentBranch := ""
 	if p == nil {
 		if !uploadMultipartFlag {
 			return fmt.Errorf("directory %q is not contained in a project", dir)
-		} else if uploadBranchFlag == "" {
-			return fmt.Errorf("Please run with -branch flag")
-		} else {
+		} else if uploadBranchFlag != "" {
 			currentBranch = uploadBranchFlag
+		} else if workspaceBranches.Current != "" {
+			currentBranch = workspaceBranches.Current
+		} else {
+			return fmt.Errorf("Please run with -branch flag")
 		}
 	} else {
 		scm := gitutil.New(jirix, gitutil.RootDirOpt(p.Path))
@@ -146,7 +162,13 @@ func runUpload(jirix *jiri.X, args []string) error {
 		return err
 	}
 	if uploadMultipartFlag {
-		for _, project := range localProjects {
+		// Only consider the projects participating in the workspace branch
+		// if the branch was created with "jiri start".
+		candidates := localProjects
+		if b := workspaceBranches.Find(currentBranch); b != nil {
+			candidates, _ = b.LocalProjects(localProjects)
+		}
+		for _, project := range candidates {
 			scm := gitutil.New(jirix, gitutil.RootDirOpt(project.Path))
 			if scm.IsOnBranch() {
 				branch, err := scm.CurrentBranchName()
@@ -175,6 +197,7 @@ func runUpload(jirix *jiri.X, args []string) error {
 		return err
 	}
 	var gerritPushOptions []GerritPushOption
+	var ownersResolver *owners.Resolver
 	remoteProjects, _, _, err := project.LoadManifestFile(jirix, jirix.JiriManifestFile(), localProjects, false /*localManifest*/)
 	if err != nil {
 		return err
@@ -225,6 +248,20 @@ func runUpload(jirix *jiri.X, args []string) error {
 		if opts.Presubmit == gerrit.PresubmitTestType("") {
 			opts.Presubmit = gerrit.PresubmitTestTypeAll
 		}
+
+		if ownersResolver == nil {
+			ownersResolver = newOwnersResolver(jirix, localProjects)
+		}
+		if suggested, err := ownersReviewers(jirix, ownersResolver, project, remoteBranch, refToUpload, opts.Reviewers); err != nil {
+			jirix.Logger.Warningf("Cannot find owners of the files modified in project %s(%s): %s\n\n", project.Name, relativePath, err)
+		} else if len(suggested) != 0 {
+			if uploadAddOwnersFlag {
+				jirix.Logger.Infof("Adding reviewers from OWNERS for project %s(%s): %s\n", project.Name, relativePath, strings.Join(suggested, ", "))
+				opts.Reviewers = append(opts.Reviewers, suggested...)
+			} else {
+				fmt.Printf("Suggested reviewers from OWNERS for project %s(%s): %s\nUse -add-owners to add them.\n", project.Name, relativePath, strings.Join(suggested, ", "))
+			}
+		}
 		gerritPushOptions = append(gerritPushOptions, GerritPushOption{project, opts, relativePath})
 	}
 
@@ -122,6 +122,7 @@ func resetFlags() {
 	uploadBranchFlag = ""
 	uploadRemoteBranchFlag = ""
 	uploadSetTopicFlag = false
+	uploadAddOwnersFlag = false
 }
 
 func TestUpload(t *testing.T) {
@@ -564,6 +565,49 @@ func TestGitOptions(t *testing.T) {
 	assertUploadFilesNotPushedToRef(t, fake.X, gerritPath, expectedRef, files)
 }
 
+func TestUploadAddOwners(t *testing.T) {
+	defer resetFlags()
+	fake, localProjects, cleanup := setupUploadTest(t)
+	defer cleanup()
+	currentDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := os.Chdir(currentDir); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	if err := os.Chdir(localProjects[1].Path); err != nil {
+		t.Fatal(err)
+	}
+	branch := "my-branch"
+	git := gitutil.New(fake.X, gitutil.UserNameOpt("John Doe"), gitutil.UserEmailOpt("john.doe@example.com"))
+	if err := git.CreateBranchWithUpstream(branch, "origin/master"); err != nil {
+		t.Fatal(err)
+	}
+	if err := git.CheckoutBranch(branch, localProjects[1].GitSubmodules); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir("dir", 0755); err != nil {
+		t.Fatal(err)
+	}
+	commitFile(t, fake.X, "OWNERS", "top@example.com\n")
+	commitFile(t, fake.X, filepath.Join("dir", "OWNERS"), "dir@example.com\n")
+	commitFile(t, fake.X, filepath.Join("dir", "file1"), "file1")
+
+	uploadAddOwnersFlag = true
+	uploadReviewersFlag = "dir@example.com"
+	if err := runUpload(fake.X, []string{}); err != nil {
+		t.Fatal(err)
+	}
+	// dir@example.com already owns the files in dir, so only the owner of
+	// the top-level OWNERS file is added.
+	gerritPath := fake.Projects[localProjects[1].Name]
+	expectedRef := "refs/for/master%r=dir@example.com,r=top@example.com"
+	assertUploadPushedFilesToRef(t, fake.X, gerritPath, expectedRef, []string{"OWNERS", "dir/file1"})
+}
+
 // commitFile commits a file with the specified content into a branch
 func commitFile(t *testing.T, jirix *jiri.X, filename string, content string) {
 	if err := ioutil.WriteFile(filename, []byte(content), 0644); err != nil {
@@ -0,0 +1,474 @@
+// Copyright 2026 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"go.fuchsia.dev/jiri"
+	"go.fuchsia.dev/jiri/cmdline"
+	"go.fuchsia.dev/jiri/gitutil"
+	"go.fuchsia.dev/jiri/project"
+)
+
+var cmdStart = &cmdline.Command{
+	Runner: jiri.RunnerFunc(runStart),
+	Name:   "start",
+	Short:  "Start a workspace branch in a set of projects",
+	Long: `
+Command "start" creates the local branch <name> in each of the given projects
+and checks it out. The branch starts at JIRI_HEAD and tracks the remote branch
+of the project from the manifest. The set of participating projects is recorded
+in the jiri root, so that the branch can later be handled as a whole by the
+"switch", "rebase", "abandon" and "upload -multipart" commands.
+
+If the workspace branch already exists, the given projects are added to it.
+`,
+	ArgsName: "<name> [<project>...]",
+	ArgsLong: `
+<name> is the name of the branch. <project> is a project name, key or path. If
+no project is given, the project containing the current directory is used.
+`,
+}
+
+var cmdSwitch = &cmdline.Command{
+	Runner: jiri.RunnerFunc(runSwitch),
+	Name:   "switch",
+	Short:  "Switch to a workspace branch",
+	Long: `
+Command "switch" checks out the workspace branch <name> in all of its projects.
+Uncommitted changes in a project are stashed first, and changes which were
+stashed when switching away from <name> are restored.
+`,
+	ArgsName: "<name>",
+	ArgsLong: "<name> is the name of a branch created with \"jiri start\".",
+}
+
+var rebaseFlags struct {
+	continueFlag bool
+	skipFlag     bool
+	abortFlag    bool
+}
+
+var cmdRebase = &cmdline.Command{
+	Runner: jiri.RunnerFunc(runRebase),
+	Name:   "rebase",
+	Short:  "Rebase a workspace branch onto JIRI_HEAD",
+	Long: `
+Command "rebase" rebases the workspace branch in each of its projects onto
+JIRI_HEAD, as set by the last "jiri update". A rebase which fails is aborted,
+and the projects which could not be rebased are reported together at the end.
+
+The -continue, -skip and -abort flags move through the branches which could
+not be rebased by "jiri update -rebase-interactive". The update stops at the
+first of them with the rebase in progress. Once the conflicts are resolved and
+staged, -continue finishes the rebase and goes on with the next branch. -skip
+leaves the current branch as it was before the update, and -abort also drops
+the remaining branches. The state of the interactive rebase is kept in the
+jiri root.
+`,
+	ArgsName: "[<name>]",
+	ArgsLong: `
+<name> is the name of the workspace branch. The current workspace branch is
+used by default. It cannot be used with -continue, -skip or -abort.
+`,
+}
+
+var cmdAbandon = &cmdline.Command{
+	Runner: jiri.RunnerFunc(runAbandon),
+	Name:   "abandon",
+	Short:  "Delete a workspace branch",
+	Long: `
+Command "abandon" deletes the workspace branch <name> from all of its projects,
+checking out JIRI_HEAD in projects which are on the branch, and forgets about
+the branch.
+`,
+	ArgsName: "<name>",
+	ArgsLong: "<name> is the name of a branch created with \"jiri start\".",
+}
+
+func init() {
+	flags := &cmdRebase.Flags
+	flags.BoolVar(&rebaseFlags.continueFlag, "continue", false, "Continue the interactive rebase started by \"jiri update -rebase-interactive\".")
+	flags.BoolVar(&rebaseFlags.skipFlag, "skip", false, "Skip the current branch of the interactive rebase and continue.")
+	flags.BoolVar(&rebaseFlags.abortFlag, "abort", false, "Abort the interactive rebase.")
+}
+
+// workspaceStashMessage is the message of the stash created by "jiri switch"
+// when switching away from branch.
+func workspaceStashMessage(branch string) string {
+	return "jiri switch: " + branch
+}
+
+// resolveProjects returns the local projects with the given names, keys or
+// paths.
+func resolveProjects(localProjects project.Projects, args []string) ([]project.Project, error) {
+	var projects []project.Project
+	for _, arg := range args {
+		p, err := localProjects.FindUnique(arg)
+		if err == nil {
+			projects = append(projects, p)
+			continue
+		}
+		path, absErr := filepath.Abs(arg)
+		if absErr != nil {
+			return nil, absErr
+		}
+		found := false
+		for _, local := range localProjects {
+			if local.Path == path {
+				projects = append(projects, local)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, err
+		}
+	}
+	return projects, nil
+}
+
+// workspaceBranchProjects returns the local projects of the workspace branch
+// sorted by path.
+func workspaceBranchProjects(jirix *jiri.X, branch *project.WorkspaceBranch) ([]project.Project, error) {
+	localProjects, err := project.LocalProjects(jirix, project.FastScan)
+	if err != nil {
+		return nil, err
+	}
+	projects, missing := branch.LocalProjects(localProjects)
+	for _, key := range missing {
+		jirix.Logger.Warningf("Project %q of workspace branch %q does not exist anymore\n\n", key, branch.Name)
+	}
+	var sorted project.ProjectsByPath
+	for _, p := range projects {
+		sorted = append(sorted, p)
+	}
+	sort.Sort(sorted)
+	return sorted, nil
+}
+
+func lookupWorkspaceBranch(wb *project.WorkspaceBranches, name string) (*project.WorkspaceBranch, error) {
+	branch := wb.Find(name)
+	if branch == nil {
+		return nil, fmt.Errorf("workspace branch %q does not exist, use \"jiri start\" to create it", name)
+	}
+	return branch, nil
+}
+
+func runStart(jirix *jiri.X, args []string) error {
+	if len(args) == 0 {
+		return jirix.UsageErrorf("must specify the branch name")
+	}
+	name := args[0]
+	localProjects, err := project.LocalProjects(jirix, project.FastScan)
+	if err != nil {
+		return err
+	}
+	var projects []project.Project
+	if len(args) == 1 {
+		p, err := currentProject(jirix)
+		if err != nil {
+			return err
+		}
+		projects = append(projects, p)
+	} else if projects, err = resolveProjects(localProjects, args[1:]); err != nil {
+		return err
+	}
+
+	wb, err := project.ReadWorkspaceBranches(jirix)
+	if err != nil {
+		return err
+	}
+	branch := wb.Find(name)
+	if branch == nil {
+		wb.Branches = append(wb.Branches, project.WorkspaceBranch{Name: name})
+		branch = &wb.Branches[len(wb.Branches)-1]
+	}
+	participating := make(map[string]bool)
+	for _, key := range branch.Projects {
+		participating[key] = true
+	}
+
+	// Check all projects before creating any branch.
+	var toStart []project.Project
+	for _, p := range projects {
+		if participating[p.Key().String()] {
+			jirix.Logger.Infof("Project %s(%s) is already on workspace branch %q\n", p.Name, p.Path, name)
+			continue
+		}
+		exists, err := gitutil.New(jirix, gitutil.RootDirOpt(p.Path)).BranchExists(name)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return fmt.Errorf("branch %q already exists in project %s(%s)", name, p.Name, p.Path)
+		}
+		participating[p.Key().String()] = true
+		toStart = append(toStart, p)
+	}
+
+	// Record the projects in which the branch was started even if starting
+	// it in a later project fails.
+	var startErr error
+	for _, p := range toStart {
+		if startErr = startProject(jirix, p, name); startErr != nil {
+			break
+		}
+		branch.Projects = append(branch.Projects, p.Key().String())
+		jirix.Logger.Infof("Started branch %q in project %s(%s)\n", name, p.Name, p.Path)
+	}
+	if len(branch.Projects) == 0 {
+		wb.Remove(name)
+	} else {
+		sort.Strings(branch.Projects)
+		wb.Current = name
+	}
+	if err := wb.Write(jirix); err != nil {
+		return err
+	}
+	return startErr
+}
+
+// startProject creates branch at JIRI_HEAD in project p, sets its upstream
+// to the remote branch of the project and checks it out.
+func startProject(jirix *jiri.X, p project.Project, branch string) error {
+	scm := gitutil.New(jirix, gitutil.RootDirOpt(p.Path))
+	remoteBranch := p.RemoteBranch
+	if remoteBranch == "" {
+		remoteBranch = "master"
+	}
+	if err := scm.CreateBranchFromRef(branch, "JIRI_HEAD"); err != nil {
+		return fmt.Errorf("cannot create branch %q in project %s(%s): %s", branch, p.Name, p.Path, err)
+	}
+	if err := scm.SetUpstream(branch, "origin/"+remoteBranch); err != nil {
+		return fmt.Errorf("cannot set upstream of branch %q in project %s(%s): %s", branch, p.Name, p.Path, err)
+	}
+	if err := scm.CheckoutBranch(branch, p.GitSubmodules); err != nil {
+		return fmt.Errorf("cannot checkout branch %q in project %s(%s): %s", branch, p.Name, p.Path, err)
+	}
+	return nil
+}
+
+func runSwitch(jirix *jiri.X, args []string) error {
+	if len(args) != 1 {
+		return jirix.UsageErrorf("must specify the branch name")
+	}
+	name := args[0]
+	wb, err := project.ReadWorkspaceBranches(jirix)
+	if err != nil {
+		return err
+	}
+	branch, err := lookupWorkspaceBranch(wb, name)
+	if err != nil {
+		return err
+	}
+	projects, err := workspaceBranchProjects(jirix, branch)
+	if err != nil {
+		return err
+	}
+	var errs MultiError
+	for _, p := range projects {
+		if err := switchProject(jirix, p, name); err != nil {
+			errs = append(errs, fmt.Errorf("project %s(%s): %s", p.Name, p.Path, err))
+		}
+	}
+	wb.Current = name
+	if err := wb.Write(jirix); err != nil {
+		return err
+	}
+	if len(errs) != 0 {
+		return fmt.Errorf("cannot switch to workspace branch %q:\n%s", name, errs)
+	}
+	return nil
+}
+
+// switchProject checks out branch in project p, stashing uncommitted changes
+// of the current branch and restoring the ones previously stashed for branch.
+func switchProject(jirix *jiri.X, p project.Project, branch string) error {
+	scm := gitutil.New(jirix, gitutil.RootDirOpt(p.Path))
+	current := ""
+	if scm.IsOnBranch() {
+		var err error
+		if current, err = scm.CurrentBranchName(); err != nil {
+			return err
+		}
+	}
+	if current == branch {
+		return nil
+	}
+	if current == "" {
+		current = "HEAD"
+	}
+	changes, err := scm.HasUncommittedChanges()
+	if err != nil {
+		return err
+	}
+	if !changes {
+		untracked, err := scm.HasUntrackedFiles()
+		if err != nil {
+			return err
+		}
+		changes = untracked
+	}
+	if changes {
+		if stashed, err := scm.StashPush(workspaceStashMessage(current)); err != nil {
+			return err
+		} else if stashed {
+			jirix.Logger.Infof("Stashed changes of %q in project %s(%s)\n", current, p.Name, p.Path)
+		}
+	}
+	if err := scm.CheckoutBranch(branch, p.GitSubmodules); err != nil {
+		return err
+	}
+	ref, err := scm.FindStash(workspaceStashMessage(branch))
+	if err != nil {
+		return err
+	}
+	if ref != "" {
+		if err := scm.StashPopRef(ref); err != nil {
+			return fmt.Errorf("cannot restore stashed changes %s: %s", ref, err)
+		}
+		jirix.Logger.Infof("Restored stashed changes of %q in project %s(%s)\n", branch, p.Name, p.Path)
+	}
+	return nil
+}
+
+func runRebase(jirix *jiri.X, args []string) error {
+	selected := 0
+	for _, f := range []bool{rebaseFlags.continueFlag, rebaseFlags.skipFlag, rebaseFlags.abortFlag} {
+		if f {
+			selected++
+		}
+	}
+	if selected > 1 {
+		return jirix.UsageErrorf("only one of -continue, -skip and -abort can be used")
+	}
+	if selected == 1 {
+		if len(args) != 0 {
+			return jirix.UsageErrorf("no arguments are allowed with -continue, -skip or -abort")
+		}
+		switch {
+		case rebaseFlags.continueFlag:
+			return project.ContinueRebase(jirix)
+		case rebaseFlags.skipFlag:
+			return project.SkipRebase(jirix)
+		default:
+			return project.AbortRebase(jirix)
+		}
+	}
+	if len(args) > 1 {
+		return jirix.UsageErrorf("wrong number of arguments")
+	}
+	wb, err := project.ReadWorkspaceBranches(jirix)
+	if err != nil {
+		return err
+	}
+	name := wb.Current
+	if len(args) == 1 {
+		name = args[0]
+	} else if name == "" {
+		return jirix.UsageErrorf("there is no current workspace branch, please specify the branch name")
+	}
+	branch, err := lookupWorkspaceBranch(wb, name)
+	if err != nil {
+		return err
+	}
+	projects, err := workspaceBranchProjects(jirix, branch)
+	if err != nil {
+		return err
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	var failures []string
+	for _, p := range projects {
+		relativePath, err := filepath.Rel(cwd, p.Path)
+		if err != nil {
+			relativePath = p.Path
+		}
+		scm := gitutil.New(jirix, gitutil.RootDirOpt(p.Path))
+		if current, err := scm.CurrentBranchName(); err != nil || current != name {
+			failures = append(failures, fmt.Sprintf("%s(%s): not on branch %q, run \"jiri switch %s\"", p.Name, relativePath, name, name))
+			continue
+		}
+		if changes, err := scm.HasUncommittedChanges(); err != nil {
+			return err
+		} else if changes {
+			failures = append(failures, fmt.Sprintf("%s(%s): has uncommitted changes", p.Name, relativePath))
+			continue
+		}
+		rebased, err := project.TryRebase(jirix, p, "JIRI_HEAD")
+		if err != nil {
+			return err
+		}
+		if !rebased {
+			failures = append(failures, fmt.Sprintf("%s(%s): conflicts with JIRI_HEAD, please rebase manually", p.Name, relativePath))
+			continue
+		}
+		jirix.Logger.Infof("Rebased project %s(%s)\n", p.Name, relativePath)
+	}
+	if len(failures) != 0 {
+		return fmt.Errorf("cannot rebase workspace branch %q in %d project(s):\n\t%s", name, len(failures), strings.Join(failures, "\n\t"))
+	}
+	return nil
+}
+
+func runAbandon(jirix *jiri.X, args []string) error {
+	if len(args) != 1 {
+		return jirix.UsageErrorf("must specify the branch name")
+	}
+	name := args[0]
+	wb, err := project.ReadWorkspaceBranches(jirix)
+	if err != nil {
+		return err
+	}
+	branch, err := lookupWorkspaceBranch(wb, name)
+	if err != nil {
+		return err
+	}
+	projects, err := workspaceBranchProjects(jirix, branch)
+	if err != nil {
+		return err
+	}
+	var errs MultiError
+	for _, p := range projects {
+		scm := gitutil.New(jirix, gitutil.RootDirOpt(p.Path))
+		if exists, err := scm.BranchExists(name); err != nil {
+			errs = append(errs, err)
+			continue
+		} else if !exists {
+			continue
+		}
+		if current, err := scm.CurrentBranchName(); err == nil && current == name {
+			if changes, err := scm.HasUncommittedChanges(); err != nil {
+				errs = append(errs, err)
+				continue
+			} else if changes {
+				errs = append(errs, fmt.Errorf("project %s(%s) has uncommitted changes on branch %q", p.Name, p.Path, name))
+				continue
+			}
+			if err := scm.CheckoutBranch("JIRI_HEAD", p.GitSubmodules, gitutil.DetachOpt(true)); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+		}
+		if err := scm.DeleteBranch(name, gitutil.ForceOpt(true)); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		jirix.Logger.Infof("Deleted branch %q in project %s(%s)\n", name, p.Name, p.Path)
+	}
+	if len(errs) != 0 {
+		return fmt.Errorf("cannot abandon workspace branch %q:\n%s", name, errs)
+	}
+	wb.Remove(name)
+	return wb.Write(jirix)
+}
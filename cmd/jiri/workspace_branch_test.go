@@ -0,0 +1,243 @@
+// Copyright 2026 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.fuchsia.dev/jiri/gitutil"
+	"go.fuchsia.dev/jiri/jiritest"
+	"go.fuchsia.dev/jiri/project"
+)
+
+func assertCurrentBranch(t *testing.T, scm *gitutil.Git, want string) {
+	t.Helper()
+	got, err := scm.CurrentBranchName()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("wrong current branch, got %q, want %q", got, want)
+	}
+}
+
+func TestWorkspaceBranch(t *testing.T) {
+	fake, cleanup := jiritest.NewFakeJiriRoot(t)
+	defer cleanup()
+	localProjects := createProjects(t, fake, 3)
+	if err := fake.UpdateUniverse(false); err != nil {
+		t.Fatal(err)
+	}
+	var scms []*gitutil.Git
+	for _, p := range localProjects {
+		setDummyUser(t, fake.X, p.Path)
+		scms = append(scms, gitutil.New(fake.X, gitutil.RootDirOpt(p.Path)))
+	}
+
+	// Start "feature" in the first two projects.
+	if err := runStart(fake.X, []string{"feature", localProjects[0].Name, localProjects[1].Path}); err != nil {
+		t.Fatal(err)
+	}
+	for _, scm := range scms[:2] {
+		assertCurrentBranch(t, scm, "feature")
+		if upstream, err := scm.TrackingBranchName(); err != nil {
+			t.Fatal(err)
+		} else if upstream != "origin/master" {
+			t.Errorf("wrong upstream, got %q, want %q", upstream, "origin/master")
+		}
+	}
+	if scms[2].IsOnBranch() {
+		t.Errorf("project %s should not be on a branch", localProjects[2].Name)
+	}
+	wb, err := project.ReadWorkspaceBranches(fake.X)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wb.Current != "feature" {
+		t.Errorf("wrong current workspace branch, got %q, want %q", wb.Current, "feature")
+	}
+	if b := wb.Find("feature"); b == nil || len(b.Projects) != 2 {
+		t.Fatalf("wrong workspace branch record: %+v", wb)
+	}
+
+	// Switching stashes and restores uncommitted changes.
+	if err := runStart(fake.X, []string{"other", localProjects[1].Name}); err != nil {
+		t.Fatal(err)
+	}
+	assertCurrentBranch(t, scms[1], "other")
+	wip := filepath.Join(localProjects[1].Path, "wip")
+	if err := ioutil.WriteFile(wip, []byte("wip"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := runSwitch(fake.X, []string{"feature"}); err != nil {
+		t.Fatal(err)
+	}
+	assertCurrentBranch(t, scms[1], "feature")
+	if _, err := os.Stat(wip); !os.IsNotExist(err) {
+		t.Errorf("uncommitted changes should have been stashed")
+	}
+	if err := runSwitch(fake.X, []string{"other"}); err != nil {
+		t.Fatal(err)
+	}
+	assertCurrentBranch(t, scms[1], "other")
+	if _, err := os.Stat(wip); err != nil {
+		t.Errorf("stashed changes should have been restored: %v", err)
+	}
+	if err := os.Remove(wip); err != nil {
+		t.Fatal(err)
+	}
+	if err := runSwitch(fake.X, []string{"feature"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Rebase onto an updated JIRI_HEAD, with a conflict in the second project.
+	writeFile(t, fake.X, localProjects[0].Path, "local0", "local0")
+	writeFile(t, fake.X, localProjects[1].Path, "conflict", "local")
+	writeFile(t, fake.X, fake.Projects[localProjects[0].Name], "remote0", "remote0")
+	writeFile(t, fake.X, fake.Projects[localProjects[1].Name], "conflict", "remote")
+	if err := fake.UpdateUniverse(false); err != nil {
+		t.Fatal(err)
+	}
+	err = runRebase(fake.X, nil)
+	if err == nil {
+		t.Fatalf("expected rebase to fail")
+	}
+	if !strings.Contains(err.Error(), localProjects[1].Name) || strings.Contains(err.Error(), localProjects[0].Name+"(") {
+		t.Errorf("wrong conflict report: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(localProjects[0].Path, "remote0")); err != nil {
+		t.Errorf("project %s was not rebased: %v", localProjects[0].Name, err)
+	}
+	assertCurrentBranch(t, scms[1], "feature")
+	if changes, err := scms[1].HasUncommittedChanges(); err != nil {
+		t.Fatal(err)
+	} else if changes {
+		t.Errorf("failed rebase should have been aborted")
+	}
+
+	// Abandon removes the branches and the record.
+	if err := runAbandon(fake.X, []string{"feature"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, scm := range scms[:2] {
+		if exists, err := scm.BranchExists("feature"); err != nil {
+			t.Fatal(err)
+		} else if exists {
+			t.Errorf("branch feature should have been deleted")
+		}
+	}
+	if scms[0].IsOnBranch() {
+		t.Errorf("project %s should be on JIRI_HEAD", localProjects[0].Name)
+	}
+	if wb, err = project.ReadWorkspaceBranches(fake.X); err != nil {
+		t.Fatal(err)
+	}
+	if wb.Find("feature") != nil || wb.Find("other") == nil || wb.Current != "" {
+		t.Errorf("wrong workspace branches after abandon: %+v", wb)
+	}
+}
+
+// TestRebaseInteractive tests resolving the conflicts of
+// "jiri update -rebase-interactive" with "jiri rebase -continue" and
+// "jiri rebase -skip".
+func TestRebaseInteractive(t *testing.T) {
+	fake, cleanup := jiritest.NewFakeJiriRoot(t)
+	defer cleanup()
+	localProjects := createProjects(t, fake, 2)
+	if err := fake.UpdateUniverse(false); err != nil {
+		t.Fatal(err)
+	}
+	var scms []*gitutil.Git
+	var localRevs []string
+	for _, p := range localProjects {
+		setDummyUser(t, fake.X, p.Path)
+		scm := gitutil.New(fake.X, gitutil.RootDirOpt(p.Path))
+		if err := scm.CreateBranchWithUpstream("feature", "origin/master"); err != nil {
+			t.Fatal(err)
+		}
+		if err := scm.CheckoutBranch("feature", false); err != nil {
+			t.Fatal(err)
+		}
+		writeFile(t, fake.X, p.Path, "conflict", "local")
+		rev, err := scm.CurrentRevision()
+		if err != nil {
+			t.Fatal(err)
+		}
+		scms = append(scms, scm)
+		localRevs = append(localRevs, rev)
+		writeFile(t, fake.X, fake.Projects[p.Name], "conflict", "remote")
+	}
+
+	fake.X.RebaseInteractive = true
+	if err := project.UpdateUniverse(fake.X, false, false, true /*rebaseTracked*/, false, false, false, false, project.DefaultHookTimeout, project.DefaultPackageTimeout); err != nil {
+		t.Fatal(err)
+	}
+	if err := project.RunPendingRebases(fake.X); err == nil {
+		t.Fatalf("expected the update to stop at a conflict")
+	}
+	state, err := project.ReadRebaseState(fake.X)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Current == nil || len(state.Pending) != 1 {
+		t.Fatalf("wrong rebase state: %+v", state)
+	}
+	first, second := 0, 1
+	if state.Current.Path != localProjects[first].Path {
+		first, second = second, first
+	}
+	if inProgress, err := scms[first].RebaseInProgress(); err != nil {
+		t.Fatal(err)
+	} else if !inProgress {
+		t.Fatalf("rebase should be in progress in project %s", localProjects[first].Name)
+	}
+
+	// Resolve the conflict and continue, which stops at the second project.
+	if err := ioutil.WriteFile(filepath.Join(localProjects[first].Path, "conflict"), []byte("resolved"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := scms[first].Add("conflict"); err != nil {
+		t.Fatal(err)
+	}
+	rebaseFlags.continueFlag = true
+	err = runRebase(fake.X, nil)
+	rebaseFlags.continueFlag = false
+	if err == nil {
+		t.Fatalf("expected the rebase to stop at the second project")
+	}
+	if inProgress, err := scms[first].RebaseInProgress(); err != nil {
+		t.Fatal(err)
+	} else if inProgress {
+		t.Errorf("rebase should be done in project %s", localProjects[first].Name)
+	}
+	if content, err := ioutil.ReadFile(filepath.Join(localProjects[first].Path, "conflict")); err != nil {
+		t.Fatal(err)
+	} else if string(content) != "resolved" {
+		t.Errorf("wrong content after rebase, got %q", content)
+	}
+
+	// Skip the second project.
+	rebaseFlags.skipFlag = true
+	err = runRebase(fake.X, nil)
+	rebaseFlags.skipFlag = false
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertCurrentBranch(t, scms[second], "feature")
+	if rev, err := scms[second].CurrentRevision(); err != nil {
+		t.Fatal(err)
+	} else if rev != localRevs[second] {
+		t.Errorf("skipped branch should be unchanged")
+	}
+	if state, err = project.ReadRebaseState(fake.X); err != nil {
+		t.Fatal(err)
+	} else if state.InProgress() {
+		t.Errorf("interactive rebase should be done: %+v", state)
+	}
+}
@@ -6,12 +6,15 @@ package gerrit
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"io"
+	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	"go.fuchsia.dev/jiri"
 	"go.fuchsia.dev/jiri/collect"
@@ -21,62 +24,166 @@ import (
 type credentials struct {
 	username string
 	password string
+	// token is an OAuth access token. If it is set, it is sent as a
+	// bearer token instead of using basic authentication.
+	token string
+}
+
+// setAuth adds the authorization header for c to req.
+func (c *credentials) setAuth(req *http.Request) {
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+		return
+	}
+	req.SetBasicAuth(c.username, c.password)
+}
+
+// credsEntry holds the credentials of a host once they were found. Its
+// mutex serializes the lookups for the host.
+type credsEntry struct {
+	mu    sync.Mutex
+	creds *credentials
+}
+
+var (
+	credsCache   = map[string]*credsEntry{}
+	credsCacheMu sync.Mutex
+)
+
+// credentialSource looks up credentials for a host in a single location.
+// It returns an error describing why no credentials were found.
+type credentialSource struct {
+	name   string
+	lookup func(jirix *jiri.X, hostUrl *url.URL) (*credentials, error)
+}
+
+var credentialSources = []credentialSource{
+	{"netrc", netrcCredentials},
+	{"git cookie file", gitCookieCredentials},
+	{"git credential helper", gitCredentialHelperCredentials},
+	{"token command", tokenCommandCredentials},
 }
 
 // hostCredentials returns credentials for the given Gerrit host. The
 // function uses best effort to scan common locations where the
-// credentials could exist.
-func hostCredentials(jirix *jiri.X, hostUrl *url.URL) (_ *credentials, e error) {
-	// Look for the host credentials in the .netrc file.
-	netrcPath := filepath.Join(os.Getenv("HOME"), ".netrc")
-	file, err := os.Open(netrcPath)
+// credentials could exist. The credentials are cached for the lifetime of the
+// process once they are found, so that credential helpers and token commands
+// run at most once per host. Failures are not cached, so that a transient
+// failure of a credential helper or token command can be retried.
+func hostCredentials(jirix *jiri.X, hostUrl *url.URL) (*credentials, error) {
+	credsCacheMu.Lock()
+	entry, ok := credsCache[hostUrl.Host]
+	if !ok {
+		entry = &credsEntry{}
+		credsCache[hostUrl.Host] = entry
+	}
+	credsCacheMu.Unlock()
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+	if entry.creds != nil {
+		return entry.creds, nil
+	}
+	creds, err := lookupCredentials(jirix, hostUrl)
 	if err != nil {
-		if !os.IsNotExist(err) {
-			return nil, err
-		}
-	} else {
-		defer collect.Error(func() error { return file.Close() }, &e)
-		credsMap, err := parseNetrcFile(file)
+		return nil, err
+	}
+	entry.creds = creds
+	return creds, nil
+}
+
+// lookupCredentials returns the credentials of the first credential source
+// which has credentials for the host.
+func lookupCredentials(jirix *jiri.X, hostUrl *url.URL) (*credentials, error) {
+	var errs []string
+	for _, source := range credentialSources {
+		creds, err := source.lookup(jirix, hostUrl)
 		if err != nil {
-			return nil, err
+			errs = append(errs, fmt.Sprintf("%s: %v", source.name, err))
+			continue
 		}
-		creds, ok := credsMap[hostUrl.Host]
-		if ok {
+		jirix.Logger.Debugf("using credentials from %s for %q", source.name, hostUrl.Host)
+		return creds, nil
+	}
+	return nil, fmt.Errorf("cannot find credentials for %q:\n\t%s", hostUrl.String(), strings.Join(errs, "\n\t"))
+}
+
+// netrcCredentials looks for the host credentials in the .netrc file.
+func netrcCredentials(jirix *jiri.X, hostUrl *url.URL) (_ *credentials, e error) {
+	netrcPath := filepath.Join(os.Getenv("HOME"), ".netrc")
+	file, err := os.Open(netrcPath)
+	if err != nil {
+		return nil, err
+	}
+	defer collect.Error(func() error { return file.Close() }, &e)
+	credsMap, err := parseNetrcFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if creds, ok := credsMap[hostUrl.Host]; ok {
+		return creds, nil
+	}
+	return nil, fmt.Errorf("no entry for %q in %q", hostUrl.Host, netrcPath)
+}
+
+// gitCookieCredentials looks for the host credentials in the git cookie
+// file.
+func gitCookieCredentials(jirix *jiri.X, hostUrl *url.URL) (_ *credentials, e error) {
+	cookieFilePath, err := gitutil.New(jirix).ConfigGetKey("http.cookiefile")
+	if err != nil {
+		return nil, errors.New("http.cookiefile is not set in git config")
+	}
+	cookieFilePath = strings.TrimSpace(cookieFilePath)
+	file, err := os.Open(cookieFilePath)
+	if err != nil {
+		return nil, err
+	}
+	defer collect.Error(func() error { return file.Close() }, &e)
+	credsMap, err := parseGitCookieFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if creds, ok := credsMap[hostUrl.Host]; ok {
+		return creds, nil
+	}
+	// Account for site-wide credentials. Namely, the git cookie
+	// file can contain credentials of the form ".<name>", which
+	// should match any host "*.<name>".
+	for host, creds := range credsMap {
+		if strings.HasPrefix(host, ".") && strings.HasSuffix(hostUrl.Host, host) {
 			return creds, nil
 		}
 	}
+	return nil, fmt.Errorf("no cookie for %q in %q", hostUrl.Host, cookieFilePath)
+}
 
-	// Look for the host credentials in the git cookie file.
-	if cookieFilePath, err := gitutil.New(jirix).ConfigGetKey("http.cookiefile"); err == nil {
-		cookieFilePath = strings.TrimSpace(cookieFilePath)
-		file, err := os.Open(cookieFilePath)
-		if err != nil {
-			if !os.IsNotExist(err) {
-				return nil, err
-			}
-		} else {
-			defer collect.Error(func() error { return file.Close() }, &e)
-			credsMap, err := parseGitCookieFile(file)
-			if err != nil {
-				return nil, err
-			}
-			creds, ok := credsMap[hostUrl.Host]
-			if ok {
-				return creds, nil
-			}
-			// Account for site-wide credentials. Namely, the git cookie
-			// file can contain credentials of the form ".<name>", which
-			// should match any host "*.<name>".
-			for host, creds := range credsMap {
-				if strings.HasPrefix(host, ".") && strings.HasSuffix(hostUrl.Host, host) {
-					return creds, nil
-				}
-			}
+// gitCredentialHelperCredentials asks the git credential helpers
+// configured by the user for the host credentials.
+func gitCredentialHelperCredentials(jirix *jiri.X, hostUrl *url.URL) (*credentials, error) {
+	u := url.URL{Scheme: hostUrl.Scheme, Host: hostUrl.Host}
+	if u.Scheme == "" {
+		u.Scheme = "https"
+	}
+	username, password, err := gitutil.New(jirix).CredentialFill(u.String())
+	if err != nil {
+		if gitErr, ok := err.(gitutil.GitError); ok {
+			return nil, fmt.Errorf("'git credential fill' failed: %s", strings.TrimSpace(gitErr.ErrorOutput))
 		}
-	} else {
-		return nil, fmt.Errorf("cannot find credentials for %q: %s", hostUrl.String(), err)
+		return nil, err
+	}
+	return &credentials{username: username, password: password}, nil
+}
+
+// tokenCommandCredentials runs the token command from the jiri config and
+// uses its output as an OAuth token for the host.
+func tokenCommandCredentials(jirix *jiri.X, hostUrl *url.URL) (*credentials, error) {
+	token, err := jirix.RunTokenCommand(hostUrl.Host)
+	if err != nil {
+		return nil, err
+	}
+	if token == "" {
+		return nil, fmt.Errorf("%q printed an empty token", jirix.TokenCommand)
 	}
-	return nil, fmt.Errorf("cannot find credentials for %q", hostUrl.String())
+	return &credentials{token: token}, nil
 }
 
 // parseGitCookieFile parses the content of the given git cookie file
@@ -129,7 +129,7 @@ func (g *Gerrit) PostReview(ref string, message string, labels map[string]string
 		return fmt.Errorf("NewRequest(%q, %q, %v) failed: %v", method, url, body, err)
 	}
 	req.Header.Add("Content-Type", "application/json;charset=UTF-8")
-	req.SetBasicAuth(cred.username, cred.password)
+	cred.setAuth(req)
 	res, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("Do(%v) failed: %v", req, err)
@@ -165,7 +165,7 @@ func (g *Gerrit) SetTopic(cl string, opts CLOpts) (e error) {
 		return fmt.Errorf("NewRequest(%q, %q, %v) failed: %v", method, url, body, err)
 	}
 	req.Header.Add("Content-Type", "application/json;charset=UTF-8")
-	req.SetBasicAuth(cred.username, cred.password)
+	cred.setAuth(req)
 	res, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("Do(%v) failed: %v", req, err)
@@ -345,7 +345,7 @@ func makeRequest(method, url string, body io.Reader, cred *credentials) (*http.R
 	req.Header.Add("Accept", "application/json")
 	// We ignore all errors when obtaining credentials since not every host requires them.
 	if cred != nil {
-		req.SetBasicAuth(cred.username, cred.password)
+		cred.setAuth(req)
 	}
 
 	res, err := http.DefaultClient.Do(req)
@@ -505,7 +505,7 @@ func (g *Gerrit) Submit(changeID string) (e error) {
 		return fmt.Errorf("NewRequest(%q, %q, %v) failed: %v", method, url, body, err)
 	}
 	req.Header.Add("Content-Type", "application/json;charset=UTF-8")
-	req.SetBasicAuth(cred.username, cred.password)
+	cred.setAuth(req)
 
 	res, err := http.DefaultClient.Do(req)
 	if err != nil {
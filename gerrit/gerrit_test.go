@@ -6,9 +6,14 @@ package gerrit
 
 import (
 	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
 	"reflect"
 	"strings"
 	"testing"
+
+	"go.fuchsia.dev/jiri/jiritest/xtest"
 )
 
 func TestParseQueryResults(t *testing.T) {
@@ -381,3 +386,80 @@ func TestReference(t *testing.T) {
 
 // TODO(jsimsa): Add a test for the hostCredentials function that
 // exercises the logic that reads the .netrc and git cookie files.
+
+func TestHostCredentialsTokenCommand(t *testing.T) {
+	home, err := ioutil.TempDir("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(home)
+	oldHome := os.Getenv("HOME")
+	defer os.Setenv("HOME", oldHome)
+	os.Setenv("HOME", home)
+	jirix, cleanup := xtest.NewX(t)
+	defer cleanup()
+
+	hostUrl, err := url.Parse("https://example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resetCache := func() {
+		credsCacheMu.Lock()
+		credsCache = map[string]*credsEntry{}
+		credsCacheMu.Unlock()
+	}
+	defer resetCache()
+
+	// Every source fails, the error should mention all of them.
+	resetCache()
+	jirix.TokenCommand = "false"
+	if _, err := hostCredentials(jirix, hostUrl); err == nil {
+		t.Fatalf("expected error when no credentials are available")
+	} else {
+		for _, source := range credentialSources {
+			if !strings.Contains(err.Error(), source.name+":") {
+				t.Errorf("expected error to mention %q, got %q", source.name, err)
+			}
+		}
+	}
+
+	// "echo" prints the host name which is passed as the last argument.
+	resetCache()
+	jirix.TokenCommand = "echo"
+	creds, err := hostCredentials(jirix, hostUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := creds.token, "example.com"; got != want {
+		t.Errorf("wrong token, got %q, want %q", got, want)
+	}
+
+	// Credentials should be served from the cache.
+	jirix.TokenCommand = "false"
+	if _, err := hostCredentials(jirix, hostUrl); err != nil {
+		t.Errorf("expected cached credentials, got error: %v", err)
+	}
+
+	// Failures should not be cached, so that the lookup is retried.
+	resetCache()
+	if _, err := hostCredentials(jirix, hostUrl); err == nil {
+		t.Fatalf("expected error when no credentials are available")
+	}
+	jirix.TokenCommand = "echo"
+	if creds, err = hostCredentials(jirix, hostUrl); err != nil {
+		t.Fatalf("expected the lookup to be retried, got error: %v", err)
+	}
+	if got, want := creds.token, "example.com"; got != want {
+		t.Errorf("wrong token, got %q, want %q", got, want)
+	}
+
+	// Arguments of the token command can be quoted.
+	resetCache()
+	jirix.TokenCommand = `printf '%s\n' "a token"`
+	if creds, err = hostCredentials(jirix, hostUrl); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := creds.token, "a token"; got != want {
+		t.Errorf("wrong token, got %q, want %q", got, want)
+	}
+}
@@ -188,6 +188,42 @@ func dumpHeaders(resp *http.Response) string {
 	return output.String()
 }
 
+// fetchFileWithCredentials downloads a file from gerritHost using the
+// credentials returned by hostCredentials.
+func fetchFileWithCredentials(jirix *jiri.X, gerritHost, path string) ([]byte, error) {
+	hostUrl, err := url.Parse(gerritHost)
+	if err != nil {
+		return nil, err
+	}
+	cred, err := hostCredentials(jirix, hostUrl)
+	if err != nil {
+		return nil, err
+	}
+	downloadPath := gerritHost + path
+	req, err := http.NewRequest("GET", downloadPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	cred.setAuth(req)
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		if resp.StatusCode == http.StatusForbidden {
+			return nil, ErrHTTPForbidden
+		}
+		return nil, fmt.Errorf("expecting status code %d from %q, got %d ", http.StatusOK, downloadPath, resp.StatusCode)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
 // FetchFileSSO downloads a file from a gerrit host that requires SSO login
 // and returns its content to a byte slice. Since it uses user's master SSO
 // cookie, the scheme of the url should always be HTTPS, otherwise an error
@@ -244,6 +280,17 @@ func FetchFileSSO(jirix *jiri.X, gerritHost, path string) ([]byte, error) {
 			return nil, ErrSSOCookieExpireInvalid
 
 		default:
+			if cookieType == GitCookieOnly {
+				// Git cookies could not be loaded. Fall back to the
+				// credentials used for Gerrit REST calls, which also
+				// cover git credential helpers and token commands.
+				credData, credErr := fetchFileWithCredentials(jirix, gerritHost, path)
+				if credErr == nil {
+					jirix.Logger.Debugf("fetched %s:%s using host credentials", gerritHost, path)
+					return credData, nil
+				}
+				jirix.Logger.Debugf("failed to fetch %s%s using host credentials: %v", gerritHost, path, credErr)
+			}
 			return nil, err
 		}
 	}
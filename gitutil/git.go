@@ -1121,6 +1121,80 @@ func (g *Git) Rebase(upstream string, opts ...RebaseOpt) error {
 	return g.run(args...)
 }
 
+// RebaseContinue continues an in-progress rebase after the conflicts have
+// been resolved, keeping the commit messages unchanged.
+func (g *Git) RebaseContinue() error {
+	args := []string{"rebase", "--continue"}
+	var stdout, stderr bytes.Buffer
+	if err := g.runGitWithInput(os.Stdin, map[string]string{"GIT_EDITOR": "true"}, &stdout, &stderr, args...); err != nil {
+		return Error(stdout.String(), stderr.String(), err, g.rootDir, args...)
+	}
+	return nil
+}
+
+// RebaseInProgress returns true if a rebase is in progress.
+func (g *Git) RebaseInProgress() (bool, error) {
+	for _, dir := range []string{"rebase-merge", "rebase-apply"} {
+		out, err := g.runOutput("rev-parse", "--git-path", dir)
+		if err != nil {
+			return false, err
+		}
+		if len(out) != 1 {
+			return false, fmt.Errorf("unexpected length of %v: got %v, want 1", out, len(out))
+		}
+		path := out[0]
+		if !filepath.IsAbs(path) && g.rootDir != "" {
+			path = filepath.Join(g.rootDir, path)
+		}
+		if _, err := os.Stat(path); err == nil {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Am applies the patches in the given mailbox file as commits. Leading
+// path components are removed from the paths in the patches as given by
+// strip, in the same way as "git am -p<strip>".
+func (g *Git) Am(file string, strip int) error {
+	return g.run("am", "--keep-cr", fmt.Sprintf("-p%d", strip), file)
+}
+
+// AmAbort aborts an in-progress "git am" operation.
+func (g *Git) AmAbort() error {
+	// First check if am is in progress
+	path := ".git/rebase-apply/applying"
+	if g.rootDir != "" {
+		path = filepath.Join(g.rootDir, path)
+	}
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			return nil // Not in progress return
+		}
+		return err
+	}
+	return g.run("am", "--abort")
+}
+
+// Apply applies the patch in the given file to the working tree and the
+// index. Leading path components are removed from the paths in the patch
+// as given by strip.
+func (g *Git) Apply(file string, strip int) error {
+	return g.run("apply", "--index", fmt.Sprintf("-p%d", strip), file)
+}
+
+// FormatPatch returns the commits in base..head in mailbox format. The
+// given prefixes are used instead of the default "a/" and "b/" source and
+// destination prefixes.
+func (g *Git) FormatPatch(base, head, srcPrefix, dstPrefix string) (string, error) {
+	args := []string{"format-patch", "--stdout", "--src-prefix=" + srcPrefix, "--dst-prefix=" + dstPrefix, base + ".." + head}
+	var stdout, stderr bytes.Buffer
+	if err := g.runGit(&stdout, &stderr, args...); err != nil {
+		return "", Error(stdout.String(), stderr.String(), err, g.rootDir, args...)
+	}
+	return stdout.String(), nil
+}
+
 // CherryPickAbort aborts an in-progress cherry-pick operation.
 func (g *Git) CherryPickAbort() error {
 	// First check if cherry-pick is in progress
@@ -1177,6 +1251,37 @@ func (g *Git) ConfigGetKey(key string) (string, error) {
 	return out[0], nil
 }
 
+// CredentialFill asks the configured git credential helpers for the
+// username and password of the given URL using "git credential fill".
+// Terminal prompts are disabled, so an error is returned if no helper
+// can supply the credentials.
+func (g *Git) CredentialFill(rawURL string) (string, string, error) {
+	args := []string{"credential", "fill"}
+	input := strings.NewReader(fmt.Sprintf("url=%s\n\n", rawURL))
+	var stdout, stderr bytes.Buffer
+	env := map[string]string{"GIT_TERMINAL_PROMPT": "0"}
+	if err := g.runGitWithInput(input, env, &stdout, &stderr, args...); err != nil {
+		return "", "", Error(stdout.String(), stderr.String(), err, g.rootDir, args...)
+	}
+	username, password := "", ""
+	for _, line := range trimOutput(stdout.String()) {
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "username":
+			username = kv[1]
+		case "password":
+			password = kv[1]
+		}
+	}
+	if password == "" {
+		return "", "", fmt.Errorf("git credential helpers returned no password for %q", rawURL)
+	}
+	return username, password, nil
+}
+
 // RemoteUrl gets the url of the remote with the given name.
 func (g *Git) RemoteUrl(name string) (string, error) {
 	configKey := fmt.Sprintf("remote.%s.url", name)
@@ -1238,6 +1343,44 @@ func (g *Git) Stash() (bool, error) {
 	return newSize > oldSize, nil
 }
 
+// StashPush stashes any unsaved changes, including untracked files, with
+// the given message. It returns true if anything was actually stashed.
+func (g *Git) StashPush(message string) (bool, error) {
+	oldSize, err := g.StashSize()
+	if err != nil {
+		return false, err
+	}
+	if err := g.run("stash", "push", "--include-untracked", "-m", message); err != nil {
+		return false, err
+	}
+	newSize, err := g.StashSize()
+	if err != nil {
+		return false, err
+	}
+	return newSize > oldSize, nil
+}
+
+// FindStash returns the most recent stash entry that was created with the
+// given message, or the empty string if there is no such entry.
+func (g *Git) FindStash(message string) (string, error) {
+	out, err := g.runOutput("stash", "list", "--format=%gd %gs")
+	if err != nil {
+		return "", err
+	}
+	for _, line := range out {
+		parts := strings.SplitN(line, " ", 2)
+		if len(parts) == 2 && strings.HasSuffix(parts[1], ": "+message) {
+			return parts[0], nil
+		}
+	}
+	return "", nil
+}
+
+// StashPopRef pops the given stash entry into the current working tree.
+func (g *Git) StashPopRef(ref string) error {
+	return g.run("stash", "pop", ref)
+}
+
 // StashSize returns the size of the stash stack.
 func (g *Git) StashSize() (int, error) {
 	out, err := g.runOutput("stash", "list")
@@ -1286,6 +1429,189 @@ func (g *Git) Show(ref, file string) (string, error) {
 	return strings.Join(out, "\n"), nil
 }
 
+// ShowBytes returns the content of file at ref. Unlike Show, the content is
+// returned as is.
+func (g *Git) ShowBytes(ref, file string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	args := []string{"show", fmt.Sprintf("%s:%s", ref, file)}
+	if err := g.runGit(&stdout, &stderr, args...); err != nil {
+		return nil, Error(stdout.String(), stderr.String(), err, g.rootDir, args...)
+	}
+	return stdout.Bytes(), nil
+}
+
+// treeEntry is an entry of "git ls-tree -r -l".
+type treeEntry struct {
+	mode   string
+	typ    string
+	object string
+	size   int64
+	path   string
+}
+
+// lsTree lists the files and submodules of the tree at ref.
+func (g *Git) lsTree(ref string) ([]treeEntry, error) {
+	var stdout, stderr bytes.Buffer
+	args := []string{"ls-tree", "-r", "-l", "-z", "--full-tree", ref}
+	if err := g.runGit(&stdout, &stderr, args...); err != nil {
+		return nil, Error(stdout.String(), stderr.String(), err, g.rootDir, args...)
+	}
+	var entries []treeEntry
+	for _, line := range strings.Split(stdout.String(), "\x00") {
+		tab := strings.IndexByte(line, '\t')
+		if tab < 0 {
+			continue
+		}
+		fields := strings.Fields(line[:tab])
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("unexpected ls-tree output %q", line)
+		}
+		entry := treeEntry{mode: fields[0], typ: fields[1], object: fields[2], path: line[tab+1:]}
+		if fields[3] != "-" {
+			size, err := strconv.ParseInt(fields[3], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("unexpected ls-tree output %q", line)
+			}
+			entry.size = size
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// Submodules returns the commits of the submodules recorded at ref, by
+// path. Submodules of submodules are not included.
+func (g *Git) Submodules(ref string) (map[string]string, error) {
+	entries, err := g.lsTree(ref)
+	if err != nil {
+		return nil, err
+	}
+	submodules := make(map[string]string)
+	for _, entry := range entries {
+		if entry.typ == "commit" {
+			submodules[entry.path] = entry.object
+		}
+	}
+	return submodules, nil
+}
+
+// SetGitlink records commit rev for the submodule at path in the index,
+// without checking out the submodule.
+func (g *Git) SetGitlink(path, rev string) error {
+	return g.run("update-index", "--add", "--cacheinfo", "160000,"+rev+","+path)
+}
+
+// RemoveFromIndex removes path from the index, and leaves the working tree
+// alone.
+func (g *Git) RemoveFromIndex(path string) error {
+	return g.run("update-index", "--force-remove", "--", path)
+}
+
+// SubmoduleStatus returns the commits checked out in the initialized
+// submodules, by path.
+func (g *Git) SubmoduleStatus() (map[string]string, error) {
+	var stdout, stderr bytes.Buffer
+	args := []string{"submodule", "status"}
+	if err := g.runGit(&stdout, &stderr, args...); err != nil {
+		return nil, Error(stdout.String(), stderr.String(), err, g.rootDir, args...)
+	}
+	status := make(map[string]string)
+	for _, line := range strings.Split(stdout.String(), "\n") {
+		// Lines look like "+<sha1> <path> (<describe>)", where the first
+		// character is "-" for submodules which are not initialized.
+		if line == "" || line[0] == '-' {
+			continue
+		}
+		fields := strings.Fields(line[1:])
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("unexpected submodule status %q", line)
+		}
+		status[fields[1]] = fields[0]
+	}
+	return status, nil
+}
+
+// LFSPointer is a git-lfs pointer file, which stands for an object stored
+// in git-lfs.
+type LFSPointer struct {
+	Path string
+	// OID is the sha256 of the object, like "sha256:4d7a...".
+	OID  string
+	Size int64
+}
+
+// lfsPointerMaxSize is the maximum size of git-lfs pointer files.
+const lfsPointerMaxSize = 1024
+
+// ParseLFSPointer parses the content of a git-lfs pointer file. It returns
+// false if data is not a pointer file.
+func ParseLFSPointer(data []byte) (oid string, size int64, ok bool) {
+	if len(data) > lfsPointerMaxSize || !bytes.HasPrefix(data, []byte("version https://git-lfs.github.com/spec/")) {
+		return "", 0, false
+	}
+	sizeSet := false
+	for _, line := range strings.Split(string(data), "\n") {
+		switch {
+		case strings.HasPrefix(line, "oid "):
+			oid = strings.TrimPrefix(line, "oid ")
+		case strings.HasPrefix(line, "size "):
+			n, err := strconv.ParseInt(strings.TrimPrefix(line, "size "), 10, 64)
+			if err != nil {
+				return "", 0, false
+			}
+			size, sizeSet = n, true
+		}
+	}
+	return oid, size, oid != "" && sizeSet
+}
+
+// LFSPointers returns the git-lfs pointer files at ref.
+func (g *Git) LFSPointers(ref string) ([]LFSPointer, error) {
+	entries, err := g.lsTree(ref)
+	if err != nil {
+		return nil, err
+	}
+	var candidates []treeEntry
+	var input bytes.Buffer
+	for _, entry := range entries {
+		if entry.typ == "blob" && entry.size <= lfsPointerMaxSize {
+			candidates = append(candidates, entry)
+			input.WriteString(entry.object + "\n")
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+	var stdout, stderr bytes.Buffer
+	args := []string{"cat-file", "--batch"}
+	if err := g.runGitWithInput(&input, nil, &stdout, &stderr, args...); err != nil {
+		return nil, Error(stdout.String(), stderr.String(), err, g.rootDir, args...)
+	}
+	// The output is "<object> <type> <size>\n<content>\n" for each object.
+	var pointers []LFSPointer
+	out := stdout.Bytes()
+	for _, entry := range candidates {
+		nl := bytes.IndexByte(out, '\n')
+		if nl < 0 {
+			return nil, fmt.Errorf("unexpected cat-file output for %s", entry.path)
+		}
+		fields := strings.Fields(string(out[:nl]))
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("unexpected cat-file output %q", out[:nl])
+		}
+		size, err := strconv.Atoi(fields[2])
+		if err != nil || nl+1+size+1 > len(out) {
+			return nil, fmt.Errorf("unexpected cat-file output %q", out[:nl])
+		}
+		content := out[nl+1 : nl+1+size]
+		out = out[nl+1+size+1:]
+		if oid, size, ok := ParseLFSPointer(content); ok {
+			pointers = append(pointers, LFSPointer{Path: entry.path, OID: oid, Size: size})
+		}
+	}
+	return pointers, nil
+}
+
 // UntrackedFiles returns the list of files that are not tracked.
 func (g *Git) UntrackedFiles() ([]string, error) {
 	out, err := g.runOutput("ls-files", "--others", "--directory", "--exclude-standard")
@@ -1358,6 +1684,12 @@ func (g *Git) runInteractive(args ...string) error {
 }
 
 func (g *Git) runGit(stdout, stderr io.Writer, args ...string) error {
+	return g.runGitWithInput(os.Stdin, nil, stdout, stderr, args...)
+}
+
+// runGitWithInput runs git with the given stdin and additional environment
+// variables, which take precedence over the ones set in g.
+func (g *Git) runGitWithInput(stdin io.Reader, extraEnv map[string]string, stdout, stderr io.Writer, args ...string) error {
 	if g.userName != "" {
 		args = append([]string{"-c", fmt.Sprintf("user.name=%s", g.userName)}, args...)
 	}
@@ -1368,11 +1700,11 @@ func (g *Git) runGit(stdout, stderr io.Writer, args ...string) error {
 	var errbuf bytes.Buffer
 	command := exec.Command("git", args...)
 	command.Dir = g.rootDir
-	command.Stdin = os.Stdin
+	command.Stdin = stdin
 	command.Stdout = io.MultiWriter(stdout, &outbuf)
 	command.Stderr = io.MultiWriter(stderr, &errbuf)
 	env := g.jirix.Env()
-	env = envvar.MergeMaps(g.opts, env)
+	env = envvar.MergeMaps(g.opts, env, extraEnv)
 	command.Env = envvar.MapToSlice(env)
 	dir := g.rootDir
 	if dir == "" {
@@ -0,0 +1,64 @@
+// Copyright 2026 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package osutil
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CheckExtractPath fails if path, an entry of an archive extracted in dir,
+// would be written outside of dir by following the symlinks which were
+// already extracted, including a symlink at path itself. If the entry is a
+// symlink, target is its target, which must not point outside of dir either.
+func CheckExtractPath(dir, path, target string) error {
+	root, err := filepath.EvalSymlinks(dir)
+	if os.IsNotExist(err) {
+		root, err = filepath.Abs(dir)
+	}
+	if err != nil {
+		return err
+	}
+	rel, err := filepath.Rel(dir, filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	parent := resolvePath(root, rel)
+	if !inDir(root, parent) || !inDir(root, resolvePath(parent, filepath.Base(path))) {
+		return fmt.Errorf("%q is outside of %q", path, dir)
+	}
+	if target == "" {
+		return nil
+	}
+	if filepath.IsAbs(target) || !inDir(root, resolvePath(parent, target)) {
+		return fmt.Errorf("symlink %q to %q points outside of %q", path, target, dir)
+	}
+	return nil
+}
+
+// resolvePath returns the path rel relative to base, following the existing
+// symlinks one component at a time like the kernel does.
+func resolvePath(base, rel string) string {
+	path := base
+	for _, elem := range strings.Split(filepath.ToSlash(rel), "/") {
+		switch elem {
+		case "", ".":
+		case "..":
+			path = filepath.Dir(path)
+		default:
+			path = filepath.Join(path, elem)
+			if resolved, err := filepath.EvalSymlinks(path); err == nil {
+				path = resolved
+			}
+		}
+	}
+	return path
+}
+
+func inDir(dir, path string) bool {
+	return path == dir || strings.HasPrefix(path, dir+string(filepath.Separator))
+}
@@ -0,0 +1,373 @@
+// Copyright 2026 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package owners resolves the owners of files in a jiri root from OWNERS
+// files.
+//
+// An OWNERS file lists, one per line:
+//
+//	user@example.com            an owner of the directory
+//	*                           anyone can approve changes in the directory
+//	set noparent                stop looking for owners in parent directories
+//	per-file <globs>=<owners>   owners of the matching files only
+//	include <file>              include the owners of another OWNERS file
+//	file://<file>               same as "include <file>"
+//
+// <globs> and <owners> are comma separated, and the owners of a per-file
+// line can also be "*" or "file://<file>". Text following "#" is a comment.
+//
+// The file of an include is relative to the directory of the OWNERS file,
+// relative to the jiri root if it starts with "/", or relative to a project
+// if it has the form "<project>:<file>". Only the owners and "*" lines of an
+// included file are used.
+package owners
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// FileName is the name of the files listing owners.
+const FileName = "OWNERS"
+
+type perFile struct {
+	globs  []string
+	owners []string
+	anyone bool
+}
+
+// ownersFile is a parsed OWNERS file, with its includes expanded.
+type ownersFile struct {
+	owners   []string
+	anyone   bool
+	noParent bool
+	perFile  []perFile
+}
+
+// Owners are the owners of a single file.
+type Owners struct {
+	// Path is the path of the file.
+	Path string
+	// Owners are the owners of the file, closest first.
+	Owners []string
+	// Anyone is true if anyone can approve changes to the file.
+	Anyone bool
+	// Files are the OWNERS files which were consulted, closest first.
+	Files []string
+
+	// distance maps owners to the number of directories between the file
+	// and the closest OWNERS file listing them.
+	distance map[string]int
+}
+
+// Resolver resolves owners of files in a jiri root. It caches the OWNERS
+// files it reads.
+type Resolver struct {
+	root     string
+	projects map[string]string
+	cache    map[string]*ownersFile
+}
+
+// NewResolver returns a resolver for the jiri root at root. projects maps
+// project names to their paths, and is used for includes of files in other
+// projects.
+func NewResolver(root string, projects map[string]string) *Resolver {
+	return &Resolver{
+		root:     filepath.Clean(root),
+		projects: projects,
+		cache:    make(map[string]*ownersFile),
+	}
+}
+
+// Owners returns the owners of the file at path, which must be inside the
+// jiri root.
+func (r *Resolver) Owners(path string) (*Owners, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	rel, err := filepath.Rel(r.root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil, fmt.Errorf("%q is not inside the jiri root %q", path, r.root)
+	}
+	result := &Owners{Path: path, distance: make(map[string]int)}
+	add := func(owners []string, distance int) {
+		for _, o := range owners {
+			if _, ok := result.distance[o]; !ok {
+				result.distance[o] = distance
+				result.Owners = append(result.Owners, o)
+			}
+		}
+	}
+	dir := path
+	if fi, err := os.Stat(path); err != nil || !fi.IsDir() {
+		dir = filepath.Dir(path)
+	}
+	for distance := 0; ; distance++ {
+		file := filepath.Join(dir, FileName)
+		f, err := r.load(file)
+		if err != nil {
+			return nil, err
+		}
+		if f != nil {
+			result.Files = append(result.Files, file)
+			name, err := filepath.Rel(dir, path)
+			if err != nil {
+				return nil, err
+			}
+			for _, pf := range f.perFile {
+				if pf.matches(filepath.ToSlash(name)) {
+					add(pf.owners, distance)
+					result.Anyone = result.Anyone || pf.anyone
+				}
+			}
+			add(f.owners, distance)
+			result.Anyone = result.Anyone || f.anyone
+			if f.noParent {
+				break
+			}
+		}
+		if dir == r.root {
+			break
+		}
+		dir = filepath.Dir(dir)
+	}
+	return result, nil
+}
+
+func (pf *perFile) matches(name string) bool {
+	for _, glob := range pf.globs {
+		if ok, _ := filepath.Match(glob, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// load returns the parsed OWNERS file at path, or nil if there is no such
+// file.
+func (r *Resolver) load(path string) (*ownersFile, error) {
+	if f, ok := r.cache[path]; ok {
+		return f, nil
+	}
+	f, err := r.parse(path, map[string]bool{})
+	if err != nil {
+		return nil, err
+	}
+	r.cache[path] = f
+	return f, nil
+}
+
+// parse parses the OWNERS file at path. visiting holds the files whose
+// includes are being expanded, to detect include cycles.
+func (r *Resolver) parse(path string, visiting map[string]bool) (*ownersFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+	visiting[path] = true
+	defer delete(visiting, path)
+
+	f := &ownersFile{}
+	scanner := bufio.NewScanner(file)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+		errorf := func(format string, args ...interface{}) error {
+			return fmt.Errorf("%s:%d: %s", path, lineNum, fmt.Sprintf(format, args...))
+		}
+		switch {
+		case line == "":
+		case line == "*":
+			f.anyone = true
+		case line == "set noparent":
+			f.noParent = true
+		case strings.HasPrefix(line, "per-file "):
+			parts := strings.SplitN(strings.TrimPrefix(line, "per-file "), "=", 2)
+			if len(parts) != 2 {
+				return nil, errorf("invalid per-file line %q", line)
+			}
+			pf := perFile{globs: splitList(parts[0])}
+			for _, owner := range splitList(parts[1]) {
+				switch {
+				case owner == "*":
+					pf.anyone = true
+				case strings.HasPrefix(owner, "file://"):
+					inc, err := r.include(path, strings.TrimPrefix(owner, "file://"), visiting)
+					if err != nil {
+						return nil, errorf("%s", err)
+					}
+					pf.owners = append(pf.owners, inc.owners...)
+					pf.anyone = pf.anyone || inc.anyone
+				case strings.Contains(owner, "@"):
+					pf.owners = append(pf.owners, owner)
+				default:
+					return nil, errorf("invalid owner %q", owner)
+				}
+			}
+			f.perFile = append(f.perFile, pf)
+		case strings.HasPrefix(line, "include ") || strings.HasPrefix(line, "file://"):
+			target := strings.TrimSpace(strings.TrimPrefix(strings.TrimPrefix(line, "include "), "file://"))
+			inc, err := r.include(path, target, visiting)
+			if err != nil {
+				return nil, errorf("%s", err)
+			}
+			f.owners = append(f.owners, inc.owners...)
+			f.anyone = f.anyone || inc.anyone
+		case strings.Contains(line, "@") && !strings.ContainsAny(line, " \t"):
+			f.owners = append(f.owners, line)
+		default:
+			// Ignore unknown directives, such as component metadata.
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Scan() failed: %v", err)
+	}
+	return f, nil
+}
+
+// include parses the OWNERS file target included from the OWNERS file at
+// from.
+func (r *Resolver) include(from, target string, visiting map[string]bool) (*ownersFile, error) {
+	path, err := r.includePath(from, target)
+	if err != nil {
+		return nil, err
+	}
+	if visiting[path] {
+		return nil, fmt.Errorf("include cycle through %q", path)
+	}
+	f, err := r.parse(path, visiting)
+	if err != nil {
+		return nil, err
+	}
+	if f == nil {
+		return nil, fmt.Errorf("included file %q does not exist", path)
+	}
+	return f, nil
+}
+
+func (r *Resolver) includePath(from, target string) (string, error) {
+	if target == "" {
+		return "", fmt.Errorf("empty include")
+	}
+	if i := strings.Index(target, ":"); i > 0 {
+		name := target[:i]
+		projectPath, ok := r.projects[name]
+		if !ok {
+			return "", fmt.Errorf("unknown project %q in include %q", name, target)
+		}
+		if projectPath == "" {
+			return "", fmt.Errorf("ambiguous project %q in include %q", name, target)
+		}
+		return filepath.Join(projectPath, filepath.FromSlash(strings.TrimPrefix(target[i+1:], "/"))), nil
+	}
+	if strings.HasPrefix(target, "/") {
+		return filepath.Join(r.root, filepath.FromSlash(target)), nil
+	}
+	return filepath.Join(filepath.Dir(from), filepath.FromSlash(target)), nil
+}
+
+func splitList(s string) []string {
+	var result []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// SuggestReviewers returns a small set of owners which together own all of
+// the given files. Owners in exclude are never suggested. Owners in have are
+// already reviewers, and the files they own are considered covered. Files
+// which anyone can approve, or which have no owners, are ignored.
+//
+// The set is computed greedily: the owner of the most uncovered files is
+// picked first, preferring owners listed closer to the files.
+func (r *Resolver) SuggestReviewers(paths, have, exclude []string) ([]string, error) {
+	excluded := make(map[string]bool)
+	for _, e := range exclude {
+		excluded[e] = true
+	}
+	var uncovered []*Owners
+	for _, path := range paths {
+		o, err := r.Owners(path)
+		if err != nil {
+			return nil, err
+		}
+		if o.Anyone || len(o.Owners) == 0 || o.hasAny(have) {
+			continue
+		}
+		uncovered = append(uncovered, o)
+	}
+	var suggested []string
+	for len(uncovered) != 0 {
+		type candidate struct {
+			count    int
+			distance int
+		}
+		candidates := make(map[string]*candidate)
+		for _, o := range uncovered {
+			for _, owner := range o.Owners {
+				if excluded[owner] {
+					continue
+				}
+				c, ok := candidates[owner]
+				if !ok {
+					c = &candidate{}
+					candidates[owner] = c
+				}
+				c.count++
+				c.distance += o.distance[owner]
+			}
+		}
+		if len(candidates) == 0 {
+			break
+		}
+		var names []string
+		for name := range candidates {
+			names = append(names, name)
+		}
+		sort.Slice(names, func(i, j int) bool {
+			ci, cj := candidates[names[i]], candidates[names[j]]
+			if ci.count != cj.count {
+				return ci.count > cj.count
+			}
+			if ci.distance != cj.distance {
+				return ci.distance < cj.distance
+			}
+			return names[i] < names[j]
+		})
+		best := names[0]
+		suggested = append(suggested, best)
+		var rest []*Owners
+		for _, o := range uncovered {
+			if !o.hasAny([]string{best}) {
+				rest = append(rest, o)
+			}
+		}
+		uncovered = rest
+	}
+	return suggested, nil
+}
+
+func (o *Owners) hasAny(owners []string) bool {
+	for _, owner := range owners {
+		if _, ok := o.distance[owner]; ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+// Copyright 2026 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package owners
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeOwners(t *testing.T, root string, files map[string]string) {
+	for path, content := range files {
+		path = filepath.Join(root, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestOwners(t *testing.T) {
+	root, err := ioutil.TempDir("", "owners")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	writeOwners(t, root, map[string]string{
+		"OWNERS":        "root@example.com\n",
+		"a/OWNERS":      "# Owners of a.\na@example.com\nper-file *.md = docs@example.com\ninclude /common/OWNERS\n",
+		"common/OWNERS": "common@example.com\n",
+		"b/OWNERS":      "set noparent\nb@example.com\nfile://proj:/team/OWNERS\n",
+		"p/team/OWNERS": "team@example.com\n",
+		"c/OWNERS":      "*\n",
+		"d/OWNERS":      "include ../e/OWNERS\n",
+		"e/OWNERS":      "include ../d/OWNERS\n",
+	})
+	r := NewResolver(root, map[string]string{"proj": filepath.Join(root, "p")})
+
+	tests := []struct {
+		path   string
+		owners []string
+		anyone bool
+	}{
+		{"a/x.go", []string{"a@example.com", "common@example.com", "root@example.com"}, false},
+		{"a/README.md", []string{"docs@example.com", "a@example.com", "common@example.com", "root@example.com"}, false},
+		{"b/y/z.go", []string{"b@example.com", "team@example.com"}, false},
+		{"c/z.go", []string{"root@example.com"}, true},
+		{"z.go", []string{"root@example.com"}, false},
+	}
+	for _, test := range tests {
+		o, err := r.Owners(filepath.Join(root, test.path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(o.Owners, test.owners) || o.Anyone != test.anyone {
+			t.Errorf("%s: got owners %v (anyone %v), want %v (anyone %v)", test.path, o.Owners, o.Anyone, test.owners, test.anyone)
+		}
+	}
+
+	if _, err := r.Owners(filepath.Join(root, "d/x.go")); err == nil || !strings.Contains(err.Error(), "include cycle") {
+		t.Errorf("expected include cycle error, got %v", err)
+	}
+
+	var paths []string
+	for _, p := range []string{"a/x.go", "b/y/z.go", "a/README.md", "c/z.go"} {
+		paths = append(paths, filepath.Join(root, p))
+	}
+	got, err := r.SuggestReviewers(paths, nil, []string{"a@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"common@example.com", "b@example.com"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong suggested reviewers, got %v, want %v", got, want)
+	}
+	got, err = r.SuggestReviewers(paths, []string{"team@example.com"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a@example.com"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong suggested reviewers, got %v, want %v", got, want)
+	}
+}
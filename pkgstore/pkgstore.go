@@ -0,0 +1,341 @@
+// Copyright 2026 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package pkgstore implements a content store of package instances shared
+// between jiri roots. Each instance is extracted into the store once, and
+// installed into jiri roots through hardlinks or reflinks, falling back to
+// copies.
+//
+// Roots which link the files of an instance are recorded as its references.
+// A reference stays live as long as the root has a file which is the same
+// file as in the store, so that instances which no root uses anymore can be
+// pruned.
+package pkgstore
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.fuchsia.dev/jiri"
+)
+
+const (
+	objectsDir = "objects"
+	refsDir    = "refs"
+)
+
+// pruneGracePeriod is the time after its last use during which an instance
+// is not pruned, even without references, so that pruning does not race
+// with roots installing it.
+var pruneGracePeriod = time.Hour
+
+// Store is a package content store in a directory.
+type Store struct {
+	dir string
+}
+
+// New returns the store in dir.
+func New(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// FromX returns the store in the cache of jirix, or nil if the jiri root
+// does not share packages.
+func FromX(jirix *jiri.X) *Store {
+	if !jirix.SharedPackages || jirix.Cache == "" {
+		return nil
+	}
+	return New(filepath.Join(jirix.Cache, "packages"))
+}
+
+// objectDir returns the directory of the content of instance id, which has
+// the form "<backend>/<instance id>".
+func (s *Store) objectDir(id string) (string, error) {
+	for _, part := range strings.Split(id, "/") {
+		if part == "" || part == "." || part == ".." {
+			return "", fmt.Errorf("invalid package instance %q", id)
+		}
+	}
+	return filepath.Join(s.dir, objectsDir, filepath.FromSlash(id)), nil
+}
+
+// Has returns true if the content of instance id is in the store.
+func (s *Store) Has(id string) bool {
+	dir, err := s.objectDir(id)
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(dir)
+	return err == nil
+}
+
+// Add extracts the content of instance id into the store with extract,
+// unless it is there already. extract is called with an empty directory.
+func (s *Store) Add(id string, extract func(dir string) error) error {
+	dir, err := s.objectDir(id)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(dir); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+		return err
+	}
+	tmpDir, err := ioutil.TempDir(filepath.Dir(dir), ".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+	if err := os.Chmod(tmpDir, 0755); err != nil {
+		return err
+	}
+	if err := extract(tmpDir); err != nil {
+		return err
+	}
+	// Files are shared between roots, make them read-only so that they
+	// are not changed in one root by accident.
+	if err := filepath.Walk(tmpDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			return err
+		}
+		return os.Chmod(path, info.Mode().Perm()&^0222)
+	}); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpDir, dir); err != nil {
+		// Another root added the instance at the same time.
+		if _, statErr := os.Stat(dir); statErr == nil {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// reference records a root which links the files of an instance.
+type reference struct {
+	// Path is the directory the instance is installed to.
+	Path string `json:"path"`
+	// File is a file of the instance, relative to Path, which is linked to
+	// the store. It is empty if the instance has no regular files.
+	File string `json:"file,omitempty"`
+}
+
+func (s *Store) refsDir(id string) string {
+	return filepath.Join(s.dir, refsDir, filepath.FromSlash(id))
+}
+
+// Install installs the content of instance id, which must be in the store,
+// into dest. Files in dest which are not part of the instance are left
+// alone. It returns the files of the instance, relative to dest.
+func (s *Store) Install(id, dest string) ([]string, error) {
+	dir, err := s.objectDir(id)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if err := os.Chtimes(dir, now, now); err != nil {
+		return nil, err
+	}
+	var files []string
+	linked := ""
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dest, rel)
+		if info.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		if err := os.RemoveAll(target); err != nil {
+			return err
+		}
+		files = append(files, rel)
+		if info.Mode()&os.ModeSymlink != 0 {
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		}
+		if err := os.Link(path, target); err == nil {
+			if linked == "" {
+				linked = rel
+			}
+			return nil
+		}
+		// Copies are not shared, they can be writable.
+		if err := reflink(path, target, info.Mode().Perm()|0200); err == nil {
+			return nil
+		}
+		return copyFile(path, target, info.Mode().Perm()|0200)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if linked != "" || len(files) == 0 {
+		if err := s.addReference(id, reference{Path: dest, File: linked}); err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+func (s *Store) addReference(id string, ref reference) error {
+	dir := s.refsDir(id)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(ref)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256([]byte(ref.Path))
+	return ioutil.WriteFile(filepath.Join(dir, hex.EncodeToString(sum[:16])+".json"), data, 0644)
+}
+
+// live returns true if the root of ref still uses the instance in objDir.
+func (ref reference) live(objDir string) bool {
+	if ref.File == "" {
+		_, err := os.Stat(ref.Path)
+		return err == nil
+	}
+	stored, err := os.Lstat(filepath.Join(objDir, ref.File))
+	if err != nil {
+		return false
+	}
+	installed, err := os.Lstat(filepath.Join(ref.Path, ref.File))
+	if err != nil {
+		return false
+	}
+	return os.SameFile(stored, installed)
+}
+
+func copyFile(src, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// Instance describes an instance in the store.
+type Instance struct {
+	ID string
+	// References are the directories which use the instance.
+	References []string
+	LastUsed   time.Time
+}
+
+// Instances returns the instances in the store, with their live references.
+// Dead references are removed.
+func (s *Store) Instances() ([]Instance, error) {
+	var instances []Instance
+	root := filepath.Join(s.dir, objectsDir)
+	backends, err := ioutil.ReadDir(root)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	for _, backend := range backends {
+		if !backend.IsDir() || strings.HasPrefix(backend.Name(), ".") {
+			continue
+		}
+		objects, err := ioutil.ReadDir(filepath.Join(root, backend.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range objects {
+			if !obj.IsDir() || strings.HasPrefix(obj.Name(), ".") {
+				continue
+			}
+			id := backend.Name() + "/" + obj.Name()
+			refs, err := s.liveReferences(id, filepath.Join(root, backend.Name(), obj.Name()))
+			if err != nil {
+				return nil, err
+			}
+			instances = append(instances, Instance{ID: id, References: refs, LastUsed: obj.ModTime()})
+		}
+	}
+	return instances, nil
+}
+
+func (s *Store) liveReferences(id, objDir string) ([]string, error) {
+	dir := s.refsDir(id)
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var refs []string
+	for _, e := range entries {
+		file := filepath.Join(dir, e.Name())
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		var ref reference
+		if err := json.Unmarshal(data, &ref); err != nil || !ref.live(objDir) {
+			if err := os.Remove(file); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		refs = append(refs, ref.Path)
+	}
+	return refs, nil
+}
+
+// Prune removes the instances which have no live references and were not
+// used recently. It returns the removed instances.
+func (s *Store) Prune() ([]string, error) {
+	instances, err := s.Instances()
+	if err != nil {
+		return nil, err
+	}
+	var removed []string
+	for _, inst := range instances {
+		if len(inst.References) != 0 || time.Since(inst.LastUsed) < pruneGracePeriod {
+			continue
+		}
+		dir, err := s.objectDir(inst.ID)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			return nil, err
+		}
+		if err := os.RemoveAll(s.refsDir(inst.ID)); err != nil {
+			return nil, err
+		}
+		removed = append(removed, inst.ID)
+	}
+	return removed, nil
+}
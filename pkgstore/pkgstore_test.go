@@ -0,0 +1,116 @@
+// Copyright 2026 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package pkgstore
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestStore(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "pkgstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	oldGracePeriod := pruneGracePeriod
+	pruneGracePeriod = 0
+	defer func() { pruneGracePeriod = oldGracePeriod }()
+
+	s := New(filepath.Join(tmp, "store"))
+	extracted := 0
+	extract := func(dir string) error {
+		extracted++
+		if err := os.MkdirAll(filepath.Join(dir, "bin"), 0755); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, "bin", "tool"), []byte("tool"), 0755); err != nil {
+			return err
+		}
+		return os.Symlink("bin/tool", filepath.Join(dir, "tool"))
+	}
+	if err := s.Add("cipd/id", extract); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Add("cipd/id", extract); err != nil {
+		t.Fatal(err)
+	}
+	if extracted != 1 {
+		t.Errorf("instance extracted %d times, want 1", extracted)
+	}
+	if err := s.Add("cipd/../escape", extract); err == nil {
+		t.Errorf("expected an error for an invalid instance id")
+	}
+
+	// Install the instance into two roots.
+	root1 := filepath.Join(tmp, "root1", "prebuilt")
+	root2 := filepath.Join(tmp, "root2", "prebuilt")
+	for _, root := range []string{root1, root2} {
+		files, err := s.Install("cipd/id", root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sort.Strings(files)
+		if len(files) != 2 || files[0] != filepath.Join("bin", "tool") || files[1] != "tool" {
+			t.Errorf("wrong files installed: %v", files)
+		}
+	}
+	info1, err := os.Stat(filepath.Join(root1, "bin", "tool"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	info2, err := os.Stat(filepath.Join(root2, "bin", "tool"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !os.SameFile(info1, info2) {
+		t.Errorf("files of both roots should be the same file")
+	}
+	if info1.Mode().Perm()&0222 != 0 {
+		t.Errorf("shared files should be read-only, got mode %v", info1.Mode())
+	}
+	if target, err := os.Readlink(filepath.Join(root1, "tool")); err != nil || target != "bin/tool" {
+		t.Errorf("wrong symlink: %q, %v", target, err)
+	}
+
+	instances, err := s.Instances()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(instances) != 1 || len(instances[0].References) != 2 {
+		t.Fatalf("wrong instances: %+v", instances)
+	}
+
+	// Instances used by a root are not pruned.
+	if err := os.RemoveAll(root1); err != nil {
+		t.Fatal(err)
+	}
+	if removed, err := s.Prune(); err != nil {
+		t.Fatal(err)
+	} else if len(removed) != 0 {
+		t.Errorf("instances used by root2 were removed: %v", removed)
+	}
+
+	// Replacing the files in the last root releases the instance.
+	if err := os.Remove(filepath.Join(root2, "bin", "tool")); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(root2, "bin", "tool"), []byte("other"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	removed, err := s.Prune()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 1 || removed[0] != "cipd/id" {
+		t.Errorf("wrong instances removed: %v", removed)
+	}
+	if s.Has("cipd/id") {
+		t.Errorf("instance should have been removed")
+	}
+}
@@ -0,0 +1,37 @@
+// Copyright 2026 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux
+// +build linux
+
+package pkgstore
+
+import (
+	"os"
+	"syscall"
+)
+
+// ioctlFiclone is FICLONE from linux/fs.h.
+const ioctlFiclone = 0x40049409
+
+// reflink creates dst as a copy-on-write clone of src, on file systems which
+// support it.
+func reflink(src, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, perm)
+	if err != nil {
+		return err
+	}
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, out.Fd(), ioctlFiclone, in.Fd())
+	out.Close()
+	if errno != 0 {
+		os.Remove(dst)
+		return errno
+	}
+	return nil
+}
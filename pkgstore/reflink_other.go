@@ -0,0 +1,17 @@
+// Copyright 2026 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !linux
+// +build !linux
+
+package pkgstore
+
+import (
+	"errors"
+	"os"
+)
+
+func reflink(src, dst string, perm os.FileMode) error {
+	return errors.New("reflinks are not supported")
+}
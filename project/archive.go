@@ -0,0 +1,494 @@
+// Copyright 2026 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package project
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.fuchsia.dev/jiri"
+	"go.fuchsia.dev/jiri/cipd"
+	"go.fuchsia.dev/jiri/osutil"
+	"go.fuchsia.dev/jiri/pkgstore"
+	"go.fuchsia.dev/jiri/retry"
+)
+
+// archiveInstancePrefix prefixes the instance ids of archive packages, which
+// are the sha256 digests of the archives.
+const archiveInstancePrefix = "sha256:"
+
+// archivePackageBackend fetches packages which are tar or zip archives from
+// HTTP(S) URLs or local files. The URL of a package is given by its "url"
+// attribute, and can be a "file://" URL or an absolute path to use a local
+// directory of archives. Archives are pinned by their sha256 digest.
+type archivePackageBackend struct{}
+
+// archiveInstall records an archive extracted into the jiri root.
+type archiveInstall struct {
+	URL      string `json:"url"`
+	Instance string `json:"instance_id"`
+}
+
+func archiveInstallsFile(jirix *jiri.X) string {
+	return filepath.Join(jirix.RootMetaDir(), jiri.ArchivePackagesJSON)
+}
+
+// readArchiveInstalls reads the archives extracted into the jiri root, keyed
+// by their path relative to the root.
+func readArchiveInstalls(jirix *jiri.X) (map[string]archiveInstall, error) {
+	installs := make(map[string]archiveInstall)
+	data, err := ioutil.ReadFile(archiveInstallsFile(jirix))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return installs, nil
+		}
+		return nil, fmtError(err)
+	}
+	if err := json.Unmarshal(data, &installs); err != nil {
+		return nil, fmt.Errorf("invalid archive packages file %q: %v", archiveInstallsFile(jirix), err)
+	}
+	return installs, nil
+}
+
+func writeArchiveInstalls(jirix *jiri.X, installs map[string]archiveInstall) error {
+	data, err := json.MarshalIndent(installs, "", "    ")
+	if err != nil {
+		return err
+	}
+	return safeWriteFile(jirix, archiveInstallsFile(jirix), data)
+}
+
+// archiveURL returns the URL of the archive of package p for platform plat.
+func (p *Package) archiveURL(plat cipd.Platform) (string, error) {
+	if p.URL == "" {
+		return "", fmt.Errorf("package %q uses the %q backend but has no url", p.Name, ArchivePackageBackend)
+	}
+	if cipd.MustExpand(p.URL) && !cipd.MustExpand(p.Name) {
+		return "", fmt.Errorf("package %q has a url using templates, its name must use them too", p.Name)
+	}
+	expander := plat.Expander()
+	expander["version"] = p.Version
+	return expander.Expand(p.URL)
+}
+
+// archivePlatforms returns the platforms which the archives of package p
+// have to be resolved for.
+func (p *Package) archivePlatforms() ([]cipd.Platform, error) {
+	if !cipd.MustExpand(p.Name) {
+		return []cipd.Platform{cipd.CipdPlatform}, nil
+	}
+	return p.GetPlatforms()
+}
+
+func (archivePackageBackend) FilterACL(jirix *jiri.X, pkgs Packages) (Packages, bool, error) {
+	// Archives have no ACLs. An inaccessible archive fails to download.
+	return pkgs, false, nil
+}
+
+func (archivePackageBackend) Resolve(jirix *jiri.X, pkgs Packages) (PackageLocks, error) {
+	pkgLocks := make(PackageLocks)
+	for _, pkg := range pkgs {
+		plats, err := pkg.archivePlatforms()
+		if err != nil {
+			return nil, err
+		}
+		for _, plat := range plats {
+			name, err := plat.Expander().Expand(pkg.Name)
+			if err == cipd.ErrSkipTemplate {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			u, err := pkg.archiveURL(plat)
+			if err != nil {
+				return nil, err
+			}
+			file, instance, err := downloadArchive(jirix, u, 0)
+			if err != nil {
+				return nil, err
+			}
+			os.Remove(file)
+			pkgLock := PackageLock{
+				PackageName: name,
+				VersionTag:  pkg.Version,
+				InstanceID:  instance,
+			}
+			pkgLocks[pkgLock.Key()] = pkgLock
+		}
+	}
+	return pkgLocks, nil
+}
+
+func (archivePackageBackend) installedInstances(jirix *jiri.X, pkgs Packages) (map[PackageKey]string, error) {
+	installs, err := readArchiveInstalls(jirix)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[PackageKey]string)
+	for key, pkg := range pkgs {
+		dest, err := pkg.installPath()
+		if err != nil {
+			return nil, err
+		}
+		if install, ok := installs[dest]; ok && install.Instance != "" {
+			result[key] = install.Instance
+		}
+	}
+	return result, nil
+}
+
+func (archivePackageBackend) Fetch(jirix *jiri.X, pkgs Packages, fetchTimeout uint) error {
+	installs, err := readArchiveInstalls(jirix)
+	if err != nil {
+		return err
+	}
+	if len(pkgs) == 0 && len(installs) == 0 {
+		return nil
+	}
+	wanted := make(map[string]bool)
+	var errs MultiError
+	for _, pkg := range pkgs {
+		name, err := cipd.CipdPlatform.Expander().Expand(pkg.Name)
+		if err == cipd.ErrSkipTemplate {
+			// The package is not available for this platform.
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		dest, err := pkg.installPath()
+		if err != nil {
+			return err
+		}
+		wanted[dest] = true
+		u, err := pkg.archiveURL(cipd.CipdPlatform)
+		if err != nil {
+			return err
+		}
+		pinned := ""
+		for _, ins := range pkg.Instances {
+			if ins.Name == name {
+				pinned = ins.ID
+				break
+			}
+		}
+		if prev, ok := installs[dest]; ok && prev.URL == u && (pinned == "" || prev.Instance == pinned) {
+			if _, err := os.Stat(filepath.Join(jirix.Root, dest)); err == nil {
+				continue
+			}
+		}
+		instance, err := fetchArchive(jirix, u, pinned, filepath.Join(jirix.Root, dest), fetchTimeout)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("package %q: %v", pkg.Name, err))
+			continue
+		}
+		installs[dest] = archiveInstall{URL: u, Instance: instance}
+	}
+	// Remove the archives which are no longer part of the manifest.
+	for dest := range installs {
+		if wanted[dest] {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(jirix.Root, dest)); err != nil {
+			return fmtError(err)
+		}
+		delete(installs, dest)
+	}
+	if err := writeArchiveInstalls(jirix, installs); err != nil {
+		return err
+	}
+	if len(errs) != 0 {
+		return errs
+	}
+	return nil
+}
+
+// fetchArchive downloads the archive at u and extracts it to dest, replacing
+// its content. If pinned is not empty, the instance id of the archive must
+// match it. It returns the instance id of the archive. If the jiri root
+// shares packages, the archive is extracted into the package store and
+// installed from there, without downloading it again if it is pinned.
+func fetchArchive(jirix *jiri.X, u, pinned, dest string, fetchTimeout uint) (string, error) {
+	store := pkgstore.FromX(jirix)
+	if store != nil && pinned != "" && store.Has(archiveStoreID(pinned)) {
+		jirix.Logger.Debugf("Installing archive %q into %q from the package store", u, dest)
+		return pinned, installStoredArchive(store, archiveStoreID(pinned), dest)
+	}
+	jirix.Logger.Debugf("Fetching archive %q into %q", u, dest)
+	file, instance, err := downloadArchive(jirix, u, fetchTimeout)
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(file)
+	if pinned != "" && pinned != instance {
+		return "", fmt.Errorf("archive %q has instance id %q, expected %q", u, instance, pinned)
+	}
+	if store != nil {
+		id := archiveStoreID(instance)
+		if err := store.Add(id, func(dir string) error {
+			return extractArchive(u, file, dir)
+		}); err != nil {
+			return "", err
+		}
+		return instance, installStoredArchive(store, id, dest)
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return "", fmtError(err)
+	}
+	tmpDir, err := ioutil.TempDir(filepath.Dir(dest), ".jiri-archive")
+	if err != nil {
+		return "", fmtError(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	if err := os.Chmod(tmpDir, 0755); err != nil {
+		return "", fmtError(err)
+	}
+	if err := extractArchive(u, file, tmpDir); err != nil {
+		return "", err
+	}
+	if err := os.RemoveAll(dest); err != nil {
+		return "", fmtError(err)
+	}
+	if err := os.Rename(tmpDir, dest); err != nil {
+		return "", fmtError(err)
+	}
+	return instance, nil
+}
+
+// archiveStoreID returns the id of an archive instance in the package store.
+func archiveStoreID(instance string) string {
+	return ArchivePackageBackend + "/" + strings.TrimPrefix(instance, archiveInstancePrefix)
+}
+
+// installStoredArchive replaces the content of dest with archive id from
+// store.
+func installStoredArchive(store *pkgstore.Store, id, dest string) error {
+	if err := os.RemoveAll(dest); err != nil {
+		return fmtError(err)
+	}
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return fmtError(err)
+	}
+	if _, err := store.Install(id, dest); err != nil {
+		return fmt.Errorf("failed to install %q from the package store: %v", id, err)
+	}
+	return nil
+}
+
+// downloadArchive downloads the archive at u into a temporary file. It
+// returns the path of the file and the instance id of the archive.
+// fetchTimeout is in minutes, no timeout is used if it is 0.
+func downloadArchive(jirix *jiri.X, u string, fetchTimeout uint) (string, string, error) {
+	tmp, err := ioutil.TempFile("", "jiri-archive")
+	if err != nil {
+		return "", "", fmt.Errorf("not able to create tmp file: %v", err)
+	}
+	defer tmp.Close()
+	h := sha256.New()
+	download := func() error {
+		if err := tmp.Truncate(0); err != nil {
+			return err
+		}
+		if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		h.Reset()
+		r, err := openArchive(u, fetchTimeout)
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		_, err = io.Copy(io.MultiWriter(tmp, h), r)
+		return err
+	}
+	if isLocalArchive(u) {
+		err = download()
+	} else {
+		err = retry.Function(jirix, download, fmt.Sprintf("download %s", u), retry.AttemptsOpt(jirix.Attempts))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", "", err
+	}
+	return tmp.Name(), archiveInstancePrefix + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func isLocalArchive(u string) bool {
+	return filepath.IsAbs(u) || strings.HasPrefix(u, "file://")
+}
+
+func openArchive(u string, fetchTimeout uint) (io.ReadCloser, error) {
+	if isLocalArchive(u) {
+		if strings.HasPrefix(u, "file://") {
+			parsed, err := url.Parse(u)
+			if err != nil {
+				return nil, err
+			}
+			u = filepath.FromSlash(parsed.Path)
+		}
+		return os.Open(u)
+	}
+	if !strings.HasPrefix(u, "http://") && !strings.HasPrefix(u, "https://") {
+		return nil, fmt.Errorf("unsupported archive url %q", u)
+	}
+	client := &http.Client{Timeout: time.Duration(fetchTimeout) * time.Minute}
+	resp, err := client.Get(u)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("downloading %q failed: %s", u, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// extractArchive extracts the archive file downloaded from u into dir. The
+// format is detected from the extension of u.
+func extractArchive(u, file, dir string) error {
+	name := u
+	if parsed, err := url.Parse(u); err == nil && parsed.Path != "" {
+		name = parsed.Path
+	}
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		return extractZip(file, dir)
+	case strings.HasSuffix(name, ".tar.gz") || strings.HasSuffix(name, ".tgz"):
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return fmt.Errorf("invalid archive %q: %v", u, err)
+		}
+		defer gz.Close()
+		return extractTar(gz, dir)
+	case strings.HasSuffix(name, ".tar"):
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		return extractTar(f, dir)
+	}
+	return fmt.Errorf("unsupported archive format of %q, expected .tar, .tar.gz, .tgz or .zip", u)
+}
+
+// archiveEntryPath returns the path of the archive entry name in dir. It
+// fails if the entry would be extracted outside of dir.
+func archiveEntryPath(dir, name string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(name))
+	if filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid path %q in archive", name)
+	}
+	return filepath.Join(dir, clean), nil
+}
+
+func extractTar(r io.Reader, dir string) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		path, err := archiveEntryPath(dir, hdr.Name)
+		if err != nil {
+			return err
+		}
+		target := ""
+		if hdr.Typeflag == tar.TypeSymlink {
+			target = hdr.Linkname
+		}
+		if err := osutil.CheckExtractPath(dir, path, target); err != nil {
+			return fmt.Errorf("invalid entry %q in archive: %v", hdr.Name, err)
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(path, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg, tar.TypeRegA:
+			if err := writeArchiveFile(path, tr, os.FileMode(hdr.Mode).Perm()); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return err
+			}
+			if err := os.Symlink(hdr.Linkname, path); err != nil {
+				return err
+			}
+		default:
+			// Skip other entries, such as devices.
+		}
+	}
+}
+
+func extractZip(file, dir string) error {
+	zr, err := zip.OpenReader(file)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+	for _, f := range zr.File {
+		path, err := archiveEntryPath(dir, f.Name)
+		if err != nil {
+			return err
+		}
+		if err := osutil.CheckExtractPath(dir, path, ""); err != nil {
+			return fmt.Errorf("invalid entry %q in archive: %v", f.Name, err)
+		}
+		if f.FileInfo().IsDir() {
+			if err := os.MkdirAll(path, 0755); err != nil {
+				return err
+			}
+			continue
+		}
+		r, err := f.Open()
+		if err != nil {
+			return err
+		}
+		err = writeArchiveFile(path, r, f.Mode().Perm())
+		r.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeArchiveFile(path string, r io.Reader, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, perm|0200)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,238 @@
+// Copyright 2026 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package project_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.fuchsia.dev/jiri"
+	"go.fuchsia.dev/jiri/cipd"
+	"go.fuchsia.dev/jiri/jiritest"
+	"go.fuchsia.dev/jiri/project"
+)
+
+func tarGz(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// TestArchivePackages tests resolving and fetching packages with the archive
+// backend from an HTTP server.
+func TestArchivePackages(t *testing.T) {
+	fake, cleanup := jiritest.NewFakeJiriRoot(t)
+	defer cleanup()
+
+	plat := cipd.CipdPlatform.String()
+	archives := map[string][]byte{
+		"/tool/" + plat + "/1.tar.gz": tarGz(t, map[string]string{"bin/tool": "tool 1"}),
+		"/tool/" + plat + "/2.tar.gz": tarGz(t, map[string]string{"bin/tool": "tool 2"}),
+		"/evil.tar.gz":                tarGz(t, map[string]string{"../escape": "evil"}),
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, ok := archives[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	pkg := project.Package{
+		Name:      "tool/${platform}",
+		Version:   "1",
+		Path:      "prebuilt/tool",
+		Backend:   project.ArchivePackageBackend,
+		URL:       server.URL + "/tool/${platform}/${version}.tar.gz",
+		Platforms: plat,
+	}
+	pkgs := project.Packages{pkg.Key(): pkg}
+
+	locks, err := project.ResolvePackageLocks(fake.X, pkgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(archives["/tool/"+plat+"/1.tar.gz"])
+	want := "sha256:" + hex.EncodeToString(sum[:])
+	lock, ok := locks[project.MakePackageLockKey("tool/"+plat, "1")]
+	if !ok || len(locks) != 1 {
+		t.Fatalf("wrong package locks: %+v", locks)
+	}
+	if lock.InstanceID != want {
+		t.Errorf("wrong instance id, got %q, want %q", lock.InstanceID, want)
+	}
+
+	// Fetch the pinned archive.
+	pkg.Instances = []project.PackageInstance{{Name: lock.PackageName, ID: lock.InstanceID}}
+	pkgs = project.Packages{pkg.Key(): pkg}
+	if err := project.FetchPackages(fake.X, pkgs, project.DefaultPackageTimeout); err != nil {
+		t.Fatal(err)
+	}
+	toolPath := filepath.Join(fake.X.Root, "prebuilt", "tool", "bin", "tool")
+	if content, err := ioutil.ReadFile(toolPath); err != nil {
+		t.Fatal(err)
+	} else if string(content) != "tool 1" {
+		t.Errorf("wrong content, got %q, want %q", content, "tool 1")
+	}
+
+	// A new version which does not match the pin fails to fetch.
+	pkg.Version = "2"
+	pkgs = project.Packages{pkg.Key(): pkg}
+	if err := project.FetchPackages(fake.X, pkgs, project.DefaultPackageTimeout); err == nil || !strings.Contains(err.Error(), "instance id") {
+		t.Errorf("expected instance id mismatch, got %v", err)
+	}
+
+	// Without a pin, the new version replaces the old one.
+	pkg.Instances = nil
+	pkgs = project.Packages{pkg.Key(): pkg}
+	if err := project.FetchPackages(fake.X, pkgs, project.DefaultPackageTimeout); err != nil {
+		t.Fatal(err)
+	}
+	if content, err := ioutil.ReadFile(toolPath); err != nil {
+		t.Fatal(err)
+	} else if string(content) != "tool 2" {
+		t.Errorf("wrong content, got %q, want %q", content, "tool 2")
+	}
+
+	// Archives with paths outside of the package are rejected.
+	evil := project.Package{
+		Name:    "evil",
+		Version: "1",
+		Path:    "prebuilt/evil",
+		Backend: project.ArchivePackageBackend,
+		URL:     server.URL + "/evil.tar.gz",
+	}
+	pkgs = project.Packages{pkg.Key(): pkg, evil.Key(): evil}
+	if err := project.FetchPackages(fake.X, pkgs, project.DefaultPackageTimeout); err == nil || !strings.Contains(err.Error(), "invalid path") {
+		t.Errorf("expected invalid path error, got %v", err)
+	}
+
+	// Packages removed from the manifest are removed from the jiri root.
+	if err := project.FetchPackages(fake.X, project.Packages{}, project.DefaultPackageTimeout); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ioutil.ReadFile(toolPath); err == nil {
+		t.Errorf("package %q should have been removed", pkg.Name)
+	}
+}
+
+// TestArchivePackagesShared tests installing archives into two jiri roots
+// from a shared package store.
+func TestArchivePackagesShared(t *testing.T) {
+	fake1, cleanup1 := jiritest.NewFakeJiriRoot(t)
+	defer cleanup1()
+	fake2, cleanup2 := jiritest.NewFakeJiriRoot(t)
+	defer cleanup2()
+	cache, err := ioutil.TempDir("", "jiri-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cache)
+	for _, x := range []*jiri.X{fake1.X, fake2.X} {
+		x.Cache = cache
+		x.SharedPackages = true
+	}
+
+	data := tarGz(t, map[string]string{"bin/tool": "tool 1"})
+	downloads := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downloads++
+		w.Write(data)
+	}))
+	defer server.Close()
+	sum := sha256.Sum256(data)
+	pkg := project.Package{
+		Name:      "tool",
+		Version:   "1",
+		Path:      "prebuilt/tool",
+		Backend:   project.ArchivePackageBackend,
+		URL:       server.URL + "/tool.tar.gz",
+		Instances: []project.PackageInstance{{Name: "tool", ID: "sha256:" + hex.EncodeToString(sum[:])}},
+	}
+	pkgs := project.Packages{pkg.Key(): pkg}
+	var infos []os.FileInfo
+	for _, x := range []*jiri.X{fake1.X, fake2.X} {
+		if err := project.FetchPackages(x, pkgs, project.DefaultPackageTimeout); err != nil {
+			t.Fatal(err)
+		}
+		info, err := os.Stat(filepath.Join(x.Root, "prebuilt", "tool", "bin", "tool"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		infos = append(infos, info)
+	}
+	if downloads != 1 {
+		t.Errorf("archive downloaded %d times, want 1", downloads)
+	}
+	if !os.SameFile(infos[0], infos[1]) {
+		t.Errorf("both roots should share the files of the archive")
+	}
+}
+
+// TestArchiveSymlinkChain tests that archive entries are not extracted
+// through a chain of symlinks pointing outside of the package.
+func TestArchiveSymlinkChain(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, hdr := range []*tar.Header{
+		{Name: "a/b", Linkname: "..", Typeflag: tar.TypeSymlink},
+		{Name: "a/b/c", Linkname: "..", Typeflag: tar.TypeSymlink},
+		{Name: "a/b/c/evil", Mode: 0644, Size: 4, Typeflag: tar.TypeReg},
+	} {
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			if _, err := tw.Write([]byte("evil")); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	tmp := t.TempDir()
+	file := filepath.Join(tmp, "pkg.tar")
+	if err := ioutil.WriteFile(file, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(tmp, "out")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := project.InternalExtractArchive("https://example.com/pkg.tar", file, dir); err == nil {
+		t.Errorf("symlink chain was extracted")
+	}
+	if _, err := os.Lstat(filepath.Join(tmp, "evil")); !os.IsNotExist(err) {
+		t.Errorf("file was written outside of the package: %v", err)
+	}
+}
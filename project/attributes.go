@@ -0,0 +1,499 @@
+// Copyright 2026 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package project
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.fuchsia.dev/jiri"
+	"go.fuchsia.dev/jiri/cipd"
+)
+
+// The attributes of projects, packages, hooks and imports are boolean expressions
+// over the attributes enabled with "jiri init -fetch-optional", like
+// "(sdk && !arm) || tests". A comma separated list of attributes, like
+// "sdk,tests", is true when any of them is enabled. The predicates
+// "os=<os>", "arch=<arch>" and "platform=<os>-<arch>" test the host
+// platform, in either cipd or Fuchsia naming.
+//
+// The fetching attributes can be an expression too, like "sdk && !internal".
+// It enables the attributes it uses without negation, and an optional item
+// using any of these attributes is only fetched if the expression is also
+// true with the attributes of the item.
+
+type attrOp int
+
+const (
+	attrName attrOp = iota
+	attrNot
+	attrAnd
+	attrOr
+)
+
+// attrExpr is a parsed attribute expression.
+type attrExpr struct {
+	op   attrOp
+	name string
+	args []*attrExpr
+}
+
+var attrPredicates = map[string]bool{
+	"os":       true,
+	"arch":     true,
+	"platform": true,
+}
+
+// isAttrExpr returns true if attrs uses operators, as opposed to being a
+// plain list of attributes.
+func isAttrExpr(attrs string) bool {
+	return strings.ContainsAny(attrs, "&|!()=")
+}
+
+type attrParser struct {
+	s   string
+	pos int
+}
+
+// parseAttrExpr parses the attribute expression s. A leading "+" is ignored
+// for compatibility with lists of attributes.
+func parseAttrExpr(s string) (*attrExpr, error) {
+	p := &attrParser{s: strings.TrimPrefix(strings.TrimSpace(s), "+")}
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, fmt.Errorf("invalid attributes %q: %v", s, err)
+	}
+	if tok := p.next(); tok != "" {
+		return nil, fmt.Errorf("invalid attributes %q: unexpected %q", s, tok)
+	}
+	return e, nil
+}
+
+// peek returns the next token without consuming it.
+func (p *attrParser) peek() string {
+	pos := p.pos
+	tok := p.next()
+	p.pos = pos
+	return tok
+}
+
+// next consumes and returns the next token, or "" at the end of input.
+func (p *attrParser) next() string {
+	for p.pos < len(p.s) && (p.s[p.pos] == ' ' || p.s[p.pos] == '\t' || p.s[p.pos] == '\n') {
+		p.pos++
+	}
+	if p.pos == len(p.s) {
+		return ""
+	}
+	start := p.pos
+	switch p.s[p.pos] {
+	case '&', '|':
+		if p.pos+1 < len(p.s) && p.s[p.pos+1] == p.s[p.pos] {
+			p.pos += 2
+		} else {
+			p.pos++
+		}
+	case '!', '(', ')', ',':
+		p.pos++
+	default:
+		for p.pos < len(p.s) && !strings.ContainsRune(" \t\n&|!(),", rune(p.s[p.pos])) {
+			p.pos++
+		}
+	}
+	return p.s[start:p.pos]
+}
+
+func (p *attrParser) parseOr() (*attrExpr, error) {
+	e, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	args := []*attrExpr{e}
+	for tok := p.peek(); tok == "||" || tok == ","; tok = p.peek() {
+		p.next()
+		e, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, e)
+	}
+	if len(args) == 1 {
+		return args[0], nil
+	}
+	return &attrExpr{op: attrOr, args: args}, nil
+}
+
+func (p *attrParser) parseAnd() (*attrExpr, error) {
+	e, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	args := []*attrExpr{e}
+	for p.peek() == "&&" {
+		p.next()
+		e, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, e)
+	}
+	if len(args) == 1 {
+		return args[0], nil
+	}
+	return &attrExpr{op: attrAnd, args: args}, nil
+}
+
+func (p *attrParser) parseUnary() (*attrExpr, error) {
+	switch tok := p.next(); tok {
+	case "":
+		return nil, fmt.Errorf("unexpected end")
+	case "!":
+		e, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &attrExpr{op: attrNot, args: []*attrExpr{e}}, nil
+	case "(":
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if tok := p.next(); tok != ")" {
+			return nil, fmt.Errorf("missing \")\"")
+		}
+		return e, nil
+	case ")", ",", "&&", "||", "&", "|":
+		return nil, fmt.Errorf("unexpected %q", tok)
+	default:
+		if err := checkAttrName(tok); err != nil {
+			return nil, err
+		}
+		return &attrExpr{op: attrName, name: tok}, nil
+	}
+}
+
+// checkAttrName returns an error if name is neither an attribute nor a
+// known platform predicate.
+func checkAttrName(name string) error {
+	i := strings.Index(name, "=")
+	if i < 0 {
+		return nil
+	}
+	if key := name[:i]; !attrPredicates[key] {
+		return fmt.Errorf("unknown predicate %q, supported predicates are os, arch and platform", key)
+	}
+	if name[i+1:] == "" {
+		return fmt.Errorf("missing value in predicate %q", name)
+	}
+	return nil
+}
+
+// names adds the attributes and predicates used in e to attrs.
+func (e *attrExpr) names(attrs attributes) {
+	if e.op == attrName {
+		attrs[e.name] = true
+	}
+	for _, arg := range e.args {
+		arg.names(attrs)
+	}
+}
+
+// enabled adds the attributes used in e without negation to attrs. Predicates
+// are left out.
+func (e *attrExpr) enabled(attrs attributes, negated bool) {
+	switch e.op {
+	case attrName:
+		if !negated && !strings.Contains(e.name, "=") {
+			attrs[e.name] = true
+		}
+	case attrNot:
+		e.args[0].enabled(attrs, !negated)
+	default:
+		for _, arg := range e.args {
+			arg.enabled(attrs, negated)
+		}
+	}
+}
+
+func (e *attrExpr) format(value func(name string) string) string {
+	switch e.op {
+	case attrName:
+		return value(e.name)
+	case attrNot:
+		arg := e.args[0].format(value)
+		if e.args[0].op == attrAnd || e.args[0].op == attrOr {
+			arg = "(" + arg + ")"
+		}
+		return "!" + arg
+	}
+	sep := " && "
+	if e.op == attrOr {
+		sep = " || "
+	}
+	var args []string
+	for _, arg := range e.args {
+		s := arg.format(value)
+		if e.op == attrAnd && arg.op == attrOr {
+			s = "(" + s + ")"
+		}
+		args = append(args, s)
+	}
+	return strings.Join(args, sep)
+}
+
+func (e *attrExpr) String() string {
+	return e.format(func(name string) string { return name })
+}
+
+func (e *attrExpr) eval(env attrEnv) bool {
+	switch e.op {
+	case attrName:
+		return env.value(e.name)
+	case attrNot:
+		return !e.args[0].eval(env)
+	case attrAnd:
+		for _, arg := range e.args {
+			if !arg.eval(env) {
+				return false
+			}
+		}
+		return true
+	default:
+		for _, arg := range e.args {
+			if arg.eval(env) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// explain returns e with the value of each attribute and predicate.
+func (e *attrExpr) explain(env attrEnv) string {
+	return e.format(func(name string) string {
+		return fmt.Sprintf("%s:%t", name, env.value(name))
+	})
+}
+
+// computeAttributes normalizes the attributes of a project, package or hook.
+// Lists of attributes are sorted, expressions are checked and formatted.
+func computeAttributes(attrs string) (string, attributes, error) {
+	if !isAttrExpr(attrs) {
+		computed := newAttributes(attrs)
+		return computed.String(), computed, nil
+	}
+	e, err := parseAttrExpr(attrs)
+	if err != nil {
+		return "", nil, err
+	}
+	computed := make(attributes)
+	e.names(computed)
+	return e.String(), computed, nil
+}
+
+// attrEnv is what attribute expressions are evaluated against.
+type attrEnv struct {
+	attrs attributes
+	// fetch is set if the fetching attributes are an expression, which must
+	// also be true with the attributes of the items to fetch which use the
+	// attributes in fetchNames.
+	fetch      *attrExpr
+	fetchNames attributes
+	// err is the error parsing the fetching attributes.
+	err  error
+	plat cipd.Platform
+}
+
+// newAttrEnv returns the environment of the fetching attributes attrs.
+func newAttrEnv(attrs string) attrEnv {
+	env := attrEnv{plat: cipd.CipdPlatform}
+	if !isAttrExpr(attrs) {
+		env.attrs = newAttributes(attrs)
+		return env
+	}
+	env.attrs = make(attributes)
+	if env.fetch, env.err = parseAttrExpr(attrs); env.err == nil {
+		env.fetch.enabled(env.attrs, false)
+		env.fetchNames = make(attributes)
+		env.fetch.names(env.fetchNames)
+		for name := range env.fetchNames {
+			if strings.Contains(name, "=") {
+				delete(env.fetchNames, name)
+			}
+		}
+	}
+	return env
+}
+
+// enabledAttributes returns the attributes enabled by the fetching attributes
+// attrs.
+func enabledAttributes(attrs string) attributes {
+	return newAttrEnv(attrs).attrs
+}
+
+// mergeFetchingAttributes returns fetching attributes which fetch the
+// optional items fetched with either a or b.
+func mergeFetchingAttributes(a, b string) string {
+	if !isAttrExpr(a) && !isAttrExpr(b) {
+		attrs := newAttributes(a)
+		attrs.Add(newAttributes(b))
+		return attrs.String()
+	}
+	var exprs []string
+	for _, attrs := range []string{a, b} {
+		if attrs = strings.TrimSpace(strings.TrimPrefix(attrs, "+")); attrs != "" {
+			exprs = append(exprs, "("+attrs+")")
+		}
+	}
+	return strings.Join(exprs, " || ")
+}
+
+func (env attrEnv) value(name string) bool {
+	i := strings.Index(name, "=")
+	if i < 0 {
+		return env.attrs[name]
+	}
+	val := name[i+1:]
+	fuchsia := cipd.FuchsiaPlatform(env.plat)
+	switch name[:i] {
+	case "os":
+		return val == env.plat.OS || val == fuchsia.OS
+	case "arch":
+		return val == env.plat.Arch || val == fuchsia.Arch
+	case "platform":
+		return val == env.plat.String() || val == fuchsia.String()
+	}
+	return false
+}
+
+// CheckFetchingAttributes returns an error if attrs is neither a list of
+// attributes nor an attribute expression to fetch optional projects and
+// packages with.
+func CheckFetchingAttributes(attrs string) error {
+	if isAttrExpr(attrs) {
+		_, err := parseAttrExpr(attrs)
+		return err
+	}
+	for _, v := range strings.Split(strings.TrimPrefix(attrs, "+"), ",") {
+		if v = strings.TrimSpace(v); strings.ContainsAny(v, " \t\n") {
+			return fmt.Errorf("invalid attribute %q: attributes are separated by commas", v)
+		}
+	}
+	return nil
+}
+
+// included returns true if an item with the given attributes is fetched with
+// env, along with the reason.
+func (env attrEnv) included(attrs string, computed attributes) (bool, string, error) {
+	if computed.IsEmpty() {
+		return true, "not optional", nil
+	}
+	if env.err != nil {
+		return false, "", env.err
+	}
+	e, err := parseAttrExpr(attrs)
+	if err != nil {
+		return false, "", err
+	}
+	ok, reason := e.eval(env), e.explain(env)
+	if env.fetch != nil && env.mentions(computed) {
+		item := attrEnv{attrs: computed, plat: env.plat}
+		ok = ok && env.fetch.eval(item)
+		reason += "; fetching attributes: " + env.fetch.explain(item)
+	}
+	return ok, reason, nil
+}
+
+// mentions returns true if the fetching expression uses one of the
+// attributes of computed. Items whose attributes are only predicates or
+// attributes the expression does not use are not checked against it.
+func (env attrEnv) mentions(computed attributes) bool {
+	for name := range computed {
+		if env.fetchNames[name] {
+			return true
+		}
+	}
+	return false
+}
+
+// FilterOptionalHooks removes the hooks in place whose attributes do not
+// match attrs.
+func FilterOptionalHooks(jirix *jiri.X, attrs string, hooks Hooks) error {
+	env := newAttrEnv(attrs)
+	for k, v := range hooks {
+		ok, reason, err := env.included(v.Attributes, v.ComputedAttributes)
+		if err != nil {
+			return fmt.Errorf("hook %q of project %q: %v", v.Name, v.ProjectName, err)
+		}
+		if !ok {
+			jirix.Logger.Debugf("hook %q of project %q is filtered (%s)", v.Name, v.ProjectName, reason)
+			delete(hooks, k)
+		}
+	}
+	return nil
+}
+
+// importIncluded returns true if an import with the given attributes is
+// loaded, along with the reason.
+func importIncluded(jirix *jiri.X, attrs string) (bool, string, error) {
+	attrs, computed, err := computeAttributes(attrs)
+	if err != nil {
+		return false, "", err
+	}
+	return newAttrEnv(jirix.FetchingAttrs).included(attrs, computed)
+}
+
+// OptionalItem explains whether an optional project, package or hook is
+// fetched.
+type OptionalItem struct {
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	Attributes string `json:"attributes"`
+	Included   bool   `json:"included"`
+	// Reason is the attribute expression with the value of each attribute
+	// and predicate.
+	Reason string `json:"reason"`
+}
+
+// ExplainOptional returns whether each optional project, package and hook is
+// fetched with attrs, sorted by kind and name.
+func ExplainOptional(attrs string, projects Projects, hooks Hooks, pkgs Packages) ([]OptionalItem, error) {
+	env := newAttrEnv(attrs)
+	var items []OptionalItem
+	add := func(kind, name, attrs string, computed attributes) error {
+		if computed.IsEmpty() {
+			return nil
+		}
+		ok, reason, err := env.included(attrs, computed)
+		if err != nil {
+			return fmt.Errorf("%s %q: %v", kind, name, err)
+		}
+		items = append(items, OptionalItem{Kind: kind, Name: name, Attributes: attrs, Included: ok, Reason: reason})
+		return nil
+	}
+	for _, p := range projects {
+		if err := add("project", p.Name, p.Attributes, p.ComputedAttributes); err != nil {
+			return nil, err
+		}
+	}
+	for _, pkg := range pkgs {
+		if err := add("package", pkg.Name, pkg.Attributes, pkg.ComputedAttributes); err != nil {
+			return nil, err
+		}
+	}
+	for _, h := range hooks {
+		if err := add("hook", h.ProjectName+":"+h.Name, h.Attributes, h.ComputedAttributes); err != nil {
+			return nil, err
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Kind != items[j].Kind {
+			return items[i].Kind < items[j].Kind
+		}
+		return items[i].Name < items[j].Name
+	})
+	return items, nil
+}
@@ -0,0 +1,132 @@
+// Copyright 2026 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package project_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"go.fuchsia.dev/jiri/cipd"
+	"go.fuchsia.dev/jiri/jiritest/xtest"
+	"go.fuchsia.dev/jiri/project"
+)
+
+func TestAttributeExpressions(t *testing.T) {
+	host := cipd.CipdPlatform
+	other := "arm64"
+	if host.Arch == other {
+		other = "amd64"
+	}
+	tests := []struct {
+		attrs, fetching string
+		want            bool
+		reason          string
+	}{
+		{"", "", true, "not optional"},
+		{"sdk,tests", "", false, "sdk:false || tests:false"},
+		{"sdk,tests", "tests", true, "sdk:false || tests:true"},
+		{"+sdk", "sdk", true, "sdk:true"},
+		{"sdk && !tests", "sdk", true, "sdk:true && !tests:false"},
+		{"sdk && !tests", "sdk,tests", false, "sdk:true && !tests:true"},
+		// Negations select items which are fetched by default.
+		{"!minimal", "", true, "!minimal:false"},
+		{"!minimal", "minimal", false, "!minimal:true"},
+		{"(sdk || tests) && os=" + host.OS, "tests", true, "(sdk:false || tests:true) && os=" + host.OS + ":true"},
+		{"arch=" + other + " || docs", "", false, "arch=" + other + ":false || docs:false"},
+		{"platform=" + host.String(), "", true, "platform=" + host.String() + ":true"},
+		{"!(a && b)", "a", true, "!(a:true && b:false)"},
+		// Fetching expressions are also evaluated with the attributes of
+		// the item.
+		{"sdk", "sdk && !internal", true, "sdk:true; fetching attributes: sdk:true && !internal:false"},
+		{"sdk,internal", "sdk && !internal", false, "internal:false || sdk:true; fetching attributes: sdk:true && !internal:true"},
+		{"tests", "sdk && !internal", false, "tests:false"},
+		// Items which do not use the attributes of the fetching expression
+		// are fetched like with a list of attributes.
+		{"os=" + host.OS, "sdk && !internal", true, "os=" + host.OS + ":true"},
+		{"!minimal", "sdk && !internal", true, "!minimal:false"},
+		{"!minimal", "minimal && !internal", false, "!minimal:true; fetching attributes: minimal:true && !internal:false"},
+		{"sdk && os=" + host.OS, "sdk && !internal", true, "sdk:true && os=" + host.OS + ":true; fetching attributes: sdk:true && !internal:false"},
+		{"sdk", "sdk && os=" + host.OS, true, "sdk:true; fetching attributes: sdk:true && os=" + host.OS + ":true"},
+		{"sdk", "sdk && arch=" + other, false, "sdk:true; fetching attributes: sdk:true && arch=" + other + ":false"},
+	}
+	for _, test := range tests {
+		got, reason, err := project.InternalMatchAttributes(test.attrs, test.fetching)
+		if err != nil {
+			t.Errorf("%q with %q: unexpected error %v", test.attrs, test.fetching, err)
+			continue
+		}
+		if got != test.want || reason != test.reason {
+			t.Errorf("%q with %q: got %t (%s), want %t (%s)", test.attrs, test.fetching, got, reason, test.want, test.reason)
+		}
+	}
+
+	for _, attrs := range []string{"a &&", "(a || b", "a & b", "a b", "cpu=x86", "os=", "a || ()"} {
+		if _, _, err := project.InternalMatchAttributes(attrs, ""); err == nil {
+			t.Errorf("%q: expected an error", attrs)
+		}
+	}
+}
+
+func TestCheckFetchingAttributes(t *testing.T) {
+	if err := project.CheckFetchingAttributes("sdk, tests"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := project.CheckFetchingAttributes("(sdk || tests) && !arch=arm64"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	for _, attrs := range []string{"sdk tests", "sdk &&", "cpu=x86"} {
+		if err := project.CheckFetchingAttributes(attrs); err == nil {
+			t.Errorf("%q: expected an error", attrs)
+		}
+	}
+}
+
+// TestConditionalImports tests that imports whose attributes do not match
+// are not loaded.
+func TestConditionalImports(t *testing.T) {
+	jirix, cleanup := xtest.NewX(t)
+	defer cleanup()
+
+	// The remote of the import does not exist, loading it would fail.
+	jiriManifest := project.Manifest{
+		Imports: []project.Import{
+			{Manifest: "internal", Name: "internal", Remote: filepath.Join(jirix.Root, "missing"), Attributes: "internal && os=" + cipd.CipdPlatform.OS},
+		},
+		LocalImports: []project.LocalImport{
+			{File: "sdk", Attributes: "sdk"},
+		},
+	}
+	sdk := project.Manifest{
+		Projects: []project.Project{
+			{Name: "sdk", Path: "sdk", Remote: "https://example.com/sdk"},
+		},
+	}
+	if err := jiriManifest.ToFile(jirix, jirix.JiriManifestFile()); err != nil {
+		t.Fatal(err)
+	}
+	if err := sdk.ToFile(jirix, filepath.Join(jirix.Root, "sdk")); err != nil {
+		t.Fatal(err)
+	}
+
+	load := func(attrs string) project.Projects {
+		t.Helper()
+		jirix.FetchingAttrs = attrs
+		projects, _, _, err := project.LoadManifestFile(jirix, jirix.JiriManifestFile(), project.Projects{}, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return projects
+	}
+	if projects := load(""); len(projects) != 0 {
+		t.Errorf("expected no projects, got %+v", projects)
+	}
+	if projects := load("sdk"); len(projects) != 1 {
+		t.Errorf("expected the sdk project, got %+v", projects)
+	}
+	jirix.FetchingAttrs = "internal"
+	if _, _, _, err := project.LoadManifestFile(jirix, jirix.JiriManifestFile(), project.Projects{}, false); err == nil {
+		t.Errorf("expected an error loading the missing import")
+	}
+}
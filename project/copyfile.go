@@ -0,0 +1,366 @@
+// Copyright 2026 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package project
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"go.fuchsia.dev/jiri"
+)
+
+// CopyFile copies a file of a project to another location of the checkout,
+// like a top-level BUILD.gn.
+type CopyFile struct {
+	// Src is the file to copy, relative to the project.
+	Src string `xml:"src,attr"`
+	// Dest is the copy, relative to the jiri root.
+	Dest    string   `xml:"dest,attr"`
+	XMLName struct{} `xml:"copyfile"`
+}
+
+// LinkFile creates a symlink to a file or directory of a project at another
+// location of the checkout.
+type LinkFile struct {
+	// Src is the file or directory linked to, relative to the project.
+	Src string `xml:"src,attr"`
+	// Dest is the symlink, relative to the jiri root.
+	Dest    string   `xml:"dest,attr"`
+	XMLName struct{} `xml:"linkfile"`
+}
+
+// checkRelativePath returns an error if path is not a relative path which
+// stays below the directory it is relative to.
+func checkRelativePath(path string) error {
+	if path == "" {
+		return fmt.Errorf("path is empty")
+	}
+	if filepath.IsAbs(path) {
+		return fmt.Errorf("path %q is absolute", path)
+	}
+	clean := filepath.Clean(path)
+	if clean == "." || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("path %q is outside of its directory", path)
+	}
+	return nil
+}
+
+func validateProjectFile(kind, src, dest string) error {
+	if err := checkRelativePath(src); err != nil {
+		return fmt.Errorf("bad %s: src: %v", kind, err)
+	}
+	if err := checkRelativePath(dest); err != nil {
+		return fmt.Errorf("bad %s: dest: %v", kind, err)
+	}
+	first := strings.SplitN(filepath.ToSlash(filepath.Clean(dest)), "/", 2)[0]
+	if first == jiri.RootMetaDir {
+		return fmt.Errorf("bad %s: dest %q is in %s", kind, dest, jiri.RootMetaDir)
+	}
+	return nil
+}
+
+// validateProjectFiles checks the paths of the copyfile and linkfile
+// elements of p.
+func validateProjectFiles(p *Project) error {
+	for _, f := range p.CopyFiles {
+		if err := validateProjectFile("copyfile", f.Src, f.Dest); err != nil {
+			return err
+		}
+	}
+	for _, f := range p.LinkFiles {
+		if err := validateProjectFile("linkfile", f.Src, f.Dest); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// projectFile is a copyfile or linkfile of a project, with absolute paths.
+type projectFile struct {
+	link    bool
+	project string
+	root    string
+	src     string
+	dest    string
+}
+
+func (f projectFile) kind() string {
+	if f.link {
+		return "linkfile"
+	}
+	return "copyfile"
+}
+
+func (f projectFile) String() string {
+	return fmt.Sprintf("%s %s -> %s of project %q", f.kind(), f.src, f.dest, f.project)
+}
+
+// projectFiles returns the copyfile and linkfile elements of p.
+func projectFiles(jirix *jiri.X, p Project) []projectFile {
+	var files []projectFile
+	for _, f := range p.CopyFiles {
+		files = append(files, projectFile{project: p.Name, root: p.Path, src: filepath.Join(p.Path, f.Src), dest: filepath.Join(jirix.Root, f.Dest)})
+	}
+	for _, f := range p.LinkFiles {
+		files = append(files, projectFile{link: true, project: p.Name, root: p.Path, src: filepath.Join(p.Path, f.Src), dest: filepath.Join(jirix.Root, f.Dest)})
+	}
+	return files
+}
+
+// isWithin returns true if path is dir or below dir.
+func isWithin(path, dir string) bool {
+	return path == dir || strings.HasPrefix(path, dir+string(filepath.Separator))
+}
+
+// evalExisting resolves the symlinks of the longest existing prefix of path.
+func evalExisting(path string) (string, error) {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err == nil {
+		return resolved, nil
+	} else if !os.IsNotExist(err) {
+		return "", err
+	}
+	parent := filepath.Dir(path)
+	if parent == path {
+		return path, nil
+	}
+	resolved, err = evalExisting(parent)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(resolved, filepath.Base(path)), nil
+}
+
+// check verifies that the source of f is in its project, and that the
+// destination of f is in the jiri root, once symlinks are resolved.
+func (f projectFile) check(jirix *jiri.X) error {
+	root, err := filepath.EvalSymlinks(jirix.Root)
+	if err != nil {
+		return fmtError(err)
+	}
+	projectRoot, err := filepath.EvalSymlinks(f.root)
+	if err != nil {
+		return fmtError(err)
+	}
+	src, err := filepath.EvalSymlinks(f.src)
+	if err != nil {
+		return fmt.Errorf("%s: %v", f, err)
+	}
+	if !isWithin(src, projectRoot) {
+		return fmt.Errorf("%s: source is outside of the project", f)
+	}
+	if fi, err := os.Stat(src); err != nil {
+		return fmtError(err)
+	} else if !f.link && !fi.Mode().IsRegular() {
+		return fmt.Errorf("%s: source is not a regular file", f)
+	}
+	// The destination itself may be a symlink created by jiri, only its
+	// directory is resolved.
+	destDir, err := evalExisting(filepath.Dir(f.dest))
+	if err != nil {
+		return fmtError(err)
+	}
+	if !isWithin(destDir, root) || filepath.Join(destDir, filepath.Base(f.dest)) == root {
+		return fmt.Errorf("%s: destination is outside of the jiri root", f)
+	}
+	if fi, err := os.Lstat(f.dest); err == nil && fi.IsDir() {
+		return fmt.Errorf("%s: destination is a directory", f)
+	}
+	return nil
+}
+
+// linkTarget returns the target of the symlink of f, relative to the
+// directory of the symlink so that the root can be moved.
+func (f projectFile) linkTarget() (string, error) {
+	return filepath.Rel(filepath.Dir(f.dest), f.src)
+}
+
+// diverged returns a description of how the destination of f differs from
+// its source, or "" if it does not.
+func (f projectFile) diverged() (string, error) {
+	fi, err := os.Lstat(f.dest)
+	if os.IsNotExist(err) {
+		return "missing", nil
+	} else if err != nil {
+		return "", fmtError(err)
+	}
+	if f.link {
+		if fi.Mode()&os.ModeSymlink == 0 {
+			return "not a symlink", nil
+		}
+		target, err := os.Readlink(f.dest)
+		if err != nil {
+			return "", fmtError(err)
+		}
+		want, err := f.linkTarget()
+		if err != nil {
+			return "", fmtError(err)
+		}
+		if target != want {
+			return fmt.Sprintf("links to %s", target), nil
+		}
+		return "", nil
+	}
+	if !fi.Mode().IsRegular() {
+		return "not a regular file", nil
+	}
+	srcData, err := ioutil.ReadFile(f.src)
+	if os.IsNotExist(err) {
+		return "source is missing", nil
+	} else if err != nil {
+		return "", fmtError(err)
+	}
+	destData, err := ioutil.ReadFile(f.dest)
+	if err != nil {
+		return "", fmtError(err)
+	}
+	if !bytes.Equal(srcData, destData) {
+		return "modified", nil
+	}
+	return "", nil
+}
+
+// apply creates or updates the destination of f.
+func (f projectFile) apply(jirix *jiri.X) error {
+	if err := f.check(jirix); err != nil {
+		return err
+	}
+	if d, err := f.diverged(); err != nil {
+		return err
+	} else if d == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(f.dest), 0755); err != nil {
+		return fmtError(err)
+	}
+	if f.link {
+		target, err := f.linkTarget()
+		if err != nil {
+			return fmtError(err)
+		}
+		if err := os.Remove(f.dest); err != nil && !os.IsNotExist(err) {
+			return fmtError(err)
+		}
+		jirix.Logger.Debugf("link %s to %s", f.dest, target)
+		return fmtError(os.Symlink(target, f.dest))
+	}
+	data, err := ioutil.ReadFile(f.src)
+	if err != nil {
+		return fmtError(err)
+	}
+	fi, err := os.Stat(f.src)
+	if err != nil {
+		return fmtError(err)
+	}
+	jirix.Logger.Debugf("copy %s to %s", f.src, f.dest)
+	if err := safeWriteFile(jirix, f.dest, data); err != nil {
+		return err
+	}
+	return fmtError(os.Chmod(f.dest, fi.Mode().Perm()))
+}
+
+// remove removes the destination of f, if it was created by jiri, and the
+// directories which are left empty.
+func (f projectFile) remove(jirix *jiri.X) error {
+	fi, err := os.Lstat(f.dest)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fmtError(err)
+	}
+	if f.link && fi.Mode()&os.ModeSymlink == 0 || !f.link && !fi.Mode().IsRegular() {
+		jirix.Logger.Warningf("%s was replaced, it is not removed\n\n", f.dest)
+		return nil
+	}
+	jirix.Logger.Debugf("remove %s", f.dest)
+	if err := os.Remove(f.dest); err != nil {
+		return fmtError(err)
+	}
+	return removeEmptyParents(jirix, filepath.Dir(f.dest))
+}
+
+// updateProjectFiles applies the copyfile and linkfile elements of
+// remoteProjects after they are updated, and removes the ones of
+// localProjects which were deleted, moved or changed.
+func updateProjectFiles(jirix *jiri.X, localProjects, remoteProjects Projects) error {
+	jirix.TimerPush("project copyfile and linkfile")
+	defer jirix.TimerPop()
+
+	wanted := make(map[string]projectFile)
+	// kept are the destinations which are left alone.
+	kept := make(map[string]bool)
+	for _, p := range remoteProjects {
+		for _, f := range projectFiles(jirix, p) {
+			if p.LocalConfig.Ignore || p.LocalConfig.NoUpdate {
+				kept[f.dest] = true
+				continue
+			}
+			if other, ok := wanted[f.dest]; ok {
+				return fmt.Errorf("%s and %s have the same destination", other, f)
+			}
+			wanted[f.dest] = f
+		}
+	}
+
+	var errs MultiError
+	for key, p := range localProjects {
+		remote, ok := remoteProjects[key]
+		if !ok && isPathDir(p.Path) {
+			// Projects which were not deleted keep their files.
+			continue
+		}
+		if ok && (remote.LocalConfig.Ignore || remote.LocalConfig.NoUpdate) {
+			continue
+		}
+		for _, f := range projectFiles(jirix, p) {
+			if _, ok := wanted[f.dest]; ok || kept[f.dest] {
+				continue
+			}
+			if err := f.remove(jirix); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	var dests []string
+	for dest := range wanted {
+		dests = append(dests, dest)
+	}
+	sort.Strings(dests)
+	for _, dest := range dests {
+		if err := wanted[dest].apply(jirix); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) != 0 {
+		return errs
+	}
+	return nil
+}
+
+// CheckProjectFiles returns a description of the destinations of the
+// copyfile and linkfile elements of p which diverged from their sources.
+func CheckProjectFiles(jirix *jiri.X, p Project) ([]string, error) {
+	var diverged []string
+	for _, f := range projectFiles(jirix, p) {
+		d, err := f.diverged()
+		if err != nil {
+			return nil, err
+		}
+		if d != "" {
+			rel, err := filepath.Rel(jirix.Root, f.dest)
+			if err != nil {
+				rel = f.dest
+			}
+			diverged = append(diverged, fmt.Sprintf("%s %s: %s", f.kind(), rel, d))
+		}
+	}
+	return diverged, nil
+}
@@ -0,0 +1,121 @@
+// Copyright 2026 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package project_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"go.fuchsia.dev/jiri/jiritest"
+	"go.fuchsia.dev/jiri/project"
+)
+
+// updateRemoteProject applies update to the project name of the remote
+// manifest.
+func updateRemoteProject(t *testing.T, fake *jiritest.FakeJiriRoot, name string, update func(p *project.Project) bool) {
+	m, err := fake.ReadRemoteManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	projects := []project.Project{}
+	for _, p := range m.Projects {
+		if p.Name != name || update(&p) {
+			projects = append(projects, p)
+		}
+	}
+	m.Projects = projects
+	if err := fake.WriteRemoteManifest(m); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func checkFileContent(t *testing.T, path, want string) {
+	t.Helper()
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != want {
+		t.Errorf("%s: got %q, want %q", path, data, want)
+	}
+}
+
+func TestProjectFiles(t *testing.T) {
+	localProjects, fake, cleanup := setupUniverse(t)
+	defer cleanup()
+	p := localProjects[1]
+	updateRemoteProject(t, fake, p.Name, func(p *project.Project) bool {
+		p.CopyFiles = []project.CopyFile{{Src: "README", Dest: "top/README.copy"}}
+		p.LinkFiles = []project.LinkFile{{Src: "README", Dest: "links/README"}}
+		return true
+	})
+	if err := fake.UpdateUniverse(false); err != nil {
+		t.Fatal(err)
+	}
+	copyPath := filepath.Join(fake.X.Root, "top", "README.copy")
+	linkPath := filepath.Join(fake.X.Root, "links", "README")
+	checkFileContent(t, copyPath, "initial readme")
+	checkFileContent(t, linkPath, "initial readme")
+	if target, err := os.Readlink(linkPath); err != nil {
+		t.Fatal(err)
+	} else if filepath.IsAbs(target) {
+		t.Errorf("got absolute symlink %q", target)
+	}
+
+	// Diverged destinations are reported, and restored by updates.
+	if err := ioutil.WriteFile(copyPath, []byte("modified"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p.CopyFiles = []project.CopyFile{{Src: "README", Dest: "top/README.copy"}}
+	p.LinkFiles = []project.LinkFile{{Src: "README", Dest: "links/README"}}
+	diverged, err := project.CheckProjectFiles(fake.X, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{filepath.Join("copyfile top", "README.copy") + ": modified"}; !reflect.DeepEqual(diverged, want) {
+		t.Errorf("got diverged files %q, want %q", diverged, want)
+	}
+
+	// Moved projects are linked at their new path.
+	newPath := filepath.Join(fake.X.Root, "new-project-path")
+	updateRemoteProject(t, fake, p.Name, func(p *project.Project) bool {
+		p.Path = newPath
+		return true
+	})
+	if err := fake.UpdateUniverse(false); err != nil {
+		t.Fatal(err)
+	}
+	checkFileContent(t, copyPath, "initial readme")
+	checkFileContent(t, linkPath, "initial readme")
+	p.Path = newPath
+	if diverged, err := project.CheckProjectFiles(fake.X, p); err != nil {
+		t.Fatal(err)
+	} else if len(diverged) != 0 {
+		t.Errorf("unexpected diverged files %q", diverged)
+	}
+
+	// The files of deleted projects are removed, with their directories.
+	updateRemoteProject(t, fake, p.Name, func(p *project.Project) bool { return false })
+	if err := fake.UpdateUniverse(true); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{copyPath, linkPath, filepath.Dir(copyPath), filepath.Dir(linkPath)} {
+		if _, err := os.Lstat(path); !os.IsNotExist(err) {
+			t.Errorf("%s was not removed: %v", path, err)
+		}
+	}
+
+	// Destinations cannot leave the root.
+	updateRemoteProject(t, fake, localProjects[0].Name, func(p *project.Project) bool {
+		p.LinkFiles = []project.LinkFile{{Src: "README", Dest: "../README"}}
+		return true
+	})
+	if err := fake.UpdateUniverse(false); err == nil {
+		t.Errorf("linkfile outside of the root was accepted")
+	}
+}
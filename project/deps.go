@@ -0,0 +1,598 @@
+// Copyright 2026 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package project
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// The DEPS files of gclient are Python files. ImportDEPS converts simple
+// DEPS files, which are a sequence of assignments of literals to variables,
+// to jiri manifests. Literals are strings, numbers, booleans, None, lists
+// and dicts. Strings can be concatenated with "+", and use Var() and the
+// "{var}" format of gclient to refer to the variables in vars.
+
+// depsDict is a Python dict, with its keys in order.
+type depsDict struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func (d *depsDict) get(key string) (interface{}, bool) {
+	v, ok := d.values[key]
+	return v, ok
+}
+
+func (d *depsDict) set(key string, value interface{}) {
+	if _, ok := d.values[key]; !ok {
+		d.keys = append(d.keys, key)
+	}
+	d.values[key] = value
+}
+
+// depsParser parses a DEPS file. The values of its variables are strings,
+// bools, int64s, nil, []interface{} and *depsDict.
+type depsParser struct {
+	s    string
+	pos  int
+	line int
+	// globals are the variables assigned so far.
+	globals *depsDict
+}
+
+func (p *depsParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("line %d: %s", p.line, fmt.Sprintf(format, args...))
+}
+
+// skipSpace skips whitespace, newlines and comments.
+func (p *depsParser) skipSpace() {
+	for p.pos < len(p.s) {
+		switch c := p.s[p.pos]; {
+		case c == '\n':
+			p.line++
+			p.pos++
+		case c == ' ' || c == '\t' || c == '\r' || c == '\\':
+			p.pos++
+		case c == '#':
+			for p.pos < len(p.s) && p.s[p.pos] != '\n' {
+				p.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// peek returns the next character, or 0 at the end of input.
+func (p *depsParser) peek() byte {
+	p.skipSpace()
+	if p.pos == len(p.s) {
+		return 0
+	}
+	return p.s[p.pos]
+}
+
+func (p *depsParser) expect(c byte) error {
+	if got := p.peek(); got != c {
+		return p.errorf("expected %q, got %q", c, got)
+	}
+	p.pos++
+	return nil
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+func (p *depsParser) ident() string {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.s) && isIdentByte(p.s[p.pos]) {
+		p.pos++
+	}
+	return p.s[start:p.pos]
+}
+
+// parse parses the assignments of the file.
+func (p *depsParser) parse() error {
+	for p.peek() != 0 {
+		name := p.ident()
+		if name == "" {
+			return p.errorf("unexpected %q", p.s[p.pos])
+		}
+		if err := p.expect('='); err != nil {
+			return err
+		}
+		v, err := p.expr()
+		if err != nil {
+			return err
+		}
+		p.globals.set(name, v)
+	}
+	return nil
+}
+
+// expr parses a concatenation of values with "+".
+func (p *depsParser) expr() (interface{}, error) {
+	v, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == '+' {
+		p.pos++
+		w, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		s, ok1 := v.(string)
+		t, ok2 := w.(string)
+		if !ok1 || !ok2 {
+			return nil, p.errorf("only strings can be concatenated")
+		}
+		v = s + t
+	}
+	return v, nil
+}
+
+func (p *depsParser) value() (interface{}, error) {
+	switch c := p.peek(); {
+	case c == '\'' || c == '"':
+		// Adjacent strings are concatenated.
+		s := ""
+		for c := p.peek(); c == '\'' || c == '"'; c = p.peek() {
+			t, err := p.str()
+			if err != nil {
+				return nil, err
+			}
+			s += t
+		}
+		return s, nil
+	case c == '{':
+		p.pos++
+		d := &depsDict{values: make(map[string]interface{})}
+		for p.peek() != '}' {
+			k, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			key, ok := k.(string)
+			if !ok {
+				return nil, p.errorf("keys of dicts must be strings")
+			}
+			if err := p.expect(':'); err != nil {
+				return nil, err
+			}
+			v, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			d.set(key, v)
+			if p.peek() != ',' {
+				break
+			}
+			p.pos++
+		}
+		return d, p.expect('}')
+	case c == '[':
+		p.pos++
+		l := []interface{}{}
+		for p.peek() != ']' {
+			v, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			l = append(l, v)
+			if p.peek() != ',' {
+				break
+			}
+			p.pos++
+		}
+		return l, p.expect(']')
+	case c == '-' || c >= '0' && c <= '9':
+		start := p.pos
+		p.pos++
+		for p.pos < len(p.s) && isIdentByte(p.s[p.pos]) {
+			p.pos++
+		}
+		n, err := strconv.ParseInt(p.s[start:p.pos], 0, 64)
+		if err != nil {
+			return nil, p.errorf("invalid number %q", p.s[start:p.pos])
+		}
+		return n, nil
+	case isIdentByte(c):
+		name := p.ident()
+		switch name {
+		case "True":
+			return true, nil
+		case "False":
+			return false, nil
+		case "None":
+			return nil, nil
+		case "Var", "Str":
+			if err := p.expect('('); err != nil {
+				return nil, err
+			}
+			v, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(')'); err != nil {
+				return nil, err
+			}
+			if name == "Str" {
+				return v, nil
+			}
+			return p.lookupVar(v)
+		}
+		if v, ok := p.globals.get(name); ok {
+			return v, nil
+		}
+		return nil, p.errorf("unsupported expression %q", name)
+	case c == 0:
+		return nil, p.errorf("unexpected end of file")
+	default:
+		return nil, p.errorf("unexpected %q", c)
+	}
+}
+
+// lookupVar returns the string value of the variable name in vars.
+func (p *depsParser) lookupVar(name interface{}) (interface{}, error) {
+	vars, _ := p.globals.get("vars")
+	if d, ok := vars.(*depsDict); ok {
+		if s, ok := name.(string); ok {
+			if v, ok := d.get(s); ok {
+				return v, nil
+			}
+		}
+	}
+	return nil, p.errorf("unknown variable %v", name)
+}
+
+// str parses a string literal.
+func (p *depsParser) str() (string, error) {
+	quote := p.s[p.pos]
+	start := p.pos
+	// Triple quoted strings are not supported.
+	p.pos++
+	for p.pos < len(p.s) && p.s[p.pos] != quote {
+		if p.s[p.pos] == '\\' {
+			p.pos++
+			if p.pos >= len(p.s) {
+				break
+			}
+		} else if p.s[p.pos] == '\n' {
+			return "", p.errorf("unterminated string")
+		}
+		p.pos++
+	}
+	if p.pos >= len(p.s) {
+		return "", p.errorf("unterminated string")
+	}
+	p.pos++
+	lit := p.s[start:p.pos]
+	if quote == '\'' {
+		// strconv only unquotes Go literals.
+		lit = `"` + strings.Replace(strings.Replace(lit[1:len(lit)-1], `\'`, `'`, -1), `"`, `\"`, -1) + `"`
+	}
+	s, err := strconv.Unquote(lit)
+	if err != nil {
+		return "", p.errorf("invalid string %s", p.s[start:p.pos])
+	}
+	return s, nil
+}
+
+// depsImporter converts the variables of a DEPS file.
+type depsImporter struct {
+	vars     *depsDict
+	unmapped []string
+}
+
+func (di *depsImporter) reportf(format string, args ...interface{}) {
+	di.unmapped = append(di.unmapped, fmt.Sprintf(format, args...))
+}
+
+// expand expands the "{var}" references of gclient in s.
+func (di *depsImporter) expand(s string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch {
+		case strings.HasPrefix(s[i:], "{{"):
+			b.WriteByte('{')
+			i++
+		case strings.HasPrefix(s[i:], "}}"):
+			b.WriteByte('}')
+			i++
+		case s[i] == '{':
+			end := strings.IndexByte(s[i:], '}')
+			if end < 0 {
+				return "", fmt.Errorf("invalid format %q", s)
+			}
+			name := s[i+1 : i+end]
+			v, ok := di.vars.get(name)
+			if !ok {
+				return "", fmt.Errorf("unknown variable %q in %q", name, s)
+			}
+			switch v := v.(type) {
+			case bool:
+				if v {
+					b.WriteString("True")
+				} else {
+					b.WriteString("False")
+				}
+			default:
+				fmt.Fprint(&b, v)
+			}
+			i += end
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String(), nil
+}
+
+// condition converts a condition of gclient to jiri attributes. Variables
+// are mapped to attributes, and comparisons of host_os and host_cpu to the
+// os and arch predicates.
+func (di *depsImporter) condition(cond string) (string, error) {
+	var toks []string
+	for i := 0; i < len(cond); {
+		c := cond[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '(' || c == ')':
+			toks = append(toks, string(c))
+			i++
+		case strings.HasPrefix(cond[i:], "=="), strings.HasPrefix(cond[i:], "!="):
+			toks = append(toks, cond[i:i+2])
+			i += 2
+		case c == '\'' || c == '"':
+			end := strings.IndexByte(cond[i+1:], c)
+			if end < 0 {
+				return "", fmt.Errorf("unterminated string")
+			}
+			toks = append(toks, cond[i:i+end+2])
+			i += end + 2
+		case isIdentByte(c):
+			start := i
+			for i < len(cond) && isIdentByte(cond[i]) {
+				i++
+			}
+			toks = append(toks, cond[start:i])
+		default:
+			return "", fmt.Errorf("unexpected %q", c)
+		}
+	}
+	var out []string
+	for i := 0; i < len(toks); i++ {
+		switch tok := toks[i]; tok {
+		case "and":
+			out = append(out, "&&")
+		case "or":
+			out = append(out, "||")
+		case "not":
+			out = append(out, "!")
+		case "(", ")":
+			out = append(out, tok)
+		case "True", "False":
+			return "", fmt.Errorf("literal %s", tok)
+		default:
+			if !isIdentByte(tok[0]) {
+				return "", fmt.Errorf("unexpected %s", tok)
+			}
+			if i+2 < len(toks) && (toks[i+1] == "==" || toks[i+1] == "!=") {
+				pred := map[string]string{"host_os": "os", "host_cpu": "arch"}[tok]
+				value := toks[i+2]
+				if pred == "" || value[0] != '\'' && value[0] != '"' {
+					return "", fmt.Errorf("comparison of %s", tok)
+				}
+				e := pred + "=" + value[1:len(value)-1]
+				if toks[i+1] == "!=" {
+					e = "!(" + e + ")"
+				}
+				out = append(out, e)
+				i += 2
+				continue
+			}
+			if v, ok := di.vars.get(tok); ok && v == true {
+				di.reportf("variable %s is True by default, the projects conditioned on it are only fetched with the attribute %s", tok, tok)
+			}
+			out = append(out, tok)
+		}
+	}
+	attrs := strings.Join(out, " ")
+	if _, _, err := computeAttributes(attrs); err != nil {
+		return "", err
+	}
+	return attrs, nil
+}
+
+// splitDepURL splits the URL of a git dependency and its revision.
+func splitDepURL(u string) (string, string) {
+	// The user of URLs like git@host:repo is not a revision.
+	if i := strings.LastIndexByte(u, '@'); i >= 0 && strings.ContainsAny(u[:i], "/:") && !strings.ContainsAny(u[i+1:], ":") {
+		return u[:i], u[i+1:]
+	}
+	return u, ""
+}
+
+// depProjectName returns the name of the project of a git dependency: the
+// path of its URL, without .git.
+func depProjectName(u string) string {
+	if parsed, err := url.Parse(u); err == nil && parsed.Host != "" {
+		u = parsed.Path
+	} else if i := strings.LastIndexByte(u, ':'); i >= 0 {
+		u = u[i+1:]
+	}
+	return strings.TrimSuffix(strings.Trim(u, "/"), ".git")
+}
+
+// depsIgnoredKeys are the variables of DEPS files which do not affect the
+// checkout.
+var depsIgnoredKeys = map[string]bool{
+	"vars":                   true,
+	"deps":                   true,
+	"use_relative_paths":     true,
+	"include_rules":          true,
+	"specific_include_rules": true,
+	"skip_child_includes":    true,
+	"noparent":               true,
+	"allowed_hosts":          true,
+}
+
+// ImportDEPS converts the DEPS file of gclient to a jiri manifest. Git
+// dependencies are mapped to projects, and cipd dependencies to packages,
+// at the paths of the deps dict. Conditions are mapped to attributes. The
+// constructs which could not be mapped to the jiri manifest are returned
+// too.
+func ImportDEPS(file string) (*Manifest, []string, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, nil, fmtError(err)
+	}
+	p := &depsParser{s: string(data), line: 1, globals: &depsDict{values: make(map[string]interface{})}}
+	if err := p.parse(); err != nil {
+		return nil, nil, fmt.Errorf("invalid DEPS file %s: %v", file, err)
+	}
+	di := &depsImporter{vars: &depsDict{values: make(map[string]interface{})}}
+	if v, ok := p.globals.get("vars"); ok {
+		vars, ok := v.(*depsDict)
+		if !ok {
+			return nil, nil, fmt.Errorf("invalid DEPS file %s: vars is not a dict", file)
+		}
+		di.vars = vars
+	}
+	for _, key := range p.globals.keys {
+		if depsIgnoredKeys[key] {
+			continue
+		}
+		switch v := p.globals.values[key].(type) {
+		case []interface{}:
+			for _, item := range v {
+				if d, ok := item.(*depsDict); ok {
+					if name, ok := d.get("name"); ok {
+						di.reportf("%s %v is not mapped", key, name)
+						continue
+					}
+				}
+				di.reportf("%s %v is not mapped", key, item)
+			}
+		default:
+			di.reportf("%s is not mapped", key)
+		}
+	}
+	m := &Manifest{}
+	v, _ := p.globals.get("deps")
+	if v == nil {
+		return m, di.unmapped, nil
+	}
+	deps, ok := v.(*depsDict)
+	if !ok {
+		return nil, nil, fmt.Errorf("invalid DEPS file %s: deps is not a dict", file)
+	}
+	names := make(map[string]bool)
+	for _, path := range deps.keys {
+		if err := di.addDep(m, names, path, deps.values[path]); err != nil {
+			return nil, nil, fmt.Errorf("invalid DEPS file %s: dependency %q: %v", file, path, err)
+		}
+	}
+	return m, di.unmapped, nil
+}
+
+func (di *depsImporter) addDep(m *Manifest, names map[string]bool, path string, dep interface{}) error {
+	var attrs string
+	depType := "git"
+	var depURL interface{} = dep
+	d, isDict := dep.(*depsDict)
+	if isDict {
+		depURL, _ = d.get("url")
+		if t, ok := d.get("dep_type"); ok {
+			depType = fmt.Sprint(t)
+		}
+		if c, ok := d.get("condition"); ok {
+			cond, ok := c.(string)
+			if !ok {
+				return fmt.Errorf("condition is not a string")
+			}
+			var err error
+			if attrs, err = di.condition(cond); err != nil {
+				di.reportf("condition %q of %s is not mapped (%v), it is fetched by default", cond, path, err)
+				attrs = ""
+			}
+		}
+	}
+
+	switch depType {
+	case "git":
+		if depURL == nil {
+			// Dependencies with no URL are removed.
+			return nil
+		}
+		s, ok := depURL.(string)
+		if !ok {
+			return fmt.Errorf("url is not a string")
+		}
+		s, err := di.expand(s)
+		if err != nil {
+			return err
+		}
+		remote, revision := splitDepURL(s)
+		p := Project{
+			Name:       depProjectName(remote),
+			Path:       path,
+			Remote:     remote,
+			Attributes: attrs,
+		}
+		if names[p.Name] {
+			di.reportf("project %q is checked out several times, the checkout at %q is named after its path", p.Name, path)
+			p.Name = path
+		}
+		names[p.Name] = true
+		switch {
+		case revision == "":
+		case strings.HasPrefix(revision, "refs/heads/"):
+			p.RemoteBranch = strings.TrimPrefix(revision, "refs/heads/")
+		default:
+			p.Revision = revision
+		}
+		m.Projects = append(m.Projects, p)
+	case "cipd":
+		pkgs, ok := d.values["packages"].([]interface{})
+		if !ok {
+			return fmt.Errorf("packages is not a list")
+		}
+		for _, item := range pkgs {
+			pkg, ok := item.(*depsDict)
+			if !ok {
+				return fmt.Errorf("packages is not a list of dicts")
+			}
+			name, ok1 := pkg.values["package"].(string)
+			version, ok2 := pkg.values["version"].(string)
+			if !ok1 || !ok2 {
+				return fmt.Errorf("package with no name or version")
+			}
+			var err error
+			if name, err = di.expand(name); err != nil {
+				return err
+			}
+			if version, err = di.expand(version); err != nil {
+				return err
+			}
+			m.Packages = append(m.Packages, Package{
+				Name:       name,
+				Version:    version,
+				Path:       path,
+				Attributes: attrs,
+			})
+		}
+	default:
+		di.reportf("dependency %s of type %s is not mapped", path, depType)
+	}
+	return nil
+}
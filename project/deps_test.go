@@ -0,0 +1,100 @@
+// Copyright 2026 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package project_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.fuchsia.dev/jiri/project"
+)
+
+func TestImportDEPS(t *testing.T) {
+	file := writeTestFile(t, t.TempDir(), "DEPS", `# A DEPS file.
+use_relative_paths = True
+vars = {
+  'chromium_git': 'https://chromium.googlesource.com',
+  'foo_revision': 'abcdef0123456789abcdef0123456789abcdef01',
+  "checkout_android": False,
+}
+deps = {
+  'third_party/foo': Var('chromium_git') + '/external/foo.git' + '@' + Var('foo_revision'),
+  'third_party/bar': {
+    'url': '{chromium_git}/bar.git@refs/heads/main',
+    'condition': 'checkout_android and not host_os == "linux"',
+  },
+  'tools/clang': {
+    'packages': [
+      {
+        'package': 'fuchsia/clang/${{platform}}',
+        'version': 'git_revision:abc',
+      },
+    ],
+    'dep_type': 'cipd',
+    'condition': 'checkout_os == "mac"',
+  },
+  'third_party/gone': None,
+}
+hooks = [
+  {
+    'name': 'sysroot',
+    'pattern': '.',
+    'action': ['python3', 'build.py'],
+  },
+]
+`)
+	m, unmapped, err := project.ImportDEPS(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantProjects := []project.Project{
+		{
+			Name:     "external/foo",
+			Path:     "third_party/foo",
+			Remote:   "https://chromium.googlesource.com/external/foo.git",
+			Revision: "abcdef0123456789abcdef0123456789abcdef01",
+		},
+		{
+			Name:         "bar",
+			Path:         "third_party/bar",
+			Remote:       "https://chromium.googlesource.com/bar.git",
+			RemoteBranch: "main",
+			Attributes:   "checkout_android && ! os=linux",
+		},
+	}
+	if !reflect.DeepEqual(m.Projects, wantProjects) {
+		t.Errorf("got projects %+v, want %+v", m.Projects, wantProjects)
+	}
+	wantPackages := []project.Package{
+		{
+			Name:    "fuchsia/clang/${platform}",
+			Version: "git_revision:abc",
+			Path:    "tools/clang",
+		},
+	}
+	if !reflect.DeepEqual(m.Packages, wantPackages) {
+		t.Errorf("got packages %+v, want %+v", m.Packages, wantPackages)
+	}
+	report := strings.Join(unmapped, "\n")
+	for _, s := range []string{"hooks sysroot", `condition "checkout_os == \"mac\""`} {
+		if !strings.Contains(report, s) {
+			t.Errorf("%q is not reported in %q", s, report)
+		}
+	}
+	if len(unmapped) != 2 {
+		t.Errorf("unexpected report %q", report)
+	}
+
+	for _, content := range []string{
+		"deps = {'a': some_call()}\n",
+		"x = 'a\\",
+	} {
+		bad := writeTestFile(t, t.TempDir(), "DEPS", content)
+		if _, _, err := project.ImportDEPS(bad); err == nil {
+			t.Errorf("bad DEPS file %q was imported", content)
+		}
+	}
+}
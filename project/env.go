@@ -0,0 +1,137 @@
+// Copyright 2026 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package project
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"go.fuchsia.dev/jiri"
+	"go.fuchsia.dev/jiri/envvar"
+)
+
+// EnvVar is an environment variable exported by a project or a package, for
+// the commands run with "jiri env", "jiri runp -env" and hooks with env set.
+type EnvVar struct {
+	// Name is the name of the variable.
+	Name string `xml:"name,attr"`
+	// Value sets the variable.
+	Value string `xml:"value,attr,omitempty"`
+	// Prepend is a path relative to the jiri root which is prepended to
+	// the list of paths of the variable, like PATH.
+	Prepend string `xml:"prepend,attr,omitempty"`
+	// Append is a path relative to the jiri root which is appended to the
+	// list of paths of the variable.
+	Append string `xml:"append,attr,omitempty"`
+	// Separator separates the paths of the variable. It is the path list
+	// separator of the host by default.
+	Separator string   `xml:"separator,attr,omitempty"`
+	XMLName   struct{} `xml:"env"`
+}
+
+func (e *EnvVar) validate() error {
+	if e.Name == "" {
+		return fmt.Errorf("bad env: must specify name: %+v", *e)
+	}
+	set := 0
+	for _, v := range []string{e.Value, e.Prepend, e.Append} {
+		if v != "" {
+			set++
+		}
+	}
+	if set != 1 {
+		return fmt.Errorf("bad env %q: must specify exactly one of value, prepend or append", e.Name)
+	}
+	if filepath.IsAbs(e.Prepend) || filepath.IsAbs(e.Append) {
+		return fmt.Errorf("bad env %q: paths must be relative to the jiri root", e.Name)
+	}
+	return nil
+}
+
+func validateEnv(env []EnvVar) error {
+	for i := range env {
+		if err := env[i].validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// apply applies e to vars. values records the values which were set, to
+// detect conflicts.
+func (e EnvVar) apply(jirix *jiri.X, vars *envvar.Vars, values map[string]string) error {
+	sep := e.Separator
+	if sep == "" {
+		sep = string(os.PathListSeparator)
+	}
+	switch {
+	case e.Value != "":
+		if v, ok := values[e.Name]; ok && v != e.Value {
+			return fmt.Errorf("conflicting values %q and %q for %s", v, e.Value, e.Name)
+		}
+		values[e.Name] = e.Value
+		vars.Set(e.Name, e.Value)
+	case e.Prepend != "":
+		vars.Set(e.Name, envvar.PrependUniqueToken(vars.Get(e.Name), sep, filepath.Join(jirix.Root, e.Prepend)))
+	default:
+		vars.Set(e.Name, envvar.AppendUniqueToken(vars.Get(e.Name), sep, filepath.Join(jirix.Root, e.Append)))
+	}
+	return nil
+}
+
+// ApplyEnv applies the environment exported by projects and packages to
+// vars. Projects are applied before packages, both in the order of their
+// keys.
+func ApplyEnv(jirix *jiri.X, vars *envvar.Vars, projects Projects, pkgs Packages) error {
+	values := make(map[string]string)
+	projectKeys := make(ProjectKeys, 0, len(projects))
+	for k := range projects {
+		projectKeys = append(projectKeys, k)
+	}
+	sort.Sort(projectKeys)
+	for _, k := range projectKeys {
+		for _, e := range projects[k].Env {
+			if err := e.apply(jirix, vars, values); err != nil {
+				return fmt.Errorf("project %q: %v", projects[k].Name, err)
+			}
+		}
+	}
+	pkgKeys := make(PackageKeys, 0, len(pkgs))
+	for k := range pkgs {
+		pkgKeys = append(pkgKeys, k)
+	}
+	sort.Sort(pkgKeys)
+	for _, k := range pkgKeys {
+		for _, e := range pkgs[k].Env {
+			if err := e.apply(jirix, vars, values); err != nil {
+				return fmt.Errorf("package %q: %v", pkgs[k].Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// SetHooksEnv sets up the hooks with env set to run in the environment
+// exported by projects and packages.
+func SetHooksEnv(jirix *jiri.X, hooks Hooks, projects Projects, pkgs Packages) error {
+	var env map[string]string
+	for k, hook := range hooks {
+		if !hook.UseEnv {
+			continue
+		}
+		if env == nil {
+			vars := envvar.VarsFromMap(jirix.Env())
+			if err := ApplyEnv(jirix, vars, projects, pkgs); err != nil {
+				return err
+			}
+			env = vars.ToMap()
+		}
+		hook.env = env
+		hooks[k] = hook
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+// Copyright 2026 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package project_test
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.fuchsia.dev/jiri/envvar"
+	"go.fuchsia.dev/jiri/jiritest/xtest"
+	"go.fuchsia.dev/jiri/project"
+)
+
+func TestApplyEnv(t *testing.T) {
+	jirix, cleanup := xtest.NewX(t)
+	defer cleanup()
+
+	proj := project.Project{
+		Name:   "sdk",
+		Remote: "https://example.com/sdk",
+		Env: []project.EnvVar{
+			{Name: "SDK_ROOT", Value: "/opt/sdk"},
+			{Name: "PATH", Append: "sdk/bin"},
+		},
+	}
+	clang := project.Package{
+		Name: "clang",
+		Path: "prebuilt/clang",
+		Env:  []project.EnvVar{{Name: "PATH", Prepend: "prebuilt/clang/bin"}},
+	}
+	gn := project.Package{
+		Name: "gn",
+		Path: "prebuilt/gn",
+		Env:  []project.EnvVar{{Name: "PATH", Prepend: "prebuilt/gn", Separator: ":"}},
+	}
+	projects := project.Projects{proj.Key(): proj}
+	pkgs := project.Packages{clang.Key(): clang, gn.Key(): gn}
+
+	vars := envvar.VarsFromMap(map[string]string{"PATH": "/usr/bin:/bin", "HOME": "/home/user"})
+	if err := project.ApplyEnv(jirix, vars, projects, pkgs); err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Join([]string{
+		filepath.Join(jirix.Root, "prebuilt/gn"),
+		filepath.Join(jirix.Root, "prebuilt/clang/bin"),
+		"/usr/bin",
+		"/bin",
+		filepath.Join(jirix.Root, "sdk/bin"),
+	}, ":")
+	if got := vars.Get("PATH"); got != want {
+		t.Errorf("got PATH %q, want %q", got, want)
+	}
+	if got := vars.Get("SDK_ROOT"); got != "/opt/sdk" {
+		t.Errorf("got SDK_ROOT %q, want %q", got, "/opt/sdk")
+	}
+	if _, ok := vars.Deltas()["HOME"]; ok {
+		t.Errorf("HOME should not be changed")
+	}
+
+	// Different values for the same variable are a conflict.
+	other := project.Package{
+		Name: "other-sdk",
+		Env:  []project.EnvVar{{Name: "SDK_ROOT", Value: "/opt/other"}},
+	}
+	pkgs[other.Key()] = other
+	if err := project.ApplyEnv(jirix, envvar.VarsFromMap(nil), projects, pkgs); err == nil {
+		t.Errorf("expected an error for conflicting values")
+	}
+}
+
+// TestProjectEnvToFile tests that the metadata of projects with <env>
+// elements can be read back.
+func TestProjectEnvToFile(t *testing.T) {
+	jirix, cleanup := xtest.NewX(t)
+	defer cleanup()
+
+	want := project.Project{
+		Name:   "sdk",
+		Path:   filepath.Join(jirix.Root, "sdk"),
+		Remote: "https://example.com/sdk",
+		Env:    []project.EnvVar{{Name: "PATH", Prepend: "sdk/bin"}},
+	}
+	file := filepath.Join(jirix.Root, "metadata")
+	if err := want.ToFile(jirix, file); err != nil {
+		t.Fatal(err)
+	}
+	got, err := project.ProjectFromFile(jirix, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got.Env, want.Env) {
+		t.Errorf("got env %+v, want %+v", got.Env, want.Env)
+	}
+}
@@ -0,0 +1,364 @@
+// Copyright 2026 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package project
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"go.fuchsia.dev/jiri"
+	"go.fuchsia.dev/jiri/cipd"
+	"go.fuchsia.dev/jiri/log"
+)
+
+// InstalledPackage records a package instance fetched into the jiri root,
+// and the files it installed.
+type InstalledPackage struct {
+	// Name is the name of the package expanded for the current platform.
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	// InstanceID is the instance of the package which was fetched, as
+	// pinned by a lockfile or snapshot, or as resolved by its backend.
+	InstanceID string `json:"instance_id,omitempty"`
+	Backend    string `json:"backend"`
+	// Path is the directory of the package relative to the jiri root.
+	Path string `json:"path"`
+	// Files maps the files of the package, relative to Path, to their
+	// sha256 digest, or to "->" followed by the target of symlinks.
+	Files map[string]string `json:"files"`
+}
+
+func (p *InstalledPackage) key() string {
+	return p.Path + ":" + p.Name
+}
+
+func installedPackagesFile(jirix *jiri.X) string {
+	return filepath.Join(jirix.RootMetaDir(), jiri.InstalledPackagesJSON)
+}
+
+// ReadInstalledPackages returns the packages recorded as installed in the
+// jiri root, sorted by path.
+func ReadInstalledPackages(jirix *jiri.X) ([]InstalledPackage, error) {
+	data, err := ioutil.ReadFile(installedPackagesFile(jirix))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmtError(err)
+	}
+	var installed []InstalledPackage
+	if err := json.Unmarshal(data, &installed); err != nil {
+		return nil, fmt.Errorf("invalid installed packages file %q: %v", installedPackagesFile(jirix), err)
+	}
+	return installed, nil
+}
+
+func writeInstalledPackages(jirix *jiri.X, installed map[string]InstalledPackage) error {
+	list := make([]InstalledPackage, 0, len(installed))
+	for _, p := range installed {
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].key() < list[j].key() })
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	return safeWriteFile(jirix, installedPackagesFile(jirix), data)
+}
+
+// hashTree returns the files under dir, relative to dir, mapped to their
+// sha256 digest or symlink target.
+func hashTree(dir string) (map[string]string, error) {
+	files := make(map[string]string)
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if info.Mode()&os.ModeSymlink != 0 {
+			target, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			files[rel] = "->" + target
+			return nil
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		h := sha256.New()
+		if _, err := io.Copy(h, f); err != nil {
+			return err
+		}
+		files[rel] = hex.EncodeToString(h.Sum(nil))
+		return nil
+	})
+	if err != nil {
+		return nil, fmtError(err)
+	}
+	return files, nil
+}
+
+// installedPackage returns the record of package pkg on the current
+// platform, without its files. It returns nil if pkg is not available on the
+// current platform.
+func (p *Package) installedPackage() (*InstalledPackage, error) {
+	name, err := cipd.CipdPlatform.Expander().Expand(p.Name)
+	if err == cipd.ErrSkipTemplate {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	dir, err := p.installPath()
+	if err != nil {
+		return nil, err
+	}
+	installed := &InstalledPackage{
+		Name:    name,
+		Version: p.Version,
+		Backend: p.BackendName(),
+		Path:    filepath.ToSlash(dir),
+	}
+	for _, ins := range p.Instances {
+		if ins.Name == name {
+			installed.InstanceID = ins.ID
+			break
+		}
+	}
+	return installed, nil
+}
+
+// recordInstalledPackages records the packages of pkgs as installed, along
+// with their files. The files of packages whose version and fetched instance
+// did not change are not hashed again, as their backend did not fetch them
+// again. Records of packages which are not in pkgs are kept, until they are
+// garbage collected.
+func recordInstalledPackages(jirix *jiri.X, pkgs Packages) error {
+	jirix.TimerPush("record installed packages")
+	defer jirix.TimerPop()
+
+	prev, err := ReadInstalledPackages(jirix)
+	if err != nil {
+		return err
+	}
+	installed := make(map[string]InstalledPackage)
+	for _, p := range prev {
+		installed[p.key()] = p
+	}
+	instances, err := fetchedInstances(jirix, pkgs)
+	if err != nil {
+		return err
+	}
+	for key, pkg := range pkgs {
+		current, err := pkg.installedPackage()
+		if err != nil {
+			return err
+		}
+		if current == nil {
+			continue
+		}
+		dir := filepath.Join(jirix.Root, filepath.FromSlash(current.Path))
+		if _, err := os.Stat(dir); err != nil {
+			// The package was not fetched.
+			continue
+		}
+		if id, ok := instances[key]; ok {
+			current.InstanceID = id
+		}
+		// Packages whose instance is not known, like unpinned packages
+		// fetched by the cipd binary, are always hashed again.
+		if p, ok := installed[current.key()]; ok && current.InstanceID != "" && p.Version == current.Version && p.InstanceID == current.InstanceID {
+			continue
+		}
+		if current.Files, err = hashTree(dir); err != nil {
+			return err
+		}
+		installed[current.key()] = *current
+	}
+	return writeInstalledPackages(jirix, installed)
+}
+
+// fetchedInstances returns the ids of the instances of pkgs which their
+// backends fetched, for the backends which record them.
+func fetchedInstances(jirix *jiri.X, pkgs Packages) (map[PackageKey]string, error) {
+	groups, err := pkgs.byBackend()
+	if err != nil {
+		return nil, err
+	}
+	instances := make(map[PackageKey]string)
+	for _, name := range backendNames(groups) {
+		recorder, ok := packageBackends[name].(instanceRecorder)
+		if !ok || len(groups[name]) == 0 {
+			continue
+		}
+		ids, err := recorder.installedInstances(jirix, groups[name])
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range ids {
+			instances[k] = v
+		}
+	}
+	return instances, nil
+}
+
+// PackageChanges are the differences between the files of an installed
+// package and its record.
+type PackageChanges struct {
+	Modified []string
+	Missing  []string
+	Added    []string
+}
+
+// Empty returns true if there are no differences.
+func (c *PackageChanges) Empty() bool {
+	return len(c.Modified) == 0 && len(c.Missing) == 0 && len(c.Added) == 0
+}
+
+// Verify hashes the files of the installed package p again and returns the
+// differences with its record.
+func (p *InstalledPackage) Verify(jirix *jiri.X) (*PackageChanges, error) {
+	changes := &PackageChanges{}
+	dir := filepath.Join(jirix.Root, filepath.FromSlash(p.Path))
+	files := make(map[string]string)
+	if _, err := os.Stat(dir); err == nil {
+		if files, err = hashTree(dir); err != nil {
+			return nil, err
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, fmtError(err)
+	}
+	for name, digest := range p.Files {
+		got, ok := files[name]
+		if !ok {
+			changes.Missing = append(changes.Missing, name)
+		} else if got != digest {
+			changes.Modified = append(changes.Modified, name)
+		}
+	}
+	for name := range files {
+		if _, ok := p.Files[name]; !ok {
+			changes.Added = append(changes.Added, name)
+		}
+	}
+	sort.Strings(changes.Modified)
+	sort.Strings(changes.Missing)
+	sort.Strings(changes.Added)
+	return changes, nil
+}
+
+// overlapsUsedPath returns true if the package directory dir is one of
+// usedPaths, or a parent or a child of one of them.
+func overlapsUsedPath(dir string, usedPaths map[string]bool) bool {
+	for used := range usedPaths {
+		if dir == used || strings.HasPrefix(used, dir+"/") || strings.HasPrefix(dir, used+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// gcPackages removes the directories of the installed packages which are not
+// in pkgs anymore, for the host or a target platform. Like for projects,
+// directories are only removed if gc is true, and if their content was not
+// changed locally. Directories which contain, or are in, the directory of a
+// package in pkgs are not removed.
+func gcPackages(jirix *jiri.X, pkgs Packages, gc bool) error {
+	prev, err := ReadInstalledPackages(jirix)
+	if err != nil || len(prev) == 0 {
+		return err
+	}
+	if pkgs, err = withTargetPlatforms(jirix, pkgs); err != nil {
+		return err
+	}
+	installed := make(map[string]InstalledPackage)
+	for _, p := range prev {
+		installed[p.key()] = p
+	}
+	inUse := make(map[string]bool)
+	usedPaths := make(map[string]bool)
+	for _, pkg := range pkgs {
+		current, err := pkg.installedPackage()
+		if err != nil {
+			return err
+		}
+		if current != nil {
+			inUse[current.key()] = true
+			usedPaths[current.Path] = true
+		}
+	}
+	// Records of packages whose directory was removed by their backend, or
+	// overlaps the directory of a package in use, are dropped right away.
+	var orphans []InstalledPackage
+	for _, p := range prev {
+		if inUse[p.key()] {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(jirix.Root, filepath.FromSlash(p.Path))); os.IsNotExist(err) || overlapsUsedPath(p.Path, usedPaths) {
+			delete(installed, p.key())
+			continue
+		}
+		orphans = append(orphans, p)
+	}
+	if len(orphans) != 0 && !gc {
+		msg := fmt.Sprintf("%d package(s) is/are marked to be deleted. Run '%s' to delete them.", len(orphans), jirix.Color.Yellow("jiri update -gc"))
+		jirix.Logger.Warningf("%s\n\n", msg)
+		if jirix.Logger.LoggerLevel >= log.DebugLevel {
+			msg = "List of package(s) marked to be deleted:"
+			for _, p := range orphans {
+				msg = fmt.Sprintf("%s\nName: %s, Path: '%s'", msg, jirix.Color.Yellow(p.Name), jirix.Color.Yellow(p.Path))
+			}
+			jirix.Logger.Debugf("%s\n\n", msg)
+		}
+		orphans = nil
+	}
+	for _, p := range orphans {
+		dir := filepath.Join(jirix.Root, filepath.FromSlash(p.Path))
+		changes, err := p.Verify(jirix)
+		if err != nil {
+			return err
+		}
+		if len(changes.Modified) != 0 || len(changes.Added) != 0 {
+			rmCommand := jirix.Color.Yellow("rm -rf %q", dir)
+			msg := fmt.Sprintf("Package %q won't be deleted as it might contain changes", p.Name)
+			msg += fmt.Sprintf("\nIf you no longer need it, invoke '%s'\n\n", rmCommand)
+			jirix.Logger.Warningf(msg)
+			continue
+		}
+		task := jirix.Logger.AddTaskMsg(fmt.Sprintf("Deleting package %q", p.Name))
+		err = os.RemoveAll(dir)
+		if err == nil {
+			err = removeEmptyParents(jirix, path.Dir(dir))
+		}
+		task.Done()
+		if err != nil {
+			return fmtError(err)
+		}
+		delete(installed, p.key())
+	}
+	if len(installed) == len(prev) {
+		return nil
+	}
+	return writeInstalledPackages(jirix, installed)
+}
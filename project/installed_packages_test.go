@@ -0,0 +1,219 @@
+// Copyright 2026 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package project_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"go.fuchsia.dev/jiri/jiritest"
+	"go.fuchsia.dev/jiri/project"
+)
+
+// TestInstalledPackages tests recording, verifying and garbage collecting
+// fetched packages.
+func TestInstalledPackages(t *testing.T) {
+	fake, cleanup := jiritest.NewFakeJiriRoot(t)
+	defer cleanup()
+
+	dir, err := ioutil.TempDir("", "jiri-archives")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for name, files := range map[string]map[string]string{
+		"a.tar.gz": {"bin/a": "a", "README": "a"},
+		"b.tar.gz": {"bin/b": "b"},
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), tarGz(t, files), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pkgA := project.Package{Name: "a", Version: "1", Path: "prebuilt/a", Backend: project.ArchivePackageBackend, URL: filepath.Join(dir, "a.tar.gz")}
+	pkgB := project.Package{Name: "b", Version: "1", Path: "prebuilt/b", Backend: project.ArchivePackageBackend, URL: "file://" + filepath.Join(dir, "b.tar.gz")}
+	pkgs := project.Packages{pkgA.Key(): pkgA, pkgB.Key(): pkgB}
+	if err := project.FetchPackages(fake.X, pkgs, project.DefaultPackageTimeout); err != nil {
+		t.Fatal(err)
+	}
+
+	installed, err := project.ReadInstalledPackages(fake.X)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(installed) != 2 || installed[0].Name != "a" || installed[0].Path != "prebuilt/a" || len(installed[0].Files) != 2 {
+		t.Fatalf("wrong installed packages: %+v", installed)
+	}
+	changes, err := installed[0].Verify(fake.X)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changes.Empty() {
+		t.Errorf("package should be unchanged: %+v", changes)
+	}
+
+	// Local changes are detected, and are not recorded by fetching the
+	// packages again.
+	aDir := filepath.Join(fake.X.Root, "prebuilt", "a")
+	if err := ioutil.WriteFile(filepath.Join(aDir, "bin", "a"), []byte("changed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := project.FetchPackages(fake.X, pkgs, project.DefaultPackageTimeout); err != nil {
+		t.Fatal(err)
+	}
+	if installed, err = project.ReadInstalledPackages(fake.X); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(aDir, "README")); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(aDir, "extra"), []byte("extra"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if changes, err = installed[0].Verify(fake.X); err != nil {
+		t.Fatal(err)
+	}
+	want := &project.PackageChanges{Modified: []string{"bin/a"}, Missing: []string{"README"}, Added: []string{"extra"}}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("wrong changes, got %+v, want %+v", changes, want)
+	}
+
+	// Orphaned packages are only deleted with gc, and if they have no
+	// local changes.
+	bDir := filepath.Join(fake.X.Root, "prebuilt", "b")
+	pkgs = project.Packages{}
+	if err := project.InternalGCPackages(fake.X, pkgs, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(bDir); err != nil {
+		t.Errorf("package b should not be deleted without gc: %v", err)
+	}
+	if err := project.InternalGCPackages(fake.X, pkgs, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(bDir); !os.IsNotExist(err) {
+		t.Errorf("package b should have been deleted")
+	}
+	if _, err := os.Stat(aDir); err != nil {
+		t.Errorf("package a has local changes and should not be deleted: %v", err)
+	}
+	if installed, err = project.ReadInstalledPackages(fake.X); err != nil {
+		t.Fatal(err)
+	}
+	if len(installed) != 1 || installed[0].Name != "a" {
+		t.Errorf("wrong installed packages after gc: %+v", installed)
+	}
+}
+
+// TestGCNestedPackages tests that orphaned packages containing a package in
+// use are not deleted.
+func TestGCNestedPackages(t *testing.T) {
+	fake, cleanup := jiritest.NewFakeJiriRoot(t)
+	defer cleanup()
+
+	dir, err := ioutil.TempDir("", "jiri-archives")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for name, files := range map[string]map[string]string{
+		"parent.tar.gz": {"bin/parent": "parent"},
+		"child.tar.gz":  {"bin/child": "child"},
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), tarGz(t, files), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	parent := project.Package{Name: "parent", Version: "1", Path: "prebuilt", Backend: project.ArchivePackageBackend, URL: filepath.Join(dir, "parent.tar.gz")}
+	child := project.Package{Name: "child", Version: "1", Path: "prebuilt/child", Backend: project.ArchivePackageBackend, URL: filepath.Join(dir, "child.tar.gz")}
+	if err := project.FetchPackages(fake.X, project.Packages{parent.Key(): parent}, project.DefaultPackageTimeout); err != nil {
+		t.Fatal(err)
+	}
+	if err := project.FetchPackages(fake.X, project.Packages{parent.Key(): parent, child.Key(): child}, project.DefaultPackageTimeout); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := project.InternalGCPackages(fake.X, project.Packages{child.Key(): child}, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(fake.X.Root, "prebuilt", "child", "bin", "child")); err != nil {
+		t.Errorf("package child should not be deleted: %v", err)
+	}
+}
+
+// TestInstalledPackagesRefetched tests that the files of packages are only
+// recorded again when their backend fetches another instance, and not when
+// files are added locally.
+func TestInstalledPackagesRefetched(t *testing.T) {
+	fake, cleanup := jiritest.NewFakeJiriRoot(t)
+	defer cleanup()
+
+	dir, err := ioutil.TempDir("", "jiri-archives")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for name, files := range map[string]map[string]string{
+		"a1.tar.gz": {"bin/a": "1"},
+		"a2.tar.gz": {"bin/a": "2", "README": "2"},
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), tarGz(t, files), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pkg := project.Package{Name: "a", Version: "1", Path: "prebuilt/a", Backend: project.ArchivePackageBackend, URL: filepath.Join(dir, "a1.tar.gz")}
+	if err := project.FetchPackages(fake.X, project.Packages{pkg.Key(): pkg}, project.DefaultPackageTimeout); err != nil {
+		t.Fatal(err)
+	}
+	installed, err := project.ReadInstalledPackages(fake.X)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(installed) != 1 || installed[0].InstanceID == "" {
+		t.Fatalf("the fetched instance should be recorded: %+v", installed)
+	}
+	first := installed[0].InstanceID
+
+	// A file added locally changes the modification time of the directory
+	// of the package, but is not recorded.
+	aDir := filepath.Join(fake.X.Root, "prebuilt", "a")
+	if err := ioutil.WriteFile(filepath.Join(aDir, "extra"), []byte("extra"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := project.FetchPackages(fake.X, project.Packages{pkg.Key(): pkg}, project.DefaultPackageTimeout); err != nil {
+		t.Fatal(err)
+	}
+	if installed, err = project.ReadInstalledPackages(fake.X); err != nil {
+		t.Fatal(err)
+	}
+	changes, err := installed[0].Verify(fake.X)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &project.PackageChanges{Added: []string{"extra"}}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("wrong changes, got %+v, want %+v", changes, want)
+	}
+
+	// Another instance is recorded.
+	pkg.URL = filepath.Join(dir, "a2.tar.gz")
+	if err := project.FetchPackages(fake.X, project.Packages{pkg.Key(): pkg}, project.DefaultPackageTimeout); err != nil {
+		t.Fatal(err)
+	}
+	if installed, err = project.ReadInstalledPackages(fake.X); err != nil {
+		t.Fatal(err)
+	}
+	if len(installed) != 1 || installed[0].InstanceID == first || len(installed[0].Files) != 2 {
+		t.Fatalf("the new instance should be recorded: %+v", installed)
+	}
+	if changes, err = installed[0].Verify(fake.X); err != nil {
+		t.Fatal(err)
+	}
+	if !changes.Empty() {
+		t.Errorf("package should be unchanged: %+v", changes)
+	}
+}
@@ -6,3 +6,28 @@ package project
 
 // InternalWriteMetadata exports writeMetadata for tests.
 var InternalWriteMetadata = writeMetadata
+
+// InternalGCPackages exports gcPackages for tests.
+var InternalGCPackages = gcPackages
+
+// InternalMatchAttributes returns whether an item with the attributes attrs
+// is fetched with the attributes to fetch, and why.
+func InternalMatchAttributes(attrs, fetching string) (bool, string, error) {
+	attrs, computed, err := computeAttributes(attrs)
+	if err != nil {
+		return false, "", err
+	}
+	return newAttrEnv(fetching).included(attrs, computed)
+}
+
+// InternalResolveSubmoduleLocks exports resolveSubmoduleLocks for tests.
+var InternalResolveSubmoduleLocks = resolveSubmoduleLocks
+
+// InternalVerifySubmoduleLocks exports verifySubmoduleLocks for tests.
+var InternalVerifySubmoduleLocks = verifySubmoduleLocks
+
+// InternalExtractArchive exports extractArchive for tests.
+var InternalExtractArchive = extractArchive
+
+// InternalDetectLicenses exports detectLicenses for tests.
+var InternalDetectLicenses = detectLicenses
@@ -44,6 +44,33 @@ type loader struct {
 	manifests        map[string]bool
 	lockfiles        map[string]bool
 	parentFile       string
+
+	// checkLocks makes the loader keep conflicting lock entries, and record
+	// the entries of each lockfile in loadedLocks, for "jiri resolve -check".
+	checkLocks  bool
+	loadedLocks []*loadedLockfile
+
+	// allImports makes the loader load every import whatever its
+	// attributes, so that lockfiles cover all of them.
+	allImports bool
+
+	// imports records the imports of manifests in the order they are
+	// loaded, for "jiri provenance".
+	imports []ManifestImport
+	// importer is the node of the manifest with the remote import being
+	// loaded.
+	importer *importTreeNode
+}
+
+// loadedLockfile holds the entries of a lockfile loaded by the loader.
+type loadedLockfile struct {
+	// path is the path of the lockfile on the filesystem, or in the
+	// checkout of the manifest repository if the lockfile was read from
+	// git.
+	path         string
+	fromGit      bool
+	projectLocks ProjectLocks
+	pkgLocks     PackageLocks
 }
 
 type importTreeNode struct {
@@ -51,6 +78,15 @@ type importTreeNode struct {
 	children           map[*importTreeNode]bool
 	tag                string
 	computedAttributes attributes
+	// file is the manifest file of the node, or empty if the node was not
+	// loaded.
+	file string
+}
+
+// importEdge is the import of the manifest of child by the manifest of
+// parent.
+type importEdge struct {
+	parent, child *importTreeNode
 }
 
 type importTree struct {
@@ -58,6 +94,10 @@ type importTree struct {
 	pool          map[string]*importTreeNode
 	filenameMap   map[string]bool
 	projectKeyMap map[ProjectKey]*importTreeNode
+	// remoteImports are the remote imports between loaded nodes. The
+	// children of remote imports in the tree are not the loaded nodes,
+	// see buildImportAttributes.
+	remoteImports []importEdge
 }
 
 func newImportTree() importTree {
@@ -67,6 +107,7 @@ func newImportTree() importTree {
 			make(map[*importTreeNode]bool),
 			"",
 			nil,
+			"",
 		},
 		pool:          make(map[string]*importTreeNode),
 		filenameMap:   make(map[string]bool),
@@ -84,6 +125,7 @@ func (t *importTree) getNode(repoPath, file, ref string) *importTreeNode {
 		make(map[*importTreeNode]bool),
 		key,
 		nil,
+		"",
 	}
 	if len(t.pool) == 0 {
 		t.root.addChild(v)
@@ -233,6 +275,19 @@ func newManifestLoader(localProjects Projects, update bool, file string) *loader
 	}
 }
 
+// importIncluded returns whether an import with the given attributes is
+// loaded, along with the reason, like the package-level importIncluded. When
+// ld.allImports is set, as when walking every import to check the lockfiles,
+// imports whose attributes are valid are loaded even if they are not
+// selected.
+func (ld *loader) importIncluded(jirix *jiri.X, attrs string) (bool, string, error) {
+	ok, reason, err := importIncluded(jirix, attrs)
+	if err == nil && ld.allImports {
+		return true, "", nil
+	}
+	return ok, reason, err
+}
+
 // loadNoCycles checks for cycles in imports.  There are two types of cycles:
 //   file - Cycle in the paths of manifest files in the local filesystem.
 //   key  - Cycle in the remote manifests specified by remote imports.
@@ -391,13 +446,13 @@ func (ld *loader) loadLockFile(jirix *jiri.X, repoPath, dir, lockFileName, ref s
 
 	var data []byte
 	if repoPath != "" {
-		s, err := gitutil.New(jirix, gitutil.RootDirOpt(repoPath)).Show(ref, lockfile)
+		s, err := gitutil.New(jirix, gitutil.RootDirOpt(repoPath)).ShowBytes(ref, lockfile)
 		if err != nil {
 			// It's fine if jiri.lock cannot be find, skip this jiri.lock
 			jirix.Logger.Debugf("Could not find %q in repository %q for ref %q", lockfile, repoPath, ref)
 			return nil
 		}
-		data = []byte(s)
+		data = s
 	} else {
 		if _, err := os.Stat(lockfile); err != nil {
 			if os.IsNotExist(err) {
@@ -414,9 +469,21 @@ func (ld *loader) loadLockFile(jirix *jiri.X, repoPath, dir, lockFileName, ref s
 		}
 		data = temp
 	}
-	if err := ld.parseLockData(jirix, data); err != nil {
+	if err := verifyLockData(jirix, repoPath, lockfile, ref, data); err != nil {
 		return err
 	}
+	projectLocks, pkgLocks, err := ld.parseLockData(jirix, data)
+	if err != nil {
+		return err
+	}
+	if ld.checkLocks {
+		ld.loadedLocks = append(ld.loadedLocks, &loadedLockfile{
+			path:         filepath.Join(repoPath, lockfile),
+			fromGit:      repoPath != "",
+			projectLocks: projectLocks,
+			pkgLocks:     pkgLocks,
+		})
+	}
 	if repoPath == "" {
 		jirix.Logger.Debugf("loaded lockfile at %s", lockfile)
 	} else {
@@ -426,16 +493,37 @@ func (ld *loader) loadLockFile(jirix *jiri.X, repoPath, dir, lockFileName, ref s
 	return nil
 }
 
-func (ld *loader) parseLockData(jirix *jiri.X, data []byte) error {
+// verifyLockData checks the signature of the lockfile data when jiri
+// requires signed lockfiles. The signature is read from the same place as
+// the lockfile.
+func verifyLockData(jirix *jiri.X, repoPath, lockfile, ref string, data []byte) error {
+	if len(jirix.TrustedLockfileKeys) == 0 || jirix.IgnoreLockSignatures {
+		return nil
+	}
+	sigFile := LockfileSignaturePath(lockfile)
+	name := fmt.Sprintf("%q", lockfile)
+	var sig []byte
+	if repoPath != "" {
+		name = fmt.Sprintf("%q in repository %q for ref %q", lockfile, repoPath, ref)
+		if s, err := gitutil.New(jirix, gitutil.RootDirOpt(repoPath)).ShowBytes(ref, sigFile); err == nil {
+			sig = s
+		}
+	} else if s, err := ioutil.ReadFile(sigFile); err == nil {
+		sig = s
+	}
+	return verifyLockfile(jirix, name, data, sig)
+}
+
+func (ld *loader) parseLockData(jirix *jiri.X, data []byte) (ProjectLocks, PackageLocks, error) {
 	projectLocks, pkgLocks, err := UnmarshalLockEntries(data)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
 	for k, v := range projectLocks {
 		if projLock, ok := ld.ProjectLocks[k]; ok {
-			if projLock != v && !jirix.UsingImportOverride {
-				return fmt.Errorf("conflicting project lock entries %+v with %+v", projLock, v)
+			if !projLock.LockEqual(v) && !jirix.UsingImportOverride && !ld.checkLocks {
+				return nil, nil, fmt.Errorf("conflicting project lock entries %+v with %+v", projLock, v)
 			}
 		} else {
 			ld.ProjectLocks[k] = v
@@ -446,15 +534,15 @@ func (ld *loader) parseLockData(jirix *jiri.X, data []byte) error {
 		if pkgLock, ok := ld.PackageLocks[k]; ok {
 			// Only package locks may conflict during a normal 'jiri resolve'.
 			// Treating conflicts as errors in all other scenarios.
-			if !pkgLock.LockEqual(v) && !jirix.IgnoreLockConflicts && !jirix.UsingImportOverride {
-				return fmt.Errorf("conflicting package lock entries %+v with %+v", pkgLock, v)
+			if !pkgLock.LockEqual(v) && !jirix.IgnoreLockConflicts && !jirix.UsingImportOverride && !ld.checkLocks {
+				return nil, nil, fmt.Errorf("conflicting package lock entries %+v with %+v", pkgLock, v)
 			}
 		} else {
 			ld.PackageLocks[k] = v
 		}
 	}
 
-	return nil
+	return projectLocks, pkgLocks, nil
 }
 
 func (ld *loader) load(jirix *jiri.X, root, repoPath, file, ref, parentImport string, localManifest bool) error {
@@ -462,6 +550,11 @@ func (ld *loader) load(jirix *jiri.X, root, repoPath, file, ref, parentImport st
 	if repoPath != "" {
 		f = filepath.Join(repoPath, file)
 	}
+	if ld.importer != nil {
+		node := ld.importTree.getNode(repoPath, file, ref)
+		ld.importTree.remoteImports = append(ld.importTree.remoteImports, importEdge{ld.importer, node})
+		ld.importer = nil
+	}
 	if ld.manifests[f] {
 		return nil
 	}
@@ -544,6 +637,7 @@ func (ld *loader) load(jirix *jiri.X, root, repoPath, file, ref, parentImport st
 	}
 	self := ld.importTree.getNode(repoPath, file, ref)
 	self.tag = defaultGitAttrs()
+	self.file = f
 	// Process remote imports.
 	for _, remote := range m.Imports {
 		// Apply override if it exists.
@@ -551,6 +645,12 @@ func (ld *loader) load(jirix *jiri.X, root, repoPath, file, ref, parentImport st
 		if err != nil {
 			return err
 		}
+		if ok, reason, err := ld.importIncluded(jirix, remote.Attributes); err != nil {
+			return fmt.Errorf("import %q found in %q: %v", remote.Name, shortFileName(jirix.Root, repoPath, file, ref), err)
+		} else if !ok {
+			jirix.Logger.Debugf("import %q of %q is skipped (%s)", remote.Name, remote.Manifest, reason)
+			continue
+		}
 		nextRoot := filepath.Join(root, remote.Root)
 		remote.Name = filepath.Join(nextRoot, remote.Name)
 		key := remote.ProjectKey()
@@ -577,15 +677,34 @@ func (ld *loader) load(jirix *jiri.X, root, repoPath, file, ref, parentImport st
 			pi = fmt.Sprintf("import[manifest=%q, remote=%q]", remote.Manifest, remote.Remote)
 		}
 
+		ld.imports = append(ld.imports, ManifestImport{
+			Manifest:   remote.Manifest,
+			Remote:     remote.Remote,
+			ImportedBy: shortFileName(jirix.Root, repoPath, file, ref),
+			project:    p,
+		})
 		self.addChild(ld.importTree.getNode(repoPath, remote.Manifest, ""))
-		if err := ld.loadImport(jirix, nextRoot, remote.Manifest, remote.cycleKey(), cacheDirPath, pi, p, localManifest); err != nil {
+		ld.importer = self
+		err = ld.loadImport(jirix, nextRoot, remote.Manifest, remote.cycleKey(), cacheDirPath, pi, p, localManifest)
+		ld.importer = nil
+		if err != nil {
 			return err
 		}
 	}
 
 	// Process local imports.
 	for _, local := range m.LocalImports {
+		if ok, reason, err := ld.importIncluded(jirix, local.Attributes); err != nil {
+			return fmt.Errorf("localimport %q found in %q: %v", local.File, shortFileName(jirix.Root, repoPath, file, ref), err)
+		} else if !ok {
+			jirix.Logger.Debugf("localimport %q is skipped (%s)", local.File, reason)
+			continue
+		}
 		nextFile := filepath.Join(filepath.Dir(file), local.File)
+		ld.imports = append(ld.imports, ManifestImport{
+			Manifest:   shortFileName(jirix.Root, repoPath, nextFile, ref),
+			ImportedBy: shortFileName(jirix.Root, repoPath, file, ref),
+		})
 		self.addChild(ld.importTree.getNode(repoPath, nextFile, ref))
 		if err := ld.Load(jirix, root, repoPath, nextFile, ref, "", parentImport, localManifest); err != nil {
 			return err
@@ -610,8 +729,15 @@ func (ld *loader) load(jirix *jiri.X, root, repoPath, file, ref, parentImport st
 			return err
 		}
 		// normalize project attributes
-		project.ComputedAttributes = newAttributes(project.Attributes)
-		project.Attributes = project.ComputedAttributes.String()
+		if project.Attributes, project.ComputedAttributes, err = computeAttributes(project.Attributes); err != nil {
+			return fmt.Errorf("project %q found in %q: %v", project.Name, shortFileName(jirix.Root, repoPath, file, ref), err)
+		}
+		if err := validateEnv(project.Env); err != nil {
+			return fmt.Errorf("project %q found in %q: %v", project.Name, shortFileName(jirix.Root, repoPath, file, ref), err)
+		}
+		if err := validateProjectFiles(&project); err != nil {
+			return fmt.Errorf("project %q found in %q: %v", project.Name, shortFileName(jirix.Root, repoPath, file, ref), err)
+		}
 		// Make paths absolute by prepending <root>.
 		project.absolutizePaths(filepath.Join(jirix.Root, root))
 
@@ -651,6 +777,10 @@ func (ld *loader) load(jirix *jiri.X, root, repoPath, file, ref, parentImport st
 	}
 
 	for _, hook := range m.Hooks {
+		var err error
+		if hook.Attributes, hook.ComputedAttributes, err = computeAttributes(hook.Attributes); err != nil {
+			return fmt.Errorf("hook %q found in %q: %v", hook.Name, shortFileName(jirix.Root, repoPath, file, ref), err)
+		}
 		if hook.ActionPath == "" {
 			return fmt.Errorf("invalid hook %q for project %q. Please make sure you are importing project %q and this hook is in the manifest which directly/indirectly imports that project.", hook.Name, hook.ProjectName, hook.ProjectName)
 		}
@@ -660,8 +790,13 @@ func (ld *loader) load(jirix *jiri.X, root, repoPath, file, ref, parentImport st
 
 	for _, pkg := range m.Packages {
 		// normalize package attributes.
-		pkg.ComputedAttributes = newAttributes(pkg.Attributes)
-		pkg.Attributes = pkg.ComputedAttributes.String()
+		var err error
+		if pkg.Attributes, pkg.ComputedAttributes, err = computeAttributes(pkg.Attributes); err != nil {
+			return fmt.Errorf("package %q found in %q: %v", pkg.Name, shortFileName(jirix.Root, repoPath, file, ref), err)
+		}
+		if err := validateEnv(pkg.Env); err != nil {
+			return fmt.Errorf("package %q found in %q: %v", pkg.Name, shortFileName(jirix.Root, repoPath, file, ref), err)
+		}
 		// Record manifest location.
 		pkg.ManifestPath = f
 		key := pkg.Key()
@@ -0,0 +1,394 @@
+// Copyright 2026 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package project
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"go.fuchsia.dev/jiri"
+	"go.fuchsia.dev/jiri/cipd"
+)
+
+// LockIssueKind is the kind of a problem found in lockfiles.
+type LockIssueKind string
+
+const (
+	// LockStale is a package lock entry for another version of a package
+	// of the manifest.
+	LockStale LockIssueKind = "stale"
+	// LockMissing is a project or package of the manifest which is not
+	// locked.
+	LockMissing LockIssueKind = "missing"
+	// LockUnused is a lock entry for a project or package which is not in
+	// the manifest.
+	LockUnused LockIssueKind = "unused"
+	// LockConflicting is a lock entry which disagrees with the revision
+	// pinned in the manifest, or with the same entry in another lockfile.
+	LockConflicting LockIssueKind = "conflicting"
+)
+
+// LockIssue is a problem found in lockfiles by CheckLockfiles.
+type LockIssue struct {
+	Kind LockIssueKind `json:"kind"`
+	// Lockfile is the lockfile holding the entry. It is empty for missing
+	// entries.
+	Lockfile string `json:"lockfile,omitempty"`
+	// Entry names the project or package of the entry.
+	Entry   string `json:"entry"`
+	Message string `json:"message"`
+}
+
+func (i LockIssue) String() string {
+	if i.Lockfile == "" {
+		return fmt.Sprintf("%s %s: %s", i.Kind, i.Entry, i.Message)
+	}
+	return fmt.Sprintf("%s: %s %s: %s", i.Lockfile, i.Kind, i.Entry, i.Message)
+}
+
+func projectLockEntry(k ProjectLockKey) string {
+	return fmt.Sprintf("project %s(%s)", k.name, k.remote)
+}
+
+func packageLockEntry(k PackageLockKey) string {
+	return fmt.Sprintf("package %s@%s", k.packageName, k.versionTag)
+}
+
+// lockCheck holds the manifests and lockfiles checked by CheckLockfiles, and
+// the changes which fix the issues found.
+type lockCheck struct {
+	projects  Projects
+	pkgs      Packages
+	lockfiles []*loadedLockfile
+	issues    []LockIssue
+
+	// expected maps the package lock keys of the manifest to the keys of
+	// their manifest packages.
+	expected map[PackageLockKey]PackageKey
+	// projectFixes are the revisions to set in project lock entries.
+	projectFixes map[*loadedLockfile]map[ProjectLockKey]string
+	// removals are the entries to remove from lockfiles.
+	removals map[*loadedLockfile][]interface{}
+	// resolves are the package lock entries to resolve, with the lockfiles
+	// they go to.
+	resolves map[PackageLockKey]map[*loadedLockfile]bool
+	// unfixable are the issues which cannot be fixed automatically.
+	unfixable []LockIssue
+}
+
+func (c *lockCheck) report(issue LockIssue) {
+	c.issues = append(c.issues, issue)
+}
+
+func (c *lockCheck) remove(lf *loadedLockfile, key interface{}) {
+	c.removals[lf] = append(c.removals[lf], key)
+}
+
+func (c *lockCheck) resolve(key PackageLockKey, lf *loadedLockfile) {
+	if c.resolves[key] == nil {
+		c.resolves[key] = make(map[*loadedLockfile]bool)
+	}
+	c.resolves[key][lf] = true
+}
+
+// loadLockCheck loads manifestFiles and the lockfiles next to them.
+func loadLockCheck(jirix *jiri.X, manifestFiles []string, localManifest bool) (*lockCheck, error) {
+	localProjects, err := LocalProjects(jirix, FastScan)
+	if err != nil {
+		return nil, err
+	}
+	c := &lockCheck{
+		projects:     make(Projects),
+		pkgs:         make(Packages),
+		expected:     make(map[PackageLockKey]PackageKey),
+		projectFixes: make(map[*loadedLockfile]map[ProjectLockKey]string),
+		removals:     make(map[*loadedLockfile][]interface{}),
+		resolves:     make(map[PackageLockKey]map[*loadedLockfile]bool),
+	}
+	seen := make(map[string]bool)
+	for _, manifestFile := range manifestFiles {
+		ld := newManifestLoader(localProjects, false, manifestFile)
+		ld.checkLocks = true
+		ld.allImports = true
+		if err := ld.Load(jirix, "", "", manifestFile, "", "", "", localManifest); err != nil {
+			return nil, err
+		}
+		jirix.AddCleanupFunc(ld.cleanup)
+		for k, v := range ld.Projects {
+			c.projects[k] = v
+		}
+		for k, v := range ld.Packages {
+			c.pkgs[k] = v
+		}
+		for _, lf := range ld.loadedLocks {
+			if !seen[lf.path] {
+				seen[lf.path] = true
+				c.lockfiles = append(c.lockfiles, lf)
+			}
+		}
+	}
+	return c, nil
+}
+
+func (c *lockCheck) checkProjects() {
+	// locked maps the projects of the manifest to the first lockfile which
+	// locks them. The remotes of project keys are normalized, unlike those
+	// of project lock keys.
+	locked := make(map[ProjectKey]*loadedLockfile)
+	revisions := make(map[ProjectKey]string)
+	projectLocked := false
+	for _, lf := range c.lockfiles {
+		for k, lock := range lf.projectLocks {
+			projectLocked = true
+			key := MakeProjectKey(lock.Name, lock.Remote)
+			p, ok := c.projects[key]
+			if !ok {
+				c.report(LockIssue{LockUnused, lf.path, projectLockEntry(k), "project is not in the manifest"})
+				c.remove(lf, k)
+				continue
+			}
+			if p.Revision != "" && p.Revision != "HEAD" && p.Revision != lock.Revision {
+				c.report(LockIssue{LockConflicting, lf.path, projectLockEntry(k),
+					fmt.Sprintf("revision %s does not match revision %s pinned in the manifest", lock.Revision, p.Revision)})
+				if c.projectFixes[lf] == nil {
+					c.projectFixes[lf] = make(map[ProjectLockKey]string)
+				}
+				c.projectFixes[lf][k] = p.Revision
+				if _, ok := locked[key]; !ok {
+					locked[key] = lf
+					revisions[key] = p.Revision
+				}
+				continue
+			}
+			if other, ok := locked[key]; ok {
+				if revision := revisions[key]; revision != lock.Revision {
+					issue := LockIssue{LockConflicting, lf.path, projectLockEntry(k),
+						fmt.Sprintf("revision %s does not match revision %s in %s", lock.Revision, revision, other.path)}
+					c.report(issue)
+					c.unfixable = append(c.unfixable, issue)
+				}
+				continue
+			}
+			locked[key] = lf
+			revisions[key] = lock.Revision
+		}
+	}
+	// Project locks are optional, they are only expected if some projects
+	// are locked.
+	if !projectLocked {
+		return
+	}
+	for k, p := range c.projects {
+		if _, ok := locked[k]; !ok {
+			issue := LockIssue{Kind: LockMissing, Entry: projectLockEntry(ProjectLock{Remote: p.Remote, Name: p.Name}.Key()), Message: "project is not locked"}
+			c.report(issue)
+			c.unfixable = append(c.unfixable, issue)
+		}
+	}
+}
+
+func (c *lockCheck) checkPackages(jirix *jiri.X) error {
+	// versions maps the package names of the manifest to their versions.
+	versions := make(map[string]string)
+	for _, v := range c.pkgs {
+		plats, err := v.GetPlatforms()
+		if err != nil {
+			return err
+		}
+		names, err := cipd.Expand(v.Name, plats)
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			c.expected[MakePackageLockKey(name, v.Version)] = v.Key()
+			versions[name] = v.Version
+		}
+	}
+
+	// usedPkgLocks records the package lock entries used by the manifest,
+	// like enforceLocks.
+	usedPkgLocks := make(map[PackageLockKey]*loadedLockfile)
+	stale := make(map[PackageLockKey]bool)
+	for _, lf := range c.lockfiles {
+		for k, lock := range lf.pkgLocks {
+			if _, ok := c.expected[k]; ok {
+				if other, ok := usedPkgLocks[k]; ok && !other.pkgLocks[k].LockEqual(lock) {
+					c.report(LockIssue{LockConflicting, lf.path, packageLockEntry(k),
+						fmt.Sprintf("instance %s does not match instance %s in %s", lock.InstanceID, other.pkgLocks[k].InstanceID, other.path)})
+					c.resolve(k, lf)
+					c.resolve(k, other)
+					continue
+				}
+				usedPkgLocks[k] = lf
+				continue
+			}
+			c.remove(lf, k)
+			if version, ok := versions[k.packageName]; ok {
+				c.report(LockIssue{LockStale, lf.path, packageLockEntry(k), fmt.Sprintf("the manifest has version %s", version)})
+				newKey := MakePackageLockKey(k.packageName, version)
+				c.resolve(newKey, lf)
+				stale[newKey] = true
+				continue
+			}
+			c.report(LockIssue{LockUnused, lf.path, packageLockEntry(k), "package is not in the manifest"})
+		}
+	}
+
+	// Package locks are only expected if there are lockfiles.
+	if len(c.lockfiles) == 0 {
+		return nil
+	}
+	for k, pkgKey := range c.expected {
+		if _, ok := usedPkgLocks[k]; ok || stale[k] {
+			continue
+		}
+		c.report(LockIssue{Kind: LockMissing, Entry: packageLockEntry(k), Message: "package is not locked"})
+		if lf := c.nearestLockfile(jirix, c.pkgs[pkgKey].ManifestPath); lf != nil {
+			c.resolve(k, lf)
+		}
+	}
+	return nil
+}
+
+// nearestLockfile returns the lockfile which the loader loads first for the
+// manifest, or a new one next to the manifest if there is none.
+func (c *lockCheck) nearestLockfile(jirix *jiri.X, manifestPath string) *loadedLockfile {
+	if manifestPath == "" {
+		return nil
+	}
+	dir := filepath.Dir(manifestPath)
+	for {
+		path := filepath.Join(dir, jirix.LockfileName)
+		for _, lf := range c.lockfiles {
+			if lf.path == path {
+				return lf
+			}
+		}
+		if dir == "" || dir == "." || dir == jirix.Root || dir == string(filepath.Separator) {
+			break
+		}
+		dir = filepath.Dir(dir)
+	}
+	lf := &loadedLockfile{
+		path:         filepath.Join(filepath.Dir(manifestPath), jirix.LockfileName),
+		projectLocks: make(ProjectLocks),
+		pkgLocks:     make(PackageLocks),
+	}
+	c.lockfiles = append(c.lockfiles, lf)
+	return lf
+}
+
+// CheckLockfiles loads manifestFiles with their lockfiles, and returns the
+// stale, missing, unused and conflicting lock entries.
+func CheckLockfiles(jirix *jiri.X, manifestFiles []string, localManifest bool) ([]LockIssue, error) {
+	c, err := checkLockfiles(jirix, manifestFiles, localManifest)
+	if err != nil {
+		return nil, err
+	}
+	return c.issues, nil
+}
+
+func checkLockfiles(jirix *jiri.X, manifestFiles []string, localManifest bool) (*lockCheck, error) {
+	c, err := loadLockCheck(jirix, manifestFiles, localManifest)
+	if err != nil {
+		return nil, err
+	}
+	c.checkProjects()
+	if err := c.checkPackages(jirix); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(c.issues, func(i, j int) bool {
+		a, b := c.issues[i], c.issues[j]
+		if a.Lockfile != b.Lockfile {
+			return a.Lockfile < b.Lockfile
+		}
+		return a.Entry < b.Entry
+	})
+	return c, nil
+}
+
+// FixLockfiles checks the lockfiles of manifestFiles like CheckLockfiles,
+// and rewrites the lockfiles with issues. Unused and stale entries are
+// removed, project entries are set to the revisions pinned in the manifest,
+// and stale, missing and conflicting package entries are resolved again.
+// It returns the issues found and the rewritten lockfiles. Lockfiles read
+// from git cannot be fixed, manifestFiles must be loaded with localManifest.
+func FixLockfiles(jirix *jiri.X, manifestFiles []string, localManifest bool) ([]LockIssue, []string, error) {
+	c, err := checkLockfiles(jirix, manifestFiles, localManifest)
+	if err != nil {
+		return nil, nil, err
+	}
+	changed := make(map[*loadedLockfile]bool)
+	for lf := range c.removals {
+		changed[lf] = true
+	}
+	for lf := range c.projectFixes {
+		changed[lf] = true
+	}
+	for _, lockfiles := range c.resolves {
+		for lf := range lockfiles {
+			changed[lf] = true
+		}
+	}
+	for lf := range changed {
+		if lf.fromGit {
+			return nil, nil, fmt.Errorf("cannot fix lockfile %s which is read from git, use the local manifest instead", lf.path)
+		}
+	}
+
+	for lf, keys := range c.removals {
+		for _, key := range keys {
+			switch k := key.(type) {
+			case ProjectLockKey:
+				delete(lf.projectLocks, k)
+			case PackageLockKey:
+				delete(lf.pkgLocks, k)
+			}
+		}
+	}
+	for lf, revisions := range c.projectFixes {
+		for k, revision := range revisions {
+			lock := lf.projectLocks[k]
+			lock.Revision = revision
+			// The submodule pins are for the old revision.
+			lock.Submodules, lock.LFSObjects = nil, nil
+			lf.projectLocks[k] = lock
+		}
+	}
+	if len(c.resolves) != 0 {
+		pkgs := make(Packages)
+		for k := range c.resolves {
+			pkg := c.pkgs[c.expected[k]]
+			pkgs[pkg.Key()] = pkg
+		}
+		resolved, err := ResolvePackageLocks(jirix, pkgs)
+		if err != nil {
+			return nil, nil, err
+		}
+		for k, lockfiles := range c.resolves {
+			lock, ok := resolved[k]
+			if !ok {
+				c.unfixable = append(c.unfixable, LockIssue{Kind: LockMissing, Entry: packageLockEntry(k), Message: "package could not be resolved"})
+				continue
+			}
+			for lf := range lockfiles {
+				lf.pkgLocks[k] = lock
+			}
+		}
+	}
+	var written []string
+	for lf := range changed {
+		if err := writeLockFile(jirix, lf.path, lf.projectLocks, lf.pkgLocks); err != nil {
+			return nil, nil, err
+		}
+		written = append(written, lf.path)
+	}
+	sort.Strings(written)
+	for _, issue := range c.unfixable {
+		jirix.Logger.Warningf("Cannot fix %s\n\n", issue)
+	}
+	return c.issues, written, nil
+}
@@ -0,0 +1,123 @@
+// Copyright 2026 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package project_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"go.fuchsia.dev/jiri/jiritest/xtest"
+	"go.fuchsia.dev/jiri/project"
+)
+
+func TestCheckLockfiles(t *testing.T) {
+	jirix, cleanup := xtest.NewX(t)
+	defer cleanup()
+	jirix.LockfileEnabled = true
+	jirix.LockfileName = "jiri.lock"
+
+	m := project.Manifest{
+		Projects: []project.Project{
+			{Name: "a", Remote: "https://example.com/a", Path: "a", Revision: "rev-a"},
+			{Name: "b", Remote: "https://example.com/b", Path: "b"},
+		},
+		Packages: []project.Package{
+			{Name: "tool", Version: "version:2", Path: "tool"},
+			{Name: "other", Version: "version:1", Path: "other"},
+			{Name: "new", Version: "version:1", Path: "new"},
+		},
+	}
+	if err := m.ToFile(jirix, jirix.JiriManifestFile()); err != nil {
+		t.Fatal(err)
+	}
+	lockfile := filepath.Join(jirix.Root, "jiri.lock")
+	writeLocks := func(projectLocks []project.ProjectLock, pkgLocks []project.PackageLock) {
+		t.Helper()
+		pl := make(project.ProjectLocks)
+		for _, l := range projectLocks {
+			pl[l.Key()] = l
+		}
+		kl := make(project.PackageLocks)
+		for _, l := range pkgLocks {
+			kl[l.Key()] = l
+		}
+		data, err := project.MarshalLockEntries(pl, kl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(lockfile, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	lockA := project.ProjectLock{Remote: "https://example.com/a", Name: "a", Revision: "rev-old"}
+	lockB := project.ProjectLock{Remote: "https://example.com/b", Name: "b", Revision: "rev-b"}
+	lockGone := project.ProjectLock{Remote: "https://example.com/gone", Name: "gone", Revision: "rev-gone"}
+	lockTool := project.PackageLock{PackageName: "tool", VersionTag: "version:1", InstanceID: "id-1"}
+	lockOther := project.PackageLock{PackageName: "other", VersionTag: "version:1", InstanceID: "id-2"}
+	lockUnused := project.PackageLock{PackageName: "unused", VersionTag: "version:1", InstanceID: "id-3"}
+	writeLocks([]project.ProjectLock{lockA, lockB, lockGone}, []project.PackageLock{lockTool, lockOther, lockUnused})
+
+	issues, err := project.CheckLockfiles(jirix, []string{jirix.JiriManifestFile()}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]project.LockIssueKind)
+	for _, issue := range issues {
+		got[issue.Entry] = issue.Kind
+	}
+	want := map[string]project.LockIssueKind{
+		"project a(https://example.com/a)":       project.LockConflicting,
+		"project gone(https://example.com/gone)": project.LockUnused,
+		"package tool@version:1":                 project.LockStale,
+		"package unused@version:1":               project.LockUnused,
+		"package new@version:1":                  project.LockMissing,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got issues %v, want %v", issues, want)
+	}
+
+	// Fix a lockfile which does not need packages to be resolved.
+	m.Packages = m.Packages[1:2]
+	if err := m.ToFile(jirix, jirix.JiriManifestFile()); err != nil {
+		t.Fatal(err)
+	}
+	issues, lockfiles, err := project.FixLockfiles(jirix, []string{jirix.JiriManifestFile()}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(issues) != 4 {
+		t.Errorf("expected 4 issues, got %v", issues)
+	}
+	if !reflect.DeepEqual(lockfiles, []string{lockfile}) {
+		t.Errorf("expected %s to be rewritten, got %v", lockfile, lockfiles)
+	}
+	data, err := ioutil.ReadFile(lockfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	projectLocks, pkgLocks, err := project.UnmarshalLockEntries(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lockA.Revision = "rev-a"
+	wantProjectLocks := project.ProjectLocks{lockA.Key(): lockA, lockB.Key(): lockB}
+	if !reflect.DeepEqual(projectLocks, wantProjectLocks) {
+		t.Errorf("got project locks %v, want %v", projectLocks, wantProjectLocks)
+	}
+	wantPkgLocks := project.PackageLocks{lockOther.Key(): lockOther}
+	if !reflect.DeepEqual(pkgLocks, wantPkgLocks) {
+		t.Errorf("got package locks %v, want %v", pkgLocks, wantPkgLocks)
+	}
+
+	issues, err = project.CheckLockfiles(jirix, []string{jirix.JiriManifestFile()}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(issues) != 0 {
+		t.Errorf("expected no issues after the fix, got %v", issues)
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright 2026 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package project
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+
+	"go.fuchsia.dev/jiri"
+	"go.fuchsia.dev/jiri/envvar"
+	"go.fuchsia.dev/jiri/sshsig"
+)
+
+// LockfileSignatureNamespace is the namespace of lockfile signatures, for
+// "ssh-keygen -Y sign -n" and "ssh-keygen -Y verify -n".
+const LockfileSignatureNamespace = "jiri-lockfile"
+
+// LockfileSignaturePath returns the path of the signature of lockfile.
+func LockfileSignaturePath(lockfile string) string {
+	return lockfile + ".sig"
+}
+
+// trustedLockfileKeys returns the keys which lockfiles must be signed with,
+// or nil if lockfile signatures are not enforced.
+func trustedLockfileKeys(jirix *jiri.X) ([]sshsig.PublicKey, error) {
+	var keys []sshsig.PublicKey
+	for _, line := range jirix.TrustedLockfileKeys {
+		key, err := sshsig.ParsePublicKey(line)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted lockfile key in jiri config: %v", err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// verifyLockfile checks that sig is a signature of the lockfile data by one
+// of the trusted keys of jirix. name is used in errors.
+func verifyLockfile(jirix *jiri.X, name string, data, sig []byte) error {
+	keys, err := trustedLockfileKeys(jirix)
+	if err != nil || len(keys) == 0 {
+		return err
+	}
+	if sig == nil {
+		return fmt.Errorf("lockfile %s is not signed, and jiri requires signed lockfiles. Run 'jiri resolve -sign' to sign it", name)
+	}
+	key, err := sshsig.Verify(sig, LockfileSignatureNamespace, data, keys)
+	if err != nil {
+		return fmt.Errorf("bad signature for lockfile %s: %v", name, err)
+	}
+	jirix.Logger.Debugf("lockfile %s is signed with %s", name, key)
+	return nil
+}
+
+// SignLockfile signs lockfile with the private key in keyFile, and writes the
+// signature next to it. The key is either an OpenSSH private key, which is
+// signed with "ssh-keygen -Y sign", or the base64 encoding of an
+// ed25519 seed or private key.
+func SignLockfile(jirix *jiri.X, lockfile, keyFile string) error {
+	keyData, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return fmt.Errorf("cannot read signing key: %v", err)
+	}
+	sigFile := LockfileSignaturePath(lockfile)
+	if bytes.Contains(keyData, []byte("PRIVATE KEY-----")) {
+		// ssh-keygen does not overwrite existing signatures.
+		if err := os.Remove(sigFile); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		var stderr bytes.Buffer
+		command := exec.Command("ssh-keygen", "-Y", "sign", "-f", keyFile, "-n", LockfileSignatureNamespace, lockfile)
+		command.Env = envvar.MapToSlice(jirix.Env())
+		command.Stdin = jirix.Stdin()
+		command.Stderr = &stderr
+		if err := command.Run(); err != nil {
+			return fmt.Errorf("failed to sign %q with ssh-keygen: %v: %s", lockfile, err, stderr.String())
+		}
+		return nil
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(keyData)))
+	if err != nil {
+		return fmt.Errorf("invalid signing key %q: %v", keyFile, err)
+	}
+	var key ed25519.PrivateKey
+	switch len(raw) {
+	case ed25519.SeedSize:
+		key = ed25519.NewKeyFromSeed(raw)
+	case ed25519.PrivateKeySize:
+		key = ed25519.PrivateKey(raw)
+	default:
+		return fmt.Errorf("invalid signing key %q: it should be an ed25519 seed or private key", keyFile)
+	}
+	data, err := ioutil.ReadFile(lockfile)
+	if err != nil {
+		return err
+	}
+	if err := safeWriteFile(jirix, sigFile, sshsig.Sign(key, LockfileSignatureNamespace, data)); err != nil {
+		return err
+	}
+	jirix.Logger.Infof("Signed %s with %s", lockfile, sshsig.PublicKey(key.Public().(ed25519.PublicKey)))
+	return nil
+}
@@ -0,0 +1,91 @@
+// Copyright 2026 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package project_test
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.fuchsia.dev/jiri/jiritest/xtest"
+	"go.fuchsia.dev/jiri/project"
+	"go.fuchsia.dev/jiri/sshsig"
+)
+
+// TestSignedLockfiles tests that lockfiles must be signed by a trusted key
+// when the jiri root has trusted keys.
+func TestSignedLockfiles(t *testing.T) {
+	jirix, cleanup := xtest.NewX(t)
+	defer cleanup()
+	jirix.LockfileEnabled = true
+	jirix.LockfileName = "jiri.lock"
+
+	pkg := project.Package{Name: "tool", Version: "version:1", Path: "tool"}
+	m := project.Manifest{Packages: []project.Package{pkg}}
+	if err := m.ToFile(jirix, jirix.JiriManifestFile()); err != nil {
+		t.Fatal(err)
+	}
+	lockfile := filepath.Join(jirix.Root, "jiri.lock")
+	lock := project.PackageLock{PackageName: "tool", VersionTag: "version:1", InstanceID: "id-1", LocalPath: "tool"}
+	writeLocks := func(locks ...project.PackageLock) {
+		t.Helper()
+		pkgLocks := make(project.PackageLocks)
+		for _, l := range locks {
+			pkgLocks[l.Key()] = l
+		}
+		data, err := project.MarshalLockEntries(nil, pkgLocks)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(lockfile, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	load := func() error {
+		_, _, _, err := project.LoadManifestFile(jirix, jirix.JiriManifestFile(), project.Projects{}, true)
+		return err
+	}
+	writeLocks(lock)
+
+	seed := bytes.Repeat([]byte{7}, ed25519.SeedSize)
+	keyFile := filepath.Join(jirix.Root, "key")
+	if err := ioutil.WriteFile(keyFile, []byte(base64.StdEncoding.EncodeToString(seed)), 0600); err != nil {
+		t.Fatal(err)
+	}
+	pub := sshsig.PublicKey(ed25519.NewKeyFromSeed(seed).Public().(ed25519.PublicKey))
+
+	// Signatures are not checked without trusted keys.
+	if err := load(); err != nil {
+		t.Fatal(err)
+	}
+
+	jirix.TrustedLockfileKeys = []string{pub.String()}
+	if err := load(); err == nil || !strings.Contains(err.Error(), "not signed") {
+		t.Errorf("expected an error for an unsigned lockfile, got %v", err)
+	}
+
+	if err := project.SignLockfile(jirix, lockfile, keyFile); err != nil {
+		t.Fatal(err)
+	}
+	if err := load(); err != nil {
+		t.Errorf("unexpected error for a signed lockfile: %v", err)
+	}
+
+	// Repointing the instance id breaks the signature.
+	lock.InstanceID = "id-2"
+	writeLocks(lock)
+	if err := load(); err == nil || !strings.Contains(err.Error(), "bad signature") {
+		t.Errorf("expected an error for a tampered lockfile, got %v", err)
+	}
+
+	jirix.IgnoreLockSignatures = true
+	if err := load(); err != nil {
+		t.Errorf("unexpected error when ignoring signatures: %v", err)
+	}
+}
@@ -45,6 +45,9 @@ type Manifest struct {
 	Hooks            []Hook        `xml:"hooks>hook"`
 	Packages         []Package     `xml:"packages>package"`
 	XMLName          struct{}      `xml:"manifest"`
+
+	// Metadata is the metadata of snapshots, see SnapshotMetadata.
+	Metadata *SnapshotMetadata `xml:"metadata"`
 }
 
 // ManifestFromBytes returns a manifest parsed from data, with defaults filled
@@ -120,6 +123,9 @@ func (m *Manifest) deepCopy() *Manifest {
 	x.Packages = append([]Package(nil), m.Packages...)
 	x.Version = m.Version
 	x.Attributes = m.Attributes
+	if m.Metadata != nil {
+		x.Metadata = m.Metadata.deepCopy()
+	}
 	return x
 }
 
@@ -261,8 +267,12 @@ type Import struct {
 	// RemoteBranch is the name of the remote branch to track.
 	RemoteBranch string `xml:"remotebranch,attr,omitempty"`
 	// Root path, prepended to all project paths specified in the manifest file.
-	Root    string   `xml:"root,attr,omitempty"`
-	XMLName struct{} `xml:"import"`
+	Root string `xml:"root,attr,omitempty"`
+	// Attributes is a list of attributes or an attribute expression. The
+	// import is only loaded, and its manifest repository only cloned, when
+	// they match the attributes to fetch.
+	Attributes string   `xml:"attributes,attr,omitempty"`
+	XMLName    struct{} `xml:"import"`
 }
 
 func (i *Import) fillDefaults() error {
@@ -360,8 +370,11 @@ func (i *Import) update(o *Import) {
 // LocalImport represents a local manifest import.
 type LocalImport struct {
 	// Manifest file to import from.
-	File    string   `xml:"file,attr,omitempty"`
-	XMLName struct{} `xml:"localimport"`
+	File string `xml:"file,attr,omitempty"`
+	// Attributes is a list of attributes or an attribute expression. The
+	// manifest is only loaded when they match the attributes to fetch.
+	Attributes string   `xml:"attributes,attr,omitempty"`
+	XMLName    struct{} `xml:"localimport"`
 }
 
 func (i *LocalImport) validate() error {
@@ -422,11 +435,19 @@ func WriteLocalConfig(jirix *jiri.X, project Project, lc LocalConfig) error {
 
 // Hook represents a hook to run
 type Hook struct {
-	Name        string   `xml:"name,attr"`
-	Action      string   `xml:"action,attr"`
-	ProjectName string   `xml:"project,attr"`
-	XMLName     struct{} `xml:"hook"`
-	ActionPath  string   `xml:"-"`
+	Name        string `xml:"name,attr"`
+	Action      string `xml:"action,attr"`
+	ProjectName string `xml:"project,attr"`
+	// Attributes is a list of attributes or an attribute expression. The
+	// hook only runs when they match the attributes to fetch.
+	Attributes string `xml:"attributes,attr,omitempty"`
+	// UseEnv runs the hook in the environment exported by the projects
+	// and packages of the manifest.
+	UseEnv             bool       `xml:"env,attr,omitempty"`
+	XMLName            struct{}   `xml:"hook"`
+	ActionPath         string     `xml:"-"`
+	ComputedAttributes attributes `xml:"-"`
+	env                map[string]string
 }
 
 // HookKey is a map key for a project.
@@ -496,15 +517,27 @@ type Package struct {
 	// this package is successfully fetched.
 	Flag string `xml:"flag,attr,omitempty"`
 
-	// Attributes store the the list attributes for this package.
-	// When it starts with "+", a computed default attributes will
-	// be appended.
+	// Attributes store the the list attributes for this package, or
+	// an attribute expression. When it starts with "+", a computed
+	// default attributes will be appended.
 	Attributes string `xml:"attributes,attr,omitempty"`
 
+	// Backend is the name of the backend fetching this package. The
+	// default is "cipd".
+	Backend string `xml:"backend,attr,omitempty"`
+
+	// URL is the location of the package archive for the "archive"
+	// backend. It can use the ${platform}, ${os}, ${arch} and ${version}
+	// templates.
+	URL string `xml:"url,attr,omitempty"`
+
 	// Instances store the known instance ids for this package.
 	// It is mainly used by snapshot file.
 	Instances []PackageInstance `xml:"instance"`
-	XMLName   struct{}          `xml:"package"`
+
+	// Env is the environment exported by the package.
+	Env     []EnvVar `xml:"env"`
+	XMLName struct{} `xml:"package"`
 
 	// ComputedAttributes stores computed attributes object
 	// which is easiler to perform matching and comparing.
@@ -555,33 +588,6 @@ func (pks PackageKeys) Len() int           { return len(pks) }
 func (pks PackageKeys) Less(i, j int) bool { return pks[i].Less(pks[j]) }
 func (pks PackageKeys) Swap(i, j int)      { pks[i], pks[j] = pks[j], pks[i] }
 
-// FilterACL returns a new Packages map without any inaccessible packages.
-func (p *Packages) FilterACL(jirix *jiri.X) (Packages, bool, error) {
-	// Perform ACL checks on internal projects
-	pkgACLMap := make(map[string]bool)
-	hasInternal := false
-	for _, pkg := range *p {
-		pkg.Name = strings.TrimRight(pkg.Name, "/")
-		if pkg.Internal {
-			hasInternal = true
-			pkgACLMap[pkg.Name] = false
-		}
-	}
-	if len(pkgACLMap) != 0 {
-		if err := cipd.CheckPackageACL(jirix, pkgACLMap); err != nil {
-			return nil, false, err
-		}
-	}
-	retPkgs := make(Packages)
-	for _, pkg := range *p {
-		if val, ok := pkgACLMap[pkg.Name]; ok && !val {
-			continue
-		}
-		retPkgs[pkg.Key()] = pkg
-	}
-	return retPkgs, hasInternal, nil
-}
-
 type PackageInstance struct {
 	Name    string   `xml:"name,attr"`
 	ID      string   `xml:"id,attr"`
@@ -615,12 +621,19 @@ func (p *Package) defaultPlatforms() string {
 // GetPath returns the relative path that Package p should be
 // downloaded to.
 func (p *Package) GetPath() (string, error) {
+	return p.pathFor(cipd.CipdPlatform)
+}
+
+// pathFor returns the relative path that Package p should be downloaded to
+// when it is fetched for platform plat. The path can still contain
+// templates like {{.OS}}.
+func (p *Package) pathFor(plat cipd.Platform) (string, error) {
 	if p.Path == "" {
 		cipdPath := p.Name
-		// Replace template with current platform information.
+		// Replace template with platform information.
 		// If failed, skip filling in default path.
 		if cipd.MustExpand(cipdPath) {
-			expanded, err := cipd.Expand(cipdPath, []cipd.Platform{cipd.CipdPlatform})
+			expanded, err := cipd.Expand(cipdPath, []cipd.Platform{plat})
 			if err != nil {
 				return "", err
 			}
@@ -641,6 +654,30 @@ func (p *Package) GetPath() (string, error) {
 	return p.Path, nil
 }
 
+// installPath returns the path, relative to the jiri root, which Package p
+// is installed to on the current platform.
+func (p *Package) installPath() (string, error) {
+	return p.installPathFor(cipd.CipdPlatform)
+}
+
+// installPathFor returns the path, relative to the jiri root, which Package
+// p is installed to when it is fetched for platform plat.
+func (p *Package) installPathFor(plat cipd.Platform) (string, error) {
+	subdir, err := p.pathFor(plat)
+	if err != nil {
+		return "", err
+	}
+	tmpl, err := template.New("pack").Parse(subdir)
+	if err != nil {
+		return "", fmt.Errorf("parsing package path %q failed", subdir)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, cipd.FuchsiaPlatform(plat)); err != nil {
+		return "", err
+	}
+	return filepath.Clean(buf.String()), nil
+}
+
 // GetPlatforms returns the platforms information of
 // this Package struct.
 func (p *Package) GetPlatforms() ([]cipd.Platform, error) {
@@ -710,12 +747,15 @@ func (ld *loader) enforceLocks(jirix *jiri.X) error {
 			if projectLock, ok := ld.ProjectLocks[ProjectLockKey(v.Key())]; ok {
 				if v.Revision == "" || v.Revision == "HEAD" {
 					v.Revision = projectLock.Revision
-					ld.Projects[v.Key()] = v
 				} else if v.Revision != projectLock.Revision {
 					s := fmt.Sprintf("project %+v has conflicting revisions in manifest and jiri.lock: %s:%s", v, v.Revision, projectLock.Revision)
 					jirix.Logger.Debugf(s)
 					err = errors.New(s)
+					continue
 				}
+				v.SubmoduleLocks = projectLock.Submodules
+				v.LFSObjectLocks = projectLock.LFSObjects
+				ld.Projects[v.Key()] = v
 			}
 		}
 		return
@@ -785,93 +825,101 @@ func (ld *loader) enforceLocks(jirix *jiri.X) error {
 // errors about ".git/index.lock exists", you are likely calling
 // LoadManifestFile in parallel.
 func LoadManifestFile(jirix *jiri.X, file string, localProjects Projects, localManifest bool) (Projects, Hooks, Packages, error) {
+	ld, err := loadManifestFile(jirix, file, localProjects, localManifest, false)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return ld.Projects, ld.Hooks, ld.Packages, nil
+}
+
+// loadManifestFile is like LoadManifestFile, but returns the loader. If
+// allImports is true, imports are loaded whatever their attributes.
+func loadManifestFile(jirix *jiri.X, file string, localProjects Projects, localManifest, allImports bool) (*loader, error) {
 	ld := newManifestLoader(localProjects, false, file)
+	ld.allImports = allImports
 	if err := ld.Load(jirix, "", "", file, "", "", "", localManifest); err != nil {
-		return nil, nil, nil, err
+		return nil, err
 	}
 	jirix.AddCleanupFunc(ld.cleanup)
 	if jirix.LockfileEnabled {
 		if err := ld.enforceLocks(jirix); err != nil {
-			return nil, nil, nil, err
+			return nil, err
 		}
 	}
 	if !jirix.OverrideWarned {
 		ld.warnOverrides(jirix)
 	}
 	ld.GenerateGitAttributesForProjects(jirix)
-	return ld.Projects, ld.Hooks, ld.Packages, nil
+	return ld, nil
+}
+
+// loadFetchedManifest loads the manifest of the checkout like
+// loadManifestFile, and removes the optional projects and packages which are
+// not fetched. It also returns the local projects.
+func loadFetchedManifest(jirix *jiri.X, localManifest bool) (*loader, Projects, error) {
+	localProjects, err := LocalProjects(jirix, FullScan)
+	if err != nil {
+		return nil, nil, err
+	}
+	ld, err := loadManifestFile(jirix, jirix.JiriManifestFile(), localProjects, localManifest, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := FilterOptionalProjectsPackages(jirix, jirix.FetchingAttrs, ld.Projects, ld.Packages); err != nil {
+		return nil, nil, err
+	}
+	return ld, localProjects, nil
 }
 
 // LoadUpdatedManifest loads an updated manifest starting with the .jiri_manifest file for localProjects. It will use
 // local manifest files instead of manifest files in remote repositories if localManifest is set to true.
 func LoadUpdatedManifest(jirix *jiri.X, localProjects Projects, localManifest bool) (Projects, Hooks, Packages, error) {
+	ld, err := loadUpdatedManifest(jirix, localProjects, localManifest)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return ld.Projects, ld.Hooks, ld.Packages, nil
+}
+
+// loadUpdatedManifest is like LoadUpdatedManifest, but returns the loader.
+func loadUpdatedManifest(jirix *jiri.X, localProjects Projects, localManifest bool) (*loader, error) {
 	jirix.TimerPush("load updated manifest")
 	defer jirix.TimerPop()
 	ld := newManifestLoader(localProjects, true, jirix.JiriManifestFile())
 	if err := ld.Load(jirix, "", "", jirix.JiriManifestFile(), "", "", "", localManifest); err != nil {
-		return nil, nil, nil, err
+		return nil, err
 	}
 	jirix.AddCleanupFunc(ld.cleanup)
 	if jirix.LockfileEnabled {
 		if err := ld.enforceLocks(jirix); err != nil {
-			return nil, nil, nil, err
+			return nil, err
 		}
 	}
 	if !jirix.OverrideWarned {
 		ld.warnOverrides(jirix)
 	}
 	ld.GenerateGitAttributesForProjects(jirix)
-	return ld.Projects, ld.Hooks, ld.Packages, nil
-}
-
-// resovlePackageLocks resolves instance ids using versions described in given
-// pkgs using cipd.
-func resolvePackageLocks(jirix *jiri.X, pkgs Packages) (PackageLocks, error) {
-	jirix.TimerPush("resolve instance id for cipd packages")
-	defer jirix.TimerPop()
-
-	pkgs, _, err := pkgs.FilterACL(jirix)
-	if err != nil {
-		return nil, err
-	}
-
-	ensureFilePath, err := generateEnsureFile(jirix, pkgs, false, "")
-	if err != nil {
-		return nil, err
-	}
-	defer os.Remove(ensureFilePath)
-
-	pkgInstances, err := cipd.Resolve(jirix, ensureFilePath)
-	if err != nil {
-		return nil, err
-	}
-	// TODO: Remove this boilerplate once we have a better package
-	// layout that doesn't cause import cycles
-	pkgLocks := make(PackageLocks)
-	for _, val := range pkgInstances {
-		pkgLock := PackageLock{
-			PackageName: val.PackageName,
-			VersionTag:  val.VersionTag,
-			InstanceID:  val.InstanceID,
-		}
-		pkgLocks[pkgLock.Key()] = pkgLock
-	}
-
-	return pkgLocks, nil
+	return ld, nil
 }
 
 // resolveProjectLocks resolves project revisions <project> tags in manifests
 func resolveProjectLocks(projects Projects) (ProjectLocks, error) {
 	projectLocks := make(ProjectLocks)
 	for _, v := range projects {
-		projectLock := ProjectLock{v.Remote, v.Name, v.Revision}
+		projectLock := ProjectLock{Remote: v.Remote, Name: v.Name, Revision: v.Revision}
 		projectLocks[projectLock.Key()] = projectLock
 	}
 	return projectLocks, nil
 }
 
 // CipdSnapshot generates a snapshot of the cipd ensure and version file
+// for the packages of pkgs fetched by cipd.
 func CreateCipdSnapshot(jirix *jiri.X, pkgs Packages, file string) error {
+	groups, err := pkgs.byBackend()
+	if err != nil {
+		return err
+	}
+	pkgs = groups[CipdPackageBackend]
 	ensureSnapshotFilePath := file + ".ensure"
 	versionSnapshotFilePath := file + ".version"
 	ensureFilePath, err := generateEnsureFile(jirix, pkgs, false, filepath.Base(versionSnapshotFilePath))
@@ -896,10 +944,10 @@ func CreateCipdSnapshot(jirix *jiri.X, pkgs Packages, file string) error {
 	return nil
 }
 
-// FetchPackages fetches prebuilt packages described in given pkgs using cipd.
-// Parameter fetchTimeout is in minutes.
+// FetchPackages fetches prebuilt packages described in given pkgs using their
+// backends. Parameter fetchTimeout is in minutes.
 func FetchPackages(jirix *jiri.X, pkgs Packages, fetchTimeout uint) error {
-	jirix.TimerPush("fetch cipd packages")
+	jirix.TimerPush("fetch packages")
 	defer jirix.TimerPop()
 
 	pkgsWAccess, hasInternalPkgs, err := pkgs.FilterACL(jirix)
@@ -907,22 +955,18 @@ func FetchPackages(jirix *jiri.X, pkgs Packages, fetchTimeout uint) error {
 		return err
 	}
 
-	ensureFilePath, err := generateEnsureFile(jirix, pkgsWAccess, !jirix.LockfileEnabled || jirix.UsingSnapshot, "")
+	fetched, err := withTargetPlatforms(jirix, pkgsWAccess)
 	if err != nil {
 		return err
 	}
-	defer os.Remove(ensureFilePath)
-
-	if jirix.LockfileEnabled && !jirix.UsingSnapshot {
-		versionFilePath, err := generateVersionFile(jirix, ensureFilePath, pkgsWAccess)
-		if err != nil {
+	groups, err := fetched.byBackend()
+	if err != nil {
+		return err
+	}
+	for _, name := range backendNames(groups) {
+		if err := packageBackends[name].Fetch(jirix, groups[name], fetchTimeout); err != nil {
 			return err
 		}
-		defer os.Remove(versionFilePath)
-	}
-
-	if err := cipd.Ensure(jirix, ensureFilePath, jirix.Root, fetchTimeout); err != nil {
-		return err
 	}
 
 	if hasInternalPkgs {
@@ -945,6 +989,9 @@ func FetchPackages(jirix *jiri.X, pkgs Packages, fetchTimeout uint) error {
 			jirix.Logger.Warningf("Some packages are skipped by cipd due to lack of access, you might want to run \"%s auth-login\" and try again", jirix.CIPDPath())
 		}
 	}
+	if err := recordInstalledPackages(jirix, fetched); err != nil {
+		return err
+	}
 	return writeAttributesJSON(jirix)
 }
 
@@ -1209,6 +1256,9 @@ func RunHooks(jirix *jiri.X, hooks Hooks, runHookTimeout uint) error {
 				command.Stdout = outFile
 				command.Stderr = errFile
 				env := jirix.Env()
+				if hook.env != nil {
+					env = hook.env
+				}
 				command.Env = envvar.MapToSlice(env)
 				jirix.Logger.Tracef("Run: %q", cmdLine)
 				err = command.Run()
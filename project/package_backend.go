@@ -0,0 +1,255 @@
+// Copyright 2026 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package project
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"go.fuchsia.dev/jiri"
+	"go.fuchsia.dev/jiri/cipd"
+)
+
+// PackageBackend is a source of the prebuilt packages described by <package>
+// tags. The backend of a package is selected by its "backend" attribute.
+type PackageBackend interface {
+	// FilterACL returns the packages of pkgs which the user has access
+	// to, and whether pkgs contains internal packages.
+	FilterACL(jirix *jiri.X, pkgs Packages) (Packages, bool, error)
+
+	// Resolve returns locks pinning the versions of pkgs to instances, for
+	// all of the platforms of each package.
+	Resolve(jirix *jiri.X, pkgs Packages) (PackageLocks, error)
+
+	// Fetch installs pkgs for the current platform into the jiri root.
+	// Packages with instances from a lockfile or snapshot are fetched at
+	// those instances. fetchTimeout is in minutes.
+	Fetch(jirix *jiri.X, pkgs Packages, fetchTimeout uint) error
+}
+
+// instanceRecorder is implemented by the package backends which record the
+// instances they fetched.
+type instanceRecorder interface {
+	// installedInstances returns the ids of the instances of pkgs which
+	// were fetched for the current platform, keyed by package key.
+	installedInstances(jirix *jiri.X, pkgs Packages) (map[PackageKey]string, error)
+}
+
+const (
+	// CipdPackageBackend is the name of the default backend, which fetches
+	// packages with the cipd client.
+	CipdPackageBackend = "cipd"
+	// ArchivePackageBackend is the name of the backend which fetches tar
+	// and zip archives from HTTP(S) URLs or local files.
+	ArchivePackageBackend = "archive"
+)
+
+var packageBackends = map[string]PackageBackend{
+	CipdPackageBackend:    cipdPackageBackend{},
+	ArchivePackageBackend: archivePackageBackend{},
+}
+
+// RegisterPackageBackend makes backend available to packages with the
+// attribute backend="<name>". It replaces any backend registered under the
+// same name.
+func RegisterPackageBackend(name string, backend PackageBackend) {
+	packageBackends[name] = backend
+}
+
+// BackendName returns the name of the backend of the package.
+func (p *Package) BackendName() string {
+	if p.Backend == "" {
+		return CipdPackageBackend
+	}
+	return p.Backend
+}
+
+// byBackend splits the packages by backend. The result contains all of the
+// backends, so that the packages which a backend installed before can be
+// removed.
+func (p Packages) byBackend() (map[string]Packages, error) {
+	result := make(map[string]Packages)
+	for name := range packageBackends {
+		result[name] = make(Packages)
+	}
+	for k, v := range p {
+		name := v.BackendName()
+		if _, ok := packageBackends[name]; !ok {
+			return nil, fmt.Errorf("package %q uses unknown backend %q", v.Name, name)
+		}
+		result[name][k] = v
+	}
+	return result, nil
+}
+
+// backendNames returns the names of the backends in m in a stable order. The
+// cipd backend comes first, so that other backends can take over paths which
+// cipd installed packages to before.
+func backendNames(m map[string]Packages) []string {
+	var names []string
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if (names[i] == CipdPackageBackend) != (names[j] == CipdPackageBackend) {
+			return names[i] == CipdPackageBackend
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
+
+// FilterACL returns a new Packages map without any inaccessible packages.
+func (p *Packages) FilterACL(jirix *jiri.X) (Packages, bool, error) {
+	groups, err := p.byBackend()
+	if err != nil {
+		return nil, false, err
+	}
+	retPkgs := make(Packages)
+	hasInternal := false
+	for _, name := range backendNames(groups) {
+		pkgs, internal, err := packageBackends[name].FilterACL(jirix, groups[name])
+		if err != nil {
+			return nil, false, err
+		}
+		hasInternal = hasInternal || internal
+		for k, v := range pkgs {
+			retPkgs[k] = v
+		}
+	}
+	return retPkgs, hasInternal, nil
+}
+
+// ResolvePackageLocks resolves instance ids using versions described in given
+// pkgs using their backends.
+func ResolvePackageLocks(jirix *jiri.X, pkgs Packages) (PackageLocks, error) {
+	jirix.TimerPush("resolve instance id for packages")
+	defer jirix.TimerPop()
+
+	pkgs, _, err := pkgs.FilterACL(jirix)
+	if err != nil {
+		return nil, err
+	}
+	groups, err := pkgs.byBackend()
+	if err != nil {
+		return nil, err
+	}
+	pkgLocks := make(PackageLocks)
+	for _, name := range backendNames(groups) {
+		if len(groups[name]) == 0 {
+			continue
+		}
+		locks, err := packageBackends[name].Resolve(jirix, groups[name])
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range locks {
+			pkgLocks[k] = v
+		}
+	}
+	return pkgLocks, nil
+}
+
+// cipdPackageBackend fetches packages using the cipd client.
+type cipdPackageBackend struct{}
+
+func (cipdPackageBackend) FilterACL(jirix *jiri.X, pkgs Packages) (Packages, bool, error) {
+	// Perform ACL checks on internal projects
+	pkgACLMap := make(map[string]bool)
+	hasInternal := false
+	for _, pkg := range pkgs {
+		pkg.Name = strings.TrimRight(pkg.Name, "/")
+		if pkg.Internal {
+			hasInternal = true
+			pkgACLMap[pkg.Name] = false
+		}
+	}
+	if len(pkgACLMap) != 0 {
+		if err := cipd.CheckPackageACL(jirix, pkgACLMap); err != nil {
+			return nil, false, err
+		}
+	}
+	retPkgs := make(Packages)
+	for _, pkg := range pkgs {
+		if val, ok := pkgACLMap[pkg.Name]; ok && !val {
+			continue
+		}
+		retPkgs[pkg.Key()] = pkg
+	}
+	return retPkgs, hasInternal, nil
+}
+
+func (cipdPackageBackend) Resolve(jirix *jiri.X, pkgs Packages) (PackageLocks, error) {
+	ensureFilePath, err := generateEnsureFile(jirix, pkgs, false, "")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(ensureFilePath)
+
+	pkgInstances, err := cipd.Resolve(jirix, ensureFilePath)
+	if err != nil {
+		return nil, err
+	}
+	// TODO: Remove this boilerplate once we have a better package
+	// layout that doesn't cause import cycles
+	pkgLocks := make(PackageLocks)
+	for _, val := range pkgInstances {
+		pkgLock := PackageLock{
+			PackageName: val.PackageName,
+			VersionTag:  val.VersionTag,
+			InstanceID:  val.InstanceID,
+		}
+		pkgLocks[pkgLock.Key()] = pkgLock
+	}
+	return pkgLocks, nil
+}
+
+func (cipdPackageBackend) installedInstances(jirix *jiri.X, pkgs Packages) (map[PackageKey]string, error) {
+	instances, err := cipd.InstalledInstances(jirix, jirix.Root)
+	if err != nil || instances == nil {
+		return nil, err
+	}
+	result := make(map[PackageKey]string)
+	for key, pkg := range pkgs {
+		installed, err := pkg.installedPackage()
+		if err != nil {
+			return nil, err
+		}
+		if installed == nil {
+			continue
+		}
+		if id, ok := instances[installed.key()]; ok {
+			result[key] = id
+		}
+	}
+	return result, nil
+}
+
+func (cipdPackageBackend) Fetch(jirix *jiri.X, pkgs Packages, fetchTimeout uint) error {
+	if len(pkgs) == 0 {
+		// Only run cipd to remove the packages it installed before.
+		if _, err := os.Stat(filepath.Join(jirix.Root, ".cipd")); os.IsNotExist(err) {
+			return nil
+		}
+	}
+	ensureFilePath, err := generateEnsureFile(jirix, pkgs, !jirix.LockfileEnabled || jirix.UsingSnapshot, "")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(ensureFilePath)
+
+	if jirix.LockfileEnabled && !jirix.UsingSnapshot {
+		versionFilePath, err := generateVersionFile(jirix, ensureFilePath, pkgs)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(versionFilePath)
+	}
+
+	return cipd.Ensure(jirix, ensureFilePath, jirix.Root, fetchTimeout)
+}
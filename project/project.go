@@ -10,9 +10,7 @@ import (
 	"encoding/xml"
 	"errors"
 	"fmt"
-	"io"
 	"io/ioutil"
-	"net/http"
 	"net/url"
 	"os"
 	"path"
@@ -76,8 +74,10 @@ type Project struct {
 	// Submodules indicates that the project contains git submodules (sub-projects).
 	GitSubmodules bool `xml:"gitsubmodules,attr,omitempty"`
 
-	// Attributes is a list of attributes for a project seperated by comma.
-	// The project will not be fetched by default when attributes are present.
+	// Attributes is a list of attributes for a project seperated by comma,
+	// or an attribute expression like "(sdk && !arch=arm64) || tests".
+	// The project is only fetched when its attributes match the attributes
+	// to fetch.
 	Attributes string `xml:"attributes,attr,omitempty"`
 
 	// GitAttributes is a list comma-separated attributes for a project,
@@ -89,6 +89,14 @@ type Project struct {
 	// this project is successfully fetched.
 	Flag string `xml:"flag,attr,omitempty"`
 
+	// Env is the environment exported by the project.
+	Env []EnvVar `xml:"env"`
+
+	// CopyFiles and LinkFiles copy and link files of the project to other
+	// locations of the checkout, after it is created or updated.
+	CopyFiles []CopyFile `xml:"copyfile"`
+	LinkFiles []LinkFile `xml:"linkfile"`
+
 	XMLName struct{} `xml:"project"`
 
 	// This is used to store computed key. This is useful when remote and
@@ -104,6 +112,11 @@ type Project struct {
 
 	// ManifestPath stores the absolute path of the manifest.
 	ManifestPath string `xml:"-"`
+
+	// SubmoduleLocks and LFSObjectLocks store the pins of the project lock
+	// entry, which are verified after the project is updated.
+	SubmoduleLocks []SubmoduleLock `xml:"-"`
+	LFSObjectLocks []LFSObjectLock `xml:"-"`
 }
 
 // ProjectsByPath implements the Sort interface. It sorts Projects by
@@ -199,13 +212,21 @@ func (p Project) ToFile(jirix *jiri.X, filename string) error {
 		return fmt.Errorf("project xml.Marshal failed: %v", err)
 	}
 	// Same logic as Manifest.ToBytes, to make the output more compact.
-	data = bytes.Replace(data, endProjectSoloBytes, endElemSoloBytes, -1)
+	// Projects with child elements keep their end tag.
+	if !p.hasChildElements() {
+		data = bytes.Replace(data, endProjectSoloBytes, endElemSoloBytes, -1)
+	}
 	if !bytes.HasSuffix(data, newlineBytes) {
 		data = append(data, '\n')
 	}
 	return safeWriteFile(jirix, filename, data)
 }
 
+// hasChildElements returns true if p is marshalled with child elements.
+func (p *Project) hasChildElements() bool {
+	return len(p.Env) != 0 || len(p.CopyFiles) != 0 || len(p.LinkFiles) != 0
+}
+
 // absolutizePaths makes all relative paths absolute by prepending basepath.
 func (p *Project) absolutizePaths(basepath string) {
 	if p.Path != "" && !filepath.IsAbs(p.Path) {
@@ -292,6 +313,12 @@ func (p *Project) update(other *Project) {
 	if other.Flag != "" {
 		p.Flag = other.Flag
 	}
+	if len(other.CopyFiles) != 0 {
+		p.CopyFiles = other.CopyFiles
+	}
+	if len(other.LinkFiles) != 0 {
+		p.LinkFiles = other.LinkFiles
+	}
 }
 
 // WriteProjectFlags write flag files into project directory using in "flag"
@@ -406,6 +433,11 @@ type ProjectLock struct {
 	Remote   string `json:"repository_url"`
 	Name     string `json:"name"`
 	Revision string `json:"revision"`
+
+	// Submodules and LFSObjects pin the content of projects with git
+	// submodules, see "jiri resolve -enable-submodule-lock".
+	Submodules []SubmoduleLock `json:"submodules,omitempty"`
+	LFSObjects []LFSObjectLock `json:"lfs_objects,omitempty"`
 }
 
 // ProjectLockKey defines the key used in ProjectLocks type
@@ -425,6 +457,12 @@ func (p ProjectLock) Key() ProjectLockKey {
 	return ProjectLockKey{name: p.Name, remote: p.Remote}
 }
 
+// LockEqual determines whether current ProjectLock pins the same content
+// as ProjectLock other.
+func (p ProjectLock) LockEqual(other ProjectLock) bool {
+	return reflect.DeepEqual(p, other)
+}
+
 // PackageLock describes locked version information for a jiri managed package.
 type PackageLock struct {
 	PackageName string `json:"package"`
@@ -468,6 +506,7 @@ type ResolveConfig interface {
 	EnableProjectLock() bool
 	HostnameAllowList() []string
 	FullResolve() bool
+	EnableSubmoduleLock() bool
 }
 
 // UnmarshalLockEntries unmarshals project locks and package locks from
@@ -475,33 +514,34 @@ type ResolveConfig interface {
 func UnmarshalLockEntries(jsonData []byte) (ProjectLocks, PackageLocks, error) {
 	projectLocks := make(ProjectLocks)
 	pkgLocks := make(PackageLocks)
-	var entries []map[string]string
+	var entries []json.RawMessage
 	if err := json.Unmarshal(jsonData, &entries); err != nil {
 		return nil, nil, err
 	}
-	for _, entry := range entries {
-		if pkgName, ok := entry["package"]; ok {
-			pkgLock := PackageLock{
-				PackageName: pkgName,
-				VersionTag:  entry["version"],
-				InstanceID:  entry["instance_id"],
-				LocalPath:   entry["path"],
+	for _, data := range entries {
+		var entry map[string]json.RawMessage
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return nil, nil, err
+		}
+		if _, ok := entry["package"]; ok {
+			var pkgLock PackageLock
+			if err := json.Unmarshal(data, &pkgLock); err != nil {
+				return nil, nil, err
 			}
 			if v, ok := pkgLocks[pkgLock.Key()]; ok {
 				if v != pkgLock {
-					return nil, nil, fmt.Errorf("package %q has more than 1 version lock %q, %q", pkgName, v.InstanceID, pkgLock.InstanceID)
+					return nil, nil, fmt.Errorf("package %q has more than 1 version lock %q, %q", pkgLock.PackageName, v.InstanceID, pkgLock.InstanceID)
 				}
 			}
 			pkgLocks[pkgLock.Key()] = pkgLock
-		} else if repoURL, ok := entry["repository_url"]; ok {
-			projectLock := ProjectLock{
-				Remote:   repoURL,
-				Name:     entry["name"],
-				Revision: entry["revision"],
+		} else if _, ok := entry["repository_url"]; ok {
+			var projectLock ProjectLock
+			if err := json.Unmarshal(data, &projectLock); err != nil {
+				return nil, nil, err
 			}
 			if v, ok := projectLocks[projectLock.Key()]; ok {
-				if v != projectLock {
-					return nil, nil, fmt.Errorf("package %q has more than 1 revision lock %q, %q", repoURL, v.Revision, projectLock.Revision)
+				if !v.LockEqual(projectLock) {
+					return nil, nil, fmt.Errorf("package %q has more than 1 revision lock %q, %q", projectLock.Remote, v.Revision, projectLock.Revision)
 				}
 			}
 			projectLocks[projectLock.Key()] = projectLock
@@ -738,14 +778,22 @@ func (sm ScanMode) String() string {
 // HEAD of all projects and writes this snapshot out to the given file.
 // if hooks are not passed, jiri will read JiriManifestFile and get hooks from there,
 // so always pass hooks incase updating from a snapshot
-func CreateSnapshot(jirix *jiri.X, file string, hooks Hooks, pkgs Packages, localManifest bool, submodules bool, cipdEnsure bool) error {
+// If filter is not nil, the snapshot only has the projects, hooks and packages
+// it selects.
+func CreateSnapshot(jirix *jiri.X, file string, hooks Hooks, pkgs Packages, localManifest bool, submodules bool, cipdEnsure bool, filter *SnapshotFilter) error {
+	return createSnapshot(jirix, file, nil, hooks, pkgs, localManifest, submodules, cipdEnsure, filter)
+}
+
+// createSnapshot is like CreateSnapshot, but takes the loader of the
+// manifest if it was already loaded, like by an update.
+func createSnapshot(jirix *jiri.X, file string, ld *loader, hooks Hooks, pkgs Packages, localManifest bool, submodules bool, cipdEnsure bool, filter *SnapshotFilter) error {
 	jirix.TimerPush("create snapshot")
 	defer jirix.TimerPop()
 
 	// Create a new Manifest with a Jiri version and current attributes
 	// pinned to each snapshot
 	manifest := Manifest{
-		Version:    ManifestVersion,
+		Version:    SnapshotVersion,
 		Attributes: jirix.FetchingAttrs,
 	}
 
@@ -768,22 +816,49 @@ func CreateSnapshot(jirix *jiri.X, file string, hooks Hooks, pkgs Packages, loca
 		}
 	}
 
-	for _, project := range localProjects {
-		manifest.Projects = append(manifest.Projects, project)
-	}
-
-	if hooks == nil || pkgs == nil {
-		_, tmpHooks, tmpPkgs, err := LoadManifestFile(jirix, jirix.JiriManifestFile(), localProjects, localManifest)
+	// The manifest is loaded for the hooks and packages if they are not
+	// passed, and for the metadata. The metadata of snapshots of snapshot
+	// checkouts does not have the imports of the manifest.
+	if ld == nil && (hooks == nil || pkgs == nil || !jirix.UsingSnapshot) {
+		ld, err = loadManifestFile(jirix, jirix.JiriManifestFile(), localProjects, localManifest, false)
 		if err != nil {
-			return err
+			if hooks == nil || pkgs == nil {
+				return err
+			}
+			jirix.Logger.Warningf("Cannot load manifest, the snapshot will not record its imports: %v\n\n", err)
 		}
-		if hooks == nil {
-			hooks = tmpHooks
+	}
+	if hooks == nil {
+		hooks = ld.Hooks
+	}
+	if pkgs == nil {
+		pkgs = ld.Packages
+	}
+	if ld != nil {
+		// Updates move the manifest repositories which the loader cloned in
+		// its temporary directory into the checkout.
+		for i, imp := range ld.imports {
+			if p, ok := localProjects[imp.project.Key()]; ok && imp.Remote != "" {
+				ld.imports[i].project.Path = p.Path
+			}
 		}
-		if pkgs == nil {
-			pkgs = tmpPkgs
+	}
+	if filter != nil {
+		if localProjects, hooks, pkgs, err = filter.filter(jirix, localProjects, hooks, pkgs); err != nil {
+			return err
 		}
 	}
+	for _, project := range localProjects {
+		manifest.Projects = append(manifest.Projects, project)
+	}
+	if manifest.Metadata, err = newSnapshotMetadata(jirix, ld, localProjects, localManifest); err != nil {
+		return err
+	}
+	if filter != nil {
+		manifest.Metadata.Partial = true
+		manifest.Metadata.ProjectFilter = filter.Projects
+		manifest.Metadata.AttributeFilter = filter.Attributes
+	}
 
 	if cipdEnsure {
 		// CreateCipdSnapshot adds a file suffix to 'file' so it won't conflict with
@@ -844,8 +919,9 @@ func CheckoutSnapshot(jirix *jiri.X, snapshot string, gc, runHooks, fetchPkgs bo
 	return WriteUpdateHistorySnapshot(jirix, hooks, pkgs, false)
 }
 
-// LoadSnapshotFile loads the specified snapshot manifest.  If the snapshot
-// manifest contains a remote import, an error will be returned.
+// LoadSnapshotFile loads the specified snapshot manifest, of version
+// ManifestVersion or SnapshotVersion.  If the snapshot manifest contains a
+// remote import, an error will be returned.
 func LoadSnapshotFile(jirix *jiri.X, snapshot string) (Projects, Hooks, Packages, error) {
 	// Snapshot files already have pinned Project revisions and Package instance IDs.
 	// They will cause conflicts with current lockfiles. Disable the lockfile for now.
@@ -854,41 +930,21 @@ func LoadSnapshotFile(jirix *jiri.X, snapshot string) (Projects, Hooks, Packages
 	defer func() {
 		jirix.LockfileEnabled = enableLockfile
 	}()
-	if _, err := os.Stat(snapshot); err != nil {
-		if !os.IsNotExist(err) {
-			return nil, nil, nil, fmtError(err)
-		}
-		u, err := url.ParseRequestURI(snapshot)
-		if err != nil {
-			return nil, nil, nil, fmt.Errorf("%q is neither a URL nor a valid file path", snapshot)
-		}
-		jirix.Logger.Infof("Getting snapshot from URL %q", u)
-		resp, err := http.Get(u.String())
-		if err != nil {
-			return nil, nil, nil, fmt.Errorf("Error getting snapshot from URL %q: %v", u, err)
-		}
-		defer resp.Body.Close()
-		tmpFile, err := ioutil.TempFile("", "snapshot")
-		if err != nil {
-			return nil, nil, nil, fmt.Errorf("Error creating tmp file: %v", err)
-		}
-		snapshot = tmpFile.Name()
-		defer os.Remove(snapshot)
-		if _, err = io.Copy(tmpFile, resp.Body); err != nil {
-			return nil, nil, nil, fmt.Errorf("Error writing to tmp file: %v", err)
-		}
-
+	file, cleanup, err := fetchSnapshot(jirix, snapshot)
+	if err != nil {
+		return nil, nil, nil, err
 	}
+	defer cleanup()
 
-	m, err := ManifestFromFile(jirix, snapshot)
+	m, err := ManifestFromFile(jirix, file)
 	if err != nil {
 		return nil, nil, nil, err
 	}
-	if ManifestVersion != m.Version {
+	if m.Version != ManifestVersion && m.Version != SnapshotVersion {
 		return nil, nil, nil, errVersionMismatch
 	}
 
-	return LoadManifestFile(jirix, snapshot, nil, false)
+	return LoadManifestFile(jirix, file, nil, false)
 }
 
 // CurrentProject gets the current project from the current directory by
@@ -1106,14 +1162,15 @@ func loadManifestFiles(jirix *jiri.X, manifestFiles []string, localManifest bool
 	}
 
 	for _, manifestFile := range manifestFiles {
-		remoteProjects, _, pkgs, err := LoadManifestFile(jirix, manifestFile, localProjects, localManifest)
+		// Lockfiles cover all imports, whatever the fetching attributes.
+		ld, err := loadManifestFile(jirix, manifestFile, localProjects, localManifest, true)
 		if err != nil {
 			return nil, nil, err
 		}
-		if err := addProject(remoteProjects); err != nil {
+		if err := addProject(ld.Projects); err != nil {
 			return nil, nil, err
 		}
-		if err := addPkg(pkgs); err != nil {
+		if err := addPkg(ld.Packages); err != nil {
 			return nil, nil, err
 		}
 	}
@@ -1285,6 +1342,11 @@ func GenerateJiriLockFile(jirix *jiri.X, manifestFiles []string, resolveConfig R
 			if err != nil {
 				return
 			}
+			if resolveConfig.EnableSubmoduleLock() {
+				if err = resolveSubmoduleLocks(jirix, projects, projectLocks); err != nil {
+					return
+				}
+			}
 		}
 		if resolveConfig.EnablePackageLock() {
 			var pkgsToProcess Packages
@@ -1302,6 +1364,10 @@ func GenerateJiriLockFile(jirix *jiri.X, manifestFiles []string, resolveConfig R
 				pkgsForRefCheck := make(map[cipd.PackageInstance]bool)
 				pkgsPlatformMap := make(map[cipd.PackageInstance][]cipd.Platform)
 				for _, v := range pkgsToProcess {
+					if v.BackendName() != CipdPackageBackend {
+						// Only cipd packages have floating refs.
+						continue
+					}
 					pkgInstance := cipd.PackageInstance{
 						PackageName: v.Name,
 						VersionTag:  v.Version,
@@ -1341,7 +1407,7 @@ func GenerateJiriLockFile(jirix *jiri.X, manifestFiles []string, resolveConfig R
 					delete(pkgsWithMultiVersionsMap, k)
 				}
 			}
-			pkgLocks, err = resolvePackageLocks(jirix, pkgsToProcess)
+			pkgLocks, err = ResolvePackageLocks(jirix, pkgsToProcess)
 			if err != nil {
 				return
 			}
@@ -1411,9 +1477,12 @@ func GenerateJiriLockFile(jirix *jiri.X, manifestFiles []string, resolveConfig R
 // UpdateUniverse updates all local projects and tools to match the remote
 // counterparts identified in the manifest. Optionally, the 'gc' flag can be
 // used to indicate that local projects that no longer exist remotely should be
-// removed.
+// removed. Once the update succeeded, it writes the update history snapshot.
 func UpdateUniverse(jirix *jiri.X, gc, localManifest, rebaseTracked, rebaseUntracked, rebaseAll, runHooks, fetchPkgs bool, runHookTimeout, fetchTimeout uint) (e error) {
 	jirix.Logger.Infof("Updating all projects")
+	var ld *loader
+	var hooks Hooks
+	var pkgs Packages
 	updateFn := func(scanMode ScanMode) error {
 		jirix.TimerPush(fmt.Sprintf("update universe: %s", scanMode))
 		defer jirix.TimerPop()
@@ -1424,27 +1493,35 @@ func UpdateUniverse(jirix *jiri.X, gc, localManifest, rebaseTracked, rebaseUntra
 			return err
 		}
 		// Determine the set of remote projects and match them up with the locals.
-		remoteProjects, hooks, pkgs, err := LoadUpdatedManifest(jirix, localProjects, localManifest)
-		MatchLocalWithRemote(localProjects, remoteProjects)
-
+		ld, err = loadUpdatedManifest(jirix, localProjects, localManifest)
 		if err != nil {
 			return err
 		}
+		MatchLocalWithRemote(localProjects, ld.Projects)
+
+		// The update removes the optional hooks and packages which are not
+		// fetched, the snapshot records all of them.
+		hooks, pkgs = make(Hooks), make(Packages)
+		for k, hook := range ld.Hooks {
+			hooks[k] = hook
+		}
+		for k, pkg := range ld.Packages {
+			pkgs[k] = pkg
+		}
 
 		// Actually update the projects.
-		return updateProjects(jirix, localProjects, remoteProjects, hooks, pkgs, gc, runHookTimeout, fetchTimeout, rebaseTracked, rebaseUntracked, rebaseAll, false /*snapshot*/, runHooks, fetchPkgs)
+		return updateProjects(jirix, localProjects, ld.Projects, ld.Hooks, ld.Packages, gc, runHookTimeout, fetchTimeout, rebaseTracked, rebaseUntracked, rebaseAll, false /*snapshot*/, runHooks, fetchPkgs)
 	}
 
 	// Specifying gc should always force a full filesystem scan.
 	if gc {
-		return updateFn(FullScan)
-	}
-
-	// Attempt a fast update, which uses the latest snapshot to avoid doing
-	// a filesystem scan.  Sometimes the latest snapshot can have problems, so if
-	// any errors come up, fallback to the slow path.
-	err := updateFn(FastScan)
-	if err != nil {
+		if err := updateFn(FullScan); err != nil {
+			return err
+		}
+	} else if err := updateFn(FastScan); err != nil {
+		// Attempt a fast update, which uses the latest snapshot to avoid doing
+		// a filesystem scan.  Sometimes the latest snapshot can have problems, so if
+		// any errors come up, fallback to the slow path.
 		if err2 := updateFn(FullScan); err2 != nil {
 			if err.Error() == err2.Error() {
 				return err
@@ -1453,6 +1530,9 @@ func UpdateUniverse(jirix *jiri.X, gc, localManifest, rebaseTracked, rebaseUntra
 		}
 	}
 
+	if err := writeUpdateHistorySnapshot(jirix, ld, hooks, pkgs, localManifest); err != nil {
+		return fmt.Errorf("while writing history: %s", err)
+	}
 	return nil
 }
 
@@ -1501,8 +1581,14 @@ func WriteUpdateHistoryLog(jirix *jiri.X) error {
 // WriteUpdateHistorySnapshot creates a snapshot of the current state of all
 // projects and writes it to the update history directory.
 func WriteUpdateHistorySnapshot(jirix *jiri.X, hooks Hooks, pkgs Packages, localManifest bool) error {
+	return writeUpdateHistorySnapshot(jirix, nil, hooks, pkgs, localManifest)
+}
+
+// writeUpdateHistorySnapshot is like WriteUpdateHistorySnapshot, but takes
+// the loader of the manifest if it was already loaded.
+func writeUpdateHistorySnapshot(jirix *jiri.X, ld *loader, hooks Hooks, pkgs Packages, localManifest bool) error {
 	snapshotFile := filepath.Join(jirix.UpdateHistoryDir(), time.Now().Format(time.RFC3339))
-	if err := CreateSnapshot(jirix, snapshotFile, hooks, pkgs, localManifest, false, false); err != nil {
+	if err := createSnapshot(jirix, snapshotFile, ld, hooks, pkgs, localManifest, false, false, nil); err != nil {
 		return err
 	}
 
@@ -1823,7 +1909,9 @@ func checkoutHeadRevision(jirix *jiri.X, project Project, forceCheckout bool) er
 	return err
 }
 
-func tryRebase(jirix *jiri.X, project Project, branch string) (bool, error) {
+// TryRebase rebases the current branch of project onto branch. If the rebase
+// fails, it is aborted and false is returned.
+func TryRebase(jirix *jiri.X, project Project, branch string) (bool, error) {
 	scm := gitutil.New(jirix, gitutil.RootDirOpt(project.Path))
 	if err := scm.Rebase(branch); err != nil {
 		err := scm.RebaseAbort()
@@ -1977,12 +2065,16 @@ func syncProjectMaster(jirix *jiri.X, project Project, state ProjectState, rebas
 				jirix.IncrementFailures()
 				continue
 			}
-			rebaseSuccess, err := tryRebase(jirix, project, tracking.Name)
+			rebaseSuccess, err := TryRebase(jirix, project, tracking.Name)
 			if err != nil {
 				return err
 			}
 			if rebaseSuccess {
 				jirix.Logger.Debugf("For project %q, rebased your local branch %q on %q", project.Name, branch.Name, tracking.Name)
+			} else if jirix.RebaseInteractive {
+				if err := queueRebase(jirix, project, branch.Name, tracking.Name, state.CurrentBranch.Name); err != nil {
+					return err
+				}
 			} else {
 				msg := fmt.Sprintf("For project %s(%s), not able to rebase your local branch %q onto %q", project.Name, relativePath, branch.Name, tracking.Name)
 				msg += "\nPlease do it manually\n\n"
@@ -2007,12 +2099,16 @@ func syncProjectMaster(jirix *jiri.X, project Project, state ProjectState, rebas
 					jirix.IncrementFailures()
 					continue
 				}
-				rebaseSuccess, err := tryRebase(jirix, project, headRevision)
+				rebaseSuccess, err := TryRebase(jirix, project, headRevision)
 				if err != nil {
 					return err
 				}
 				if rebaseSuccess {
 					jirix.Logger.Debugf("For project %q, rebased your untracked branch %q on %q", project.Name, branch.Name, headRevision)
+				} else if jirix.RebaseInteractive {
+					if err := queueRebase(jirix, project, branch.Name, headRevision, state.CurrentBranch.Name); err != nil {
+						return err
+					}
 				} else {
 					msg := fmt.Sprintf("For project %s(%s), not able to rebase your untracked branch %q onto JIRI_HEAD.", project.Name, relativePath, branch.Name)
 					msg += "\nPlease do it manually\n\n"
@@ -2331,33 +2427,32 @@ func fetchLocalProjects(jirix *jiri.X, localProjects, remoteProjects Projects) e
 	return nil
 }
 
-// FilterOptionalProjectsPackages removes projects and packages in place if the Optional field is true and
-// attributes in attrs does not match the Attributes field. Currently "match" means the intersection of
-// both attributes is not empty.
+// FilterOptionalProjectsPackages removes projects and packages in place if their
+// attributes do not match attrs. attrs is a comma separated list of enabled
+// attributes, and the attributes of projects and packages are either lists of
+// attributes, which match when any of them is enabled, or expressions.
 func FilterOptionalProjectsPackages(jirix *jiri.X, attrs string, projects Projects, pkgs Packages) error {
-	allowedAttrs := newAttributes(attrs)
+	env := newAttrEnv(attrs)
 
 	for k, v := range projects {
-		if !v.ComputedAttributes.IsEmpty() {
-			if v.ComputedAttributes == nil {
-				return fmt.Errorf("project %+v should have valid ComputedAttributes, but it is nil", v)
-			}
-			if !allowedAttrs.Match(v.ComputedAttributes) {
-				jirix.Logger.Debugf("project %q is filtered (%s:%s)", v.Name, v.ComputedAttributes, allowedAttrs)
-				delete(projects, k)
-			}
+		ok, reason, err := env.included(v.Attributes, v.ComputedAttributes)
+		if err != nil {
+			return fmt.Errorf("project %q: %v", v.Name, err)
+		}
+		if !ok {
+			jirix.Logger.Debugf("project %q is filtered (%s)", v.Name, reason)
+			delete(projects, k)
 		}
 	}
 
 	for k, v := range pkgs {
-		if !v.ComputedAttributes.IsEmpty() {
-			if v.ComputedAttributes == nil {
-				return fmt.Errorf("package %+v should have valid ComputedAttributes, but it is nil", v)
-			}
-			if !allowedAttrs.Match(v.ComputedAttributes) {
-				jirix.Logger.Debugf("package %q is filtered (%s:%s)", v.Name, v.ComputedAttributes, allowedAttrs)
-				delete(pkgs, k)
-			}
+		ok, reason, err := env.included(v.Attributes, v.ComputedAttributes)
+		if err != nil {
+			return fmt.Errorf("package %q: %v", v.Name, err)
+		}
+		if !ok {
+			jirix.Logger.Debugf("package %q is filtered (%s)", v.Name, reason)
+			delete(pkgs, k)
 		}
 	}
 	return nil
@@ -2382,6 +2477,9 @@ func updateProjects(jirix *jiri.X, localProjects, remoteProjects Projects, hooks
 	if err := FilterOptionalProjectsPackages(jirix, jirix.FetchingAttrs, remoteProjects, pkgs); err != nil {
 		return err
 	}
+	if err := FilterOptionalHooks(jirix, jirix.FetchingAttrs, hooks); err != nil {
+		return err
+	}
 
 	if err := updateCache(jirix, remoteProjects); err != nil {
 		return err
@@ -2442,6 +2540,22 @@ func updateProjects(jirix *jiri.X, localProjects, remoteProjects Projects, hooks
 	if err := runCommonOperations(jirix, nullOperations, log.TraceLevel); err != nil {
 		return err
 	}
+	if err := updateProjectFiles(jirix, localProjects, remoteProjects); err != nil {
+		return err
+	}
+
+	var multiErr MultiError
+	for _, project := range remoteProjects {
+		if project.LocalConfig.Ignore || project.LocalConfig.NoUpdate || !project.GitSubmodules {
+			continue
+		}
+		if err := verifySubmoduleLocks(jirix, project); err != nil {
+			multiErr = append(multiErr, err)
+		}
+	}
+	if len(multiErr) != 0 {
+		return multiErr
+	}
 
 	jirix.TimerPush("jiri revision files")
 	var wg sync.WaitGroup
@@ -2502,10 +2616,16 @@ func updateProjects(jirix *jiri.X, localProjects, remoteProjects Projects, hooks
 				return err
 			}
 		}
+		if err := gcPackages(jirix, pkgs, gc); err != nil {
+			return err
+		}
 	}
 
 	if shouldRunHooks {
 		hookRun = true
+		if err := SetHooksEnv(jirix, hooks, remoteProjects, pkgs); err != nil {
+			return err
+		}
 		if err := RunHooks(jirix, hooks, runHookTimeout); err != nil {
 			return err
 		}
@@ -2599,7 +2719,7 @@ func writeMetadata(jirix *jiri.X, project Project, dir string) (e error) {
 
 func writeAttributesJSON(jirix *jiri.X) error {
 	attrs := make([]string, 0)
-	for k := range newAttributes(jirix.FetchingAttrs) {
+	for k := range enabledAttributes(jirix.FetchingAttrs) {
 		attrs = append(attrs, k)
 	}
 	jsonData, err := json.MarshalIndent(&attrs, "", "    ")
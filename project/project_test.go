@@ -2295,7 +2295,18 @@ func TestProjectToFromFile(t *testing.T) {
 func TestMarshalAndUnmarshalLockEntries(t *testing.T) {
 	t.Parallel()
 
-	projectLock0 := project.ProjectLock{"https://dart.googlesource.com/web_socket_channel.git", "dart", "1.0.9"}
+	projectLock0 := project.ProjectLock{
+		Remote:   "https://dart.googlesource.com/web_socket_channel.git",
+		Name:     "dart",
+		Revision: "1.0.9",
+	}
+	projectLock1 := project.ProjectLock{
+		Remote:     "https://fuchsia.googlesource.com/third_party/submodules",
+		Name:       "submodules",
+		Revision:   "8f6a8cde94ae5ec1cd7c2dd7ec5c4a9e59e0ec36",
+		Submodules: []project.SubmoduleLock{{Path: "third_party/a", Revision: "2d6f2d4b3b2c1f1f9f0c2f2e1d1c0b0a09080706"}},
+		LFSObjects: []project.LFSObjectLock{{Path: "data/blob.bin", OID: "sha256:4d7a214614ab2935c943f9e0ff69d22eadbb8f32b1258daaa5e2ca24d17e2393", Size: 12345}},
+	}
 	pkgLock0 := project.PackageLock{
 		PackageName: "fuchsia/go/mac-amd64",
 		VersionTag:  "git_revision:b8bd7d94a2ae6c80ab8b6ed5900d3eeba8a777c3",
@@ -2304,6 +2315,7 @@ func TestMarshalAndUnmarshalLockEntries(t *testing.T) {
 
 	testProjectLocks0 := project.ProjectLocks{
 		projectLock0.Key(): projectLock0,
+		projectLock1.Key(): projectLock1,
 	}
 	testPkgLocks0 := project.PackageLocks{
 		pkgLock0.Key(): pkgLock0,
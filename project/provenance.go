@@ -0,0 +1,333 @@
+// Copyright 2026 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package project
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"go.fuchsia.dev/jiri"
+	"go.fuchsia.dev/jiri/cipd"
+	"go.fuchsia.dev/jiri/gitutil"
+	"go.fuchsia.dev/jiri/version"
+)
+
+const (
+	// InTotoStatementType is the type of in-toto statements.
+	InTotoStatementType = "https://in-toto.io/Statement/v0.1"
+	// SLSAProvenancePredicateType is the predicate type of SLSA provenance.
+	SLSAProvenancePredicateType = "https://slsa.dev/provenance/v0.2"
+	// ProvenanceBuildType is the build type of the provenance generated by
+	// jiri.
+	ProvenanceBuildType = "https://fuchsia.googlesource.com/jiri/provenance/v1"
+)
+
+// DigestSet maps digest algorithms to digests in hex.
+type DigestSet map[string]string
+
+// ProvenanceSubject is an artifact described by a provenance statement.
+type ProvenanceSubject struct {
+	Name   string    `json:"name"`
+	Digest DigestSet `json:"digest"`
+}
+
+// ProvenanceMaterial is a source of the artifacts, a project at a commit or a
+// package instance.
+type ProvenanceMaterial struct {
+	URI    string    `json:"uri"`
+	Digest DigestSet `json:"digest,omitempty"`
+}
+
+// ManifestImport is the import of a manifest by another, as loaded by jiri.
+type ManifestImport struct {
+	Manifest string `json:"manifest" xml:"manifest,attr"`
+	// Remote is the repository of the imported manifest. It is empty for
+	// local imports.
+	Remote string `json:"remote,omitempty" xml:"remote,attr,omitempty"`
+	// Revision is the commit of the manifest repository which was loaded.
+	Revision string `json:"revision,omitempty" xml:"revision,attr,omitempty"`
+	// ImportedBy is the manifest with the import.
+	ImportedBy string `json:"importedBy" xml:"importedby,attr"`
+
+	// project is the manifest repository.
+	project Project
+}
+
+// ProvenanceBuildConfig records how jiri assembled the sources.
+type ProvenanceBuildConfig struct {
+	JiriVersion string           `json:"jiriVersion"`
+	Manifest    string           `json:"manifest"`
+	Attributes  string           `json:"attributes,omitempty"`
+	Imports     []ManifestImport `json:"imports,omitempty"`
+}
+
+// ProvenanceConfigSource is the manifest the sources were assembled from.
+type ProvenanceConfigSource struct {
+	URI        string    `json:"uri,omitempty"`
+	Digest     DigestSet `json:"digest,omitempty"`
+	EntryPoint string    `json:"entryPoint,omitempty"`
+}
+
+// ProvenanceCompleteness tells which parts of the provenance are complete.
+type ProvenanceCompleteness struct {
+	Parameters  bool `json:"parameters"`
+	Environment bool `json:"environment"`
+	Materials   bool `json:"materials"`
+}
+
+// ProvenancePredicate is a SLSA provenance predicate.
+type ProvenancePredicate struct {
+	Builder struct {
+		ID string `json:"id"`
+	} `json:"builder"`
+	BuildType  string `json:"buildType"`
+	Invocation struct {
+		ConfigSource ProvenanceConfigSource `json:"configSource"`
+	} `json:"invocation"`
+	BuildConfig ProvenanceBuildConfig `json:"buildConfig"`
+	Metadata    struct {
+		Completeness ProvenanceCompleteness `json:"completeness"`
+		Reproducible bool                   `json:"reproducible"`
+	} `json:"metadata"`
+	Materials []ProvenanceMaterial `json:"materials"`
+}
+
+// Provenance is an in-toto statement with a SLSA provenance predicate.
+type Provenance struct {
+	Type          string              `json:"_type"`
+	Subject       []ProvenanceSubject `json:"subject"`
+	PredicateType string              `json:"predicateType"`
+	Predicate     ProvenancePredicate `json:"predicate"`
+}
+
+// ProvenanceConfig provides the configuration for "jiri provenance".
+type ProvenanceConfig interface {
+	// Snapshot is the snapshot to describe instead of the checkout.
+	Snapshot() string
+	LocalManifest() bool
+	BuilderID() string
+	// Artifacts are the files which are the subjects of the provenance.
+	Artifacts() []string
+}
+
+// NewProvenance returns the provenance of the projects and packages of the
+// checkout, or of a snapshot. Every project is a material with its git
+// commit, and every package instance is a material with its instance id. The
+// materials are marked as complete unless some of them have no digest.
+func NewProvenance(jirix *jiri.X, config ProvenanceConfig) (*Provenance, error) {
+	jirix.TimerPush("create provenance")
+	defer jirix.TimerPop()
+
+	prov := &Provenance{
+		Type:          InTotoStatementType,
+		Subject:       []ProvenanceSubject{},
+		PredicateType: SLSAProvenancePredicateType,
+	}
+	pred := &prov.Predicate
+	pred.Builder.ID = config.BuilderID()
+	pred.BuildType = ProvenanceBuildType
+	pred.BuildConfig.JiriVersion = version.FormattedVersion()
+
+	var projects Projects
+	var pkgs Packages
+	if snapshot := config.Snapshot(); snapshot != "" {
+		var err error
+		if projects, _, pkgs, err = LoadSnapshotFile(jirix, snapshot); err != nil {
+			return nil, err
+		}
+		digest, err := fileDigest(snapshot)
+		if err != nil {
+			return nil, err
+		}
+		pred.BuildConfig.Manifest = snapshot
+		pred.Invocation.ConfigSource = ProvenanceConfigSource{
+			URI:        "file:" + filepath.ToSlash(snapshot),
+			Digest:     digest,
+			EntryPoint: filepath.Base(snapshot),
+		}
+	} else {
+		ld, localProjects, err := loadFetchedManifest(jirix, config.LocalManifest())
+		if err != nil {
+			return nil, err
+		}
+		// The projects are recorded at the revision they are checked out
+		// at.
+		projects = make(Projects)
+		for k, p := range ld.Projects {
+			if local, ok := localProjects[k]; ok {
+				p.Revision = local.Revision
+			} else {
+				jirix.Logger.Warningf("project %q is not checked out, it is recorded at its manifest revision\n\n", p.Name)
+			}
+			projects[k] = p
+		}
+		pkgs = ld.Packages
+		pred.BuildConfig.Manifest = filepath.Base(jirix.JiriManifestFile())
+		if pred.BuildConfig.Imports, err = resolveManifestImports(jirix, ld.imports, config.LocalManifest()); err != nil {
+			return nil, err
+		}
+		for _, imp := range pred.BuildConfig.Imports {
+			if imp.Remote != "" {
+				// The first remote import is the root manifest.
+				pred.Invocation.ConfigSource = ProvenanceConfigSource{
+					URI:        "git+" + rewriteRemote(jirix, imp.Remote),
+					Digest:     DigestSet{"sha1": imp.Revision},
+					EntryPoint: imp.Manifest,
+				}
+				break
+			}
+		}
+	}
+
+	// Snapshots set the attributes they were created with.
+	pred.BuildConfig.Attributes = jirix.FetchingAttrs
+
+	for _, artifact := range config.Artifacts() {
+		digest, err := fileDigest(artifact)
+		if err != nil {
+			return nil, err
+		}
+		prov.Subject = append(prov.Subject, ProvenanceSubject{Name: filepath.ToSlash(artifact), Digest: digest})
+	}
+
+	projectKeys := make(ProjectKeys, 0, len(projects))
+	for k := range projects {
+		projectKeys = append(projectKeys, k)
+	}
+	sort.Sort(projectKeys)
+	for _, k := range projectKeys {
+		p := projects[k]
+		material := ProvenanceMaterial{URI: "git+" + rewriteRemote(jirix, p.Remote)}
+		if p.Revision != "" && p.Revision != "HEAD" {
+			material.Digest = DigestSet{"sha1": p.Revision}
+		}
+		pred.Materials = append(pred.Materials, material)
+	}
+	pkgMaterials, err := packageMaterials(jirix, pkgs)
+	if err != nil {
+		return nil, err
+	}
+	pred.Materials = append(pred.Materials, pkgMaterials...)
+	// The materials are only complete if all of them are pinned.
+	pred.Metadata.Completeness.Materials = true
+	for _, material := range pred.Materials {
+		if len(material.Digest) == 0 {
+			pred.Metadata.Completeness.Materials = false
+			break
+		}
+	}
+	return prov, nil
+}
+
+// resolveManifestImports sets the revisions of the manifest repositories
+// which were loaded, JIRI_HEAD unless localManifest is set.
+func resolveManifestImports(jirix *jiri.X, imports []ManifestImport, localManifest bool) ([]ManifestImport, error) {
+	ref := "JIRI_HEAD"
+	if localManifest {
+		ref = "HEAD"
+	}
+	for i, imp := range imports {
+		if imp.Remote == "" || imp.project.Path == "" {
+			continue
+		}
+		rev, err := gitutil.New(jirix, gitutil.RootDirOpt(imp.project.Path)).CurrentRevisionForRef(ref)
+		if err != nil {
+			return nil, fmt.Errorf("cannot get revision of manifest repository %q: %v", imp.Remote, err)
+		}
+		imports[i].Revision = rev
+	}
+	return imports, nil
+}
+
+// packageMaterials returns the materials of the instances of pkgs. Packages
+// without known instances are recorded without digest.
+func packageMaterials(jirix *jiri.X, pkgs Packages) ([]ProvenanceMaterial, error) {
+	var materials []ProvenanceMaterial
+	pkgKeys := make(PackageKeys, 0, len(pkgs))
+	for k := range pkgs {
+		pkgKeys = append(pkgKeys, k)
+	}
+	sort.Sort(pkgKeys)
+	for _, k := range pkgKeys {
+		pkg := pkgs[k]
+		if len(pkg.Instances) == 0 {
+			jirix.Logger.Warningf("package %q has no pinned instance, it is recorded without digest\n\n", pkg.Name)
+			materials = append(materials, ProvenanceMaterial{URI: cipd.PackageURL(pkg.Name, pkg.Version)})
+			continue
+		}
+		urls := make(map[string]string)
+		if pkg.BackendName() == ArchivePackageBackend {
+			plats, err := pkg.archivePlatforms()
+			if err != nil {
+				return nil, err
+			}
+			for _, plat := range plats {
+				name, err := plat.Expander().Expand(pkg.Name)
+				if err != nil {
+					continue
+				}
+				if urls[name], err = pkg.archiveURL(plat); err != nil {
+					return nil, err
+				}
+			}
+		}
+		instances := append([]PackageInstance(nil), pkg.Instances...)
+		sort.Slice(instances, func(i, j int) bool { return instances[i].Name < instances[j].Name })
+		for _, ins := range instances {
+			uri, ok := urls[ins.Name]
+			if !ok {
+				uri = cipd.PackageURL(ins.Name, pkg.Version)
+			}
+			materials = append(materials, ProvenanceMaterial{URI: uri, Digest: instanceDigest(ins.ID)})
+		}
+	}
+	return materials, nil
+}
+
+// instanceDigest returns the digest of a package instance id. Besides the
+// instance id itself, the digest is decoded from cipd instance ids, which
+// are the base64 encoding of the digest followed by its hash algorithm, or
+// legacy sha1 digests, and from archive instance ids.
+func instanceDigest(id string) DigestSet {
+	digest := DigestSet{"cipdInstanceId": id}
+	if strings.HasPrefix(id, archiveInstancePrefix) {
+		digest = DigestSet{"sha256": strings.TrimPrefix(id, archiveInstancePrefix)}
+	} else if _, err := hex.DecodeString(id); err == nil && len(id) == 40 {
+		digest["sha1"] = id
+	} else if raw, err := base64.RawURLEncoding.DecodeString(id); err == nil && len(raw) == sha256.Size+1 && raw[sha256.Size] == 2 {
+		digest["sha256"] = hex.EncodeToString(raw[:sha256.Size])
+	}
+	return digest
+}
+
+func fileDigest(path string) (DigestSet, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmtError(err)
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, fmtError(err)
+	}
+	return DigestSet{"sha256": hex.EncodeToString(h.Sum(nil))}, nil
+}
+
+// ToFile writes the provenance to filename as json.
+func (p *Provenance) ToFile(jirix *jiri.X, filename string) error {
+	out, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize JSON output: %s", err)
+	}
+	return safeWriteFile(jirix, filename, append(out, '\n'))
+}
@@ -0,0 +1,243 @@
+// Copyright 2026 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package project
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"go.fuchsia.dev/jiri"
+	"go.fuchsia.dev/jiri/gitutil"
+)
+
+// RebaseStep is a rebase of a local branch which failed during "jiri update
+// -rebase-interactive" and has to be resolved by the user.
+type RebaseStep struct {
+	// Project is the key of the project.
+	Project string `json:"project"`
+	// Path is the path of the project, used in messages.
+	Path   string `json:"path"`
+	Branch string `json:"branch"`
+	Onto   string `json:"onto"`
+	// Restore is the branch to check out once the step is done. If it is
+	// empty, JIRI_HEAD is checked out.
+	Restore string `json:"restore,omitempty"`
+}
+
+// RebaseState is the state of an interactive rebase. It is stored in
+// .jiri_root/rebase_state.json.
+type RebaseState struct {
+	// Current is the step whose rebase is in progress, waiting for the
+	// user to resolve conflicts.
+	Current *RebaseStep  `json:"current,omitempty"`
+	Pending []RebaseStep `json:"pending,omitempty"`
+}
+
+// rebaseStateMu guards the rebase state file while projects are updated
+// concurrently.
+var rebaseStateMu sync.Mutex
+
+func rebaseStateFile(jirix *jiri.X) string {
+	return filepath.Join(jirix.RootMetaDir(), jiri.RebaseStateJSON)
+}
+
+// ReadRebaseState reads the state of the interactive rebase. It returns an
+// empty state if there is no interactive rebase in progress.
+func ReadRebaseState(jirix *jiri.X) (*RebaseState, error) {
+	state := &RebaseState{}
+	data, err := ioutil.ReadFile(rebaseStateFile(jirix))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return state, nil
+		}
+		return nil, fmtError(err)
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("invalid rebase state file %q: %v", rebaseStateFile(jirix), err)
+	}
+	return state, nil
+}
+
+// InProgress returns true if there are rebases left to resolve.
+func (s *RebaseState) InProgress() bool {
+	return s.Current != nil || len(s.Pending) != 0
+}
+
+// Write writes the state of the interactive rebase, removing the state file
+// once no rebase is left.
+func (s *RebaseState) Write(jirix *jiri.X) error {
+	if !s.InProgress() {
+		if err := os.Remove(rebaseStateFile(jirix)); err != nil && !os.IsNotExist(err) {
+			return fmtError(err)
+		}
+		return nil
+	}
+	data, err := json.MarshalIndent(s, "", "    ")
+	if err != nil {
+		return err
+	}
+	return safeWriteFile(jirix, rebaseStateFile(jirix), data)
+}
+
+// queueRebase records that branch of project could not be rebased onto onto,
+// so that the rebase is resolved interactively after the update.
+func queueRebase(jirix *jiri.X, project Project, branch, onto, restore string) error {
+	rebaseStateMu.Lock()
+	defer rebaseStateMu.Unlock()
+	state, err := ReadRebaseState(jirix)
+	if err != nil {
+		return err
+	}
+	state.Pending = append(state.Pending, RebaseStep{
+		Project: project.Key().String(),
+		Path:    project.Path,
+		Branch:  branch,
+		Onto:    onto,
+		Restore: restore,
+	})
+	jirix.Logger.Infof("For project %s(%s), rebasing your local branch %q onto %q has conflicts, it will be rebased interactively after the update\n\n", project.Name, project.Path, branch, onto)
+	return state.Write(jirix)
+}
+
+func (step *RebaseStep) description() string {
+	return fmt.Sprintf("branch %q of project %s onto %q", step.Branch, step.Path, step.Onto)
+}
+
+// finish checks out the branch the project was on before the step.
+func (step *RebaseStep) finish(jirix *jiri.X) error {
+	scm := gitutil.New(jirix, gitutil.RootDirOpt(step.Path))
+	if step.Restore == "" {
+		return scm.CheckoutBranch("JIRI_HEAD", false, gitutil.DetachOpt(true))
+	}
+	if step.Restore != step.Branch {
+		return scm.CheckoutBranch(step.Restore, false)
+	}
+	return nil
+}
+
+// RunPendingRebases runs the pending rebases of the interactive rebase one
+// after the other. It stops at the first rebase with conflicts, leaving it in
+// progress for the user to resolve, and returns an error explaining how to
+// go on.
+func RunPendingRebases(jirix *jiri.X) error {
+	state, err := ReadRebaseState(jirix)
+	if err != nil {
+		return err
+	}
+	if state.Current != nil {
+		return fmt.Errorf("rebase of %s is still in progress", state.Current.description())
+	}
+	for len(state.Pending) != 0 {
+		step := state.Pending[0]
+		state.Pending = state.Pending[1:]
+		scm := gitutil.New(jirix, gitutil.RootDirOpt(step.Path))
+		if err := scm.CheckoutBranch(step.Branch, false); err != nil {
+			jirix.Logger.Errorf("Cannot rebase %s: %s\n\n", step.description(), err)
+			jirix.IncrementFailures()
+			continue
+		}
+		if err := scm.Rebase(step.Onto); err != nil {
+			state.Current = &step
+			if err := state.Write(jirix); err != nil {
+				return err
+			}
+			return fmt.Errorf("Rebasing %s stopped with conflicts.\n"+
+				"Resolve the conflicts in %s and stage them, then run \"jiri rebase -continue\".\n"+
+				"Run \"jiri rebase -skip\" to leave this branch as it was, or \"jiri rebase -abort\" to stop rebasing.",
+				step.description(), step.Path)
+		}
+		jirix.Logger.Infof("Rebased %s\n", step.description())
+		if err := step.finish(jirix); err != nil {
+			return err
+		}
+	}
+	return state.Write(jirix)
+}
+
+// ContinueRebase continues the rebase in progress once the user has resolved
+// the conflicts, and then runs the pending rebases.
+func ContinueRebase(jirix *jiri.X) error {
+	state, err := ReadRebaseState(jirix)
+	if err != nil {
+		return err
+	}
+	if state.Current == nil {
+		return fmt.Errorf("no interactive rebase is in progress")
+	}
+	scm := gitutil.New(jirix, gitutil.RootDirOpt(state.Current.Path))
+	if inProgress, err := scm.RebaseInProgress(); err != nil {
+		return err
+	} else if inProgress {
+		if err := scm.RebaseContinue(); err != nil {
+			return fmt.Errorf("cannot continue rebasing %s: %s", state.Current.description(), err)
+		}
+	}
+	jirix.Logger.Infof("Rebased %s\n", state.Current.description())
+	if err := state.Current.finish(jirix); err != nil {
+		return err
+	}
+	state.Current = nil
+	if err := state.Write(jirix); err != nil {
+		return err
+	}
+	return RunPendingRebases(jirix)
+}
+
+// SkipRebase aborts the rebase in progress, leaving the branch as it was
+// before, and then runs the pending rebases.
+func SkipRebase(jirix *jiri.X) error {
+	state, err := ReadRebaseState(jirix)
+	if err != nil {
+		return err
+	}
+	if state.Current == nil {
+		return fmt.Errorf("no interactive rebase is in progress")
+	}
+	if err := abortRebaseStep(jirix, state.Current); err != nil {
+		return err
+	}
+	jirix.Logger.Warningf("Skipped rebasing %s\n\n", state.Current.description())
+	state.Current = nil
+	if err := state.Write(jirix); err != nil {
+		return err
+	}
+	return RunPendingRebases(jirix)
+}
+
+// AbortRebase aborts the rebase in progress and drops the pending rebases.
+func AbortRebase(jirix *jiri.X) error {
+	state, err := ReadRebaseState(jirix)
+	if err != nil {
+		return err
+	}
+	if !state.InProgress() {
+		return fmt.Errorf("no interactive rebase is in progress")
+	}
+	if state.Current != nil {
+		if err := abortRebaseStep(jirix, state.Current); err != nil {
+			return err
+		}
+	}
+	for _, step := range state.Pending {
+		jirix.Logger.Warningf("Not rebasing %s\n\n", step.description())
+	}
+	return (&RebaseState{}).Write(jirix)
+}
+
+func abortRebaseStep(jirix *jiri.X, step *RebaseStep) error {
+	scm := gitutil.New(jirix, gitutil.RootDirOpt(step.Path))
+	if inProgress, err := scm.RebaseInProgress(); err != nil {
+		return err
+	} else if inProgress {
+		if err := scm.RebaseAbort(); err != nil {
+			return err
+		}
+	}
+	return step.finish(jirix)
+}
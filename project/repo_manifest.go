@@ -0,0 +1,460 @@
+// Copyright 2026 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package project
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The manifests of the repo tool, like the default.xml file of Android,
+// are converted to jiri manifests by ImportRepoManifest. See
+// https://gerrit.googlesource.com/git-repo/+/HEAD/docs/manifest-format.md.
+
+type repoRemote struct {
+	Name     string `xml:"name,attr"`
+	Fetch    string `xml:"fetch,attr"`
+	PushURL  string `xml:"pushurl,attr"`
+	Review   string `xml:"review,attr"`
+	Revision string `xml:"revision,attr"`
+}
+
+type repoDefault struct {
+	Remote     string `xml:"remote,attr"`
+	Revision   string `xml:"revision,attr"`
+	DestBranch string `xml:"dest-branch,attr"`
+	Upstream   string `xml:"upstream,attr"`
+	SyncJ      string `xml:"sync-j,attr"`
+	SyncC      string `xml:"sync-c,attr"`
+	SyncS      string `xml:"sync-s,attr"`
+	SyncTags   string `xml:"sync-tags,attr"`
+}
+
+type repoFile struct {
+	Src  string `xml:"src,attr"`
+	Dest string `xml:"dest,attr"`
+}
+
+type repoAnnotation struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+// repoProject is a <project> or an <extend-project> element.
+type repoProject struct {
+	Name        string           `xml:"name,attr"`
+	Path        string           `xml:"path,attr"`
+	Remote      string           `xml:"remote,attr"`
+	Revision    string           `xml:"revision,attr"`
+	DestBranch  string           `xml:"dest-branch,attr"`
+	Groups      string           `xml:"groups,attr"`
+	SyncC       string           `xml:"sync-c,attr"`
+	SyncS       string           `xml:"sync-s,attr"`
+	SyncTags    string           `xml:"sync-tags,attr"`
+	Upstream    string           `xml:"upstream,attr"`
+	CloneDepth  string           `xml:"clone-depth,attr"`
+	Annotations []repoAnnotation `xml:"annotation"`
+	Copyfiles   []repoFile       `xml:"copyfile"`
+	Linkfiles   []repoFile       `xml:"linkfile"`
+	Projects    []repoProject    `xml:"project"`
+}
+
+type repoRemoveProject struct {
+	Name     string `xml:"name,attr"`
+	Path     string `xml:"path,attr"`
+	Optional string `xml:"optional,attr"`
+}
+
+type repoInclude struct {
+	Name     string `xml:"name,attr"`
+	Groups   string `xml:"groups,attr"`
+	Revision string `xml:"revision,attr"`
+}
+
+// repoImporter converts a repo manifest and the manifests it includes.
+type repoImporter struct {
+	// dir is the directory of the manifest repository, which include
+	// elements are relative to.
+	dir         string
+	manifestURL string
+	remotes     map[string]repoRemote
+	def         repoDefault
+	projects    []repoProject
+	included    map[string]bool
+	unmapped    []string
+}
+
+func (ri *repoImporter) reportf(format string, args ...interface{}) {
+	ri.unmapped = append(ri.unmapped, fmt.Sprintf(format, args...))
+}
+
+// ImportRepoManifest converts the repo manifest file to a jiri manifest.
+// Relative fetch URLs of remotes are resolved against manifestURL, the URL
+// of the manifest repository. Included manifests are read from the
+// directory of file. The constructs which could not be mapped to the jiri
+// manifest are returned too.
+func ImportRepoManifest(file, manifestURL string) (*Manifest, []string, error) {
+	ri := &repoImporter{
+		dir:         filepath.Dir(file),
+		manifestURL: manifestURL,
+		remotes:     make(map[string]repoRemote),
+		included:    make(map[string]bool),
+	}
+	if err := ri.load(file, repoInclude{}); err != nil {
+		return nil, nil, err
+	}
+	m, err := ri.convert()
+	if err != nil {
+		return nil, nil, err
+	}
+	return m, ri.unmapped, nil
+}
+
+// load reads the elements of the manifest file, in order. The groups and
+// revision of include apply to the projects of the file.
+func (ri *repoImporter) load(file string, include repoInclude) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return fmtError(err)
+	}
+	defer f.Close()
+	d := xml.NewDecoder(f)
+	depth := 0
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("invalid repo manifest %s: %v", file, err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if depth == 0 {
+				if t.Name.Local != "manifest" {
+					return fmt.Errorf("invalid repo manifest %s: unexpected <%s>", file, t.Name.Local)
+				}
+				depth++
+				continue
+			}
+			if err := ri.loadElement(d, t, file, include); err != nil {
+				return fmt.Errorf("invalid repo manifest %s: %v", file, err)
+			}
+		case xml.EndElement:
+			depth--
+		}
+	}
+	return nil
+}
+
+func (ri *repoImporter) loadElement(d *xml.Decoder, start xml.StartElement, file string, include repoInclude) error {
+	switch start.Name.Local {
+	case "remote":
+		var r repoRemote
+		if err := d.DecodeElement(&r, &start); err != nil {
+			return err
+		}
+		if r.PushURL != "" {
+			ri.reportf("pushurl %q of remote %q is not mapped", r.PushURL, r.Name)
+		}
+		ri.remotes[r.Name] = r
+	case "default":
+		if err := d.DecodeElement(&ri.def, &start); err != nil {
+			return err
+		}
+		for _, attr := range []struct{ name, value string }{
+			{"dest-branch", ri.def.DestBranch},
+			{"sync-j", ri.def.SyncJ},
+			{"sync-c", ri.def.SyncC},
+			{"sync-s", ri.def.SyncS},
+			{"sync-tags", ri.def.SyncTags},
+		} {
+			if attr.value != "" {
+				ri.reportf("attribute %s of <default> is not mapped", attr.name)
+			}
+		}
+	case "project":
+		var p repoProject
+		if err := d.DecodeElement(&p, &start); err != nil {
+			return err
+		}
+		if include.Groups != "" {
+			p.Groups = strings.TrimPrefix(p.Groups+","+include.Groups, ",")
+		}
+		if p.Revision == "" {
+			p.Revision = include.Revision
+		}
+		ri.projects = append(ri.projects, p)
+	case "extend-project":
+		var ext repoProject
+		if err := d.DecodeElement(&ext, &start); err != nil {
+			return err
+		}
+		ri.extendProject(ext)
+	case "remove-project":
+		var rm repoRemoveProject
+		if err := d.DecodeElement(&rm, &start); err != nil {
+			return err
+		}
+		ri.removeProject(rm)
+	case "include":
+		var inc repoInclude
+		if err := d.DecodeElement(&inc, &start); err != nil {
+			return err
+		}
+		if include.Groups != "" {
+			inc.Groups = strings.TrimPrefix(inc.Groups+","+include.Groups, ",")
+		}
+		if inc.Revision == "" {
+			inc.Revision = include.Revision
+		}
+		path := filepath.Join(ri.dir, inc.Name)
+		if ri.included[path] {
+			return fmt.Errorf("%s is included twice", inc.Name)
+		}
+		ri.included[path] = true
+		return ri.load(path, inc)
+	default:
+		// <notice>, <manifest-server>, <repo-hooks>, <superproject> and
+		// <contactinfo> have no equivalent.
+		ri.reportf("<%s> in %s is not mapped", start.Name.Local, filepath.Base(file))
+		return d.Skip()
+	}
+	return nil
+}
+
+// matches returns true if p is the project name, at path if it is set.
+func (p *repoProject) matches(name, path string) bool {
+	return p.Name == name && (path == "" || p.path() == path)
+}
+
+func (p *repoProject) path() string {
+	if p.Path != "" {
+		return p.Path
+	}
+	return p.Name
+}
+
+func (ri *repoImporter) extendProject(ext repoProject) {
+	found := false
+	for i := range ri.projects {
+		p := &ri.projects[i]
+		if !p.matches(ext.Name, ext.Path) {
+			continue
+		}
+		found = true
+		if ext.Groups != "" {
+			p.Groups = strings.TrimPrefix(p.Groups+","+ext.Groups, ",")
+		}
+		if ext.Revision != "" {
+			p.Revision = ext.Revision
+		}
+		if ext.Remote != "" {
+			p.Remote = ext.Remote
+		}
+		if ext.Upstream != "" {
+			p.Upstream = ext.Upstream
+		}
+		if ext.DestBranch != "" {
+			p.DestBranch = ext.DestBranch
+		}
+		p.Copyfiles = append(p.Copyfiles, ext.Copyfiles...)
+		p.Linkfiles = append(p.Linkfiles, ext.Linkfiles...)
+		p.Annotations = append(p.Annotations, ext.Annotations...)
+	}
+	if !found {
+		ri.reportf("<extend-project> of %q matches no project", ext.Name)
+	}
+}
+
+func (ri *repoImporter) removeProject(rm repoRemoveProject) {
+	projects := ri.projects[:0]
+	for _, p := range ri.projects {
+		if !p.matches(rm.Name, rm.Path) {
+			projects = append(projects, p)
+		}
+	}
+	if len(projects) == len(ri.projects) && rm.Optional != "true" {
+		ri.reportf("<remove-project> of %q matches no project", rm.Name)
+	}
+	ri.projects = projects
+}
+
+var shaRE = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// repoImplicitGroups are the groups of every project, or of every project
+// fetched by default.
+var repoImplicitGroups = map[string]bool{
+	"all":     true,
+	"default": true,
+}
+
+// repoGroups returns the explicit groups of a project, and whether the
+// project is fetched by default.
+func repoGroups(groups string) ([]string, bool) {
+	var names []string
+	isDefault := true
+	for _, g := range strings.FieldsFunc(groups, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n'
+	}) {
+		switch {
+		case g == "notdefault":
+			isDefault = false
+		case repoImplicitGroups[g], strings.HasPrefix(g, "name:"), strings.HasPrefix(g, "path:"):
+		default:
+			names = append(names, g)
+		}
+	}
+	return names, isDefault
+}
+
+// resolveFetchURL resolves the fetch URL of a remote against the URL of the
+// manifest repository, like repo does.
+func (ri *repoImporter) resolveFetchURL(r repoRemote) (string, error) {
+	u, err := url.Parse(r.Fetch)
+	if err != nil {
+		return "", fmt.Errorf("invalid fetch URL %q of remote %q: %v", r.Fetch, r.Name, err)
+	}
+	if u.IsAbs() || strings.Contains(r.Fetch, "@") {
+		return r.Fetch, nil
+	}
+	if ri.manifestURL == "" {
+		return "", fmt.Errorf("remote %q has a relative fetch URL %q, the URL of the manifest repository is needed to resolve it", r.Name, r.Fetch)
+	}
+	base, err := url.Parse(ri.manifestURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid manifest URL %q: %v", ri.manifestURL, err)
+	}
+	return base.ResolveReference(u).String(), nil
+}
+
+// gerritHost returns the jiri gerrit host of a review URL of repo.
+func gerritHost(review string) string {
+	if review == "" {
+		return ""
+	}
+	if !strings.Contains(review, "://") {
+		review = "https://" + review
+	}
+	return strings.TrimSuffix(review, "/")
+}
+
+func (ri *repoImporter) convert() (*Manifest, error) {
+	m := &Manifest{}
+	names := make(map[string]bool)
+	droppedGroups := make(map[string]bool)
+	for _, rp := range ri.projects {
+		remoteName := rp.Remote
+		if remoteName == "" {
+			remoteName = ri.def.Remote
+		}
+		remote, ok := ri.remotes[remoteName]
+		if !ok {
+			return nil, fmt.Errorf("project %q has unknown remote %q", rp.Name, remoteName)
+		}
+		fetch, err := ri.resolveFetchURL(remote)
+		if err != nil {
+			return nil, err
+		}
+		p := Project{
+			Name:       rp.Name,
+			Path:       rp.path(),
+			Remote:     strings.TrimSuffix(fetch, "/") + "/" + rp.Name,
+			GerritHost: gerritHost(remote.Review),
+		}
+		if names[p.Name] {
+			// The key of jiri projects is their name and remote.
+			ri.reportf("project %q is checked out several times, the checkout at %q is named after its path", rp.Name, p.Path)
+			p.Name = p.Path
+		}
+		names[p.Name] = true
+
+		revision := rp.Revision
+		if revision == "" {
+			revision = remote.Revision
+		}
+		if revision == "" {
+			revision = ri.def.Revision
+		}
+		upstream := rp.Upstream
+		if upstream == "" {
+			upstream = ri.def.Upstream
+		}
+		switch {
+		case shaRE.MatchString(revision):
+			p.Revision = revision
+			p.RemoteBranch = strings.TrimPrefix(upstream, "refs/heads/")
+		case strings.HasPrefix(revision, "refs/tags/"):
+			p.Revision = revision
+		default:
+			p.RemoteBranch = strings.TrimPrefix(revision, "refs/heads/")
+		}
+		if rp.CloneDepth != "" {
+			depth, err := strconv.Atoi(rp.CloneDepth)
+			if err != nil {
+				return nil, fmt.Errorf("invalid clone-depth %q of project %q", rp.CloneDepth, rp.Name)
+			}
+			p.HistoryDepth = depth
+		}
+
+		// Jiri attributes select optional projects, so only the groups of
+		// the projects which are not fetched by default are mapped.
+		groups, isDefault := repoGroups(rp.Groups)
+		if isDefault {
+			for _, g := range groups {
+				droppedGroups[g] = true
+			}
+		} else if len(groups) == 0 {
+			ri.reportf("project %q is only in group notdefault, it is fetched by default", rp.Name)
+		} else if err := CheckFetchingAttributes(strings.Join(groups, ",")); err != nil {
+			ri.reportf("groups %q of project %q are not mapped: %v", rp.Groups, rp.Name, err)
+		} else {
+			p.Attributes = strings.Join(groups, ",")
+		}
+
+		for _, attr := range []struct{ name, value string }{
+			{"dest-branch", rp.DestBranch},
+			{"sync-c", rp.SyncC},
+			{"sync-s", rp.SyncS},
+			{"sync-tags", rp.SyncTags},
+		} {
+			if attr.value != "" {
+				ri.reportf("attribute %s of project %q is not mapped", attr.name, rp.Name)
+			}
+		}
+		for _, f := range rp.Copyfiles {
+			p.CopyFiles = append(p.CopyFiles, CopyFile{Src: f.Src, Dest: f.Dest})
+		}
+		for _, f := range rp.Linkfiles {
+			p.LinkFiles = append(p.LinkFiles, LinkFile{Src: f.Src, Dest: f.Dest})
+		}
+		if err := validateProjectFiles(&p); err != nil {
+			return nil, fmt.Errorf("project %q: %v", rp.Name, err)
+		}
+		for _, a := range rp.Annotations {
+			ri.reportf("annotation %s of project %q is not mapped", a.Name, rp.Name)
+		}
+		for _, sub := range rp.Projects {
+			ri.reportf("project %q nested in project %q is not mapped", sub.Name, rp.Name)
+		}
+		m.Projects = append(m.Projects, p)
+	}
+	var dropped []string
+	for g := range droppedGroups {
+		dropped = append(dropped, g)
+	}
+	sort.Strings(dropped)
+	for _, g := range dropped {
+		ri.reportf("group %q is not mapped: it has projects which are fetched by default, and jiri attributes only select optional projects", g)
+	}
+	return m, nil
+}
@@ -0,0 +1,96 @@
+// Copyright 2026 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package project_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.fuchsia.dev/jiri/project"
+)
+
+func writeTestFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestImportRepoManifest(t *testing.T) {
+	dir := t.TempDir()
+	file := writeTestFile(t, dir, "default.xml", `<?xml version="1.0" encoding="UTF-8"?>
+<manifest>
+  <remote name="aosp" fetch=".." review="android-review.googlesource.com" />
+  <remote name="other" fetch="https://other.example.com/git" />
+  <default revision="refs/heads/main" remote="aosp" sync-j="4" />
+  <notice>Notice</notice>
+  <project path="build/make" name="platform/build" groups="pdk">
+    <copyfile src="core/root.mk" dest="Makefile" />
+  </project>
+  <project path="device/x" name="device/x" groups="device,notdefault" revision="0123456789012345678901234567890123456789" upstream="refs/heads/dev" clone-depth="1" />
+  <project name="platform/gone" />
+  <remove-project name="platform/gone" />
+  <include name="extra.xml" groups="extra" />
+  <extend-project name="tools/y" remote="other" revision="refs/tags/v1" />
+</manifest>
+`)
+	writeTestFile(t, dir, "extra.xml", `<manifest>
+  <project path="tools/y" name="tools/y" groups="notdefault" />
+</manifest>
+`)
+	m, unmapped, err := project.ImportRepoManifest(file, "https://android.googlesource.com/platform/manifest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []project.Project{
+		{
+			Name:         "platform/build",
+			Path:         "build/make",
+			Remote:       "https://android.googlesource.com/platform/build",
+			RemoteBranch: "main",
+			GerritHost:   "https://android-review.googlesource.com",
+			CopyFiles:    []project.CopyFile{{Src: "core/root.mk", Dest: "Makefile"}},
+		},
+		{
+			Name:         "device/x",
+			Path:         "device/x",
+			Remote:       "https://android.googlesource.com/device/x",
+			RemoteBranch: "dev",
+			Revision:     "0123456789012345678901234567890123456789",
+			HistoryDepth: 1,
+			GerritHost:   "https://android-review.googlesource.com",
+			Attributes:   "device",
+		},
+		{
+			Name:       "tools/y",
+			Path:       "tools/y",
+			Remote:     "https://other.example.com/git/tools/y",
+			Revision:   "refs/tags/v1",
+			Attributes: "extra",
+		},
+	}
+	if !reflect.DeepEqual(m.Projects, want) {
+		t.Errorf("got projects %+v, want %+v", m.Projects, want)
+	}
+	report := strings.Join(unmapped, "\n")
+	for _, s := range []string{"sync-j", "<notice>", `group "pdk"`} {
+		if !strings.Contains(report, s) {
+			t.Errorf("%q is not reported in %q", s, report)
+		}
+	}
+	if len(unmapped) != 3 {
+		t.Errorf("unexpected report %q", report)
+	}
+
+	// Relative fetch URLs need the URL of the manifest repository.
+	if _, _, err := project.ImportRepoManifest(file, ""); err == nil {
+		t.Errorf("relative fetch URL resolved without a manifest URL")
+	}
+}
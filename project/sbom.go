@@ -0,0 +1,650 @@
+// Copyright 2026 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package project
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.fuchsia.dev/jiri"
+	"go.fuchsia.dev/jiri/cipd"
+	"go.fuchsia.dev/jiri/gitutil"
+	"go.fuchsia.dev/jiri/version"
+)
+
+// SBOM formats supported by "jiri sbom".
+const (
+	SPDXJSONFormat      = "spdx-json"
+	CycloneDXJSONFormat = "cyclonedx-json"
+)
+
+// SBOMComponentType is the type of a component of a SBOM.
+type SBOMComponentType string
+
+const (
+	SBOMManifest SBOMComponentType = "manifest"
+	SBOMProject  SBOMComponentType = "project"
+	SBOMPackage  SBOMComponentType = "package"
+)
+
+// noAssertion is used by SPDX for unknown values.
+const noAssertion = "NOASSERTION"
+
+// licenseFiles are the files which are checked for the licenses of projects
+// and packages.
+var licenseFiles = []string{"LICENSE", "LICENSE.txt", "LICENSE.md", "COPYING", "README.fuchsia"}
+
+// SBOMComponent is a manifest, project or package of a SBOM.
+type SBOMComponent struct {
+	// ID identifies the component in the relationships of the SBOM.
+	ID      string
+	Type    SBOMComponentType
+	Name    string
+	Version string
+	// DownloadLocation is the remote of a project, or the location of a
+	// package. It is empty if it is unknown.
+	DownloadLocation string
+	// Licenses are SPDX license identifiers. Licenses which have no SPDX
+	// identifier use a LicenseRef- identifier.
+	Licenses []string
+}
+
+// SBOMRelationship records that the From component contains the To
+// component: a manifest imports another manifest, or declares a project or
+// a package.
+type SBOMRelationship struct {
+	From string
+	To   string
+}
+
+// SBOM is the software bill of materials of a checkout.
+type SBOM struct {
+	// Root is the ID of the component of the .jiri_manifest file.
+	Root          string
+	Components    []SBOMComponent
+	Relationships []SBOMRelationship
+
+	// licenseNames maps LicenseRef- identifiers to license names.
+	licenseNames map[string]string
+}
+
+// NewSBOM returns the SBOM of the projects and packages of the manifest of
+// the checkout. Projects are described at their pinned revision, or at
+// their current revision if they are not pinned, and their licenses are
+// detected from the license files found at that revision.
+func NewSBOM(jirix *jiri.X, localManifest bool) (*SBOM, error) {
+	jirix.TimerPush("create sbom")
+	defer jirix.TimerPop()
+
+	ld, localProjects, err := loadFetchedManifest(jirix, localManifest)
+	if err != nil {
+		return nil, err
+	}
+
+	sbom := &SBOM{licenseNames: make(map[string]string)}
+	manifestIDs := make(map[string]string)
+	addManifest := func(node *importTreeNode) string {
+		if node.file == "" {
+			return ""
+		}
+		if id, ok := manifestIDs[node.file]; ok {
+			return id
+		}
+		name := shortFileName(jirix.Root, "", node.file, "")
+		id := string(SBOMManifest) + ":" + name
+		manifestIDs[node.file] = id
+		sbom.Components = append(sbom.Components, SBOMComponent{ID: id, Type: SBOMManifest, Name: name})
+		return id
+	}
+
+	// Walk the import tree from the .jiri_manifest file.
+	nodes := make([]*importTreeNode, 0, len(ld.importTree.pool))
+	for _, node := range ld.importTree.pool {
+		nodes = append(nodes, node)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].tag < nodes[j].tag })
+	for _, node := range nodes {
+		if node.file == jirix.JiriManifestFile() {
+			sbom.Root = addManifest(node)
+		}
+	}
+	for _, node := range nodes {
+		addManifest(node)
+	}
+	edges := append([]importEdge(nil), ld.importTree.remoteImports...)
+	for _, node := range nodes {
+		for child := range node.children {
+			edges = append(edges, importEdge{node, child})
+		}
+	}
+	seen := make(map[SBOMRelationship]bool)
+	for _, edge := range edges {
+		rel := SBOMRelationship{From: manifestIDs[edge.parent.file], To: manifestIDs[edge.child.file]}
+		if rel.From == "" || rel.To == "" || rel.From == rel.To || seen[rel] {
+			continue
+		}
+		seen[rel] = true
+		sbom.Relationships = append(sbom.Relationships, rel)
+	}
+
+	projectComponents := sbomProjects(jirix, ld.Projects, localProjects)
+	projectKeys := make(ProjectKeys, 0, len(ld.Projects))
+	for k := range ld.Projects {
+		projectKeys = append(projectKeys, k)
+	}
+	sort.Sort(projectKeys)
+	for _, k := range projectKeys {
+		component := projectComponents[k]
+		sbom.Components = append(sbom.Components, component)
+		if node, ok := ld.importTree.projectKeyMap[k]; ok && manifestIDs[node.file] != "" {
+			sbom.Relationships = append(sbom.Relationships, SBOMRelationship{From: manifestIDs[node.file], To: component.ID})
+		}
+	}
+
+	pkgKeys := make(PackageKeys, 0, len(ld.Packages))
+	for k := range ld.Packages {
+		pkgKeys = append(pkgKeys, k)
+	}
+	sort.Sort(pkgKeys)
+	for _, k := range pkgKeys {
+		pkg := ld.Packages[k]
+		component := sbomPackage(jirix, pkg)
+		sbom.Components = append(sbom.Components, component)
+		if id := manifestIDs[pkg.ManifestPath]; id != "" {
+			sbom.Relationships = append(sbom.Relationships, SBOMRelationship{From: id, To: component.ID})
+		}
+	}
+
+	for _, component := range sbom.Components {
+		for _, license := range component.Licenses {
+			if strings.HasPrefix(license, "LicenseRef-") {
+				sbom.licenseNames[license] = strings.TrimPrefix(license, "LicenseRef-")
+			}
+		}
+	}
+	return sbom, nil
+}
+
+// sbomProjects returns the components of projects. The license files of the
+// projects are read from the local checkouts in parallel.
+func sbomProjects(jirix *jiri.X, projects, localProjects Projects) map[ProjectKey]SBOMComponent {
+	components := make(map[ProjectKey]SBOMComponent)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	limit := make(chan struct{}, jirix.Jobs)
+	for k, p := range projects {
+		component := SBOMComponent{
+			ID:               string(SBOMProject) + ":" + k.String(),
+			Type:             SBOMProject,
+			Name:             p.Name,
+			Version:          p.Revision,
+			DownloadLocation: rewriteRemote(jirix, p.Remote),
+		}
+		local, ok := localProjects[k]
+		if component.Version == "" || component.Version == "HEAD" {
+			component.Version = ""
+			if ok {
+				component.Version = local.Revision
+			}
+		}
+		if !ok || component.Version == "" {
+			jirix.Logger.Warningf("project %q is not checked out, its licenses are not detected\n\n", p.Name)
+			components[k] = component
+			continue
+		}
+		wg.Add(1)
+		limit <- struct{}{}
+		go func(k ProjectKey, component SBOMComponent, path string) {
+			defer func() { <-limit }()
+			defer wg.Done()
+			scm := gitutil.New(jirix, gitutil.RootDirOpt(path))
+			files := make(map[string]string)
+			for _, name := range licenseFiles {
+				// Files which do not exist at the revision are skipped.
+				if data, err := scm.Show(component.Version, name); err == nil {
+					files[name] = data
+				}
+			}
+			component.Licenses = detectLicenses(files)
+			mu.Lock()
+			components[k] = component
+			mu.Unlock()
+		}(k, component, local.Path)
+	}
+	wg.Wait()
+	return components
+}
+
+// sbomPackage returns the component of pkg. Its licenses are detected from
+// the license files of the package installed in the checkout, if any.
+func sbomPackage(jirix *jiri.X, pkg Package) SBOMComponent {
+	component := SBOMComponent{
+		ID:      string(SBOMPackage) + ":" + pkg.Name + "=" + pkg.Path,
+		Type:    SBOMPackage,
+		Name:    pkg.Name,
+		Version: pkg.Version,
+	}
+	if pkg.BackendName() == ArchivePackageBackend {
+		if url, err := pkg.archiveURL(cipd.CipdPlatform); err == nil {
+			component.DownloadLocation = url
+		}
+	} else {
+		component.DownloadLocation = cipd.PackageURL(pkg.Name, pkg.Version)
+	}
+	path, err := pkg.GetPath()
+	if err != nil || cipd.MustExpand(path) {
+		return component
+	}
+	files := make(map[string]string)
+	for _, name := range licenseFiles {
+		if data, err := ioutil.ReadFile(filepath.Join(jirix.Root, path, name)); err == nil {
+			files[name] = string(data)
+		}
+	}
+	component.Licenses = detectLicenses(files)
+	return component
+}
+
+// licenseTexts identifies licenses from phrases of their texts, in the order
+// they are checked. The phrases are in lower case, with normalized spaces.
+// The GNU licenses mention each other, so they are identified by their title,
+// which is at the start of their texts.
+var licenseTexts = []struct {
+	id      string
+	title   string
+	phrases []string
+}{
+	{"Apache-2.0", "", []string{"apache license", "version 2.0"}},
+	{"MPL-2.0", "", []string{"mozilla public license", "2.0"}},
+	{"LGPL-2.1-only", "gnu lesser general public license version 2.1", nil},
+	{"LGPL-3.0-only", "gnu lesser general public license version 3", nil},
+	{"GPL-3.0-only", "gnu general public license version 3", nil},
+	{"GPL-2.0-only", "gnu general public license version 2", nil},
+	{"MIT", "", []string{"permission is hereby granted, free of charge"}},
+	{"BSD-3-Clause", "", []string{"redistribution and use in source and binary forms", "endorse or promote products"}},
+	{"BSD-2-Clause", "", []string{"redistribution and use in source and binary forms"}},
+	{"ISC", "", []string{"permission to use, copy, modify, and/or distribute this software for any purpose"}},
+	{"Zlib", "", []string{"this software is provided 'as-is'"}},
+	{"Unlicense", "", []string{"this is free and unencumbered software released into the public domain"}},
+}
+
+// licenseTitleLen is the length of the start of license texts which their
+// titles are looked for in, leaving room for a copyright notice.
+const licenseTitleLen = 300
+
+// licenseNameIDs maps the license names used in README.fuchsia files to SPDX
+// license identifiers. The names are in lower case, with normalized spaces
+// instead of dashes.
+var licenseNameIDs = map[string]string{
+	"apache 2.0":                 "Apache-2.0",
+	"apache license 2.0":         "Apache-2.0",
+	"apache version 2.0":         "Apache-2.0",
+	"apache license version 2.0": "Apache-2.0",
+	"mit":                        "MIT",
+	"bsd":                        "BSD-3-Clause",
+	"bsd 3 clause":               "BSD-3-Clause",
+	"3 clause bsd":               "BSD-3-Clause",
+	"bsd 2 clause":               "BSD-2-Clause",
+	"2 clause bsd":               "BSD-2-Clause",
+	"mpl 2.0":                    "MPL-2.0",
+	"isc":                        "ISC",
+	"zlib":                       "Zlib",
+	"lgpl 2.1":                   "LGPL-2.1-only",
+	"lgpl 3.0":                   "LGPL-3.0-only",
+	"gpl 2.0":                    "GPL-2.0-only",
+	"gpl 3.0":                    "GPL-3.0-only",
+	"unlicense":                  "Unlicense",
+}
+
+var spdxIdentifierRE = regexp.MustCompile(`SPDX-License-Identifier:\s*([A-Za-z0-9.+-]+)`)
+
+// detectLicenses returns the sorted SPDX identifiers of the licenses found in
+// files, which maps the names of license files to their content.
+// README.fuchsia files declare their licenses in "License:" lines, other
+// files are matched against known license texts.
+func detectLicenses(files map[string]string) []string {
+	found := make(map[string]bool)
+	for name, data := range files {
+		if name == "README.fuchsia" {
+			for _, line := range strings.Split(data, "\n") {
+				if !strings.HasPrefix(line, "License:") {
+					continue
+				}
+				for _, l := range strings.Split(strings.TrimPrefix(line, "License:"), ",") {
+					if id := licenseIdentifier(l); id != "" {
+						found[id] = true
+					}
+				}
+			}
+			continue
+		}
+		if m := spdxIdentifierRE.FindStringSubmatch(data); m != nil {
+			found[m[1]] = true
+			continue
+		}
+		text := strings.ToLower(strings.Join(strings.Fields(data), " "))
+		head := text
+		if len(head) > licenseTitleLen {
+			head = head[:licenseTitleLen]
+		}
+		for _, license := range licenseTexts {
+			matched := strings.Contains(head, license.title)
+			for _, phrase := range license.phrases {
+				if !strings.Contains(text, phrase) {
+					matched = false
+					break
+				}
+			}
+			if matched {
+				found[license.id] = true
+				break
+			}
+		}
+	}
+	var licenses []string
+	for id := range found {
+		licenses = append(licenses, id)
+	}
+	sort.Strings(licenses)
+	return licenses
+}
+
+var licenseRefRE = regexp.MustCompile(`[^A-Za-z0-9.-]+`)
+
+// licenseIdentifier returns the SPDX identifier of a license name, or a
+// LicenseRef- identifier if the name is unknown.
+func licenseIdentifier(name string) string {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return ""
+	}
+	key := strings.ToLower(strings.Join(strings.Fields(strings.NewReplacer("-", " ", "_", " ").Replace(name)), " "))
+	if id, ok := licenseNameIDs[key]; ok {
+		return id
+	}
+	for _, license := range licenseTexts {
+		if strings.EqualFold(name, license.id) {
+			return license.id
+		}
+	}
+	return "LicenseRef-" + strings.Trim(licenseRefRE.ReplaceAllString(name, "-"), "-")
+}
+
+// Write writes the SBOM to w in format.
+func (s *SBOM) Write(w io.Writer, format string) error {
+	var doc interface{}
+	switch format {
+	case SPDXJSONFormat:
+		doc = s.spdx()
+	case CycloneDXJSONFormat:
+		doc = s.cycloneDX()
+	default:
+		return fmt.Errorf("unknown sbom format %q", format)
+	}
+	out, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize JSON output: %s", err)
+	}
+	_, err = fmt.Fprintf(w, "%s\n", out)
+	return err
+}
+
+// ToFile writes the SBOM to filename in format.
+func (s *SBOM) ToFile(jirix *jiri.X, filename, format string) error {
+	var buf strings.Builder
+	if err := s.Write(&buf, format); err != nil {
+		return err
+	}
+	return safeWriteFile(jirix, filename, []byte(buf.String()))
+}
+
+func newUUID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+type spdxDocument struct {
+	SPDXVersion                string                 `json:"spdxVersion"`
+	DataLicense                string                 `json:"dataLicense"`
+	SPDXID                     string                 `json:"SPDXID"`
+	Name                       string                 `json:"name"`
+	DocumentNamespace          string                 `json:"documentNamespace"`
+	CreationInfo               spdxCreationInfo       `json:"creationInfo"`
+	Packages                   []spdxPackage          `json:"packages"`
+	Relationships              []spdxRelationship     `json:"relationships"`
+	HasExtractedLicensingInfos []spdxExtractedLicense `json:"hasExtractedLicensingInfos,omitempty"`
+}
+
+type spdxCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type spdxPackage struct {
+	Name             string `json:"name"`
+	SPDXID           string `json:"SPDXID"`
+	VersionInfo      string `json:"versionInfo,omitempty"`
+	DownloadLocation string `json:"downloadLocation"`
+	FilesAnalyzed    bool   `json:"filesAnalyzed"`
+	LicenseConcluded string `json:"licenseConcluded"`
+	LicenseDeclared  string `json:"licenseDeclared"`
+	CopyrightText    string `json:"copyrightText"`
+	Comment          string `json:"comment,omitempty"`
+}
+
+type spdxRelationship struct {
+	SPDXElementID      string `json:"spdxElementId"`
+	RelationshipType   string `json:"relationshipType"`
+	RelatedSPDXElement string `json:"relatedSpdxElement"`
+}
+
+type spdxExtractedLicense struct {
+	LicenseID     string `json:"licenseId"`
+	Name          string `json:"name"`
+	ExtractedText string `json:"extractedText"`
+}
+
+var spdxIDRE = regexp.MustCompile(`[^A-Za-z0-9.-]+`)
+
+// spdx returns the SBOM as a SPDX 2.3 document. Every component is a SPDX
+// package.
+func (s *SBOM) spdx() *spdxDocument {
+	doc := &spdxDocument{
+		SPDXVersion:       "SPDX-2.3",
+		DataLicense:       "CC0-1.0",
+		SPDXID:            "SPDXRef-DOCUMENT",
+		Name:              "jiri",
+		DocumentNamespace: "https://fuchsia.googlesource.com/jiri/spdx/" + newUUID(),
+		CreationInfo: spdxCreationInfo{
+			Created:  time.Now().UTC().Format(time.RFC3339),
+			Creators: []string{"Tool: jiri-" + version.FormattedVersion()},
+		},
+		Packages:      []spdxPackage{},
+		Relationships: []spdxRelationship{},
+	}
+	ids := make(map[string]string)
+	used := make(map[string]bool)
+	for _, c := range s.Components {
+		id := "SPDXRef-" + strings.Trim(spdxIDRE.ReplaceAllString(c.ID, "-"), "-")
+		for i := 2; used[id]; i++ {
+			id = fmt.Sprintf("SPDXRef-%s-%d", strings.Trim(spdxIDRE.ReplaceAllString(c.ID, "-"), "-"), i)
+		}
+		used[id] = true
+		ids[c.ID] = id
+		pkg := spdxPackage{
+			Name:             c.Name,
+			SPDXID:           id,
+			VersionInfo:      c.Version,
+			DownloadLocation: noAssertion,
+			LicenseConcluded: noAssertion,
+			LicenseDeclared:  noAssertion,
+			CopyrightText:    noAssertion,
+			Comment:          "jiri " + string(c.Type),
+		}
+		if c.DownloadLocation != "" {
+			pkg.DownloadLocation = c.DownloadLocation
+			if c.Type == SBOMProject {
+				pkg.DownloadLocation = "git+" + c.DownloadLocation
+				if c.Version != "" {
+					pkg.DownloadLocation += "@" + c.Version
+				}
+			}
+		}
+		if len(c.Licenses) != 0 {
+			pkg.LicenseDeclared = strings.Join(c.Licenses, " AND ")
+		}
+		doc.Packages = append(doc.Packages, pkg)
+	}
+	if s.Root != "" {
+		doc.Relationships = append(doc.Relationships, spdxRelationship{"SPDXRef-DOCUMENT", "DESCRIBES", ids[s.Root]})
+	}
+	for _, rel := range s.Relationships {
+		doc.Relationships = append(doc.Relationships, spdxRelationship{ids[rel.From], "CONTAINS", ids[rel.To]})
+	}
+	refs := make([]string, 0, len(s.licenseNames))
+	for ref := range s.licenseNames {
+		refs = append(refs, ref)
+	}
+	sort.Strings(refs)
+	for _, ref := range refs {
+		doc.HasExtractedLicensingInfos = append(doc.HasExtractedLicensingInfos, spdxExtractedLicense{
+			LicenseID:     ref,
+			Name:          s.licenseNames[ref],
+			ExtractedText: "License: " + s.licenseNames[ref],
+		})
+	}
+	return doc
+}
+
+type cycloneDXDocument struct {
+	BOMFormat    string                `json:"bomFormat"`
+	SpecVersion  string                `json:"specVersion"`
+	SerialNumber string                `json:"serialNumber"`
+	Version      int                   `json:"version"`
+	Metadata     cycloneDXMetadata     `json:"metadata"`
+	Components   []cycloneDXComponent  `json:"components"`
+	Dependencies []cycloneDXDependency `json:"dependencies"`
+}
+
+type cycloneDXMetadata struct {
+	Timestamp string              `json:"timestamp"`
+	Tools     []cycloneDXTool     `json:"tools"`
+	Component *cycloneDXComponent `json:"component,omitempty"`
+}
+
+type cycloneDXTool struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type cycloneDXComponent struct {
+	BOMRef             string                 `json:"bom-ref"`
+	Type               string                 `json:"type"`
+	Name               string                 `json:"name"`
+	Version            string                 `json:"version,omitempty"`
+	Licenses           []cycloneDXLicense     `json:"licenses,omitempty"`
+	ExternalReferences []cycloneDXExternalRef `json:"externalReferences,omitempty"`
+	Properties         []cycloneDXProperty    `json:"properties,omitempty"`
+}
+
+type cycloneDXLicense struct {
+	License struct {
+		ID   string `json:"id,omitempty"`
+		Name string `json:"name,omitempty"`
+	} `json:"license"`
+}
+
+type cycloneDXExternalRef struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+type cycloneDXProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type cycloneDXDependency struct {
+	Ref       string   `json:"ref"`
+	DependsOn []string `json:"dependsOn"`
+}
+
+// cycloneDX returns the SBOM as a CycloneDX 1.4 document. The root manifest
+// is the component of the metadata, and the relationships are dependencies.
+func (s *SBOM) cycloneDX() *cycloneDXDocument {
+	doc := &cycloneDXDocument{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  "1.4",
+		SerialNumber: "urn:uuid:" + newUUID(),
+		Version:      1,
+		Metadata: cycloneDXMetadata{
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+			Tools:     []cycloneDXTool{{Name: "jiri", Version: version.FormattedVersion()}},
+		},
+		Components:   []cycloneDXComponent{},
+		Dependencies: []cycloneDXDependency{},
+	}
+	for _, c := range s.Components {
+		component := cycloneDXComponent{
+			BOMRef:     c.ID,
+			Type:       "library",
+			Name:       c.Name,
+			Version:    c.Version,
+			Properties: []cycloneDXProperty{{Name: "jiri:type", Value: string(c.Type)}},
+		}
+		switch c.Type {
+		case SBOMManifest:
+			component.Type = "file"
+		case SBOMProject:
+			if c.DownloadLocation != "" {
+				component.ExternalReferences = []cycloneDXExternalRef{{Type: "vcs", URL: c.DownloadLocation}}
+			}
+		case SBOMPackage:
+			if c.DownloadLocation != "" {
+				component.ExternalReferences = []cycloneDXExternalRef{{Type: "distribution", URL: c.DownloadLocation}}
+			}
+		}
+		for _, id := range c.Licenses {
+			var license cycloneDXLicense
+			if name, ok := s.licenseNames[id]; ok {
+				license.License.Name = name
+			} else {
+				license.License.ID = id
+			}
+			component.Licenses = append(component.Licenses, license)
+		}
+		if c.ID == s.Root {
+			doc.Metadata.Component = &component
+			continue
+		}
+		doc.Components = append(doc.Components, component)
+	}
+	dependsOn := make(map[string][]string)
+	var refs []string
+	for _, rel := range s.Relationships {
+		if _, ok := dependsOn[rel.From]; !ok {
+			refs = append(refs, rel.From)
+		}
+		dependsOn[rel.From] = append(dependsOn[rel.From], rel.To)
+	}
+	for _, ref := range refs {
+		doc.Dependencies = append(doc.Dependencies, cycloneDXDependency{Ref: ref, DependsOn: dependsOn[ref]})
+	}
+	return doc
+}
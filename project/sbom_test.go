@@ -0,0 +1,41 @@
+// Copyright 2026 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package project_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"go.fuchsia.dev/jiri/project"
+)
+
+// TestDetectLicenses tests identifying the full texts of licenses, which are
+// in testdata/licenses, named after their SPDX identifiers.
+func TestDetectLicenses(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "licenses", "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no license texts")
+	}
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []string{filepath.Base(file)}
+		if got := project.InternalDetectLicenses(map[string]string{"LICENSE": string(data)}); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %v, want %v", file, got, want)
+		}
+		// A copyright notice can precede the license.
+		got := project.InternalDetectLicenses(map[string]string{"COPYING": "Copyright 2026 Example Authors\n\n" + string(data)})
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s with a copyright notice: got %v, want %v", file, got, want)
+		}
+	}
+}
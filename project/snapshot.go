@@ -0,0 +1,318 @@
+// Copyright 2026 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package project
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"go.fuchsia.dev/jiri"
+	"go.fuchsia.dev/jiri/cipd"
+	"go.fuchsia.dev/jiri/version"
+)
+
+// SnapshotVersion is the version of the snapshots created by jiri. Snapshots
+// of version ManifestVersion have no metadata, and can still be loaded.
+const SnapshotVersion = "2.0"
+
+// SnapshotMetadata records how the checkout of a snapshot was created. It is
+// the <metadata> element of snapshots.
+type SnapshotMetadata struct {
+	// Created is the creation time of the snapshot, in RFC 3339 format.
+	Created     string `xml:"created,attr,omitempty"`
+	JiriVersion string `xml:"jiriversion,attr,omitempty"`
+	// Platform is the cipd platform of the host which created the
+	// snapshot, like "linux-amd64".
+	Platform string `xml:"platform,attr,omitempty"`
+	// Manifest is the manifest file of the checkout, relative to the root.
+	Manifest string `xml:"manifest,attr,omitempty"`
+	// Imports are the imports of manifests which were loaded, in order.
+	Imports []ManifestImport `xml:"imports>import"`
+	// Sources record the manifest which declared each project.
+	Sources []SnapshotSource `xml:"sources>source"`
+
+	// Partial is set for snapshots of a subset of the checkout, which were
+	// created with ProjectFilter and AttributeFilter, see SnapshotFilter.
+	Partial         bool   `xml:"partial,attr,omitempty"`
+	ProjectFilter   string `xml:"projectfilter,attr,omitempty"`
+	AttributeFilter string `xml:"attributefilter,attr,omitempty"`
+}
+
+// SnapshotSource is the manifest which declared a project of a snapshot.
+type SnapshotSource struct {
+	Name   string `xml:"name,attr"`
+	Remote string `xml:"remote,attr"`
+	// Manifest is the manifest file, relative to the root. Manifests read
+	// from git are in the checkout of their repository.
+	Manifest string `xml:"manifest,attr"`
+}
+
+func (m *SnapshotMetadata) deepCopy() *SnapshotMetadata {
+	x := *m
+	x.Imports = append([]ManifestImport(nil), m.Imports...)
+	x.Sources = append([]SnapshotSource(nil), m.Sources...)
+	return &x
+}
+
+// newSnapshotMetadata returns the metadata of a snapshot of projects. If ld
+// is not nil, it is the loader of the manifest of the checkout, which
+// provides the imports and the sources of the projects.
+func newSnapshotMetadata(jirix *jiri.X, ld *loader, projects Projects, localManifest bool) (*SnapshotMetadata, error) {
+	m := &SnapshotMetadata{
+		Created:     time.Now().UTC().Format(time.RFC3339),
+		JiriVersion: version.FormattedVersion(),
+		Platform:    cipd.CipdPlatform.String(),
+		Manifest:    shortFileName(jirix.Root, "", jirix.JiriManifestFile(), ""),
+	}
+	if ld == nil {
+		return m, nil
+	}
+	imports, err := resolveManifestImports(jirix, ld.imports, localManifest)
+	if err != nil {
+		return nil, err
+	}
+	m.Imports = imports
+	for k, p := range projects {
+		if declared, ok := ld.Projects[k]; ok && declared.ManifestPath != "" {
+			m.Sources = append(m.Sources, SnapshotSource{
+				Name:     p.Name,
+				Remote:   p.Remote,
+				Manifest: shortFileName(jirix.Root, "", declared.ManifestPath, ""),
+			})
+		}
+	}
+	sort.Slice(m.Sources, func(i, j int) bool {
+		if m.Sources[i].Name != m.Sources[j].Name {
+			return m.Sources[i].Name < m.Sources[j].Name
+		}
+		return m.Sources[i].Remote < m.Sources[j].Remote
+	})
+	return m, nil
+}
+
+// fetchSnapshot returns the path of snapshot, which is a file or a URL. A
+// snapshot at a URL is downloaded to a temporary file, which is removed by
+// the returned cleanup function.
+func fetchSnapshot(jirix *jiri.X, snapshot string) (string, func(), error) {
+	if _, err := os.Stat(snapshot); err == nil {
+		return snapshot, func() {}, nil
+	} else if !os.IsNotExist(err) {
+		return "", nil, fmtError(err)
+	}
+	u, err := url.ParseRequestURI(snapshot)
+	if err != nil {
+		return "", nil, fmt.Errorf("%q is neither a URL nor a valid file path", snapshot)
+	}
+	jirix.Logger.Infof("Getting snapshot from URL %q", u)
+	resp, err := http.Get(u.String())
+	if err != nil {
+		return "", nil, fmt.Errorf("Error getting snapshot from URL %q: %v", u, err)
+	}
+	defer resp.Body.Close()
+	tmpFile, err := ioutil.TempFile("", "snapshot")
+	if err != nil {
+		return "", nil, fmt.Errorf("Error creating tmp file: %v", err)
+	}
+	defer tmpFile.Close()
+	cleanup := func() { os.Remove(tmpFile.Name()) }
+	if _, err = io.Copy(tmpFile, resp.Body); err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("Error writing to tmp file: %v", err)
+	}
+	return tmpFile.Name(), cleanup, nil
+}
+
+// LoadSnapshotManifest returns the manifest of snapshot, which is a file or
+// a URL. Snapshots of version ManifestVersion have no metadata.
+func LoadSnapshotManifest(jirix *jiri.X, snapshot string) (*Manifest, error) {
+	file, cleanup, err := fetchSnapshot(jirix, snapshot)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+	m, err := ManifestFromFile(jirix, file)
+	if err != nil {
+		return nil, err
+	}
+	if m.Version != ManifestVersion && m.Version != SnapshotVersion {
+		return nil, errVersionMismatch
+	}
+	return m, nil
+}
+
+// SnapshotFilter selects the projects of a partial snapshot, with their
+// dependencies: the projects they are nested in, their hooks, and the
+// packages installed in them.
+type SnapshotFilter struct {
+	// Projects is a regular expression matching the names of the selected
+	// projects. If empty, all projects match.
+	Projects string
+	// Attributes selects the projects, and the optional packages, which
+	// are fetched with these attributes. If empty, the attributes of
+	// projects are not checked.
+	Attributes string
+}
+
+// filter returns the projects, hooks and packages selected by f.
+func (f *SnapshotFilter) filter(jirix *jiri.X, projects Projects, hooks Hooks, pkgs Packages) (Projects, Hooks, Packages, error) {
+	var re *regexp.Regexp
+	if f.Projects != "" {
+		var err error
+		if re, err = regexp.Compile(f.Projects); err != nil {
+			return nil, nil, nil, fmt.Errorf("invalid project filter %q: %v", f.Projects, err)
+		}
+	}
+	if err := CheckFetchingAttributes(f.Attributes); err != nil {
+		return nil, nil, nil, err
+	}
+	env := newAttrEnv(f.Attributes)
+	// fetched returns true if an item with attrs is fetched with the
+	// attributes of the filter, like the items which are not optional.
+	fetched := func(attrs string) (bool, error) {
+		if f.Attributes == "" {
+			return true, nil
+		}
+		attrs, computed, err := computeAttributes(attrs)
+		if err != nil {
+			return false, err
+		}
+		ok, _, err := env.included(attrs, computed)
+		return ok, err
+	}
+
+	selected := make(Projects)
+	for k, p := range projects {
+		if re != nil && !re.MatchString(p.Name) {
+			continue
+		}
+		ok, err := fetched(p.Attributes)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("project %q: %v", p.Name, err)
+		}
+		if ok {
+			selected[k] = p
+		}
+	}
+	// contains returns true if path is in the checkout of a selected
+	// project.
+	contains := func(path string) bool {
+		for _, p := range selected {
+			if path == p.Path || strings.HasPrefix(path, p.Path+string(filepath.Separator)) {
+				return true
+			}
+		}
+		return false
+	}
+	filtered := make(Projects)
+	for k, p := range projects {
+		if _, ok := selected[k]; ok {
+			filtered[k] = p
+			continue
+		}
+		// The projects which selected projects are nested in are
+		// needed to check them out.
+		for _, s := range selected {
+			if strings.HasPrefix(s.Path, p.Path+string(filepath.Separator)) {
+				filtered[k] = p
+				break
+			}
+		}
+	}
+
+	filteredHooks := make(Hooks)
+	for k, h := range hooks {
+		if h.ActionPath != "" && contains(h.ActionPath) {
+			filteredHooks[k] = h
+		}
+	}
+
+	filteredPkgs := make(Packages)
+	for k, pkg := range pkgs {
+		if f.Attributes != "" && pkg.Attributes != "" {
+			ok, err := fetched(pkg.Attributes)
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("package %q: %v", pkg.Name, err)
+			}
+			if ok {
+				filteredPkgs[k] = pkg
+				continue
+			}
+		}
+		path, err := pkg.GetPath()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if contains(filepath.Join(jirix.Root, path)) {
+			filteredPkgs[k] = pkg
+		}
+	}
+	return filtered, filteredHooks, filteredPkgs, nil
+}
+
+// CheckoutPartialSnapshot updates the projects of a partial snapshot to the
+// state specified in the snapshot, and leaves the other projects alone.
+// Hooks of the snapshot are run, and its packages are fetched along with
+// the packages of the last update.
+func CheckoutPartialSnapshot(jirix *jiri.X, snapshot string, runHooks, fetchPkgs bool, runHookTimeout, fetchTimeout uint) error {
+	jirix.UsingSnapshot = true
+	localProjects, err := LocalProjects(jirix, FastScan)
+	if err != nil {
+		return err
+	}
+	// Packages which are not fetched are removed by cipd, so the packages
+	// of the last update are fetched too.
+	var lastHooks Hooks
+	var lastPkgs Packages
+	lastAttrs := ""
+	if _, err := os.Stat(jirix.UpdateHistoryLatestLink()); err == nil {
+		if _, lastHooks, lastPkgs, err = LoadSnapshotFile(jirix, jirix.UpdateHistoryLatestLink()); err != nil {
+			return err
+		}
+		lastAttrs = jirix.FetchingAttrs
+	} else {
+		jirix.Logger.Warningf("No update history, the packages which are not in %s may be removed\n\n", snapshot)
+	}
+	remoteProjects, hooks, pkgs, err := LoadSnapshotFile(jirix, snapshot)
+	if err != nil {
+		return err
+	}
+	jirix.FetchingAttrs = mergeFetchingAttributes(lastAttrs, jirix.FetchingAttrs)
+
+	// Only the local projects of the snapshot are compared with it, the
+	// other projects are not deleted.
+	selected := make(Projects)
+	for k := range remoteProjects {
+		if p, ok := localProjects[k]; ok {
+			selected[k] = p
+		}
+	}
+	allPkgs := make(Packages)
+	for k, pkg := range lastPkgs {
+		allPkgs[k] = pkg
+	}
+	for k, pkg := range pkgs {
+		allPkgs[k] = pkg
+	}
+	if err := updateProjects(jirix, selected, remoteProjects, hooks, allPkgs, false /*gc*/, runHookTimeout, fetchTimeout, false /*rebaseTracked*/, false /*rebaseUntracked*/, false /*rebaseAll*/, true /*snapshot*/, runHooks, fetchPkgs); err != nil {
+		return err
+	}
+	allHooks := make(Hooks)
+	for k, hook := range lastHooks {
+		allHooks[k] = hook
+	}
+	for k, hook := range hooks {
+		allHooks[k] = hook
+	}
+	return WriteUpdateHistorySnapshot(jirix, allHooks, allPkgs, false)
+}
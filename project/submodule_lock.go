@@ -0,0 +1,189 @@
+// Copyright 2026 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package project
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"go.fuchsia.dev/jiri"
+	"go.fuchsia.dev/jiri/gitutil"
+)
+
+// SubmoduleLock pins the commit of a git submodule of a project.
+type SubmoduleLock struct {
+	// Path is the path of the submodule in the project.
+	Path     string `json:"path"`
+	Revision string `json:"revision"`
+}
+
+// LFSObjectLock pins a git-lfs object of a project, as recorded in its
+// pointer file.
+type LFSObjectLock struct {
+	// Path is the path of the pointer file in the project.
+	Path string `json:"path"`
+	// OID is the hash of the object, like "sha256:4d7a...".
+	OID  string `json:"oid"`
+	Size int64  `json:"size"`
+}
+
+// resolveSubmoduleLocks records the submodule commits and git-lfs objects of
+// the projects with git submodules in their project locks. They are read
+// from the local checkouts of the projects, at the locked revision, or at
+// JIRI_HEAD for projects which are not pinned. In that case the project lock
+// is pinned to JIRI_HEAD too, as the submodule pins only make sense for a
+// given revision of the project.
+func resolveSubmoduleLocks(jirix *jiri.X, projects Projects, projectLocks ProjectLocks) error {
+	for _, p := range projects {
+		if !p.GitSubmodules {
+			continue
+		}
+		key := ProjectLock{Remote: p.Remote, Name: p.Name}.Key()
+		lock, ok := projectLocks[key]
+		if !ok {
+			continue
+		}
+		scm := gitutil.New(jirix, gitutil.RootDirOpt(p.Path))
+		revision := lock.Revision
+		if revision == "" || revision == "HEAD" {
+			revision = "JIRI_HEAD"
+		}
+		revision, err := scm.CurrentRevisionForRef(revision)
+		if err != nil {
+			return fmt.Errorf("cannot resolve revision of project %q, run \"jiri update\" first: %v", p.Name, err)
+		}
+		submodules, err := scm.Submodules(revision)
+		if err != nil {
+			return fmt.Errorf("cannot list submodules of project %q at %s: %v", p.Name, revision, err)
+		}
+		pointers, err := scm.LFSPointers(revision)
+		if err != nil {
+			return fmt.Errorf("cannot list git-lfs objects of project %q at %s: %v", p.Name, revision, err)
+		}
+		lock.Revision = revision
+		lock.Submodules = nil
+		for path, rev := range submodules {
+			lock.Submodules = append(lock.Submodules, SubmoduleLock{Path: path, Revision: rev})
+		}
+		sort.Slice(lock.Submodules, func(i, j int) bool {
+			return lock.Submodules[i].Path < lock.Submodules[j].Path
+		})
+		lock.LFSObjects = nil
+		for _, pointer := range pointers {
+			lock.LFSObjects = append(lock.LFSObjects, LFSObjectLock{Path: pointer.Path, OID: pointer.OID, Size: pointer.Size})
+		}
+		sort.Slice(lock.LFSObjects, func(i, j int) bool {
+			return lock.LFSObjects[i].Path < lock.LFSObjects[j].Path
+		})
+		projectLocks[key] = lock
+	}
+	return nil
+}
+
+// verifySubmoduleLocks checks that the submodules checked out in the
+// project, and its git-lfs objects, match the pins of its project lock.
+// Projects which are not at their locked revision, for instance because they
+// are on a local branch, are not checked.
+func verifySubmoduleLocks(jirix *jiri.X, project Project) error {
+	if len(project.SubmoduleLocks) == 0 && len(project.LFSObjectLocks) == 0 {
+		return nil
+	}
+	scm := gitutil.New(jirix, gitutil.RootDirOpt(project.Path))
+	head, err := scm.CurrentRevision()
+	if err != nil {
+		return err
+	}
+	if head != project.Revision {
+		jirix.Logger.Warningf("Project %q is at %s instead of its locked revision %s, its submodules and git-lfs objects are not verified\n\n", project.Name, head, project.Revision)
+		return nil
+	}
+	var errs []string
+	if len(project.SubmoduleLocks) != 0 {
+		status, err := scm.SubmoduleStatus()
+		if err != nil {
+			return err
+		}
+		locked := make(map[string]bool)
+		for _, lock := range project.SubmoduleLocks {
+			locked[lock.Path] = true
+			rev, ok := status[lock.Path]
+			switch {
+			case !ok:
+				errs = append(errs, fmt.Sprintf("submodule %s is not checked out", lock.Path))
+			case rev != lock.Revision:
+				errs = append(errs, fmt.Sprintf("submodule %s is at %s instead of %s", lock.Path, rev, lock.Revision))
+			}
+		}
+		for path := range status {
+			if !locked[path] {
+				errs = append(errs, fmt.Sprintf("submodule %s is not locked", path))
+			}
+		}
+	}
+	if len(project.LFSObjectLocks) != 0 {
+		pointers, err := scm.LFSPointers("HEAD")
+		if err != nil {
+			return err
+		}
+		found := make(map[string]gitutil.LFSPointer)
+		for _, pointer := range pointers {
+			found[pointer.Path] = pointer
+		}
+		for _, lock := range project.LFSObjectLocks {
+			pointer, ok := found[lock.Path]
+			if !ok || pointer.OID != lock.OID || pointer.Size != lock.Size {
+				errs = append(errs, fmt.Sprintf("git-lfs object %s does not match %s", lock.Path, lock.OID))
+				continue
+			}
+			delete(found, lock.Path)
+			if err := verifyLFSObject(filepath.Join(project.Path, lock.Path), lock); err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+		for path := range found {
+			errs = append(errs, fmt.Sprintf("git-lfs object %s is not locked", path))
+		}
+	}
+	if len(errs) != 0 {
+		sort.Strings(errs)
+		return fmt.Errorf("project %q does not match its lock:\n%s", project.Name, strings.Join(errs, "\n"))
+	}
+	return nil
+}
+
+// verifyLFSObject checks that the file fetched by git-lfs at path matches
+// lock. Files which are still pointers are not fetched, and not checked.
+func verifyLFSObject(path string, lock LFSObjectLock) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("git-lfs object %s: %v", lock.Path, err)
+	}
+	defer f.Close()
+	head, err := ioutil.ReadAll(io.LimitReader(f, 1024))
+	if err != nil {
+		return fmt.Errorf("git-lfs object %s: %v", lock.Path, err)
+	}
+	if _, _, ok := gitutil.ParseLFSPointer(head); ok {
+		return nil
+	}
+	h := sha256.New()
+	h.Write(head)
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return fmt.Errorf("git-lfs object %s: %v", lock.Path, err)
+	}
+	size += int64(len(head))
+	if oid := "sha256:" + hex.EncodeToString(h.Sum(nil)); oid != lock.OID || size != lock.Size {
+		return fmt.Errorf("git-lfs object %s has content %s instead of %s", lock.Path, oid, lock.OID)
+	}
+	return nil
+}
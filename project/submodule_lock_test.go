@@ -0,0 +1,90 @@
+// Copyright 2026 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package project_test
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.fuchsia.dev/jiri/jiritest/xtest"
+	"go.fuchsia.dev/jiri/project"
+)
+
+func TestSubmoduleLocks(t *testing.T) {
+	jirix, cleanup := xtest.NewX(t)
+	defer cleanup()
+
+	git := func(dir string, args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "protocol.file.allow=always"}, args...)...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com")
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	sub := filepath.Join(jirix.Root, "sub")
+	super := filepath.Join(jirix.Root, "super")
+	for _, dir := range []string{sub, filepath.Join(super, "data")} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	git(sub, "init")
+	git(sub, "commit", "--allow-empty", "-m", "sub")
+	subRev := git(sub, "rev-parse", "HEAD")
+
+	pointer := "version https://git-lfs.github.com/spec/v1\noid sha256:4d7a214614ab2935c943f9e0ff69d22eadbb8f32b1258daaa5e2ca24d17e2393\nsize 12345\n"
+	if err := ioutil.WriteFile(filepath.Join(super, "data", "blob.bin"), []byte(pointer), 0644); err != nil {
+		t.Fatal(err)
+	}
+	git(super, "init")
+	git(super, "submodule", "add", sub, "third_party/sub")
+	git(super, "add", "-A")
+	git(super, "commit", "-m", "super")
+
+	p := project.Project{Name: "super", Remote: "https://example.com/super", Path: super, GitSubmodules: true, Revision: git(super, "rev-parse", "HEAD")}
+	lock := project.ProjectLock{Remote: p.Remote, Name: p.Name, Revision: p.Revision}
+	projectLocks := project.ProjectLocks{lock.Key(): lock}
+	if err := project.InternalResolveSubmoduleLocks(jirix, project.Projects{p.Key(): p}, projectLocks); err != nil {
+		t.Fatal(err)
+	}
+	lock = projectLocks[lock.Key()]
+	wantSubmodules := []project.SubmoduleLock{{Path: "third_party/sub", Revision: subRev}}
+	if !reflect.DeepEqual(lock.Submodules, wantSubmodules) {
+		t.Errorf("got submodules %+v, want %+v", lock.Submodules, wantSubmodules)
+	}
+	wantLFSObjects := []project.LFSObjectLock{{Path: "data/blob.bin", OID: "sha256:4d7a214614ab2935c943f9e0ff69d22eadbb8f32b1258daaa5e2ca24d17e2393", Size: 12345}}
+	if !reflect.DeepEqual(lock.LFSObjects, wantLFSObjects) {
+		t.Errorf("got git-lfs objects %+v, want %+v", lock.LFSObjects, wantLFSObjects)
+	}
+
+	p.SubmoduleLocks = lock.Submodules
+	p.LFSObjectLocks = lock.LFSObjects
+	if err := project.InternalVerifySubmoduleLocks(jirix, p); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// Move the submodule to another commit.
+	git(filepath.Join(super, "third_party", "sub"), "commit", "--allow-empty", "-m", "moved")
+	if err := project.InternalVerifySubmoduleLocks(jirix, p); err == nil || !strings.Contains(err.Error(), "submodule third_party/sub is at") {
+		t.Errorf("expected an error for a moved submodule, got %v", err)
+	}
+
+	// Projects which are not at their locked revision are not verified.
+	git(super, "commit", "--allow-empty", "-m", "local change")
+	if err := project.InternalVerifySubmoduleLocks(jirix, p); err != nil {
+		t.Errorf("unexpected error for a project at another revision: %v", err)
+	}
+}
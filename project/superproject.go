@@ -0,0 +1,256 @@
+// Copyright 2026 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package project
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"go.fuchsia.dev/jiri"
+	"go.fuchsia.dev/jiri/gitutil"
+)
+
+// A superproject is a git repository with a submodule for each project of
+// the checkout, whose gitlinks record the revisions of the projects. The
+// submodules are described in the .gitmodules file of the superproject, in
+// the format of "jiri generate-gitmodules".
+
+// SubmoduleName returns the name of the submodule of project p in a
+// superproject.
+func SubmoduleName(p Project) string {
+	hashBytes := sha256.Sum256([]byte(p.Key().String()))
+	return p.Name + "-" + hex.EncodeToString(hashBytes[:5])
+}
+
+// submoduleNameRE matches the names returned by SubmoduleName.
+var submoduleNameRE = regexp.MustCompile(`^(.+)-[0-9a-f]{10}$`)
+
+// GitlinkChange is a change of the gitlink of a project in a superproject.
+type GitlinkChange struct {
+	Path string
+	// Old is the previous revision of the project, empty if the project is
+	// added.
+	Old string
+	// New is the new revision of the project, empty if the project is
+	// removed.
+	New string
+}
+
+func (c GitlinkChange) String() string {
+	switch {
+	case c.Old == "":
+		return fmt.Sprintf("Add %s at %s", c.Path, c.New)
+	case c.New == "":
+		return fmt.Sprintf("Remove %s", c.Path)
+	}
+	return fmt.Sprintf("Update %s from %s to %s", c.Path, c.Old, c.New)
+}
+
+// SyncSuperproject commits the revisions of projects into the superproject
+// at dir, which is created if it does not exist. The gitlinks of projects
+// whose revision changed are updated, the gitlinks of projects which are
+// gone are removed, and the .gitmodules file is regenerated. Nothing is
+// committed if nothing changed. Nested projects, and the project at the
+// root, are not submodules.
+func SyncSuperproject(jirix *jiri.X, dir string, projects Projects, message string) ([]GitlinkChange, error) {
+	if _, err := os.Stat(filepath.Join(dir, ".git")); os.IsNotExist(err) {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmtError(err)
+		}
+		if err := gitutil.New(jirix).Init(dir); err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, fmtError(err)
+	}
+	scm := gitutil.New(jirix, gitutil.RootDirOpt(dir))
+	gitlinks := make(map[string]string)
+	if _, err := scm.CurrentRevisionForRef("HEAD"); err == nil {
+		if gitlinks, err = scm.Submodules("HEAD"); err != nil {
+			return nil, err
+		}
+	}
+
+	projEntries, treeRoot, err := GenerateSubmoduleTree(jirix, projects)
+	if err != nil {
+		return nil, err
+	}
+	var changes []GitlinkChange
+	var gitmodules bytes.Buffer
+	seen := make(map[string]bool)
+	for _, p := range projEntries {
+		if _, ok := treeRoot.Dropped[p.Key()]; ok {
+			continue
+		}
+		p.Path = filepath.ToSlash(p.Path)
+		seen[p.Path] = true
+		fmt.Fprintf(&gitmodules, "[submodule \"%s\"]\n\tpath = %s\n\turl = %s\n", SubmoduleName(p), p.Path, p.Remote)
+		if old := gitlinks[p.Path]; old != p.Revision {
+			if err := scm.SetGitlink(p.Path, p.Revision); err != nil {
+				return nil, err
+			}
+			changes = append(changes, GitlinkChange{Path: p.Path, Old: old, New: p.Revision})
+		}
+	}
+	for path, old := range gitlinks {
+		if !seen[path] {
+			if err := scm.RemoveFromIndex(path); err != nil {
+				return nil, err
+			}
+			changes = append(changes, GitlinkChange{Path: path, Old: old})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+
+	// Superprojects without commits have no .gitmodules file.
+	committed, _ := scm.ShowBytes("HEAD", ".gitmodules")
+	if len(changes) == 0 && bytes.Equal(committed, gitmodules.Bytes()) {
+		return nil, nil
+	}
+	if err := safeWriteFile(jirix, filepath.Join(dir, ".gitmodules"), gitmodules.Bytes()); err != nil {
+		return nil, err
+	}
+	if err := scm.Add(".gitmodules"); err != nil {
+		return nil, err
+	}
+
+	if message == "" {
+		message = "Update submodules"
+	}
+	if len(changes) != 0 {
+		message += "\n"
+		for _, c := range changes {
+			message += "\n" + c.String()
+		}
+	}
+	if err := scm.CommitWithMessage(message); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// gitmodule is a submodule declared in a .gitmodules file.
+type gitmodule struct {
+	name   string
+	path   string
+	url    string
+	branch string
+}
+
+var gitmoduleSectionRE = regexp.MustCompile(`^\[submodule\s+"(.*)"\]$`)
+
+// parseGitmodules parses the content of a .gitmodules file.
+func parseGitmodules(data string) ([]gitmodule, error) {
+	var modules []gitmodule
+	// current is the index of the submodule of the current section, or -1.
+	current := -1
+	for i, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if line[0] == '[' {
+			m := gitmoduleSectionRE.FindStringSubmatch(line)
+			if m == nil {
+				// Other sections are ignored.
+				current = -1
+				continue
+			}
+			modules = append(modules, gitmodule{name: m[1]})
+			current = len(modules) - 1
+			continue
+		}
+		eq := strings.IndexByte(line, '=')
+		if eq < 0 {
+			return nil, fmt.Errorf("line %d: invalid line %q", i+1, line)
+		}
+		if current < 0 {
+			continue
+		}
+		value := strings.Trim(strings.TrimSpace(line[eq+1:]), `"`)
+		switch strings.ToLower(strings.TrimSpace(line[:eq])) {
+		case "path":
+			modules[current].path = value
+		case "url":
+			modules[current].url = value
+		case "branch":
+			modules[current].branch = value
+		}
+	}
+	return modules, nil
+}
+
+// ImportSuperproject returns a manifest with a project for each submodule
+// of the superproject at dir, at ref. The projects are pinned to the
+// revisions of the gitlinks. Relative submodule URLs are resolved against
+// the origin remote of the superproject.
+func ImportSuperproject(jirix *jiri.X, dir, ref string) (*Manifest, error) {
+	scm := gitutil.New(jirix, gitutil.RootDirOpt(dir))
+	gitlinks, err := scm.Submodules(ref)
+	if err != nil {
+		return nil, err
+	}
+	data, err := scm.ShowBytes(ref, ".gitmodules")
+	if err != nil {
+		return nil, fmt.Errorf("cannot read .gitmodules of %s at %s: %v", dir, ref, err)
+	}
+	modules, err := parseGitmodules(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid .gitmodules of %s at %s: %v", dir, ref, err)
+	}
+	m := &Manifest{}
+	for _, module := range modules {
+		if module.path == "" || module.url == "" {
+			return nil, fmt.Errorf("submodule %q of %s has no path or url", module.name, dir)
+		}
+		rev, ok := gitlinks[module.path]
+		if !ok {
+			jirix.Logger.Warningf("submodule %q of %s has no gitlink at %s, it is skipped\n\n", module.name, dir, ref)
+			continue
+		}
+		remote := module.url
+		if strings.HasPrefix(remote, "./") || strings.HasPrefix(remote, "../") {
+			base, err := scm.RemoteUrl("origin")
+			if err != nil {
+				return nil, fmt.Errorf("submodule %q of %s has a relative url, and the superproject has no origin: %v", module.name, dir, err)
+			}
+			remote = resolveSubmoduleURL(base, remote)
+		}
+		name := module.name
+		if match := submoduleNameRE.FindStringSubmatch(name); match != nil {
+			// Strip the suffix added by SubmoduleName.
+			name = match[1]
+		}
+		m.Projects = append(m.Projects, Project{
+			Name:         name,
+			Path:         module.path,
+			Remote:       remote,
+			RemoteBranch: module.branch,
+			Revision:     rev,
+		})
+	}
+	return m, nil
+}
+
+// resolveSubmoduleURL resolves the relative URL of a submodule against the
+// URL of its superproject.
+func resolveSubmoduleURL(base, rel string) string {
+	u, err := url.Parse(base)
+	if err != nil || u.Scheme == "" {
+		// A path on the filesystem.
+		return filepath.Join(base, rel)
+	}
+	u.Path = path.Join(u.Path, rel)
+	return u.String()
+}
@@ -0,0 +1,121 @@
+// Copyright 2026 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package project
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.fuchsia.dev/jiri"
+	"go.fuchsia.dev/jiri/cipd"
+)
+
+// targetPlatforms returns the platforms of jirix.TargetPlatforms, leaving
+// out the host platform.
+func targetPlatforms(jirix *jiri.X) ([]cipd.Platform, error) {
+	var plats []cipd.Platform
+	seen := make(map[string]bool)
+	for _, s := range strings.Split(jirix.TargetPlatforms, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" || seen[s] {
+			continue
+		}
+		seen[s] = true
+		plat, err := cipd.NewPlatform(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid target platform in jiri config: %v", err)
+		}
+		if plat == cipd.CipdPlatform {
+			continue
+		}
+		plats = append(plats, plat)
+	}
+	return plats, nil
+}
+
+// withTargetPlatforms returns pkgs along with a copy of each package for each
+// of the target platforms of the jiri root which the package is available
+// for. The name, path and url of a copy are expanded for its platform, so
+// backends fetch it like any other package. Packages whose name does not use
+// platform templates are only fetched for the host, and copies whose path
+// is used by another package already are left out.
+func withTargetPlatforms(jirix *jiri.X, pkgs Packages) (Packages, error) {
+	targets, err := targetPlatforms(jirix)
+	if err != nil || len(targets) == 0 {
+		return pkgs, err
+	}
+	result := make(Packages, len(pkgs))
+	paths := make(map[string]string)
+	keys := make(PackageKeys, 0, len(pkgs))
+	for k, pkg := range pkgs {
+		result[k] = pkg
+		keys = append(keys, k)
+		dir, err := pkg.installPath()
+		if err != nil {
+			return nil, err
+		}
+		paths[dir] = pkg.Name
+	}
+	sort.Sort(keys)
+	for _, k := range keys {
+		pkg := pkgs[k]
+		if !cipd.MustExpand(pkg.Name) {
+			continue
+		}
+		plats, err := pkg.GetPlatforms()
+		if err != nil {
+			return nil, err
+		}
+		available := make(map[cipd.Platform]bool)
+		for _, plat := range plats {
+			available[plat] = true
+		}
+		for _, plat := range targets {
+			if !available[plat] {
+				jirix.Logger.Debugf("package %q is not available for target platform %s", pkg.Name, plat)
+				continue
+			}
+			name, err := plat.Expander().Expand(pkg.Name)
+			if err == cipd.ErrSkipTemplate {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			dir, err := pkg.installPathFor(plat)
+			if err != nil {
+				return nil, err
+			}
+			if other, ok := paths[dir]; ok {
+				reason := fmt.Sprintf("its path %q is used by package %q", dir, other)
+				if other == pkg.Name {
+					reason = fmt.Sprintf("its path %q does not depend on the platform", dir)
+				}
+				jirix.Logger.Warningf("package %q is not fetched for target platform %s: %s. Use {{.OS}} and {{.Arch}} in its path.\n\n", pkg.Name, plat, reason)
+				continue
+			}
+			paths[dir] = name
+			target := pkg
+			target.Name = name
+			target.Path = dir
+			target.Platforms = ""
+			target.Flag = ""
+			if target.BackendName() == ArchivePackageBackend {
+				if target.URL, err = pkg.archiveURL(plat); err != nil {
+					return nil, err
+				}
+			}
+			target.Instances = nil
+			for _, ins := range pkg.Instances {
+				if ins.Name == name {
+					target.Instances = append(target.Instances, ins)
+				}
+			}
+			result[target.Key()] = target
+		}
+	}
+	return result, nil
+}
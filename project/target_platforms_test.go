@@ -0,0 +1,109 @@
+// Copyright 2026 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package project_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.fuchsia.dev/jiri/cipd"
+	"go.fuchsia.dev/jiri/jiritest"
+	"go.fuchsia.dev/jiri/project"
+)
+
+// TestTargetPlatforms tests fetching packages for the target platforms of the
+// jiri root in addition to the host platform.
+func TestTargetPlatforms(t *testing.T) {
+	fake, cleanup := jiritest.NewFakeJiriRoot(t)
+	defer cleanup()
+
+	host := cipd.CipdPlatform
+	target := cipd.Platform{OS: "linux", Arch: "arm64"}
+	if host == target {
+		target = cipd.Platform{OS: "mac", Arch: "arm64"}
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(tarGz(t, map[string]string{"bin/tool": r.URL.Path}))
+	}))
+	defer server.Close()
+
+	platforms := host.String() + "," + target.String()
+	tool := project.Package{
+		Name:      "tool/${platform}",
+		Version:   "1",
+		Path:      "prebuilt/tool/{{.OS}}-{{.Arch}}",
+		Backend:   project.ArchivePackageBackend,
+		URL:       server.URL + "/tool/${platform}.tar.gz",
+		Platforms: platforms,
+	}
+	// The path of fixed does not depend on the platform, so it can only be
+	// fetched for the host.
+	fixed := project.Package{
+		Name:      "fixed/${platform}",
+		Version:   "1",
+		Path:      "prebuilt/fixed",
+		Backend:   project.ArchivePackageBackend,
+		URL:       server.URL + "/fixed/${platform}.tar.gz",
+		Platforms: platforms,
+	}
+	// hostOnly is not available for the target platform.
+	hostOnly := project.Package{
+		Name:      "host-only/${platform}",
+		Version:   "1",
+		Path:      "prebuilt/host-only/{{.OS}}-{{.Arch}}",
+		Backend:   project.ArchivePackageBackend,
+		URL:       server.URL + "/host-only/${platform}.tar.gz",
+		Platforms: host.String(),
+	}
+	pkgs := project.Packages{tool.Key(): tool, fixed.Key(): fixed, hostOnly.Key(): hostOnly}
+
+	fake.X.TargetPlatforms = target.String()
+	if err := project.FetchPackages(fake.X, pkgs, project.DefaultPackageTimeout); err != nil {
+		t.Fatal(err)
+	}
+	checkTool := func(dir, want string) {
+		t.Helper()
+		content, err := ioutil.ReadFile(filepath.Join(fake.X.Root, dir, "bin", "tool"))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if string(content) != want {
+			t.Errorf("wrong content in %q, got %q, want %q", dir, content, want)
+		}
+	}
+	checkTool("prebuilt/tool/"+cipd.FuchsiaPlatform(host).String(), "/tool/"+host.String()+".tar.gz")
+	checkTool("prebuilt/tool/"+cipd.FuchsiaPlatform(target).String(), "/tool/"+target.String()+".tar.gz")
+	checkTool("prebuilt/fixed", "/fixed/"+host.String()+".tar.gz")
+	checkTool("prebuilt/host-only/"+cipd.FuchsiaPlatform(host).String(), "/host-only/"+host.String()+".tar.gz")
+	targetHostOnly := filepath.Join(fake.X.Root, "prebuilt", "host-only", cipd.FuchsiaPlatform(target).String())
+	if _, err := os.Stat(targetHostOnly); !os.IsNotExist(err) {
+		t.Errorf("package %q should not be fetched for %s", hostOnly.Name, target)
+	}
+
+	installed, err := project.ReadInstalledPackages(fake.X)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(installed) != 4 {
+		t.Fatalf("expected 4 installed packages, got %+v", installed)
+	}
+
+	// Packages of a target platform which is not used anymore are garbage
+	// collected.
+	fake.X.TargetPlatforms = ""
+	if err := project.InternalGCPackages(fake.X, pkgs, true); err != nil {
+		t.Fatal(err)
+	}
+	targetTool := filepath.Join(fake.X.Root, "prebuilt", "tool", cipd.FuchsiaPlatform(target).String())
+	if _, err := os.Stat(targetTool); !os.IsNotExist(err) {
+		t.Errorf("package %q for %s should have been removed", tool.Name, target)
+	}
+	checkTool("prebuilt/tool/"+cipd.FuchsiaPlatform(host).String(), "/tool/"+host.String()+".tar.gz")
+}
@@ -0,0 +1,112 @@
+// Copyright 2026 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package project
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"go.fuchsia.dev/jiri"
+)
+
+// WorkspaceBranch is a local branch that was started with "jiri start" in a
+// set of projects.
+type WorkspaceBranch struct {
+	Name string `json:"name"`
+	// Projects are the keys of the projects participating in the branch.
+	Projects []string `json:"projects"`
+}
+
+// WorkspaceBranches is the set of workspace branches of a jiri root. It is
+// stored in .jiri_root/workspace_branches.json.
+type WorkspaceBranches struct {
+	// Current is the name of the workspace branch that was last started or
+	// switched to.
+	Current  string            `json:"current,omitempty"`
+	Branches []WorkspaceBranch `json:"branches"`
+}
+
+func workspaceBranchesFile(jirix *jiri.X) string {
+	return filepath.Join(jirix.RootMetaDir(), jiri.WorkspaceBranchesJSON)
+}
+
+// ReadWorkspaceBranches reads the workspace branches of the jiri root. It
+// returns an empty set if no workspace branch was ever started.
+func ReadWorkspaceBranches(jirix *jiri.X) (*WorkspaceBranches, error) {
+	wb := &WorkspaceBranches{}
+	data, err := ioutil.ReadFile(workspaceBranchesFile(jirix))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return wb, nil
+		}
+		return nil, fmtError(err)
+	}
+	if err := json.Unmarshal(data, wb); err != nil {
+		return nil, fmt.Errorf("invalid workspace branches file %q: %v", workspaceBranchesFile(jirix), err)
+	}
+	return wb, nil
+}
+
+// Write writes the workspace branches to the jiri root.
+func (wb *WorkspaceBranches) Write(jirix *jiri.X) error {
+	sort.Slice(wb.Branches, func(i, j int) bool {
+		return wb.Branches[i].Name < wb.Branches[j].Name
+	})
+	data, err := json.MarshalIndent(wb, "", "    ")
+	if err != nil {
+		return err
+	}
+	return safeWriteFile(jirix, workspaceBranchesFile(jirix), data)
+}
+
+// Find returns the workspace branch with the given name, or nil if there is
+// no such branch.
+func (wb *WorkspaceBranches) Find(name string) *WorkspaceBranch {
+	for i := range wb.Branches {
+		if wb.Branches[i].Name == name {
+			return &wb.Branches[i]
+		}
+	}
+	return nil
+}
+
+// Remove removes the workspace branch with the given name.
+func (wb *WorkspaceBranches) Remove(name string) {
+	var branches []WorkspaceBranch
+	for _, b := range wb.Branches {
+		if b.Name != name {
+			branches = append(branches, b)
+		}
+	}
+	wb.Branches = branches
+	if wb.Current == name {
+		wb.Current = ""
+	}
+}
+
+// LocalProjects returns the local projects participating in the branch.
+// Keys of projects which no longer exist locally are returned separately.
+func (b *WorkspaceBranch) LocalProjects(localProjects Projects) (Projects, []string) {
+	projects := make(Projects)
+	var missing []string
+	for _, s := range b.Projects {
+		key, ok := ProjectKeyFromString(s)
+		if !ok {
+			missing = append(missing, s)
+			continue
+		}
+		p, ok := localProjects[key]
+		if !ok {
+			missing = append(missing, s)
+			continue
+		}
+		projects[key] = p
+	}
+	return projects, missing
+}
@@ -0,0 +1,208 @@
+// Copyright 2026 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package sshsig implements the signatures of "ssh-keygen -Y sign", as
+// described in
+// https://github.com/openssh/openssh-portable/blob/master/PROTOCOL.sshsig,
+// for ed25519 keys.
+package sshsig
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	magic         = "SSHSIG"
+	version       = 1
+	keyType       = "ssh-ed25519"
+	hashAlgorithm = "sha512"
+	armorBegin    = "-----BEGIN SSH SIGNATURE-----"
+	armorEnd      = "-----END SSH SIGNATURE-----"
+)
+
+// PublicKey is an ed25519 public key.
+type PublicKey ed25519.PublicKey
+
+// ParsePublicKey parses a public key in the authorized_keys format, like
+// "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAA... comment".
+func ParsePublicKey(line string) (PublicKey, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return nil, fmt.Errorf("invalid public key %q", line)
+	}
+	if fields[0] != keyType {
+		return nil, fmt.Errorf("unsupported public key type %q, only %s keys are supported", fields[0], keyType)
+	}
+	blob, err := base64.StdEncoding.DecodeString(fields[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key %q: %v", line, err)
+	}
+	return parsePublicKeyBlob(blob)
+}
+
+func parsePublicKeyBlob(blob []byte) (PublicKey, error) {
+	r := reader{blob}
+	typ := r.string()
+	key := r.string()
+	if r.err() != nil || string(typ) != keyType || len(key) != ed25519.PublicKeySize {
+		return nil, errors.New("invalid ed25519 public key")
+	}
+	return PublicKey(key), nil
+}
+
+func (k PublicKey) blob() []byte {
+	var b bytes.Buffer
+	writeString(&b, []byte(keyType))
+	writeString(&b, k)
+	return b.Bytes()
+}
+
+// String returns k in the authorized_keys format.
+func (k PublicKey) String() string {
+	return keyType + " " + base64.StdEncoding.EncodeToString(k.blob())
+}
+
+// Equal returns true if k and other are the same key.
+func (k PublicKey) Equal(other PublicKey) bool {
+	return bytes.Equal(k, other)
+}
+
+// signedData returns the data which is signed for message in namespace.
+func signedData(namespace string, message []byte) []byte {
+	h := sha512.Sum512(message)
+	var b bytes.Buffer
+	b.WriteString(magic)
+	writeString(&b, []byte(namespace))
+	writeString(&b, nil)
+	writeString(&b, []byte(hashAlgorithm))
+	writeString(&b, h[:])
+	return b.Bytes()
+}
+
+// Sign returns the armored signature of message in namespace with key.
+func Sign(key ed25519.PrivateKey, namespace string, message []byte) []byte {
+	var sig bytes.Buffer
+	writeString(&sig, []byte(keyType))
+	writeString(&sig, ed25519.Sign(key, signedData(namespace, message)))
+
+	var b bytes.Buffer
+	b.WriteString(magic)
+	binary.Write(&b, binary.BigEndian, uint32(version))
+	writeString(&b, PublicKey(key.Public().(ed25519.PublicKey)).blob())
+	writeString(&b, []byte(namespace))
+	writeString(&b, nil)
+	writeString(&b, []byte(hashAlgorithm))
+	writeString(&b, sig.Bytes())
+
+	encoded := base64.StdEncoding.EncodeToString(b.Bytes())
+	var out bytes.Buffer
+	out.WriteString(armorBegin + "\n")
+	for len(encoded) > 70 {
+		out.WriteString(encoded[:70] + "\n")
+		encoded = encoded[70:]
+	}
+	out.WriteString(encoded + "\n")
+	out.WriteString(armorEnd + "\n")
+	return out.Bytes()
+}
+
+// Verify checks that the armored signature is a signature of message in
+// namespace by one of the trusted keys, and returns that key.
+func Verify(signature []byte, namespace string, message []byte, trusted []PublicKey) (PublicKey, error) {
+	s := strings.TrimSpace(string(signature))
+	if !strings.HasPrefix(s, armorBegin) || !strings.HasSuffix(s, armorEnd) {
+		return nil, errors.New("invalid signature: missing armor")
+	}
+	s = strings.Join(strings.Fields(s[len(armorBegin):len(s)-len(armorEnd)]), "")
+	blob, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature: %v", err)
+	}
+	if !bytes.HasPrefix(blob, []byte(magic)) || len(blob) < len(magic)+4 {
+		return nil, errors.New("invalid signature: missing preamble")
+	}
+	if v := binary.BigEndian.Uint32(blob[len(magic):]); v != version {
+		return nil, fmt.Errorf("unsupported signature version %d", v)
+	}
+	r := reader{blob[len(magic)+4:]}
+	keyBlob := r.string()
+	ns := r.string()
+	r.string() // reserved
+	hash := r.string()
+	sigBlob := r.string()
+	if r.err() != nil {
+		return nil, r.err()
+	}
+	if string(ns) != namespace {
+		return nil, fmt.Errorf("signature is for namespace %q, not %q", ns, namespace)
+	}
+	if string(hash) != hashAlgorithm {
+		return nil, fmt.Errorf("unsupported signature hash algorithm %q", hash)
+	}
+	key, err := parsePublicKeyBlob(keyBlob)
+	if err != nil {
+		return nil, err
+	}
+	trustedKey := false
+	for _, k := range trusted {
+		if k.Equal(key) {
+			trustedKey = true
+			break
+		}
+	}
+	if !trustedKey {
+		return nil, fmt.Errorf("signature is made with untrusted key %s", key)
+	}
+	sr := reader{sigBlob}
+	typ := sr.string()
+	sig := sr.string()
+	if sr.err() != nil || string(typ) != keyType {
+		return nil, errors.New("invalid ed25519 signature")
+	}
+	if !ed25519.Verify(ed25519.PublicKey(key), signedData(namespace, message), sig) {
+		return nil, errors.New("signature does not match")
+	}
+	return key, nil
+}
+
+func writeString(b *bytes.Buffer, s []byte) {
+	binary.Write(b, binary.BigEndian, uint32(len(s)))
+	b.Write(s)
+}
+
+// reader reads the strings of the ssh wire format. Errors are sticky.
+type reader struct {
+	b []byte
+}
+
+var errShort = errors.New("invalid signature: truncated data")
+
+func (r *reader) string() []byte {
+	if r.b == nil || len(r.b) < 4 {
+		r.b = nil
+		return nil
+	}
+	n := binary.BigEndian.Uint32(r.b)
+	if uint32(len(r.b)-4) < n {
+		r.b = nil
+		return nil
+	}
+	s := r.b[4 : 4+n]
+	r.b = r.b[4+n:]
+	return s
+}
+
+func (r *reader) err() error {
+	if r.b == nil {
+		return errShort
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+// Copyright 2026 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sshsig
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"testing"
+)
+
+func TestSignVerify(t *testing.T) {
+	key := ed25519.NewKeyFromSeed(bytes.Repeat([]byte{1}, ed25519.SeedSize))
+	other := ed25519.NewKeyFromSeed(bytes.Repeat([]byte{2}, ed25519.SeedSize))
+	pub, err := ParsePublicKey(PublicKey(key.Public().(ed25519.PublicKey)).String() + " user@host")
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPub := PublicKey(other.Public().(ed25519.PublicKey))
+
+	message := []byte("[]\n")
+	sig := Sign(key, "jiri-lockfile", message)
+	if got, err := Verify(sig, "jiri-lockfile", message, []PublicKey{otherPub, pub}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	} else if !got.Equal(pub) {
+		t.Errorf("got key %s, want %s", got, pub)
+	}
+	if _, err := Verify(sig, "jiri-lockfile", []byte("[{}]\n"), []PublicKey{pub}); err == nil {
+		t.Errorf("expected an error for a modified message")
+	}
+	if _, err := Verify(sig, "file", message, []PublicKey{pub}); err == nil {
+		t.Errorf("expected an error for another namespace")
+	}
+	if _, err := Verify(sig, "jiri-lockfile", message, []PublicKey{otherPub}); err == nil {
+		t.Errorf("expected an error for an untrusted key")
+	}
+	if _, err := Verify(sig[:len(sig)/2], "jiri-lockfile", message, []PublicKey{pub}); err == nil {
+		t.Errorf("expected an error for a truncated signature")
+	}
+}
+
+// TestVerifySSHKeygen tests verifying a signature made with
+// "ssh-keygen -Y sign -n jiri-lockfile".
+func TestVerifySSHKeygen(t *testing.T) {
+	pub, err := ParsePublicKey("ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIAyk+HmZtAaMDj002cLAF9ErSa9n94DPo9YEHu42AZtj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := []byte(`-----BEGIN SSH SIGNATURE-----
+U1NIU0lHAAAAAQAAADMAAAALc3NoLWVkMjU1MTkAAAAgDKT4eZm0BowOPTTZwsAX0StJr2
+f3gM+j1gQe7jYBm2MAAAANamlyaS1sb2NrZmlsZQAAAAAAAAAGc2hhNTEyAAAAUwAAAAtz
+c2gtZWQyNTUxOQAAAECuhcZvGUM/53+38wWPto1gzZsA3FHyN/Y0oGhN1Dm26PsoHUlb9V
+D/rKtPvNizMa+axr/VLSiTgCk+M5PjcVsI
+-----END SSH SIGNATURE-----
+`)
+	if _, err := Verify(sig, "jiri-lockfile", []byte("hello\n"), []PublicKey{pub}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestParsePublicKey(t *testing.T) {
+	for _, line := range []string{
+		"",
+		"ssh-ed25519",
+		"ssh-rsa AAAAB3NzaC1yc2EAAAADAQABAAABAQ",
+		"ssh-ed25519 not-base64",
+		"ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIAyk",
+	} {
+		if _, err := ParsePublicKey(line); err == nil {
+			t.Errorf("%q: expected an error", line)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright 2026 The Fuchsia Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jiri
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"go.fuchsia.dev/jiri/envvar"
+)
+
+// RunTokenCommand runs the token command of the jiri config for host, which
+// is passed as its last argument, and returns the first line it prints. The
+// command is split into arguments like by a shell, so arguments can be quoted.
+func (x *X) RunTokenCommand(host string) (string, error) {
+	args, err := splitCommand(x.TokenCommand)
+	if err != nil {
+		return "", fmt.Errorf("invalid token command %q: %v", x.TokenCommand, err)
+	}
+	if len(args) == 0 {
+		return "", errors.New("no token command is configured, use \"jiri init -token-command\" to set one")
+	}
+	args = append(args, host)
+	var stdout, stderr bytes.Buffer
+	command := exec.Command(args[0], args[1:]...)
+	command.Env = envvar.MapToSlice(x.Env())
+	command.Stdout = &stdout
+	command.Stderr = &stderr
+	x.Logger.Debugf("running token command %q", args)
+	if err := command.Run(); err != nil {
+		return "", fmt.Errorf("%q failed: %v, stderr: %s", x.TokenCommand, err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(strings.SplitN(stdout.String(), "\n", 2)[0]), nil
+}
+
+// splitCommand splits the command s into arguments separated by spaces.
+// Like in a shell, arguments can be quoted with single or double quotes, and
+// backslashes escape the next character outside of single quotes.
+func splitCommand(s string) ([]string, error) {
+	var args []string
+	var arg strings.Builder
+	inArg := false
+	var quote rune
+	escaped := false
+	for _, r := range s {
+		switch {
+		case escaped:
+			arg.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped, inArg = true, true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				arg.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inArg = r, true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(r)
+			inArg = true
+		}
+	}
+	if escaped {
+		return nil, errors.New("trailing backslash")
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
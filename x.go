@@ -27,15 +27,19 @@ import (
 )
 
 const (
-	AttrsJSON          = "attributes.json"
-	RootMetaDir        = ".jiri_root"
-	ProjectMetaDir     = ".git/jiri"
-	OldProjectMetaDir  = ".jiri"
-	ConfigFile         = "config"
-	DefaultCacheSubdir = "cache"
-	ProjectMetaFile    = "metadata.v2"
-	ProjectConfigFile  = "config"
-	JiriManifestFile   = ".jiri_manifest"
+	AttrsJSON             = "attributes.json"
+	WorkspaceBranchesJSON = "workspace_branches.json"
+	RebaseStateJSON       = "rebase_state.json"
+	ArchivePackagesJSON   = "archive_packages.json"
+	InstalledPackagesJSON = "installed_packages.json"
+	RootMetaDir           = ".jiri_root"
+	ProjectMetaDir        = ".git/jiri"
+	OldProjectMetaDir     = ".jiri"
+	ConfigFile            = "config"
+	DefaultCacheSubdir    = "cache"
+	ProjectMetaFile       = "metadata.v2"
+	ProjectConfigFile     = "config"
+	JiriManifestFile      = ".jiri_manifest"
 
 	// PreservePathEnv is the name of the environment variable that, when set to a
 	// non-empty value, causes jiri tools to use the existing PATH variable,
@@ -48,13 +52,17 @@ type Config struct {
 	CachePath         string   `xml:"cache>path,omitempty"`
 	CipdParanoidMode  string   `xml:"cipd_paranoid_mode,omitempty"`
 	CipdMaxThreads    int      `xml:"cipd_max_threads,omitempty"`
+	CipdNative        bool     `xml:"cipd_native,omitempty"`
 	Shared            bool     `xml:"cache>shared,omitempty"`
+	SharedPackages    bool     `xml:"cache>sharedPackages,omitempty"`
 	RewriteSsoToHttps bool     `xml:"rewriteSsoToHttps,omitempty"`
 	SsoCookiePath     string   `xml:"SsoCookiePath,omitempty"`
+	TokenCommand      string   `xml:"credentials>tokenCommand,omitempty"`
 	LockfileEnabled   string   `xml:"lockfile>enabled,omitempty"`
 	LockfileName      string   `xml:"lockfile>name,omitempty"`
 	PrebuiltJSON      string   `xml:"prebuilt>JSON,omitempty"`
 	FetchingAttrs     string   `xml:"fetchingAttrs,omitempty"`
+	TargetPlatforms   string   `xml:"targetPlatforms,omitempty"`
 	AnalyticsOptIn    string   `xml:"analytics>optin,omitempty"`
 	AnalyticsUserId   string   `xml:"analytics>userId,omitempty"`
 	Partial           bool     `xml:"partial,omitempty"`
@@ -64,6 +72,10 @@ type Config struct {
 	AnalyticsVersion string `xml:"analytics>version,omitempty"`
 	KeepGitHooks     bool   `xml:"keepGitHooks,omitempty"`
 
+	// TrustedLockfileKeys are the public keys, in the authorized_keys
+	// format, which lockfiles must be signed with.
+	TrustedLockfileKeys []string `xml:"lockfile>trustedKey,omitempty"`
+
 	XMLName struct{} `xml:"config"`
 }
 
@@ -107,7 +119,9 @@ type X struct {
 	Cache               string
 	CipdParanoidMode    bool
 	CipdMaxThreads      int
+	CipdNative          bool
 	Shared              bool
+	SharedPackages      bool
 	Jobs                uint
 	KeepGitHooks        bool
 	RewriteSsoToHttps   bool
@@ -115,14 +129,17 @@ type X struct {
 	LockfileName        string
 	OffloadPackfiles    bool
 	SsoCookiePath       string
+	TokenCommand        string
 	Partial             bool
 	PartialSkip         []string
 	PrebuiltJSON        string
 	FetchingAttrs       string
+	TargetPlatforms     string
 	UsingSnapshot       bool
 	UsingImportOverride bool
 	OverrideOptional    bool
 	IgnoreLockConflicts bool
+	RebaseInteractive   bool
 	Color               color.Color
 	Logger              *log.Logger
 	failures            uint32
@@ -130,6 +147,13 @@ type X struct {
 	cleanupFuncs        []func()
 	AnalyticsSession    *analytics_util.AnalyticsSession
 	OverrideWarned      bool
+
+	// TrustedLockfileKeys are the public keys which lockfiles must be
+	// signed with. Signatures are not checked when it is empty.
+	TrustedLockfileKeys []string
+	// IgnoreLockSignatures disables checking the signatures of lockfiles,
+	// which are regenerated by "jiri resolve".
+	IgnoreLockSignatures bool
 }
 
 func (jirix *X) IncrementFailures() {
@@ -272,6 +296,7 @@ func NewX(env *cmdline.Env) (*X, error) {
 		x.KeepGitHooks = x.config.KeepGitHooks
 		x.RewriteSsoToHttps = x.config.RewriteSsoToHttps
 		x.SsoCookiePath = x.config.SsoCookiePath
+		x.TokenCommand = x.config.TokenCommand
 		if x.config.LockfileEnabled == "" {
 			x.LockfileEnabled = true
 		} else {
@@ -291,9 +316,13 @@ func NewX(env *cmdline.Env) (*X, error) {
 			}
 		}
 		x.CipdMaxThreads = x.config.CipdMaxThreads
+		x.CipdNative = x.config.CipdNative
+		x.SharedPackages = x.config.SharedPackages
 		x.LockfileName = x.config.LockfileName
+		x.TrustedLockfileKeys = x.config.TrustedLockfileKeys
 		x.PrebuiltJSON = x.config.PrebuiltJSON
 		x.FetchingAttrs = x.config.FetchingAttrs
+		x.TargetPlatforms = x.config.TargetPlatforms
 		if x.LockfileName == "" {
 			x.LockfileName = "jiri.lock"
 		}
@@ -8,6 +8,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 )
 
@@ -46,3 +47,31 @@ func TestFindRootEnvSymlink(t *testing.T) {
 		t.Fatalf("unexpected output: got %v, want %v", got, want)
 	}
 }
+
+func TestSplitCommand(t *testing.T) {
+	tests := []struct {
+		command string
+		want    []string
+	}{
+		{"", nil},
+		{"  token-tool  print ", []string{"token-tool", "print"}},
+		{`tool -scope 'a b' "c d"`, []string{"tool", "-scope", "a b", "c d"}},
+		{`tool a\ b 'c\d' "e\"f"`, []string{"tool", "a b", `c\d`, `e"f`}},
+		{`tool '' x""y`, []string{"tool", "", "xy"}},
+	}
+	for _, test := range tests {
+		got, err := splitCommand(test.command)
+		if err != nil {
+			t.Errorf("splitCommand(%q) failed: %v", test.command, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("splitCommand(%q): got %q, want %q", test.command, got, test.want)
+		}
+	}
+	for _, command := range []string{`tool 'a`, `tool "a`, `tool a\`} {
+		if _, err := splitCommand(command); err == nil {
+			t.Errorf("splitCommand(%q) did not fail", command)
+		}
+	}
+}